@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/observatorium/up/pkg/options"
+)
+
+// authenticate requires requests to the handler to set the bearer token or the basic auth credentials of the
+// internal server, if configured.
+func authenticate(s options.InternalServer, h http.Handler) http.Handler {
+	if s.Token == nil && s.BasicAuthUsername == "" {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Token != nil {
+			token, err := s.Token.Get()
+			if err != nil {
+				http.Error(w, "retrieving token failed", http.StatusInternalServerError)
+				return
+			}
+
+			if !equal(r.Header.Get("Authorization"), "Bearer "+token) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+				return
+			}
+		}
+
+		if s.BasicAuthUsername != "" {
+			username, password, ok := r.BasicAuth()
+			if !ok || !equal(username, s.BasicAuthUsername) || !equal(password, s.BasicAuthPassword) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="up"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+				return
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// equal compares credentials in constant time.
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/options"
+)
+
+func TestAuthenticate(t *testing.T) {
+	for i, tc := range []struct {
+		internal options.InternalServer
+		path     string
+		setAuth  func(r *http.Request)
+		expected int
+	}{
+		{path: "/metrics", expected: http.StatusOK},
+		{internal: options.InternalServer{Token: auth.NewStaticToken("secret")}, path: "/metrics", expected: http.StatusUnauthorized},
+		{
+			internal: options.InternalServer{Token: auth.NewStaticToken("secret")},
+			path:     "/metrics",
+			setAuth:  func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") },
+			expected: http.StatusUnauthorized,
+		},
+		{
+			internal: options.InternalServer{Token: auth.NewStaticToken("secret")},
+			path:     "/metrics",
+			setAuth:  func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") },
+			expected: http.StatusOK,
+		},
+		{
+			internal: options.InternalServer{Token: auth.NewStaticToken("secret")},
+			path:     "/metrics",
+			setAuth:  func(r *http.Request) { r.SetBasicAuth("up", "secret") },
+			expected: http.StatusUnauthorized,
+		},
+		{
+			internal: options.InternalServer{Token: auth.NewFileToken(filepath.Join(t.TempDir(), "missing"))},
+			path:     "/metrics",
+			expected: http.StatusInternalServerError,
+		},
+		{
+			internal: options.InternalServer{BasicAuthUsername: "up", BasicAuthPassword: "secret"},
+			path:     "/-/status",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			internal: options.InternalServer{BasicAuthUsername: "up", BasicAuthPassword: "secret"},
+			path:     "/-/status",
+			setAuth:  func(r *http.Request) { r.SetBasicAuth("up", "wrong") },
+			expected: http.StatusUnauthorized,
+		},
+		{
+			internal: options.InternalServer{BasicAuthUsername: "up", BasicAuthPassword: "secret"},
+			path:     "/-/status",
+			setAuth:  func(r *http.Request) { r.SetBasicAuth("other", "secret") },
+			expected: http.StatusUnauthorized,
+		},
+		{
+			internal: options.InternalServer{BasicAuthUsername: "up", BasicAuthPassword: "secret"},
+			path:     "/-/status",
+			setAuth:  func(r *http.Request) { r.SetBasicAuth("up", "secret") },
+			expected: http.StatusOK,
+		},
+		{internal: options.InternalServer{Token: auth.NewStaticToken("secret")}, path: "/-/healthy", expected: http.StatusOK},
+		{internal: options.InternalServer{Token: auth.NewStaticToken("secret")}, path: "/-/ready", expected: http.StatusOK},
+		{
+			internal: options.InternalServer{BasicAuthUsername: "up", BasicAuthPassword: "secret"},
+			path:     "/-/healthy",
+			expected: http.StatusOK,
+		},
+		{
+			internal: options.InternalServer{BasicAuthUsername: "up", BasicAuthPassword: "secret"},
+			path:     "/-/ready",
+			expected: http.StatusOK,
+		},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			opts := options.Options{Internal: tc.internal, Token: auth.NewNoOpTokenProvider()}
+			reg := prometheus.NewRegistry()
+			router := newRouter(opts, newLiveConfig(opts), newReadiness(opts), newStatuses(nil), reg, reg, nil)
+
+			srv := httptest.NewServer(router)
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+tc.path, nil)
+			testutil.Ok(t, err)
+
+			if tc.setAuth != nil {
+				tc.setAuth(req)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			testutil.Ok(t, err)
+			defer res.Body.Close()
+
+			testutil.Equals(t, tc.expected, res.StatusCode)
+
+			if res.StatusCode == http.StatusUnauthorized {
+				testutil.Assert(t, res.Header.Get("WWW-Authenticate") != "", "expected a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestParseInternalServer(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		f := filepath.Join(dir, name)
+		testutil.Ok(t, os.WriteFile(f, []byte(content), 0o600))
+
+		return f
+	}
+
+	tokenFile := write("token", "secret\n")
+	basicAuthFile := write("basic-auth", "up:secret\n")
+
+	for i, tc := range []struct {
+		tokenFile     string
+		basicAuthFile string
+		token         string
+		username      string
+		password      string
+		ok            bool
+	}{
+		{ok: true},
+		{tokenFile: tokenFile, token: "secret", ok: true},
+		{basicAuthFile: basicAuthFile, username: "up", password: "secret", ok: true},
+		{tokenFile: tokenFile, basicAuthFile: basicAuthFile},
+		{tokenFile: filepath.Join(dir, "missing")},
+		{basicAuthFile: filepath.Join(dir, "missing")},
+		{basicAuthFile: write("no-password", "up")},
+		{basicAuthFile: write("empty-password", "up:")},
+		{basicAuthFile: write("empty-username", ":secret")},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			opts := options.Options{}
+
+			err := parseInternalServer(&opts, tc.tokenFile, tc.basicAuthFile)
+			if !tc.ok {
+				testutil.NotOk(t, err)
+				return
+			}
+
+			testutil.Ok(t, err)
+
+			if tc.token != "" {
+				testutil.Assert(t, opts.Internal.Token != nil, "expected a token")
+
+				token, err := opts.Internal.Token.Get()
+				testutil.Ok(t, err)
+				testutil.Equals(t, tc.token, token)
+			} else {
+				testutil.Equals(t, nil, opts.Internal.Token)
+			}
+
+			testutil.Equals(t, tc.username, opts.Internal.BasicAuthUsername)
+			testutil.Equals(t, tc.password, opts.Internal.BasicAuthPassword)
+		})
+	}
+}
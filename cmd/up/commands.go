@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/observatorium/up/pkg/api"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/observatorium/up/pkg/up"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The subcommands of up. Without a subcommand, up runs as with 'run'.
+const (
+	// runCommand writes and reads back periodically, as well as executing the queries.
+	runCommand = "run"
+	// validateCommand validates the configuration without making requests, as with --dry-run.
+	validateCommand = "validate"
+	// queryCommand executes a single query of the queries file once and prints its result, bounded by the
+	// --request-timeout only.
+	queryCommand = "query"
+	// writeCommand only writes, periodically or once with --once.
+	writeCommand = "write"
+)
+
+// subcommand splits the subcommand off the command line arguments.
+func subcommand(args []string) (string, []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case runCommand, validateCommand, queryCommand, writeCommand:
+			return args[0], args[1:]
+		}
+	}
+
+	return runCommand, args
+}
+
+// queryOnce executes the query of --name once and prints the data of its response to w.
+func queryOnce(l log.Logger, opts options.Options, w io.Writer) error {
+	if opts.QueryName == "" {
+		return errors.New("--name must be set")
+	}
+
+	if opts.ReadEndpoint == nil {
+		return errors.New("--endpoint-read must be set")
+	}
+
+	var q options.Query
+
+	for _, c := range opts.Queries {
+		if c.GetName() == opts.QueryName {
+			q = c
+			break
+		}
+	}
+
+	if q == nil {
+		return errors.Errorf("query %q is not defined in --queries-file", opts.QueryName)
+	}
+
+	// Heavy queries take longer than the period of the writer, so only the --request-timeout bounds the query, if set.
+	ctx, stats := api.WithStats(context.Background())
+	t := time.Now()
+	httpCode, warn, err := up.Query(ctx, l, q, opts.Endpoints.Read.Apply(opts))
+	err = q.GetCommon().CheckStatus(httpCode, err)
+
+	if err == nil && q.GetCommon().FailsOnWarnings(opts.FailOnWarnings) {
+		err = api.WarningsError(warn)
+	}
+
+	level.Info(l).Log(
+		"msg", "executed query",
+		"type", q.GetType(),
+		"name", q.GetName(),
+		"http_code", httpCode,
+		"duration", time.Since(t).Seconds(),
+		"warnings", fmt.Sprintf("%#+v", warn),
+	)
+
+	// The data is printed even if assertions failed, as it tells why.
+	if len(stats.Data) > 0 {
+		if _, wErr := fmt.Fprintln(w, string(stats.Data)); wErr != nil {
+			return errors.Wrap(wErr, "printing result")
+		}
+	}
+
+	return err
+}
+
+// writeOnce writes once, for every tenant of the --tenants-file if given.
+func writeOnce(l log.Logger, opts options.Options) error {
+	if opts.WriteEndpoint == nil {
+		return errors.New("--endpoint-write must be set")
+	}
+
+	ls, err := up.NewLogsState(opts)
+	if err != nil {
+		return errors.Wrap(err, "parsing logs template")
+	}
+
+	// The metrics are not served, but writes record some of them.
+	m := instr.RegisterMetrics(prometheus.NewRegistry(), opts.Buckets)
+	cfg := newLiveConfig(opts)
+
+	write := opts.Endpoints.Write.Apply(opts)
+
+	targets := []options.Options{write}
+	if len(opts.Tenants) > 0 {
+		targets = targets[:0]
+		for _, t := range opts.Tenants {
+			targets = append(targets, t.Apply(write))
+		}
+	}
+
+	for _, o := range targets {
+		ctx, cancel := context.WithTimeout(context.Background(), o.Period)
+		httpCode, err := up.Write(ctx, l, m, o, cfg, ls)
+
+		cancel()
+
+		if err != nil && len(opts.Tenants) > 0 {
+			return errors.Wrapf(err, "tenant %q", o.Tenant)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		level.Info(l).Log("msg", "wrote", "tenant", o.Tenant, "http_code", httpCode)
+	}
+
+	return nil
+}
+
+// writeOnly drops the options of reading, so the write command runs the writer only.
+func writeOnly(opts options.Options) options.Options {
+	opts.ReadEndpoint = nil
+	opts.StoreEndpoint = ""
+	opts.Queries = nil
+	opts.QueriesFile = ""
+	opts.Scenarios = nil
+
+	return opts
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// envRegexp matches ${VAR} references, including the ones escaped by a leading $.
+var envRegexp = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} in the content of a file with the value of the environment variable VAR.
+// $${VAR} escapes the reference, leaving ${VAR} in the content. Any other $, e.g. ending a regular expression,
+// is left as is. Unset variables are an error, rather than expanding to an empty string.
+func expandEnv(b []byte) ([]byte, error) {
+	missing := map[string]struct{}{}
+
+	res := envRegexp.ReplaceAllFunc(b, func(ref []byte) []byte {
+		if bytes.HasPrefix(ref, []byte("$$")) {
+			return ref[1:]
+		}
+
+		name := string(envRegexp.FindSubmatch(ref)[1])
+
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing[name] = struct{}{}
+			return ref
+		}
+
+		return []byte(v)
+	})
+
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		return nil, errors.Errorf("environment variables %s are not set", strings.Join(names, ", "))
+	}
+
+	return res, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("UP_TEST_TENANT", "tenant-a")
+	t.Setenv("UP_TEST_EMPTY", "")
+
+	for i, tc := range []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{input: "tenant: up", expected: "tenant: up"},
+		{input: "tenant: ${UP_TEST_TENANT}", expected: "tenant: tenant-a"},
+		{input: "${UP_TEST_TENANT}/${UP_TEST_TENANT}", expected: "tenant-a/tenant-a"},
+		{input: "tenant: '${UP_TEST_EMPTY}'", expected: "tenant: ''"},
+		{input: "tenant: $${UP_TEST_TENANT}", expected: "tenant: ${UP_TEST_TENANT}"},
+		{input: "tenant: $${UP_TEST_UNSET}", expected: "tenant: ${UP_TEST_UNSET}"},
+		{input: `query: up{job=~"api$"}`, expected: `query: up{job=~"api$"}`},
+		{input: "query: $", expected: "query: $"},
+		{input: "tenant: $UP_TEST_TENANT", expected: "tenant: $UP_TEST_TENANT"},
+		{input: "tenant: ${1INVALID}", expected: "tenant: ${1INVALID}"},
+		{input: "tenant: ${UP_TEST_UNSET}", err: "environment variables UP_TEST_UNSET are not set"},
+		{
+			input: "${UP_TEST_UNSET_B} ${UP_TEST_TENANT} ${UP_TEST_UNSET_A} ${UP_TEST_UNSET_B}",
+			err:   "environment variables UP_TEST_UNSET_A, UP_TEST_UNSET_B are not set",
+		},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			res, err := expandEnv([]byte(tc.input))
+			if tc.err != "" {
+				testutil.NotOk(t, err)
+				testutil.Equals(t, tc.err, err.Error())
+
+				return
+			}
+
+			testutil.Ok(t, err)
+			testutil.Equals(t, tc.expected, string(res))
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/options"
+)
+
+// readiness tracks whether up is ready: the configuration was parsed before the internal server starts, so it is
+// ready once its tokens can be retrieved and its writer and reader succeeded at least once.
+type readiness struct {
+	tokens []auth.TokenProvider
+
+	mtx        sync.Mutex
+	needsWrite bool
+	needsRead  bool
+}
+
+func newReadiness(opts options.Options) *readiness {
+	r := &readiness{
+		tokens:     []auth.TokenProvider{opts.Token},
+		needsWrite: opts.WriteEndpoint != nil,
+		// As in addReaderRunGroup, the reader only runs with data to read back.
+		needsRead: opts.ReadEndpoint != nil && (opts.WriteEndpoint != nil || opts.ReadQuery != ""),
+	}
+
+	for _, t := range opts.Tenants {
+		r.tokens = append(r.tokens, t.Apply(opts).Token)
+	}
+
+	for _, e := range []*options.EndpointSpec{opts.Endpoints.Write, opts.Endpoints.Read, opts.Endpoints.Store} {
+		if e != nil {
+			r.tokens = append(r.tokens, e.Apply(opts).Token)
+		}
+	}
+
+	return r
+}
+
+// wrote records a successful write.
+func (r *readiness) wrote() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.needsWrite = false
+}
+
+// read records a successful read.
+func (r *readiness) read() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.needsRead = false
+}
+
+func (r *readiness) ready() error {
+	for _, t := range r.tokens {
+		if t == nil {
+			continue
+		}
+
+		if _, err := t.Get(); err != nil {
+			return fmt.Errorf("retrieving token: %w", err)
+		}
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.needsWrite {
+		return fmt.Errorf("no successful write yet")
+	}
+
+	if r.needsRead {
+		return fmt.Errorf("no successful read yet")
+	}
+
+	return nil
+}
+
+// healthyHandler serves the liveness of up, which is healthy as long as it serves requests.
+func healthyHandler(w http.ResponseWriter, _ *http.Request) {
+	fmt.Fprintln(w, "up is healthy.")
+}
+
+// readyHandler serves the readiness of up, failing with the reason it is not ready yet.
+func readyHandler(r *readiness) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if err := r.ready(); err != nil {
+			http.Error(w, fmt.Sprintf("up is not ready: %s", err), http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprintln(w, "up is ready.")
+	}
+}
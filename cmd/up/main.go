@@ -2,42 +2,51 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	stdlog "log"
+	"net"
 	"net/http"
 	"net/http/pprof"
 	"net/url"
 	"os"
 	"os/signal"
+	"runtime"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/observatorium/up/pkg/alertmanager"
+	"github.com/observatorium/up/pkg/api"
 	"github.com/observatorium/up/pkg/auth"
 	"github.com/observatorium/up/pkg/instr"
 	"github.com/observatorium/up/pkg/logs"
 	"github.com/observatorium/up/pkg/metrics"
 	"github.com/observatorium/up/pkg/options"
+	"github.com/observatorium/up/pkg/report"
+	"github.com/observatorium/up/pkg/store"
+	"github.com/observatorium/up/pkg/tracing"
+	"github.com/observatorium/up/pkg/transport"
+	"github.com/observatorium/up/pkg/up"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/oklog/run"
 	"github.com/pkg/errors"
-	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/prometheus/prometheus/promql/parser"
+	"golang.org/x/net/http/httpguts"
 	"gopkg.in/yaml.v2"
 )
 
 const (
-	numOfEndpoints        = 2
 	timeoutBetweenQueries = 100 * time.Millisecond
 
 	labelSuccess = "success"
@@ -50,18 +59,38 @@ type CallsFile struct {
 	Queries []options.QuerySpec  `yaml:"queries"`
 	Labels  []options.LabelSpec  `yaml:"labels"`
 	Series  []options.SeriesSpec `yaml:"series"`
+	Absent  []options.AbsentSpec `yaml:"absent"`
+	LogQL   []options.LogQLSpec  `yaml:"logql"`
+	// LogsLabels and LogsSeries are the equivalents of Labels and Series for logs.
+	LogsLabels     []options.LogsLabelSpec          `yaml:"logs_labels"`
+	LogsSeries     []options.LogsSeriesSpec         `yaml:"logs_series"`
+	LogsIndexStats []options.LogsIndexStatsSpec     `yaml:"logs_index_stats"`
+	LogsVolume     []options.LogsVolumeSpec         `yaml:"logs_volume"`
+	LogsFields     []options.LogsDetectedFieldsSpec `yaml:"logs_detected_fields"`
+	LogsPatterns   []options.LogsPatternsSpec       `yaml:"logs_patterns"`
+	Scenarios      []options.ScenarioSpec           `yaml:"scenarios"`
+	Targets        []options.TargetsSpec            `yaml:"targets"`
+	Rules          []options.RulesSpec              `yaml:"rules"`
+	Alerts         []options.AlertsSpec             `yaml:"alerts"`
+	Diffs          []options.DiffSpec               `yaml:"diffs"`
 }
 
 type logsFile struct {
 	Spec options.LogsSpec `yaml:"spec"`
 }
 
+type tenantsFile struct {
+	Tenants []options.TenantSpec `yaml:"tenants"`
+}
+
 func main() { //nolint:golint,funlen
 	l := log.WithPrefix(log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr)), "name", "up")
 	l = log.WithPrefix(l, "ts", log.DefaultTimestampUTC)
 	l = log.WithPrefix(l, "caller", log.DefaultCaller)
 
-	opts, err := parseFlags(l)
+	cmd, args := subcommand(os.Args[1:])
+
+	opts, err := parseFlags(l, cmd, args)
 	if err != nil {
 		level.Error(l).Log("msg", "could not parse command line flags", "err", err)
 		os.Exit(1)
@@ -70,16 +99,84 @@ func main() { //nolint:golint,funlen
 	l = level.NewFilter(l, opts.LogLevel)
 	l = log.WithPrefix(l, "caller", log.DefaultCaller)
 
+	var tracer *tracing.Tracer
+	if opts.TracingEndpoint != nil {
+		tracer = tracing.NewTracer(l, opts.TracingEndpoint, opts.TracingSamplingRatio)
+		tracing.SetTracer(tracer)
+	}
+
+	if opts.Profiling.Enabled {
+		runtime.SetMutexProfileFraction(opts.Profiling.MutexFraction)
+		runtime.SetBlockProfileRate(opts.Profiling.BlockRate)
+	}
+
+	if opts.TLS.InsecureSkipVerify {
+		level.Warn(l).Log("msg", "the certificates of the servers are not verified, --tls-insecure-skip-verify is unsafe")
+	}
+
+	if err := up.ConfigureTransport(l, opts); err != nil {
+		level.Error(l).Log("msg", "failed to configure the transports", "err", err)
+		os.Exit(1)
+	}
+
+	switch cmd {
+	case queryCommand:
+		err := queryOnce(l, opts, os.Stdout)
+		tracer.Shutdown()
+
+		if err != nil {
+			level.Error(l).Log("msg", "query failed", "name", opts.QueryName, "err", err)
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	case writeCommand:
+		if opts.WriteOnce {
+			err := writeOnce(l, opts)
+			tracer.Shutdown()
+
+			if err != nil {
+				level.Error(l).Log("msg", "write failed", "err", err)
+				os.Exit(1)
+			}
+
+			level.Info(l).Log("msg", "write succeeded")
+			os.Exit(0)
+		}
+
+		opts = writeOnly(opts)
+	case validateCommand:
+		opts.DryRun = true
+	}
+
+	if opts.DryRun {
+		if err := dryRun(l, opts); err != nil {
+			level.Error(l).Log("msg", "configuration is invalid", "err", err)
+			os.Exit(1)
+		}
+
+		level.Info(l).Log("msg", "configuration is valid", "queries", len(opts.Queries), "scenarios", len(opts.Scenarios))
+		os.Exit(0)
+	}
+
 	reg := prometheus.NewRegistry()
-	reg.MustRegister(
+	// The metrics of up are registered with the label of the instance, if any.
+	var r prometheus.Registerer = reg
+	if opts.Instance.Label != "" {
+		r = prometheus.WrapRegistererWith(prometheus.Labels{opts.Instance.Label: opts.Instance.ID}, reg)
+	}
+
+	r.MustRegister(
 		collectors.NewGoCollector(),
 		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
 	)
 
-	m := instr.RegisterMetrics(reg)
+	m := instr.RegisterMetrics(r, opts.Buckets)
+	up.InstrumentTransport(opts, m)
 
-	// Error channel to gather failures
-	ch := make(chan error, numOfEndpoints)
+	started := time.Now()
+	// Whether a signal stopped the run. It is only read after the run group returned.
+	interrupted := false
 
 	g := &run.Group{}
 	{
@@ -87,15 +184,46 @@ func main() { //nolint:golint,funlen
 		sig := make(chan os.Signal, 1)
 		g.Add(func() error {
 			signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
-			<-sig
+			_, interrupted = <-sig
 			level.Info(l).Log("msg", "caught interrupt")
 			return nil
 		}, func(_ error) {
+			// Signals caught after stopping must not be sent on the closed channel.
+			signal.Stop(sig)
 			close(sig)
 		})
 	}
+	if tracer != nil {
+		// Spans are exported periodically and once more on exit.
+		tctx, tcancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return tracer.Run(tctx)
+		}, func(_ error) {
+			tcancel()
+		})
+	}
+	var alerter *alertmanager.Alerter
+	if opts.Alertmanager.URL != nil {
+		alerter = alertmanager.NewAlerter(l, opts.Alertmanager.URL, labelMap(opts.Alertmanager.Labels),
+			labelMap(opts.Alertmanager.Annotations))
+
+		// Alerts are sent as the checks fail and recover, the firing alerts again periodically.
+		actx, acancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return alerter.Run(actx)
+		}, func(_ error) {
+			acancel()
+		})
+	}
+	// Reloads requested through the HTTP server.
+	reloads := make(chan chan error)
+
+	cfg := newLiveConfig(opts)
+	rd := newReadiness(opts)
+	st := newStatuses(alerter)
+
 	// Schedule HTTP server
-	scheduleHTTPServer(l, opts, reg, g)
+	scheduleHTTPServer(l, opts, cfg, rd, st, reg, r, g, reloads)
 
 	ctx := context.Background()
 
@@ -106,82 +234,151 @@ func main() { //nolint:golint,funlen
 		ctx, cancel = context.WithCancel(ctx)
 	}
 
-	if opts.WriteEndpoint != nil {
-		g.Add(func() error {
-			l := log.With(l, "component", "writer")
-			level.Info(l).Log("msg", "starting the writer")
-
-			return runPeriodically(ctx, opts, m.RemoteWriteRequests, l, ch, func(rCtx context.Context) {
-				t := time.Now()
-				httpCode, err := write(rCtx, l, opts)
-				duration := time.Since(t).Seconds()
-				m.RemoteWriteRequestDuration.Observe(duration)
-				if err != nil {
-					m.RemoteWriteRequests.WithLabelValues(labelError, strconv.Itoa(httpCode)).Inc()
-					level.Error(l).Log("msg", "failed to make request", "err", err)
-				} else {
-					m.RemoteWriteRequests.WithLabelValues(labelSuccess, strconv.Itoa(httpCode)).Inc()
-				}
-			})
-		}, func(_ error) {
-			cancel()
+	rw, err := newResultsWriter(opts.ResultsFile, opts.ResultsFileResultBytes)
+	if err != nil {
+		level.Error(l).Log("msg", "could not open results file", "err", err)
+		os.Exit(1)
+	}
+
+	ls, err := up.NewLogsState(opts)
+	if err != nil {
+		level.Error(l).Log("msg", "could not parse logs template", "err", err)
+		os.Exit(1)
+	}
+
+	// The reloader always runs, so SIGHUP and the reload endpoint do not fail without files to reload.
+	addConfigReloaderRunGroup(ctx, g, l, opts, m, cfg, reloads, cancel)
+
+	// The checks are added once the error channel gathering their failures is sized, so every check can report its
+	// failure before the channel is drained after the run.
+	var checks []func(ch chan error)
+
+	if len(opts.Tenants) == 0 {
+		requests, requestDuration := m.RemoteWriteRequests, m.RemoteWriteRequestDuration
+		responses, responseDuration := m.QueryResponses, m.QueryResponseDuration
+
+		if opts.EndpointType == options.LogsEndpointType {
+			requests = m.LogsWrites.MustCurryWith(prometheus.Labels{"encoding": string(opts.LogsEncoding)})
+			requestDuration = m.LogsWriteDuration
+			responses, responseDuration = m.LogsQueries, m.LogsQueryDuration
+		}
+
+		checks = append(checks, func(ch chan error) {
+			addWriterRunGroup(ctx, g, l, opts.Endpoints.Write.Apply(opts), m, cfg, rd, st, ls, requests, requestDuration, ch, cancel)
+		}, func(ch chan error) {
+			addReaderRunGroup(ctx, g, l, opts.Endpoints.Read.Apply(opts), m, cfg, rd, st, ls, responses, responseDuration, ch, cancel)
 		})
 	}
 
-	if opts.ReadEndpoint != nil && opts.WriteEndpoint != nil {
-		g.Add(func() error {
-			l := log.With(l, "component", "reader")
-			level.Info(l).Log("msg", "starting the reader")
+	// Every tenant of the --tenants-file writes and reads back its own data.
+	for _, t := range opts.Tenants {
+		t := t
+		tm := instr.RegisterTenantMetrics(r, opts.Buckets, t.Name)
+		tl := log.With(l, "tenant", t.Name)
 
-			// Wait for at least one period before start reading metrics.
-			level.Info(l).Log("msg", "waiting for initial delay before querying", "type", opts.EndpointType)
-			select {
-			case <-ctx.Done():
-				return nil
-			case <-time.After(opts.InitialQueryDelay):
-			}
+		tls := up.LogsState{Template: ls.Template}
+		if opts.LogsChurnInterval > 0 {
+			tls.Churn = logs.NewChurn(opts.LogsChurnInterval)
+		}
+
+		checks = append(checks, func(ch chan error) {
+			addWriterRunGroup(ctx, g, tl, t.Apply(opts.Endpoints.Write.Apply(opts)), m, cfg, rd, st, tls, tm.Writes, tm.WriteDuration, ch,
+				cancel)
+		}, func(ch chan error) {
+			addReaderRunGroup(ctx, g, tl, t.Apply(opts.Endpoints.Read.Apply(opts)), m, cfg, rd, st, tls, tm.Reads, tm.ReadDuration, ch,
+				cancel)
+		})
+	}
 
-			level.Info(l).Log("msg", "start querying", "type", opts.EndpointType)
+	if opts.ReadEndpoint != nil && opts.WriteEndpoint != nil && opts.Exemplars {
+		checks = append(checks, func(ch chan error) {
+			addExemplarReaderRunGroup(ctx, g, l, opts.Endpoints.Read.Apply(opts), m, ch, cancel)
+		})
+	}
 
-			return runPeriodically(ctx, opts, m.QueryResponses, l, ch, func(rCtx context.Context) {
-				t := time.Now()
-				httpCode, err := read(rCtx, l, m, opts)
-				duration := time.Since(t).Seconds()
-				m.QueryResponseDuration.Observe(duration)
-				if err != nil {
-					if httpCode != 0 {
-						m.QueryResponses.WithLabelValues(labelError, strconv.Itoa(httpCode)).Inc()
-					}
-					level.Error(l).Log("msg", "failed to query", "err", err)
-				} else {
-					if httpCode != 0 {
-						m.QueryResponses.WithLabelValues(labelSuccess, strconv.Itoa(httpCode)).Inc()
-					}
-				}
-			})
-		}, func(_ error) {
-			cancel()
+	if opts.ReadEndpoint != nil && opts.WriteEndpoint != nil && opts.Metadata {
+		checks = append(checks, func(ch chan error) {
+			addMetadataReaderRunGroup(ctx, g, l, opts.Endpoints.Read.Apply(opts), m, ch, cancel)
+		})
+	}
+
+	if opts.ReadEndpoint != nil && opts.WriteEndpoint != nil && opts.Federate {
+		checks = append(checks, func(ch chan error) {
+			addFederateReaderRunGroup(ctx, g, l, opts.Endpoints.Read.Apply(opts), m, ch, cancel)
+		})
+	}
+
+	if opts.ReadEndpoint != nil && opts.BuildInfo {
+		checks = append(checks, func(ch chan error) {
+			addBuildInfoReaderRunGroup(ctx, g, l, opts.Endpoints.Read.Apply(opts), m, ch, cancel)
+		})
+	}
+
+	if opts.StoreEndpoint != "" && opts.WriteEndpoint != nil {
+		checks = append(checks, func(ch chan error) {
+			addStoreReaderRunGroup(ctx, g, l, opts.Endpoints.Store.Apply(opts), m, ch, cancel)
 		})
 	}
 
-	if opts.ReadEndpoint != nil && opts.Queries != nil {
-		addCustomQueryRunGroup(ctx, g, l, opts, m, cancel)
+	// With reloading, queries can be added to an initially empty queries file.
+	if opts.ReadEndpoint != nil && (opts.Queries != nil || opts.QueriesFile != "") {
+		checks = append(checks, func(ch chan error) {
+			addCustomQueryRunGroup(ctx, g, l, opts.Endpoints.Read.Apply(opts), m, cfg, st, rw, ch, cancel)
+		})
+	}
+
+	if opts.ReadEndpoint != nil && (opts.Scenarios != nil || opts.QueriesFile != "") {
+		checks = append(checks, func(ch chan error) {
+			addScenarioRunGroup(ctx, g, l, opts, m, cfg, ch, cancel)
+		})
+	}
+
+	// Error channel to gather failures, every check reports at most one.
+	ch := make(chan error, len(checks))
+	for _, add := range checks {
+		add(ch)
+	}
+
+	runErr := g.Run()
+	if runErr != nil {
+		level.Info(l).Log("msg", "run group exited with error", "err", runErr)
 	}
 
-	if err := g.Run(); err != nil {
-		level.Info(l).Log("msg", "run group exited with error", "err", err)
+	// Export the spans ended while the run group was stopped.
+	tracer.Shutdown()
+
+	if err := rw.Close(); err != nil {
+		level.Warn(l).Log("msg", "failed to close results file", "err", err)
 	}
 
 	close(ch)
 
-	fail := false
+	var errs []error
 	for err := range ch {
-		fail = true
+		errs = append(errs, err)
 
 		level.Error(l).Log("err", err)
 	}
 
-	if fail {
+	if err := summarize(l, reg, opts); err != nil {
+		errs = append(errs, err)
+
+		level.Error(l).Log("msg", "failed to evaluate summary", "err", err)
+	}
+
+	if opts.ReportFile != "" || opts.JUnitFile != "" {
+		if err := writeReports(l, reg, opts, cfg, started, exitReason(ctx, opts, interrupted, runErr), errs); err != nil {
+			level.Error(l).Log("msg", "failed to write report", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	// A failed push does not fail the run, the checks have been evaluated already.
+	if err := pushMetrics(l, reg, opts); err != nil {
+		level.Error(l).Log("msg", "failed to push metrics", "err", err)
+	}
+
+	if len(errs) > 0 {
 		level.Error(l).Log("msg", "up failed")
 		os.Exit(1)
 	}
@@ -189,41 +386,306 @@ func main() { //nolint:golint,funlen
 	level.Info(l).Log("msg", "up completed its mission!")
 }
 
-func write(ctx context.Context, l log.Logger, opts options.Options) (int, error) {
-	switch opts.EndpointType {
-	case options.MetricsEndpointType:
-		return metrics.Write(ctx, opts.WriteEndpoint, opts.Token, metrics.Generate(opts.Labels), l, opts.TLS,
-			opts.TenantHeader, opts.Tenant)
-	case options.LogsEndpointType:
-		return logs.Write(ctx, opts.WriteEndpoint, opts.Token, logs.Generate(opts.Labels, opts.Logs), l, opts.TLS)
+// addWriterRunGroup writes periodically, counting the requests in requests and observing their duration.
+func addWriterRunGroup(ctx context.Context, g *run.Group, l log.Logger, opts options.Options, m instr.Metrics, cfg *liveConfig,
+	rd *readiness, st *statuses, ls up.LogsState, requests *prometheus.CounterVec, requestDuration prometheus.Histogram, ch chan error,
+	cancel func()) {
+	if opts.WriteEndpoint == nil {
+		return
 	}
 
-	return 0, fmt.Errorf("invalid endpoint-type: %v", opts.EndpointType)
+	st.add(up.WriterCheck, opts.Tenant)
+
+	g.Add(func() error {
+		l := log.With(l, "component", "writer")
+		level.Info(l).Log("msg", "starting the writer")
+
+		windowFailed := m.CheckWindowFailed.WithLabelValues("writer", opts.Tenant)
+
+		return up.RunPeriodically(ctx, opts, opts.WriteThreshold, requests, windowFailed, l, ch, func(rCtx context.Context) {
+			t := time.Now()
+			httpCode, err := up.Write(rCtx, l, m, opts, cfg, ls)
+			duration := time.Since(t).Seconds()
+			requestDuration.Observe(duration)
+			st.record(up.WriterCheck, opts.Tenant, t, "", err)
+			if err != nil {
+				requests.WithLabelValues(labelError, strconv.Itoa(httpCode)).Inc()
+				level.Error(l).Log("msg", "failed to make request", "err", err)
+			} else {
+				requests.WithLabelValues(labelSuccess, strconv.Itoa(httpCode)).Inc()
+				m.LastSuccessfulWrite.WithLabelValues(opts.Tenant).SetToCurrentTime()
+				rd.wrote()
+			}
+		})
+	}, func(_ error) {
+		cancel()
+	})
 }
 
-func read(ctx context.Context, l log.Logger, m instr.Metrics, opts options.Options) (int, error) {
-	switch opts.EndpointType {
-	case options.MetricsEndpointType:
-		return metrics.Read(ctx, opts.ReadEndpoint, opts.Token, opts.Labels, -1*opts.InitialQueryDelay, opts.Latency, m, l, opts.TLS)
-	case options.LogsEndpointType:
-		return logs.Read(ctx, opts.ReadEndpoint, opts.Token, opts.Labels, -1*opts.InitialQueryDelay, opts.Latency, m, l, opts.TLS)
+// addReaderRunGroup reads back the written data periodically, counting the queries in responses and observing
+// their duration. Without a write endpoint, the reader can only verify data written by others using an explicit read query.
+func addReaderRunGroup(ctx context.Context, g *run.Group, l log.Logger, opts options.Options, m instr.Metrics, cfg *liveConfig,
+	rd *readiness, st *statuses, ls up.LogsState, responses *prometheus.CounterVec, responseDuration prometheus.Histogram, ch chan error,
+	cancel func()) {
+	if opts.ReadEndpoint == nil || (opts.WriteEndpoint == nil && opts.ReadQuery == "") {
+		return
 	}
 
-	return 0, fmt.Errorf("invalid endpoint-type: %v", opts.EndpointType)
-}
+	st.add(up.ReaderCheck, opts.Tenant)
 
-func query(ctx context.Context, l log.Logger, q options.Query, opts options.Options) (int, promapiv1.Warnings, error) {
-	switch opts.EndpointType {
-	case options.MetricsEndpointType:
-		return metrics.Query(ctx, l, opts.ReadEndpoint, opts.Token, q, opts.TLS, opts.DefaultStep)
-	case options.LogsEndpointType:
-		return logs.Query(ctx, l, opts.ReadEndpoint, opts.Token, q, opts.TLS, opts.DefaultStep)
+	var skew *transport.ClockSkew
+	if opts.ClockSkew {
+		skew = transport.NewClockSkew(m.ClockSkew)
+	}
+
+	rr := &metrics.RangeReader{
+		Window:         opts.ReadWindow,
+		Period:         opts.Period,
+		FailOnWarnings: opts.FailOnWarnings,
+		ClockSkew:      skew,
+		RemoteWriter:   opts.WriteEndpoint == nil,
 	}
+	lrr := &logs.RangeReader{
+		Window:         opts.ReadWindow,
+		Period:         opts.Period,
+		Streams:        opts.LogsStreamsPerPush,
+		EntriesPerPush: opts.LogsLinesPerPush,
+		Started:        time.Now(),
+		Template:       ls.Template,
+	}
+
+	g.Add(func() error {
+		l := log.With(l, "component", "reader")
+		level.Info(l).Log("msg", "starting the reader")
+
+		// Wait for at least one period before start reading metrics.
+		level.Info(l).Log("msg", "waiting for initial delay before querying", "type", opts.EndpointType)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.InitialQueryDelay):
+		}
+
+		level.Info(l).Log("msg", "start querying", "type", opts.EndpointType)
+
+		windowFailed := m.CheckWindowFailed.WithLabelValues("reader", opts.Tenant)
 
-	return 0, nil, fmt.Errorf("invalid endpoint-type: %v", opts.EndpointType)
+		return up.RunPeriodically(ctx, opts, opts.ReadThreshold, responses, windowFailed, l, ch, func(rCtx context.Context) {
+			rCtx, trace := api.WithTrace(rCtx)
+			t := time.Now()
+			httpCode, err := up.Read(rCtx, l, m, opts, cfg, ls, rr, lrr, skew)
+			duration := time.Since(t).Seconds()
+			instr.ObserveWithTrace(responseDuration, duration, *trace)
+			st.record(up.ReaderCheck, opts.Tenant, t, trace.ID, err)
+			if opts.EndpointType == options.LogsEndpointType {
+				m.LogsReadDuration.WithLabelValues(instr.TotalReadPhase).Observe(duration)
+			}
+			if err != nil {
+				if httpCode != 0 {
+					responses.WithLabelValues(labelError, strconv.Itoa(httpCode)).Inc()
+				}
+				level.Error(l).Log("msg", "failed to query", "err", err)
+			} else {
+				if httpCode != 0 {
+					responses.WithLabelValues(labelSuccess, strconv.Itoa(httpCode)).Inc()
+				}
+				m.LastSuccessfulRead.WithLabelValues(opts.Tenant).SetToCurrentTime()
+				rd.read()
+			}
+		})
+	}, func(_ error) {
+		cancel()
+	})
+}
+
+func addExemplarReaderRunGroup(ctx context.Context, g *run.Group, l log.Logger, opts options.Options, m instr.Metrics,
+	ch chan error, cancel func()) {
+	g.Add(func() error {
+		l := log.With(l, "component", "exemplar-reader")
+		level.Info(l).Log("msg", "starting the exemplar reader")
+
+		// Wait for at least one period before start reading exemplars.
+		level.Info(l).Log("msg", "waiting for initial delay before querying exemplars")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.InitialQueryDelay):
+		}
+
+		level.Info(l).Log("msg", "start querying exemplars")
+
+		windowFailed := m.CheckWindowFailed.WithLabelValues("exemplar-reader", opts.Tenant)
+
+		return up.RunPeriodically(ctx, opts, opts.ReadThreshold, m.ExemplarQueries, windowFailed, l, ch, func(rCtx context.Context) {
+			t := time.Now()
+			httpCode, err := metrics.ReadExemplars(rCtx, opts.ReadEndpoint, opts.Token, opts.Labels, opts.Latency, l, opts.TLS,
+				opts.TenantHeader, opts.Tenant)
+			duration := time.Since(t).Seconds()
+			m.ExemplarQueryDuration.Observe(duration)
+			if err != nil {
+				if httpCode != 0 {
+					m.ExemplarQueries.WithLabelValues(labelError, strconv.Itoa(httpCode)).Inc()
+				}
+				level.Error(l).Log("msg", "failed to query exemplars", "err", err)
+			} else {
+				m.ExemplarQueries.WithLabelValues(labelSuccess, strconv.Itoa(httpCode)).Inc()
+			}
+		})
+	}, func(_ error) {
+		cancel()
+	})
+}
+
+func addMetadataReaderRunGroup(ctx context.Context, g *run.Group, l log.Logger, opts options.Options, m instr.Metrics,
+	ch chan error, cancel func()) {
+	g.Add(func() error {
+		l := log.With(l, "component", "metadata-reader")
+		level.Info(l).Log("msg", "starting the metadata reader")
+
+		// Wait for at least one period before start reading metadata.
+		level.Info(l).Log("msg", "waiting for initial delay before querying metadata")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.InitialQueryDelay):
+		}
+
+		level.Info(l).Log("msg", "start querying metadata")
+
+		windowFailed := m.CheckWindowFailed.WithLabelValues("metadata-reader", opts.Tenant)
+
+		return up.RunPeriodically(ctx, opts, opts.ReadThreshold, m.MetadataQueries, windowFailed, l, ch, func(rCtx context.Context) {
+			t := time.Now()
+			httpCode, err := metrics.ReadMetadata(rCtx, opts.ReadEndpoint, opts.Token, opts.Name, l, opts.TLS,
+				opts.TenantHeader, opts.Tenant)
+			duration := time.Since(t).Seconds()
+			m.MetadataQueryDuration.Observe(duration)
+			if err != nil {
+				if httpCode != 0 {
+					m.MetadataQueries.WithLabelValues(labelError, strconv.Itoa(httpCode)).Inc()
+				}
+				level.Error(l).Log("msg", "failed to query metadata", "err", err)
+			} else {
+				m.MetadataQueries.WithLabelValues(labelSuccess, strconv.Itoa(httpCode)).Inc()
+			}
+		})
+	}, func(_ error) {
+		cancel()
+	})
+}
+
+// addFederateReaderRunGroup scrapes the written series from the federation endpoint periodically, for the consumers
+// reading the data through federation rather than the query API.
+func addFederateReaderRunGroup(ctx context.Context, g *run.Group, l log.Logger, opts options.Options, m instr.Metrics,
+	ch chan error, cancel func()) {
+	g.Add(func() error {
+		l := log.With(l, "component", "federate-reader")
+		level.Info(l).Log("msg", "starting the federate reader")
+
+		// Wait for at least one period before start scraping the written series.
+		level.Info(l).Log("msg", "waiting for initial delay before scraping federation")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.InitialQueryDelay):
+		}
+
+		level.Info(l).Log("msg", "start scraping federation")
+
+		windowFailed := m.CheckWindowFailed.WithLabelValues("federate-reader", opts.Tenant)
+
+		return up.RunPeriodically(ctx, opts, opts.ReadThreshold, m.FederateQueries, windowFailed, l, ch, func(rCtx context.Context) {
+			t := time.Now()
+			httpCode, err := metrics.ReadFederated(rCtx, opts.ReadEndpoint, opts.Token, opts.Labels, opts.Latency, l, opts.TLS,
+				opts.TenantHeader, opts.Tenant)
+			duration := time.Since(t).Seconds()
+			m.FederateQueryDuration.Observe(duration)
+			if err != nil {
+				if httpCode != 0 {
+					m.FederateQueries.WithLabelValues(labelError, strconv.Itoa(httpCode)).Inc()
+				}
+				level.Error(l).Log("msg", "failed to scrape federation", "err", err)
+			} else {
+				m.FederateQueries.WithLabelValues(labelSuccess, strconv.Itoa(httpCode)).Inc()
+			}
+		})
+	}, func(_ error) {
+		cancel()
+	})
+}
+
+// addBuildInfoReaderRunGroup queries the build info of the read endpoint periodically, recording the version of the
+// server that answered, e.g. to surface version drift across queriers.
+func addBuildInfoReaderRunGroup(ctx context.Context, g *run.Group, l log.Logger, opts options.Options, m instr.Metrics,
+	ch chan error, cancel func()) {
+	g.Add(func() error {
+		l := log.With(l, "component", "build-info-reader")
+		level.Info(l).Log("msg", "starting the build info reader")
+
+		windowFailed := m.CheckWindowFailed.WithLabelValues("build-info-reader", opts.Tenant)
+
+		return up.RunPeriodically(ctx, opts, opts.ReadThreshold, m.BuildInfoQueries, windowFailed, l, ch, func(rCtx context.Context) {
+			t := time.Now()
+			bi, httpCode, err := metrics.ReadBuildInfo(rCtx, opts.ReadEndpoint, opts.Token, l, opts.TLS, opts.TenantHeader, opts.Tenant)
+			duration := time.Since(t).Seconds()
+			m.BuildInfoQueryDuration.Observe(duration)
+			if err != nil {
+				if httpCode != 0 {
+					m.BuildInfoQueries.WithLabelValues(labelError, strconv.Itoa(httpCode)).Inc()
+				}
+				level.Error(l).Log("msg", "failed to query build info", "err", err)
+			} else {
+				m.BuildInfoQueries.WithLabelValues(labelSuccess, strconv.Itoa(httpCode)).Inc()
+
+				// Only the version of the latest answer is kept, the versions of replicas show across instances of up.
+				m.TargetBuildInfo.Reset()
+				m.TargetBuildInfo.WithLabelValues(bi.Version, bi.Revision, bi.Branch, bi.GoVersion).Set(1)
+			}
+		})
+	}, func(_ error) {
+		cancel()
+	})
+}
+
+func addStoreReaderRunGroup(ctx context.Context, g *run.Group, l log.Logger, opts options.Options, m instr.Metrics,
+	ch chan error, cancel func()) {
+	g.Add(func() error {
+		l := log.With(l, "component", "store-reader")
+		level.Info(l).Log("msg", "starting the store reader")
+
+		// Wait for at least one period before start reading metrics.
+		level.Info(l).Log("msg", "waiting for initial delay before querying store")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.InitialQueryDelay):
+		}
+
+		level.Info(l).Log("msg", "start querying store")
+
+		windowFailed := m.CheckWindowFailed.WithLabelValues("store-reader", opts.Tenant)
+
+		return up.RunPeriodically(ctx, opts, opts.ReadThreshold, m.StoreSeriesRequests, windowFailed, l, ch, func(rCtx context.Context) {
+			t := time.Now()
+			code, err := store.Read(rCtx, opts.StoreEndpoint, opts.StoreTLS, opts.Token, opts.Labels, opts.Latency, m, l, opts.TLS)
+			duration := time.Since(t).Seconds()
+			m.StoreSeriesRequestDuration.Observe(duration)
+			if err != nil {
+				if code != "" {
+					m.StoreSeriesRequests.WithLabelValues(labelError, code).Inc()
+				}
+				level.Error(l).Log("msg", "failed to query store", "err", err)
+			} else {
+				m.StoreSeriesRequests.WithLabelValues(labelSuccess, code).Inc()
+			}
+		})
+	}, func(_ error) {
+		cancel()
+	})
 }
 
-func addCustomQueryRunGroup(ctx context.Context, g *run.Group, l log.Logger, opts options.Options, m instr.Metrics, cancel func()) {
+func addCustomQueryRunGroup(ctx context.Context, g *run.Group, l log.Logger, opts options.Options, m instr.Metrics,
+	cfg *liveConfig, st *statuses, rw *resultsWriter, ch chan error, cancel func()) {
 	g.Add(func() error {
 		l := log.With(l, "component", "query-reader")
 		level.Info(l).Log("msg", "starting the reader for queries")
@@ -238,380 +700,1859 @@ func addCustomQueryRunGroup(ctx context.Context, g *run.Group, l log.Logger, opt
 
 		level.Info(l).Log("msg", "start querying for specified queries")
 
+		// Results are kept by query, so they survive reloads of the queries file.
+		results := map[string]*queryResults{}
+
 		for {
 			select {
 			case <-ctx.Done():
-				return nil
+				return reportQueryResults(l, ch, cfg.Queries(), results, opts.QueriesThreshold)
 			default:
-				for _, q := range opts.Queries {
+				for _, q := range cfg.Queries() {
 					select {
 					case <-ctx.Done():
-						return nil
+						return reportQueryResults(l, ch, cfg.Queries(), results, opts.QueriesThreshold)
 					default:
+						r, ok := results[queryKey(q)]
+						if !ok {
+							r = &queryResults{}
+							results[queryKey(q)] = r
+						}
+
 						t := time.Now()
-						httpCode, warn, err := query(ctx, l, q, opts)
-						duration := time.Since(t).Seconds()
-						queryType := q.GetType()
-						name := q.GetName()
+						traceID, err := executeCustomQuery(ctx, l, opts, m, rw, q)
+						st.record(up.QueryCheckPrefix+q.GetName(), "", t, traceID, err)
+
 						if err != nil {
-							level.Info(l).Log(
-								"msg", "failed to execute specified query",
-								"type", queryType,
-								"name", name,
-								"duration", duration,
-								"warnings", fmt.Sprintf("%#+v", warn),
-								"err", err,
-							)
-							if httpCode != 0 {
-								m.CustomQueryErrors.WithLabelValues(queryType, name, strconv.Itoa(httpCode)).Inc()
+							r.failures++
+
+							if opts.FailFast {
+								return failFast(l, ch, errors.Wrapf(err, "custom query %q failed", q.GetName()))
 							}
+						} else {
+							r.success++
+						}
+					}
+					time.Sleep(timeoutBetweenQueries)
+				}
+				time.Sleep(timeoutBetweenQueries)
+			}
+		}
+	}, func(_ error) {
+		cancel()
+	})
+}
+
+// executeCustomQuery executes a single custom query, records its metrics and returns the ID of its trace and why it
+// failed, if so.
+func executeCustomQuery(ctx context.Context, l log.Logger, opts options.Options, m instr.Metrics, rw *resultsWriter,
+	q options.Query) (string, error) {
+	// The stats and the trace are of this execution alone, not of the uncached execution compared against.
+	qCtx, stats := api.WithStats(ctx)
+	qCtx, trace := api.WithTrace(qCtx)
+	t := time.Now()
+	httpCode, warn, err := up.Query(qCtx, l, q, opts)
+	duration := time.Since(t).Seconds()
+	err = q.GetCommon().CheckStatus(httpCode, err)
+	if err == nil && q.GetCommon().FailsOnWarnings(opts.FailOnWarnings) {
+		err = api.WarningsError(warn)
+	}
+	queryType := q.GetType()
+	name := q.GetName()
+	if err != nil {
+		level.Info(l).Log(
+			"msg", "failed to execute specified query",
+			"type", queryType,
+			"name", name,
+			"duration", duration,
+			"warnings", fmt.Sprintf("%#+v", warn),
+			"trace-id", trace.ID,
+			"err", err,
+		)
+		if httpCode != 0 {
+			m.CustomQueryErrors.WithLabelValues(queryType, name, strconv.Itoa(httpCode)).Inc()
+		}
+
+		var aErr *options.AssertionError
+		if errors.As(err, &aErr) {
+			m.CustomQueryAssertionFailures.WithLabelValues(queryType, name).Inc()
+		}
+
+		var dErr *options.DiffError
+		if errors.As(err, &dErr) {
+			m.CustomQueryDiffMismatches.WithLabelValues(name, "series").Add(float64(dErr.Series))
+			m.CustomQueryDiffMismatches.WithLabelValues(name, "value").Add(float64(dErr.Values))
+		}
+	} else {
+		level.Debug(l).Log("msg", "successfully executed specified query",
+			"type", queryType,
+			"name", name,
+			"duration", duration,
+			"warnings", fmt.Sprintf("%#+v", warn),
+			"trace-id", trace.ID,
+		)
+
+		m.CustomQueryLastDuration.WithLabelValues(queryType, name, strconv.Itoa(httpCode)).Set(duration)
+		m.LastSuccessfulQuery.WithLabelValues(queryType, name).SetToCurrentTime()
+
+		if c, ok := q.(options.Cacheable); ok && opts.CompareCache && c.Cached() {
+			compareUncached(ctx, l, opts, m, c, duration)
+		}
+	}
+	if httpCode != 0 {
+		m.CustomQueryExecuted.WithLabelValues(queryType, name, strconv.Itoa(httpCode)).Inc()
+		instr.ObserveWithTrace(m.CustomQueryRequestDuration.WithLabelValues(queryType, name, strconv.Itoa(httpCode)), duration, *trace)
+		m.ObserveWarnings(queryType, name, warn)
+	}
+	if httpCode/100 == 2 {
+		m.ObserveResultSize(queryType, name, *stats)
+	}
+
+	if stats.Engine != nil {
+		m.ObserveEngineStats(queryType, name, stats.Engine)
+	}
+
+	if wErr := rw.write(q, t, duration, httpCode, warn, stats.Data, err); wErr != nil {
+		level.Warn(l).Log("msg", "failed to write query result to results file", "err", wErr)
+	}
+
+	return trace.ID, err
+}
+
+// compareUncached runs the query again bypassing caches and records how much slower it is than the cached run.
+func compareUncached(ctx context.Context, l log.Logger, opts options.Options, m instr.Metrics, q options.Cacheable,
+	cachedDuration float64) {
+	t := time.Now()
+	_, _, err := up.Query(ctx, l, q.Uncached(), opts)
+	duration := time.Since(t).Seconds()
+
+	if err != nil {
+		level.Info(l).Log("msg", "failed to execute uncached query for comparison", "name", q.GetName(), "err", err)
+		return
+	}
+
+	m.CustomQueryUncachedDuration.WithLabelValues(q.GetType(), q.GetName()).Set(duration)
+	m.CustomQueryCacheSavings.WithLabelValues(q.GetType(), q.GetName()).Set(duration - cachedDuration)
+}
+
+// failFast reports the error, ending the run through the returning actor.
+func failFast(l log.Logger, ch chan error, err error) error {
+	level.Error(l).Log("msg", "failing fast", "err", err)
+	ch <- err
+
+	return err
+}
+
+type queryResults struct {
+	success, failures float64
+}
+
+// belowThreshold returns why the success ratio is below the threshold, if so.
+func (r queryResults) belowThreshold(t float64) (string, bool) {
+	if r.success+r.failures == 0 {
+		return "never executed", true
+	}
+
+	if ratio := r.success / (r.success + r.failures); ratio < t {
+		return fmt.Sprintf("%2.f%% < %2.f%%", ratio*100, t*100), true
+	}
+
+	return "", false
+}
+
+// queryKey identifies a query across reloads of the queries file.
+func queryKey(q options.Query) string {
+	return q.GetType() + "/" + q.GetName()
+}
+
+// reportQueryResults evaluates the success ratio of every custom query with a threshold.
+func reportQueryResults(l log.Logger, ch chan error, queries []options.Query, results map[string]*queryResults,
+	threshold float64) error {
+	var failed []string
+
+	for _, q := range queries {
+		t := q.GetCommon().EffectiveThreshold(threshold)
+		if t <= 0 {
+			continue
+		}
+
+		r := queryResults{}
+		if res, ok := results[queryKey(q)]; ok {
+			r = *res
+		}
+
+		level.Info(l).Log("msg", "number of queries", "name", q.GetName(), "success", r.success, "errors", r.failures)
+
+		if reason, ok := r.belowThreshold(t); ok {
+			failed = append(failed, fmt.Sprintf("%s (%s)", q.GetName(), reason))
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	level.Error(l).Log("msg", "ratio of custom queries is below threshold")
+
+	err := errors.Errorf("custom queries failed with less than their success ratio: %s", strings.Join(failed, ", "))
+	ch <- err
+
+	return err
+}
+
+// summarize writes the summary of the run and compares it against the baseline, if configured.
+func summarize(l log.Logger, g prometheus.Gatherer, opts options.Options) error {
+	if opts.SummaryFile == "" && opts.Baseline == nil {
+		return nil
+	}
+
+	s, err := report.Collect(g)
+	if err != nil {
+		return err
+	}
+
+	if opts.SummaryFile != "" {
+		if err := report.WriteFile(opts.SummaryFile, s); err != nil {
+			return err
+		}
+
+		level.Info(l).Log("msg", "summary written", "file", opts.SummaryFile)
+	}
+
+	if opts.Baseline == nil {
+		return nil
+	}
+
+	regressions := report.Compare(*opts.Baseline, s, opts.BaselineTolerance)
+	for _, r := range regressions {
+		level.Error(l).Log("msg", "regression against baseline", "component", r.Component, "reason", r.Reason)
+	}
+
+	if len(regressions) > 0 {
+		return errors.Errorf("%d regressions against baseline", len(regressions))
+	}
+
+	level.Info(l).Log("msg", "no regressions against baseline")
+
+	return nil
+}
+
+// exitReason returns why the run ended. The context is canceled as soon as any part of the run group returns.
+func exitReason(ctx context.Context, opts options.Options, interrupted bool, runErr error) string {
+	switch {
+	case interrupted:
+		return report.ExitInterrupted
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return report.ExitDurationElapsed
+	case opts.FailFast && runErr != nil:
+		return report.ExitFailedFast
+	}
+
+	return report.ExitStopped
+}
+
+// writeReports writes the report of the run to the --report-file and as JUnit XML to the --junit-file.
+func writeReports(l log.Logger, g prometheus.Gatherer, opts options.Options, cfg *liveConfig, started time.Time, reason string,
+	errs []error) error {
+	s, err := report.Collect(g)
+	if err != nil {
+		return err
+	}
+
+	t := report.Thresholds{Write: opts.WriteThreshold, Read: opts.ReadThreshold, Queries: map[string]float64{}}
+	for _, q := range cfg.Queries() {
+		t.Queries[q.GetName()] = q.GetCommon().EffectiveThreshold(opts.QueriesThreshold)
+	}
+
+	var regressions []report.Regression
+	if opts.Baseline != nil {
+		regressions = report.Compare(*opts.Baseline, s, opts.BaselineTolerance)
+	}
+
+	r := report.NewReport(started, reason, s, t, regressions, errs)
+
+	if opts.ReportFile != "" {
+		if err := report.WriteReportFile(opts.ReportFile, r); err != nil {
+			return err
+		}
+
+		level.Info(l).Log("msg", "report written", "file", opts.ReportFile, "exit_reason", reason)
+	}
+
+	if opts.JUnitFile != "" {
+		if err := report.WriteJUnitFile(opts.JUnitFile, r); err != nil {
+			return err
+		}
+
+		level.Info(l).Log("msg", "JUnit report written", "file", opts.JUnitFile)
+	}
+
+	return nil
+}
+
+// Helpers
+
+func labelMap(labels []prompb.Label) map[string]string {
+	m := make(map[string]string, len(labels))
+	for _, l := range labels {
+		m[l.Name] = l.Value
+	}
+
+	return m
+}
+
+func parseFlags(l log.Logger, cmd string, args []string) (options.Options, error) {
+	var (
+		rawEndpointType   string
+		rawWriteEndpoint  string
+		rawReadEndpoint   string
+		rawReadMode       string
+		rawLogLevel       string
+		queriesFileName   string
+		logsFileName      string
+		tokenFile         string
+		token             string
+		baselineFileName  string
+		tenantsFileName   string
+		endpointsFileName string
+		labelsFileName    string
+		rawAPIURL         string
+		rawTracingURL     string
+		rawPushGateway    string
+		rawPushRW         string
+		rawAlertmanager   string
+		internalToken     string
+		internalAuthFile  string
+		profile           string
+		profilesFileName  string
+	)
+
+	opts := options.Options{}
+
+	flag.StringVar(&rawLogLevel, "log.level", "info", "The log filtering level. Options: 'error', 'warn', 'info', 'debug'.")
+	flag.StringVar(&rawEndpointType, "endpoint-type", "metrics", "The endpoint type. Options: 'logs', 'metrics'.")
+	flag.StringVar(&rawWriteEndpoint, "endpoint-write", "",
+		"The endpoint to which to make remote-write requests. For logs, "+
+			"a grpc:// or grpcs:// endpoint pushes to the gRPC API of the Loki distributor, bypassing HTTP gateways.")
+	flag.StringVar(&rawReadEndpoint, "endpoint-read", "", "The endpoint to which to make query requests.")
+	flag.StringVar(&rawAPIURL, "observatorium-api-url", "",
+		"The base URL of an Observatorium API, e.g. 'https://observatorium.example.com', to derive --endpoint-write and "+
+			"--endpoint-read from for the --tenant and --endpoint-type, e.g. '<url>/api/metrics/v1/<tenant>/api/v1/receive'.")
+	flag.StringVar(&opts.StoreEndpoint, "endpoint-store", "",
+		"The Thanos StoreAPI gRPC address, e.g. 'localhost:10901', to which to make series requests reading back written metrics.")
+	flag.BoolVar(&opts.StoreTLS, "endpoint-store-tls", false, "Use TLS with the TLS client flags when connecting to --endpoint-store.")
+	flag.StringVar(&opts.ReadQuery, "read-query", "",
+		"The query used to read back written data instead of the selector for the written labels. "+
+			"For metrics it must return a single series with the written sample value. "+
+			"If set, the reader also runs without --endpoint-write, verifying data written by another up instance.")
+	flag.StringVar(&rawReadMode, "read-mode", "instant",
+		"The way written data is read back. Options: 'instant', 'range'. "+
+			"The range mode reads back metrics over --read-window and detects gaps and duplicates of written samples. "+
+			"For logs it verifies that all generated entries pushed within --read-window are read back.")
+	flag.BoolVar(&opts.Exemplars, "exemplars", false,
+		"Attach an exemplar to the written metrics and verify it can be queried back. Only supported for metrics.")
+	flag.BoolVar(&opts.Metadata, "metadata", false,
+		"Write metadata of the written metrics and verify it can be queried back. Only supported for metrics.")
+	flag.BoolVar(&opts.Federate, "federate", false,
+		"Scrape the written metrics from the /federate endpoint below the read endpoint and verify their latest sample is "+
+			"within the latency, for consumers reading through federation. Only supported for metrics.")
+	flag.BoolVar(&opts.BuildInfo, "build-info", false,
+		"Query the build info of the read endpoint periodically and record the version of the server as up_target_build_info, "+
+			"e.g. to surface version drift across queriers. Only supported for metrics.")
+	flag.DurationVar(&opts.ReadWindow, "read-window", 5*time.Minute, "The window to read back in the range read mode.")
+	flag.Var(&opts.Labels, "labels", "The labels in addition to '__name__' that should be applied to remote-write requests.")
+	flag.Var(&opts.LabelsFromEnv, "label-from-env",
+		"Labels with the values of environment variables, e.g. of the Kubernetes downward API, as 'pod=POD_NAME,node=NODE_NAME'. "+
+			"The --labels take precedence over them. Can be repeated.")
+	flag.StringVar(&labelsFileName, "labels-file", "",
+		"A YAML or JSON file of a map of label names to values, applied like the --labels. "+
+			"Both --labels and --label-from-env take precedence over the file.")
+	flag.StringVar(&opts.Instance.Label, "instance-label", "",
+		"The name of a label identifying the replica of up, e.g. 'replica', added to the written series and the metrics of up, "+
+			"so replicas writing to the same tenant do not overwrite the samples of each other. "+
+			"The labels of --labels, --label-from-env and --labels-file take precedence, e.g. to label by 'replica=POD_NAME'. "+
+			"Leave blank to not label replicas.")
+	flag.StringVar(&opts.Instance.ID, "instance-id", "",
+		"The value of the --instance-label. Defaults to the hostname, which is the name of the pod on Kubernetes.")
+	flag.StringVar(&opts.Listen, "listen", ":8080",
+		"The address on which internal server runs. It serves /metrics, /-/reload, /-/config, the effective configuration, "+
+			"/-/status, the last run of every check as HTML or JSON, "+
+			"/-/healthy and /-/ready, which succeeds once the tokens can be retrieved and the first write and read succeeded.")
+	flag.StringVar(&opts.Internal.TLS.Cert, "internal-tls-cert", "",
+		"File containing the x509 certificate the internal server serves HTTPS with. Leave blank to serve HTTP.")
+	flag.StringVar(&opts.Internal.TLS.Key, "internal-tls-key", "",
+		"File containing the x509 private key matching --internal-tls-cert.")
+	flag.StringVar(&internalToken, "internal-auth-token-file", "",
+		"The file of the bearer token requests to the internal server must set, read for every request. "+
+			"/-/healthy and /-/ready stay unauthenticated for probes.")
+	flag.StringVar(&internalAuthFile, "internal-basic-auth-file", "",
+		"The file of the 'username:password' basic auth credentials requests to the internal server must set "+
+			"instead of --internal-auth-token-file.")
+	flag.Var(&opts.Logs, "logs", "The logs that should be sent to remote-write requests.")
+	flag.StringVar(&logsFileName, "logs-file", "", "A file containing logs to send against the logs write endpoint.")
+	flag.StringVar(&opts.LogsTemplate, "logs-template", "",
+		"A Go template generating the log line of every push instead of --logs or --logs-file, "+
+			"e.g. 'ts={{.Timestamp.UnixNano}} seq={{.Sequence}} {{.Padding}}'.")
+	flag.IntVar(&opts.LogsLineSize, "logs-line-size", 0,
+		"The approximate size in bytes up to which generated lines are padded with random characters. 0 disables padding.")
+	flag.IntVar(&opts.LogsLinesPerPush, "logs-lines-per-push", 1,
+		"The number of lines generated per stream and push. More than one line generates lines instead of using --logs or --logs-file.")
+	flag.IntVar(&opts.LogsStreamsPerPush, "logs-streams-per-push", 1,
+		"The number of streams generated per push, distinguished by the 'stream' label. "+
+			"More than one stream generates lines instead of using --logs or --logs-file.")
+	flag.DurationVar(&opts.LogsChurnInterval, "logs-churn-interval", 0,
+		"The interval to rotate the value of the 'churn' label of the pushed streams on, creating new streams. The default 0 disables churn.")
+	opts.LogsEncoding = options.JSONPushEncoding
+	flag.Var(&opts.LogsEncoding, "logs-encoding",
+		"The encoding of logs pushed to the logs write endpoint. Options: 'json', 'protobuf' (snappy compressed).")
+	opts.LogsCompression = options.NoPushCompression
+	flag.Var(&opts.LogsCompression, "logs-compression",
+		"The compression of the body of logs pushes in addition to the encoding, exercising the limits on decompressed sizes. "+
+			"Options: 'none', 'gzip'.")
+	if cmd == queryCommand {
+		// No metrics are written by the query command, --name selects the query to execute instead.
+		flag.StringVar(&opts.QueryName, "name", "",
+			"The name of the query of --queries-file to execute. The query is bounded by the --request-timeout only.")
+	} else {
+		flag.StringVar(&opts.Name, "name", "up", "The name of the metric to send in remote-write requests.")
+	}
+	flag.StringVar(&token, "token", "",
+		"The bearer token to set in the authorization header on requests. Takes predence over --token-file if set.")
+	flag.StringVar(&tokenFile, "token-file", "",
+		"The file from which to read a bearer token to set in the authorization header on requests.")
+	flag.StringVar(&queriesFileName, "queries-file", "",
+		"A file containing queries to run against the read endpoint. "+
+			"An HTTP(S) URL is fetched at startup and, on reloads, refreshed using its ETag. "+
+			"In a Kubernetes cluster, configmap://<namespace>/<name>/<key> or secret://<namespace>/<name>/<key> "+
+			"reads the key of a ConfigMap or Secret and watches it for changes.")
+	flag.BoolVar(&opts.ExpandEnv, "expand-env", false,
+		"Replace ${VAR} in --queries-file, --logs-file, --labels-file, --tenants-file, --endpoints-file and --profiles-file "+
+			"with the value of the environment variable VAR, failing if it is not set. $${VAR} escapes the expansion.")
+	flag.DurationVar(&opts.ReloadInterval, "reload-interval", 0,
+		"The interval to check the queries and logs files, local or remote, for changes and reload them. "+
+			"The default 0 only reloads them on SIGHUP and on POST requests to /-/reload.")
+	flag.DurationVar(&opts.Period, "period", 5*time.Second, "The time to wait between remote-write requests.")
+	flag.DurationVar(&opts.Duration, "duration", 5*time.Minute,
+		"The duration of the up command to run until it stops. If 0 it will not stop until the process is terminated.")
+	flag.Float64Var(&opts.SuccessThreshold, "threshold", 0.9,
+		"The percentage of successful requests needed to succeed overall, the default of --threshold-write and --threshold-read. 0 - 1.")
+	flag.Float64Var(&opts.WriteThreshold, "threshold-write", 0.9,
+		"The percentage of successful write requests needed to succeed overall. 0 - 1. Defaults to --threshold.")
+	flag.Float64Var(&opts.ReadThreshold, "threshold-read", 0.9,
+		"The percentage of successful read requests, including exemplar, metadata, federation, build info and store reads, "+
+			"needed to succeed overall. 0 - 1. Defaults to --threshold.")
+	flag.DurationVar(&opts.EvaluationWindow, "evaluation-window", 15*time.Minute,
+		"The window to evaluate the --threshold-write and --threshold-read of every check on with --duration=0, "+
+			"which otherwise only evaluates them on shutdown. "+
+			"Failed windows are logged and counted by up_check_window_failed_total. 0 disables the evaluation.")
+	flag.BoolVar(&opts.CompareCache, "compare-cache", false,
+		"Run custom queries with cache enabled a second time bypassing caches, to measure the latency saved by caches.")
+	flag.BoolVar(&opts.DryRun, "dry-run", false,
+		"Validate the flags, the queries and logs files and the TLS and token files, then exit without making requests. "+
+			"Exits with 1 if the configuration is invalid.")
+	flag.BoolVar(&opts.FailFast, "fail-fast", false,
+		"Stop and fail the run at the first failed custom query or scenario instead of waiting for the --duration to pass.")
+	flag.Float64Var(&opts.QueriesThreshold, "queries-threshold", 0,
+		"The percentage of successful executions needed by every custom query and scenario to succeed overall. 0 - 1. "+
+			"0 disables the evaluation. Can be overridden in query and scenario specs.")
+	flag.DurationVar(&opts.Latency, "latency", 15*time.Second,
+		"The maximum allowable latency between writing and reading.")
+	flag.DurationVar(&opts.InitialQueryDelay, "initial-query-delay", 10*time.Second,
+		"The time to wait before executing the first query.")
+	flag.BoolVar(&opts.ClockSkew, "clock-skew-compensation", false,
+		"Estimate the clock skew to the read endpoint from the Date header of responses and correct the latency of metrics by it. "+
+			"Only the metrics of the read-only mode, written by another instance, are corrected, those written by up carry its own "+
+			"clock, which the latency is measured with.")
+	flag.BoolVar(&opts.FailOnWarnings, "fail-on-warnings", false,
+		"Count queries returning warnings, e.g. about partial responses, as failed. Can be overridden in query specs.")
+	flag.DurationVar(&opts.DefaultStep, "step", 5*time.Minute, "Default step duration for range queries. "+
+		"Can be overridden if step is set in query spec.")
+
+	flag.StringVar(&opts.TLS.Cert, "tls-client-cert-file", "",
+		"File containing the default x509 Certificate for HTTPS. Leave blank to disable TLS.")
+	flag.StringVar(&opts.TLS.Key, "tls-client-private-key-file", "",
+		"File containing the default x509 private key matching --tls-cert-file. Leave blank to disable TLS.")
+	flag.StringVar(&opts.TLS.CACert, "tls-ca-file", "",
+		"File containing the TLS CA to use against servers for verification. If no CA is specified, the system certificate pool is used.")
+	flag.BoolVar(&opts.TLS.InsecureSkipVerify, "tls-insecure-skip-verify", false,
+		"UNSAFE: Do not verify the certificates of the servers, e.g. to probe endpoints with self-signed certificates "+
+			"in development clusters. Requests can be intercepted, including their tokens. Never use it in production.")
+	flag.StringVar(&opts.Proxy.HTTP, "http-proxy", "",
+		"The proxy of the requests to http:// endpoints, e.g. 'http://proxy:3128'. Defaults to the HTTP_PROXY environment variable.")
+	flag.StringVar(&opts.Proxy.HTTPS, "https-proxy", "",
+		"The proxy of the requests to https:// endpoints. Defaults to the HTTPS_PROXY environment variable. "+
+			"The proxy_url of an endpoint of the --endpoints-file overrides the proxies of the flags for its requests.")
+	flag.StringVar(&opts.Proxy.NoProxy, "no-proxy", "",
+		"A comma-separated list of hosts, domains and IP ranges requested without proxy, e.g. 'localhost,.svc,10.0.0.0/8'. "+
+			"Defaults to the NO_PROXY environment variable. The gRPC endpoints only use the proxies of the environment.")
+	flag.Var(&opts.Resolver.Static, "resolve",
+		"Dial the IP address instead of the resolved ones for a host and port, like curl, as 'host:port:addr', "+
+			"e.g. to probe one replica behind a shared host. The certificates are still verified against the host. Can be repeated.")
+	flag.StringVar(&opts.Resolver.DNSServer, "dns-server", "",
+		"The address of the DNS server resolving the hosts of the endpoints, e.g. '10.0.0.10:53'. "+
+			"Leave blank to use the resolver of the system.")
+	flag.StringVar(&opts.HostHeader, "host-header", "",
+		"The Host header of the requests to the write and read endpoints, including the custom queries, instead of their host, "+
+			"e.g. to probe a virtual host through the address of an ingress controller. "+
+			"The certificates are still verified against the host of the endpoints, use --resolve to keep both.")
+	flag.IntVar(&opts.Retry.Max, "retry-max", 0,
+		"The number of retries of failed requests of up, of errors and of the --retry-status-codes. "+
+			"The retries are counted in up_request_retries_total. 0 disables retries.")
+	opts.Retry.StatusCodes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout}
+	flag.Var(&opts.Retry.StatusCodes, "retry-status-codes",
+		"The comma-separated status codes of responses retried with --retry-max.")
+	opts.Retry.Methods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete}
+	flag.Var(&opts.Retry.Methods, "retry-methods",
+		"The comma-separated methods of the requests retried with --retry-max, the idempotent ones. "+
+			"Add POST to retry remote-write, which is idempotent, and the queries of the API, which are sent as POST forms.")
+	flag.DurationVar(&opts.Retry.MinBackoff, "retry-min-backoff", 100*time.Millisecond,
+		"The backoff before the first retry, doubling for every further retry with jitter.")
+	flag.DurationVar(&opts.Retry.MaxBackoff, "retry-max-backoff", 5*time.Second,
+		"The maximum backoff between retries. A longer Retry-After of the response is respected.")
+	flag.DurationVar(&opts.Retry.Budget, "retry-budget", 0,
+		"The time of a request including its retries after which it is not retried again. 0 leaves it to the timeout of the request.")
+	flag.Float64Var(&opts.RateLimit.Global, "rate-limit", 0,
+		"The requests per second of up to all endpoints, so that aggressive settings, e.g. many queries or short periods, "+
+			"cannot overload the servers. Requests wait for the limit, they are counted in up_requests_throttled_total. "+
+			"0 disables the limit.")
+	flag.Float64Var(&opts.RateLimit.PerEndpoint, "rate-limit-per-endpoint", 0,
+		"The requests per second of up to every host of the endpoints. 0 disables the limit.")
+	flag.IntVar(&opts.RateLimit.Burst, "rate-limit-burst", 0,
+		"The number of requests made at once before --rate-limit and --rate-limit-per-endpoint apply. "+
+			"0 allows as many as the rate, at least 1.")
+	flag.DurationVar(&opts.Timeouts.Dial, "dial-timeout", transport.DefaultTimeouts.Dial,
+		"The timeout of connecting to the endpoints, including the DNS lookup. 0 disables the timeout.")
+	flag.DurationVar(&opts.Timeouts.TLSHandshake, "tls-handshake-timeout", transport.DefaultTimeouts.TLSHandshake,
+		"The timeout of the TLS handshakes with the endpoints. 0 disables the timeout.")
+	flag.DurationVar(&opts.Timeouts.ResponseHeader, "response-header-timeout", 0,
+		"The timeout of waiting for the headers of responses after writing the requests. 0 disables the timeout.")
+	flag.DurationVar(&opts.Timeouts.Request, "request-timeout", 0,
+		"The timeout of every request of up including its retries and reading the response, e.g. to enforce the latency of SLOs. "+
+			"0 leaves the requests of the writer and reader bounded by the --period, and the query of 'up query' unbounded.")
+	flag.Int64Var(&opts.MaxResponseSize, "max-response-size", 0,
+		"The maximum size in bytes of the bodies of responses read by up, both before and after decoding them. Reading or "+
+			"decoding larger bodies fails, protecting up from gateways returning unbounded payloads, they are counted in "+
+			"up_responses_too_large_total. 0 disables the limit.")
+	opts.Resolver.IPProtocol = options.AnyProtocol
+	flag.Var(&opts.Resolver.IPProtocol, "ip-protocol",
+		"The address family to connect to the endpoints over, e.g. to verify both families of dual-stack gateways on their own. "+
+			"Options: 'ip4', 'ip6', 'any'. The family of every request is the 'family' label of up_request_phase_duration_seconds.")
+	flag.BoolVar(&opts.DisableHTTP2, "disable-http2", false,
+		"Request the HTTP endpoints over HTTP/1.1 only, e.g. to isolate bugs of gateways that only occur over HTTP/2. "+
+			"The protocol of every request is the 'protocol' label of up_request_phase_duration_seconds.")
+	flag.Var(&opts.Encodings.Write, "endpoint-write-content-encoding",
+		"The Content-Encoding of the requests to the write endpoint, replacing the one of the protocol, e.g. snappy "+
+			"of remote-write, to test the limits of gateways. Options: 'identity', 'gzip', 'snappy', 'zstd'. "+
+			"Leave blank to use the one of the protocol.")
+	flag.Var(&opts.Encodings.Read, "endpoint-read-accept-encoding",
+		"The Accept-Encoding of the requests to the read endpoint, the responses are decoded transparently. "+
+			"Options: 'identity', 'gzip', 'snappy', 'zstd'. Leave blank to accept gzip. "+
+			"The encodings used are counted in up_request_encodings_total.")
+	flag.StringVar(&opts.TenantHeader, "tenant-header", "",
+		"Name of HTTP header used to determine tenant for write and read requests. "+
+			"Defaults to 'tenant_id' for metrics and Loki's 'X-Scope-OrgID' for logs.")
+	flag.StringVar(&opts.Tenant, "tenant", "", "Tenant ID to used to determine tenant for write and read requests.")
+	flag.StringVar(&tenantsFileName, "tenants-file", "",
+		"A file of tenants, each with its own token, labels and success threshold, running their own write and read pipelines "+
+			"instead of the single pipeline of --tenant. Their requests are counted by tenant-labelled metrics.")
+	flag.StringVar(&endpointsFileName, "endpoints-file", "",
+		"A file of the TLS client configuration, token, headers and proxy_url of the write, read and store endpoints, each "+
+			"overriding the TLS client, token and proxy flags for the requests to its endpoint, e.g. to probe endpoints "+
+			"of different gateways through different proxies. The tokens of the --tenants-file and of the queries take precedence.")
+	flag.Var(&opts.LogsTenants, "logs-tenants",
+		"Comma-separated tenant IDs to rotate the tenant of every logs push through instead of --tenant, "+
+			"exercising multi-tenant write patterns. The reader queries the tenant of the latest successful push.")
+	flag.Var(&opts.Buckets.WriteDuration, "write-duration-buckets",
+		"Comma-separated buckets in seconds for the duration of write requests. Defaults to the Prometheus client defaults.")
+	flag.Var(&opts.Buckets.QueryDuration, "query-duration-buckets",
+		"Comma-separated buckets in seconds for the duration of read requests. Defaults to the Prometheus client defaults.")
+	flag.Var(&opts.Buckets.MetricValueDifference, "metric-value-difference-buckets",
+		"Comma-separated buckets in seconds for the difference between the written and the current time. Defaults to 4 - 7.75.")
+	flag.Var(&opts.Buckets.CustomQueryDuration, "custom-query-duration-buckets",
+		"Comma-separated buckets in seconds for the duration of custom queries. Defaults to 0.1 - 120.")
+	flag.Var(&opts.Buckets.LogsReadDuration, "logs-read-duration-buckets",
+		"Comma-separated buckets in seconds for the duration of the phases of reading back logs. Defaults to 0.001 - 32.768. "+
+			"The histogram is also exposed as a native histogram.")
+	flag.Var(&opts.Buckets.RequestPhaseDuration, "request-phase-duration-buckets",
+		"Comma-separated buckets in seconds for the duration of the phases of requests, e.g. the TLS handshake. "+
+			"Defaults to 0.001 - 32.768.")
+	flag.BoolVar(&opts.Buckets.Native, "native-histograms", false,
+		"Expose the duration and freshness histograms also as native histograms with a resolution of 10%, "+
+			"scraped by Prometheus with native histograms enabled. The classic buckets are exposed as before.")
+	flag.StringVar(&opts.SummaryFile, "summary-file", "",
+		"A file to write the summary of the run to as JSON on exit. It can be used as --baseline-file for later runs.")
+	flag.StringVar(&opts.ReportFile, "report-file", "",
+		"A file to write the report of the run to as JSON on exit: the summary, the verdicts of the thresholds, "+
+			"the regressions against the baseline, the errors and the reason the run ended.")
+	flag.StringVar(&opts.JUnitFile, "junit-file", "",
+		"A file to write the report of the run to as JUnit XML on exit, with the write and read checks, every custom query "+
+			"and the run as test cases, for CI systems to show which check failed.")
+	flag.StringVar(&opts.ResultsFile, "results-file", "",
+		"A file to append the result of every custom query execution to as JSON lines.")
+	flag.IntVar(&opts.ResultsFileResultBytes, "results-file-result-bytes", 0,
+		"The number of bytes of the response data of queries to include in the results file. 0 omits the data.")
+	flag.StringVar(&baselineFileName, "baseline-file", "",
+		"A summary file of a previous run to compare the results against. Regressions beyond the tolerances fail the run.")
+	flag.Float64Var(&opts.BaselineTolerance.Latency, "baseline-latency-tolerance", 0.2,
+		"The allowed relative increase of mean latencies compared to the baseline. 0.2 allows 20% slower requests.")
+	flag.Float64Var(&opts.BaselineTolerance.Ratio, "baseline-ratio-tolerance", 0.01,
+		"The allowed absolute decrease of success ratios compared to the baseline. 0 - 1.")
+	flag.StringVar(&rawTracingURL, "tracing-endpoint", "",
+		"The OTLP/HTTP endpoint, e.g. 'http://localhost:4318' of an OpenTelemetry Collector, to export spans of all requests to. "+
+			"The trace context is propagated to the servers in the traceparent header. Leave blank to disable tracing.")
+	flag.Float64Var(&opts.TracingSamplingRatio, "tracing-sampling-ratio", 1,
+		"The ratio of traces of checks to sample with --tracing-endpoint. 0 - 1.")
+	flag.StringVar(&rawPushGateway, "push-gateway-url", "",
+		"The URL of a Pushgateway to push the metrics of up to on exit, for runs that end before they are scraped, "+
+			"e.g. in CI. The metrics replace the ones of the previous run of the --push-job.")
+	flag.StringVar(&rawPushRW, "push-remote-write-url", "",
+		"The remote-write endpoint to write the metrics of up to on exit, as a single sample of every series with "+
+			"the job label of --push-job. The token, the TLS and the tenant of the write endpoint are used.")
+	flag.StringVar(&opts.Push.Job, "push-job", "up",
+		"The job of the metrics pushed with --push-gateway-url and --push-remote-write-url.")
+	flag.StringVar(&rawAlertmanager, "alertmanager-url", "",
+		"The URL of an Alertmanager to fire an alert to for every failing check, resolving it when the check succeeds "+
+			"again. The alerts have the 'alertname', 'check' and 'tenant' labels. Leave blank to disable alerts.")
+	flag.Var(&opts.Alertmanager.Labels, "alertmanager-labels",
+		"The labels in addition to 'alertname', 'check' and 'tenant' of the alerts of --alertmanager-url, "+
+			"e.g. 'severity=\"critical\"'. 'alertname' can be overridden.")
+	flag.Var(&opts.Alertmanager.Annotations, "alertmanager-annotations",
+		"The annotations in addition to 'summary' and 'description' of the alerts of --alertmanager-url, "+
+			"e.g. 'runbook_url=\"https://...\"'.")
+	flag.StringVar(&opts.DebugCapture.Dir, "debug-capture-dir", "",
+		"A directory to write every failed request to as JSON, with the headers and the bodies of the request and the response, "+
+			"to reproduce failures of the servers. The values of headers and query parameters named like secrets, "+
+			"e.g. 'Authorization', are redacted. Leave blank to disable capturing.")
+	flag.IntVar(&opts.DebugCapture.MaxBodyBytes, "debug-capture-max-body-bytes", 64*1024,
+		"The number of bytes of every body to capture with --debug-capture-dir, longer bodies are truncated.")
+	flag.IntVar(&opts.DebugCapture.MaxFiles, "debug-capture-max-files", 100,
+		"The number of failed requests to capture with --debug-capture-dir, further failed requests are not captured.")
+	flag.BoolVar(&opts.Profiling.Enabled, "profiling", false,
+		"Serve the CPU profile, the execution trace and the symbols of pprof on /debug/pprof/ "+
+			"besides the index of the heap, goroutine, allocs, threadcreate, mutex and block profiles.")
+	flag.IntVar(&opts.Profiling.MutexFraction, "profiling-mutex-fraction", 0,
+		"Report 1 in this many mutex contention events in the mutex profile with --profiling. 0 disables the profile.")
+	flag.IntVar(&opts.Profiling.BlockRate, "profiling-block-rate", 0,
+		"Sample one blocking event per this many nanoseconds spent blocked in the block profile with --profiling. "+
+			"0 disables the profile.")
+	flag.Var(&opts.MetricsFilter.Allow, "metrics-allow",
+		"A regular expression matching the whole names of the metrics to serve on /metrics, e.g. 'up_.*'. "+
+			"Leave blank to serve all metrics.")
+	flag.Var(&opts.MetricsFilter.Deny, "metrics-deny",
+		"A regular expression matching the whole names of the metrics not to serve on /metrics, "+
+			"e.g. 'up_custom_query_(engine|cache)_.*'.")
+	flag.Var(&opts.MetricsFilter.DropLabels, "metrics-drop-labels",
+		"The labels to drop from the metrics served on /metrics, e.g. 'query' for fleets of many queries. "+
+			"The series that only differ by the labels are aggregated, summing counters and histograms "+
+			"and taking the maximum of gauges.")
+	flag.StringVar(&profile, "profile", "",
+		"The name of the run profile of --profiles-file to apply, e.g. 'smoke' or 'soak'.")
+	flag.StringVar(&profilesFileName, "profiles-file", "",
+		"A file of named run profiles, each setting flags by their name, e.g. 'period: 1s'. "+
+			"Flags set on the command line take precedence over the profile.")
+
+	if cmd == writeCommand {
+		flag.BoolVar(&opts.WriteOnce, "once", false, "Write once and exit, instead of writing periodically.")
+	}
+
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if err := applyProfile(l, flag.CommandLine, profile, profilesFileName, opts.ExpandEnv); err != nil {
+		return opts, errors.Wrap(err, "applying profile")
+	}
+
+	// The thresholds of writes and reads default to the shared --threshold.
+	set := map[string]bool{}
+	flag.CommandLine.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if !set["threshold-write"] {
+		opts.WriteThreshold = opts.SuccessThreshold
+	}
+
+	if !set["threshold-read"] {
+		opts.ReadThreshold = opts.SuccessThreshold
+	}
+
+	return buildOptionsFromFlags(
+		l, opts, rawLogLevel, rawEndpointType, rawWriteEndpoint, rawReadEndpoint, rawReadMode, queriesFileName, logsFileName, token,
+		tokenFile, baselineFileName, tenantsFileName, endpointsFileName, labelsFileName, rawAPIURL, rawTracingURL, internalToken,
+		internalAuthFile, rawPushGateway, rawPushRW, rawAlertmanager,
+	)
+}
+
+func buildOptionsFromFlags(
+	l log.Logger,
+	opts options.Options,
+	rawLogLevel, rawEndpointType, rawWriteEndpoint, rawReadEndpoint, rawReadMode, queriesFileName, logsFileName, token, tokenFile,
+	baselineFileName, tenantsFileName, endpointsFileName, labelsFileName, rawAPIURL, rawTracingURL, internalToken, internalAuthFile,
+	rawPushGateway, rawPushRW, rawAlertmanager string,
+) (options.Options, error) {
+	var err error
+
+	err = parseLogLevel(&opts, rawLogLevel)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing log level")
+	}
+
+	err = parseEndpointType(&opts, rawEndpointType)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing endpoint type")
+	}
+
+	if opts.TenantHeader == "" {
+		opts.TenantHeader = defaultTenantHeader(opts.EndpointType)
+	}
+
+	if rawAPIURL != "" {
+		if rawWriteEndpoint != "" || rawReadEndpoint != "" {
+			return opts, errors.Errorf("--observatorium-api-url cannot be used with --endpoint-write or --endpoint-read")
+		}
+
+		rawWriteEndpoint, rawReadEndpoint, err = observatoriumEndpoints(opts.EndpointType, rawAPIURL, opts.Tenant)
+		if err != nil {
+			return opts, errors.Wrap(err, "parsing Observatorium API URL")
+		}
+	}
+
+	err = parseWriteEndpoint(&opts, l, rawWriteEndpoint, opts.ReadQuery)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing write endpoint")
+	}
+
+	err = parseReadEndpoint(&opts, l, rawReadEndpoint)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing read endpoint")
+	}
+
+	err = parseReadMode(&opts, rawReadMode)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing read mode")
+	}
+
+	err = parseQueriesFileName(&opts, l, queriesFileName)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing queries file name")
+	}
+
+	err = parseLogsFileName(&opts, l, logsFileName)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing logs file name")
+	}
+
+	err = parseBaselineFileName(&opts, baselineFileName)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing baseline file name")
+	}
+
+	err = parseTenantsFileName(&opts, l, tenantsFileName)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing tenants file name")
+	}
+
+	err = parseEndpointsFileName(&opts, endpointsFileName)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing endpoints file name")
+	}
+
+	// The --labels take precedence over the labels of environment variables, which take precedence over the --labels-file.
+	if err := opts.Labels.Merge(opts.LabelsFromEnv); err != nil {
+		return opts, fmt.Errorf("--label-from-env is invalid: %w", err)
+	}
+
+	err = parseLabelsFileName(&opts, labelsFileName)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing labels file name")
+	}
+
+	err = parseInstance(&opts)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing instance")
+	}
+
+	if opts.ReadQuery != "" {
+		if err := validateQuery(opts.EndpointType, opts.ReadQuery); err != nil {
+			return opts, fmt.Errorf("--read-query is invalid: %w", err)
+		}
+	}
+
+	if opts.StoreEndpoint != "" && opts.EndpointType != options.MetricsEndpointType {
+		return opts, errors.Errorf("--endpoint-store is only supported for metrics")
+	}
+
+	if opts.Exemplars && opts.EndpointType != options.MetricsEndpointType {
+		return opts, errors.Errorf("--exemplars is only supported for metrics")
+	}
+
+	if opts.Metadata && opts.EndpointType != options.MetricsEndpointType {
+		return opts, errors.Errorf("--metadata is only supported for metrics")
+	}
+
+	if opts.Federate && opts.EndpointType != options.MetricsEndpointType {
+		return opts, errors.Errorf("--federate is only supported for metrics")
+	}
+
+	if opts.BuildInfo && opts.EndpointType != options.MetricsEndpointType {
+		return opts, errors.Errorf("--build-info is only supported for metrics")
+	}
+
+	if err := parseLogsVolume(&opts); err != nil {
+		return opts, err
+	}
+
+	if opts.LogsEncoding != options.JSONPushEncoding && opts.EndpointType != options.LogsEndpointType {
+		return opts, errors.Errorf("--logs-encoding is only supported for logs")
+	}
+
+	if opts.LogsCompression != options.NoPushCompression && opts.EndpointType != options.LogsEndpointType {
+		return opts, errors.Errorf("--logs-compression is only supported for logs")
+	}
+
+	if opts.WriteEndpoint != nil && logs.IsGRPC(opts.WriteEndpoint) {
+		if opts.EndpointType != options.LogsEndpointType {
+			return opts, errors.Errorf("--endpoint-write over gRPC is only supported for logs")
+		}
+
+		if opts.LogsCompression != options.NoPushCompression {
+			return opts, errors.Errorf("--logs-compression is not supported for pushes over gRPC")
+		}
+
+		// Pushes over gRPC are always encoded as protobuf.
+		opts.LogsEncoding = options.ProtobufPushEncoding
+	}
+
+	err = parseTracingEndpoint(&opts, rawTracingURL)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing tracing endpoint")
+	}
+
+	err = parseInternalServer(&opts, internalToken, internalAuthFile)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing internal server")
+	}
+
+	err = parsePush(&opts, rawPushGateway, rawPushRW)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing push")
+	}
+
+	opts.Alertmanager.URL, err = parseHTTPURL("--alertmanager-url", rawAlertmanager)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing Alertmanager URL")
+	}
+
+	err = parseProxy(&opts)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing proxy")
+	}
+
+	err = parseDNSServer(&opts)
+	if err != nil {
+		return opts, errors.Wrap(err, "parsing DNS server")
+	}
+
+	if opts.HostHeader != "" && !httpguts.ValidHostHeader(opts.HostHeader) {
+		return opts, errors.Errorf("--host-header %q is not a valid host", opts.HostHeader)
+	}
+
+	if opts.Retry.Max < 0 || opts.Retry.MinBackoff < 0 || opts.Retry.MaxBackoff < opts.Retry.MinBackoff || opts.Retry.Budget < 0 {
+		return opts, errors.Errorf("--retry-max, --retry-min-backoff and --retry-budget cannot be negative " +
+			"and --retry-max-backoff cannot be less than --retry-min-backoff")
+	}
+
+	if opts.RateLimit.Global < 0 || opts.RateLimit.PerEndpoint < 0 || opts.RateLimit.Burst < 0 {
+		return opts, errors.Errorf("--rate-limit, --rate-limit-per-endpoint and --rate-limit-burst cannot be negative")
+	}
+
+	if opts.MaxResponseSize < 0 {
+		return opts, errors.Errorf("--max-response-size cannot be negative")
+	}
+
+	if opts.Timeouts.Dial < 0 || opts.Timeouts.TLSHandshake < 0 || opts.Timeouts.ResponseHeader < 0 || opts.Timeouts.Request < 0 {
+		return opts, errors.Errorf("--dial-timeout, --tls-handshake-timeout, --response-header-timeout and --request-timeout " +
+			"cannot be negative")
+	}
+
+	if opts.Profiling.MutexFraction < 0 || opts.Profiling.BlockRate < 0 {
+		return opts, errors.Errorf("--profiling-mutex-fraction and --profiling-block-rate cannot be negative")
+	}
+
+	if opts.DebugCapture.Dir != "" && (opts.DebugCapture.MaxBodyBytes < 0 || opts.DebugCapture.MaxFiles <= 0) {
+		return opts, errors.Errorf("--debug-capture-max-body-bytes cannot be negative and --debug-capture-max-files must be positive")
+	}
+
+	if opts.Latency <= opts.Period {
+		return opts, errors.Errorf("--latency cannot be less than period")
+	}
+
+	if opts.EvaluationWindow < 0 || (opts.EvaluationWindow > 0 && opts.EvaluationWindow < opts.Period) {
+		return opts, errors.Errorf("--evaluation-window cannot be less than period")
+	}
+
+	opts.Labels = append(opts.Labels, prompb.Label{
+		Name:  "__name__",
+		Value: opts.Name,
+	})
+	// We need to ensure labels are sorted before we proceed.
+	opts.Labels.Sort()
+
+	opts.Token = tokenProvider(token, tokenFile)
+
+	return opts, err
+}
+
+func parseLogLevel(opts *options.Options, rawLogLevel string) error {
+	switch rawLogLevel {
+	case "error":
+		opts.LogLevel = level.AllowError()
+	case "warn":
+		opts.LogLevel = level.AllowWarn()
+	case "info":
+		opts.LogLevel = level.AllowInfo()
+	case "debug":
+		opts.LogLevel = level.AllowDebug()
+	default:
+		return errors.Errorf("unexpected log level")
+	}
+
+	return nil
+}
+
+func parseEndpointType(opts *options.Options, rawEndpointType string) error {
+	switch options.EndpointType(rawEndpointType) {
+	case options.LogsEndpointType:
+		opts.EndpointType = options.LogsEndpointType
+	case options.MetricsEndpointType:
+		opts.EndpointType = options.MetricsEndpointType
+	default:
+		return errors.Errorf("unexpected endpoint type")
+	}
+
+	return nil
+}
+
+// dryRun validates the configuration which is otherwise only loaded once requests are made.
+// Everything else, e.g. the queries, is validated by parsing the flags already.
+func dryRun(l log.Logger, opts options.Options) error {
+	if opts.TLS.Cert != "" || opts.TLS.Key != "" || opts.TLS.CACert != "" {
+		if _, err := transport.NewTLSConfig(l, opts.TLS); err != nil {
+			return errors.Wrap(err, "TLS client flags are invalid")
+		}
+	}
+
+	if _, err := opts.Token.Get(); err != nil {
+		return errors.Wrap(err, "reading token")
+	}
+
+	for _, t := range opts.Tenants {
+		if _, err := t.Apply(opts).Token.Get(); err != nil {
+			return errors.Wrapf(err, "reading token of tenant %q", t.Name)
+		}
+	}
+
+	for name, e := range map[string]*options.EndpointSpec{
+		"write": opts.Endpoints.Write, "read": opts.Endpoints.Read, "store": opts.Endpoints.Store,
+	} {
+		if e == nil {
+			continue
+		}
+
+		eo := e.Apply(opts)
+
+		if e.TLS != nil && (eo.TLS.Cert != "" || eo.TLS.Key != "" || eo.TLS.CACert != "") {
+			if _, err := transport.NewTLSConfig(l, eo.TLS); err != nil {
+				return errors.Wrapf(err, "TLS client configuration of the %s endpoint is invalid", name)
+			}
+		}
+
+		if _, err := eo.Token.Get(); err != nil {
+			return errors.Wrapf(err, "reading token of the %s endpoint", name)
+		}
+	}
+
+	for _, q := range opts.Queries {
+		if _, err := q.GetCommon().EffectiveToken(opts.Token).Get(); err != nil {
+			return errors.Wrapf(err, "reading token of query %q", q.GetName())
+		}
+	}
+
+	if opts.LogsTemplate != "" {
+		if _, err := logs.NewLineTemplate(opts.LogsTemplate, opts.LogsLineSize); err != nil {
+			return fmt.Errorf("--logs-template is invalid: %w", err)
+		}
+	}
+
+	if opts.WriteEndpoint == nil && opts.ReadEndpoint == nil && opts.StoreEndpoint == "" {
+		return errors.Errorf("no endpoint to make requests against")
+	}
+
+	return nil
+}
+
+// defaultTenantHeader returns the header carrying the tenant if --tenant-header is not set.
+func defaultTenantHeader(endpointType options.EndpointType) string {
+	if endpointType == options.LogsEndpointType {
+		return "X-Scope-OrgID"
+	}
+
+	return "tenant_id"
+}
+
+func parseWriteEndpoint(opts *options.Options, l log.Logger, rawWriteEndpoint, rawReadQuery string) error {
+	if rawWriteEndpoint != "" {
+		writeEndpoint, err := url.ParseRequestURI(rawWriteEndpoint)
+		if err != nil {
+			return fmt.Errorf("--endpoint-write is invalid: %w", err)
+		}
+
+		opts.WriteEndpoint = writeEndpoint
+	} else {
+		l.Log("msg", "no write endpoint specified, no write tests being performed")
+
+		if rawReadQuery != "" {
+			l.Log("msg", "running in read-only mode using the specified read query")
+		}
+	}
+
+	return nil
+}
+
+// observatoriumEndpoints returns the write and read endpoints of the tenant of an Observatorium API.
+func observatoriumEndpoints(endpointType options.EndpointType, rawAPIURL, tenant string) (string, string, error) {
+	u, err := url.ParseRequestURI(rawAPIURL)
+	if err != nil {
+		return "", "", fmt.Errorf("--observatorium-api-url is invalid: %w", err)
+	}
+
+	if u.Scheme != transport.HTTP && u.Scheme != transport.HTTPS {
+		return "", "", errors.Errorf("--observatorium-api-url must be an http:// or https:// URL")
+	}
+
+	if tenant == "" {
+		return "", "", errors.Errorf("--observatorium-api-url requires --tenant")
+	}
+
+	// The read endpoints are the bases of the Prometheus and Loki APIs, which the clients add the paths of the queries to.
+	base := strings.TrimSuffix(u.String(), "/")
+
+	switch endpointType {
+	case options.MetricsEndpointType:
+		read := base + "/api/metrics/v1/" + url.PathEscape(tenant)
+
+		return read + "/api/v1/receive", read, nil
+	case options.LogsEndpointType:
+		read := base + "/api/logs/v1/" + url.PathEscape(tenant)
+
+		return read + "/loki/api/v1/push", read, nil
+	}
+
+	return "", "", fmt.Errorf("invalid endpoint-type: %v", endpointType)
+}
+
+func parseReadEndpoint(opts *options.Options, l log.Logger, rawReadEndpoint string) error {
+	if rawReadEndpoint != "" {
+		readEndpoint, err := url.ParseRequestURI(rawReadEndpoint)
+		if err != nil {
+			return fmt.Errorf("--endpoint-read is invalid: %w", err)
+		}
+
+		opts.ReadEndpoint = readEndpoint
+	} else {
+		l.Log("msg", "no read endpoint specified, no read tests being performed")
+	}
+
+	return nil
+}
+
+func parseTracingEndpoint(opts *options.Options, rawTracingURL string) error {
+	if opts.TracingSamplingRatio < 0 || opts.TracingSamplingRatio > 1 {
+		return errors.Errorf("--tracing-sampling-ratio must be between 0 and 1")
+	}
+
+	if rawTracingURL == "" {
+		return nil
+	}
+
+	u, err := url.ParseRequestURI(rawTracingURL)
+	if err != nil {
+		return fmt.Errorf("--tracing-endpoint is invalid: %w", err)
+	}
+
+	if u.Scheme != transport.HTTP && u.Scheme != transport.HTTPS {
+		return errors.Errorf("--tracing-endpoint must be an http:// or https:// URL")
+	}
+
+	opts.TracingEndpoint = u
+
+	return nil
+}
+
+func parsePush(opts *options.Options, rawGatewayURL, rawRemoteWriteURL string) error {
+	var err error
+
+	if opts.Push.GatewayURL, err = parseHTTPURL("--push-gateway-url", rawGatewayURL); err != nil {
+		return err
+	}
+
+	if opts.Push.RemoteWriteURL, err = parseHTTPURL("--push-remote-write-url", rawRemoteWriteURL); err != nil {
+		return err
+	}
+
+	if (opts.Push.GatewayURL != nil || opts.Push.RemoteWriteURL != nil) && opts.Push.Job == "" {
+		return errors.Errorf("--push-job cannot be empty")
+	}
+
+	return nil
+}
+
+func parseHTTPURL(name, raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	u, err := url.ParseRequestURI(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s is invalid: %w", name, err)
+	}
+
+	if u.Scheme != transport.HTTP && u.Scheme != transport.HTTPS {
+		return nil, errors.Errorf("%s must be an http:// or https:// URL", name)
+	}
+
+	return u, nil
+}
+
+func parseProxy(opts *options.Options) error {
+	for name, raw := range map[string]string{"--http-proxy": opts.Proxy.HTTP, "--https-proxy": opts.Proxy.HTTPS} {
+		if raw == "" {
+			continue
+		}
+
+		if err := validateProxyURL(name, raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateProxyURL(name, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%s is invalid: %w", name, err)
+	}
+
+	if u.Scheme != transport.HTTP && u.Scheme != transport.HTTPS && u.Scheme != "socks5" {
+		return errors.Errorf("%s must be an http://, https:// or socks5:// URL", name)
+	}
+
+	return nil
+}
+
+func parseDNSServer(opts *options.Options) error {
+	if opts.Resolver.DNSServer == "" {
+		return nil
+	}
+
+	// The port of DNS is the default.
+	if _, _, err := net.SplitHostPort(opts.Resolver.DNSServer); err != nil {
+		opts.Resolver.DNSServer = net.JoinHostPort(opts.Resolver.DNSServer, "53")
+	}
+
+	host, _, err := net.SplitHostPort(opts.Resolver.DNSServer)
+	if err != nil || net.ParseIP(host) == nil {
+		return errors.Errorf("--dns-server must be an IP address with an optional port")
+	}
+
+	return nil
+}
+
+func parseInternalServer(opts *options.Options, tokenFile, basicAuthFile string) error {
+	if (opts.Internal.TLS.Cert == "") != (opts.Internal.TLS.Key == "") {
+		return errors.Errorf("--internal-tls-cert and --internal-tls-key must be set together")
+	}
+
+	if opts.Internal.TLS.Cert != "" {
+		if _, err := tls.LoadX509KeyPair(opts.Internal.TLS.Cert, opts.Internal.TLS.Key); err != nil {
+			return fmt.Errorf("--internal-tls-cert is invalid: %w", err)
+		}
+	}
+
+	if tokenFile != "" && basicAuthFile != "" {
+		return errors.Errorf("--internal-auth-token-file cannot be used with --internal-basic-auth-file")
+	}
+
+	if tokenFile != "" {
+		t := auth.NewFileToken(tokenFile)
+		if _, err := t.Get(); err != nil {
+			return fmt.Errorf("--internal-auth-token-file is invalid: %w", err)
+		}
+
+		opts.Internal.Token = t
+	}
+
+	if basicAuthFile != "" {
+		b, err := ioutil.ReadFile(basicAuthFile)
+		if err != nil {
+			return fmt.Errorf("--internal-basic-auth-file is invalid: %w", err)
+		}
+
+		username, password, ok := strings.Cut(strings.TrimSpace(string(b)), ":")
+		if !ok || username == "" || password == "" {
+			return errors.Errorf("--internal-basic-auth-file must contain 'username:password'")
+		}
+
+		opts.Internal.BasicAuthUsername, opts.Internal.BasicAuthPassword = username, password
+	}
+
+	return nil
+}
+
+func parseReadMode(opts *options.Options, rawReadMode string) error {
+	switch options.ReadMode(rawReadMode) {
+	case options.InstantReadMode:
+		opts.ReadMode = options.InstantReadMode
+	case options.RangeReadMode:
+		if opts.ReadWindow < opts.Period {
+			return errors.Errorf("--read-window cannot be less than period")
+		}
+
+		opts.ReadMode = options.RangeReadMode
+	default:
+		return errors.Errorf("unexpected read mode")
+	}
+
+	return nil
+}
+
+func parseQueriesFileName(opts *options.Options, l log.Logger, queriesFileName string) error {
+	if queriesFileName != "" {
+		b, _, err := readFile(context.Background(), queriesFileName, "")
+		if err != nil {
+			return fmt.Errorf("--queries-file is invalid: %w", err)
+		}
+
+		if opts.ExpandEnv {
+			if b, err = expandEnv(b); err != nil {
+				return fmt.Errorf("--queries-file is invalid: %w", err)
+			}
+		}
+
+		opts.Queries, opts.Scenarios, err = parseQueries(l, opts.EndpointType, b)
+		if err != nil {
+			return err
+		}
+
+		opts.QueriesFile = queriesFileName
+	}
+
+	return nil
+}
+
+// validateQuery validates a query as PromQL or, against logs, as LogQL.
+func validateQuery(endpointType options.EndpointType, query string) error {
+	if endpointType == options.LogsEndpointType {
+		return logs.ValidateQuery(query)
+	}
+
+	_, err := parser.ParseExpr(query)
+
+	return err
+}
+
+// parseQueries parses and validates the content of a queries file for the given endpoint type.
+func parseQueries(l log.Logger, endpointType options.EndpointType,
+	b []byte) ([]options.Query, []options.ScenarioSpec, error) {
+	var queries []options.Query
+
+	qf := CallsFile{}
+	err := yaml.Unmarshal(b, &qf) //nolint:typecheck
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("--queries-file content is invalid: %w", err)
+	}
+
+	// validate queries
+	for _, q := range qf.Queries {
+		if err := validateQuery(endpointType, q.Query); err != nil {
+			return nil, nil, fmt.Errorf("query %q in --queries-file content is invalid: %w", q.Name, err)
+		}
+
+		if q.MaxSourceResolution != "" && q.MaxSourceResolution != "auto" {
+			if _, err := model.ParseDuration(q.MaxSourceResolution); err != nil {
+				return nil, nil, fmt.Errorf("query %q in --queries-file max_source_resolution is invalid: %w", q.Name, err)
+			}
+		}
+
+		if q.Timeout < 0 || q.LookbackDelta < 0 {
+			return nil, nil, fmt.Errorf("query %q in --queries-file timeout and lookback_delta cannot be negative", q.Name)
+		}
+
+		// Loki has neither parameter, its queries time out and look back by its configuration.
+		if (q.Timeout > 0 || q.LookbackDelta > 0) && endpointType == options.LogsEndpointType {
+			return nil, nil, fmt.Errorf("query %q in --queries-file timeout and lookback_delta are only supported for metrics", q.Name)
+		}
+
+		// Plain queries against logs are run as LogQL queries with Loki's defaults.
+		if endpointType == options.LogsEndpointType {
+			queries = append(queries, options.LogQLSpecFromQuerySpec(q))
+			continue
+		}
+
+		queries = append(queries, q)
+	}
+
+	for _, q := range qf.Series {
+		if len(q.Matchers) == 0 {
+			return nil, nil, fmt.Errorf("series query %q in --queries-file matchers cannot be empty", q.Name)
+		}
+
+		if len(q.Matchers) > 0 {
+			for _, s := range q.Matchers {
+				if _, err := parser.ParseMetricSelector(s); err != nil {
+					return nil, nil, fmt.Errorf("series query %q in --queries-file matchers are invalid: %w", q.Name, err)
+				}
+			}
+		}
+
+		queries = append(queries, q)
+	}
+
+	for _, q := range qf.Labels {
+		if len(q.Label) > 0 && !model.LabelNameRE.MatchString(q.Label) {
+			return nil, nil, fmt.Errorf("label_values query %q in --queries-file label is invalid: %w", q.Name, err)
+		}
+
+		queries = append(queries, q)
+	}
+
+	for _, q := range qf.Absent {
+		if _, err := parser.ParseExpr(q.Query); err != nil {
+			return nil, nil, fmt.Errorf("absent query %q in --queries-file content is invalid: %w", q.Name, err)
+		}
+
+		queries = append(queries, q)
+	}
+
+	if (len(qf.LogQL) > 0 || len(qf.LogsLabels) > 0 || len(qf.LogsSeries) > 0 || len(qf.LogsIndexStats) > 0 ||
+		len(qf.LogsVolume) > 0 || len(qf.LogsFields) > 0 || len(qf.LogsPatterns) > 0) && endpointType != options.LogsEndpointType {
+		return nil, nil, fmt.Errorf("logql, logs_labels, logs_series, logs_index_stats, logs_volume, logs_detected_fields " +
+			"and logs_patterns queries in --queries-file require --endpoint-type=logs")
+	}
+
+	for _, q := range qf.LogQL {
+		if err := logs.ValidateQuery(q.Query); err != nil {
+			return nil, nil, fmt.Errorf("logql query %q in --queries-file content is invalid: %w", q.Name, err)
+		}
+
+		switch q.Direction {
+		case "", options.DirectionForward, options.DirectionBackward:
+		default:
+			return nil, nil, fmt.Errorf("logql query %q in --queries-file direction %q is invalid", q.Name, q.Direction)
+		}
+
+		if q.Limit < 0 {
+			return nil, nil, fmt.Errorf("logql query %q in --queries-file limit cannot be negative", q.Name)
+		}
+
+		queries = append(queries, q)
+	}
+
+	for _, q := range qf.LogsLabels {
+		if len(q.Label) > 0 && !model.LabelNameRE.MatchString(q.Label) {
+			return nil, nil, fmt.Errorf("logs_labels query %q in --queries-file label is invalid", q.Name)
+		}
+
+		queries = append(queries, q)
+	}
+
+	for _, q := range qf.LogsSeries {
+		if len(q.Matchers) == 0 {
+			return nil, nil, fmt.Errorf("logs_series query %q in --queries-file matchers cannot be empty", q.Name)
+		}
+
+		for _, s := range q.Matchers {
+			if err := logs.ValidateSelector(s); err != nil {
+				return nil, nil, fmt.Errorf("logs_series query %q in --queries-file matchers are invalid: %w", q.Name, err)
+			}
+		}
+
+		queries = append(queries, q)
+	}
+
+	for _, q := range qf.LogsIndexStats {
+		if err := logs.ValidateSelector(q.Query); err != nil {
+			return nil, nil, fmt.Errorf("logs_index_stats query %q in --queries-file content is invalid: %w", q.Name, err)
+		}
+
+		queries = append(queries, q)
+	}
+
+	for _, q := range qf.LogsVolume {
+		if err := logs.ValidateSelector(q.Query); err != nil {
+			return nil, nil, fmt.Errorf("logs_volume query %q in --queries-file content is invalid: %w", q.Name, err)
+		}
+
+		switch q.AggregateBy {
+		case "", options.AggregateBySeries, options.AggregateByLabels:
+		default:
+			return nil, nil, fmt.Errorf("logs_volume query %q in --queries-file aggregate_by %q is invalid", q.Name, q.AggregateBy)
+		}
+
+		for _, l := range q.TargetLabels {
+			if !model.LabelName(l).IsValid() {
+				return nil, nil, fmt.Errorf("logs_volume query %q in --queries-file target label %q is invalid", q.Name, l)
+			}
+		}
+
+		if q.Limit < 0 {
+			return nil, nil, fmt.Errorf("logs_volume query %q in --queries-file limit cannot be negative", q.Name)
+		}
+
+		queries = append(queries, q)
+	}
+
+	for _, q := range qf.LogsFields {
+		if err := logs.ValidateQuery(q.Query); err != nil {
+			return nil, nil, fmt.Errorf("logs_detected_fields query %q in --queries-file content is invalid: %w", q.Name, err)
+		}
+
+		if q.Limit < 0 {
+			return nil, nil, fmt.Errorf("logs_detected_fields query %q in --queries-file limit cannot be negative", q.Name)
+		}
+
+		queries = append(queries, q)
+	}
+
+	for _, q := range qf.LogsPatterns {
+		if err := logs.ValidateQuery(q.Query); err != nil {
+			return nil, nil, fmt.Errorf("logs_patterns query %q in --queries-file content is invalid: %w", q.Name, err)
+		}
+
+		queries = append(queries, q)
+	}
+
+	for _, q := range queries {
+		if s := q.GetCommon().ExpectStatus; s != 0 && (s < 100 || s > 599) {
+			return nil, nil, fmt.Errorf("query %q in --queries-file expect_status %d is invalid", q.GetName(), s)
+		}
 
-						} else {
-							level.Debug(l).Log("msg", "successfully executed specified query",
-								"type", queryType,
-								"name", name,
-								"duration", duration,
-								"warnings", fmt.Sprintf("%#+v", warn),
-							)
-
-							m.CustomQueryLastDuration.WithLabelValues(queryType, name, strconv.Itoa(httpCode)).Set(duration)
-						}
-						if httpCode != 0 {
-							m.CustomQueryExecuted.WithLabelValues(queryType, name, strconv.Itoa(httpCode)).Inc()
-							m.CustomQueryRequestDuration.WithLabelValues(queryType, name, strconv.Itoa(httpCode)).Observe(duration)
-						}
-					}
-					time.Sleep(timeoutBetweenQueries)
-				}
-				time.Sleep(timeoutBetweenQueries)
+		for k, v := range q.GetCommon().Headers {
+			if !httpguts.ValidHeaderFieldName(k) || !httpguts.ValidHeaderFieldValue(v) {
+				return nil, nil, fmt.Errorf("query %q in --queries-file header %q is invalid", q.GetName(), k)
 			}
 		}
-	}, func(_ error) {
-		cancel()
-	})
-}
+	}
 
-func runPeriodically(ctx context.Context, opts options.Options, c *prometheus.CounterVec, l log.Logger, ch chan error,
-	f func(rCtx context.Context)) error {
-	var (
-		t        = time.NewTicker(opts.Period)
-		deadline time.Time
-		rCtx     context.Context
-		rCancel  context.CancelFunc
-	)
+	if (len(qf.Targets) > 0 || len(qf.Rules) > 0 || len(qf.Alerts) > 0) && endpointType != options.MetricsEndpointType {
+		return nil, nil, fmt.Errorf("targets, rules and alerts queries in --queries-file require --endpoint-type=metrics")
+	}
 
-	for {
-		select {
-		case <-t.C:
-			// NOTICE: Do not propagate parent context to prevent cancellation of in-flight request.
-			// It will be cancelled after the deadline.
-			deadline = time.Now().Add(opts.Period)
-			rCtx, rCancel = context.WithDeadline(context.Background(), deadline)
-
-			// Will only get scheduled once per period and guaranteed to get cancelled after deadline.
-			go func() {
-				defer rCancel() // Make sure context gets cancelled even if execution panics.
-
-				f(rCtx)
-			}()
-		case <-ctx.Done():
-			t.Stop()
-
-			if rCtx != nil {
-				select {
-				// If it gets immediately cancelled, zero value of deadline won't cause a lock!
-				case <-time.After(time.Until(deadline)):
-					rCancel()
-				case <-rCtx.Done():
-				}
-			}
+	for _, q := range qf.Targets {
+		switch q.State {
+		case "", options.TargetStateActive, options.TargetStateDropped, options.TargetStateAny:
+		default:
+			return nil, nil, fmt.Errorf("targets query %q in --queries-file state %q is invalid", q.Name, q.State)
+		}
+
+		queries = append(queries, q)
+	}
 
-			return reportResults(l, ch, c, opts.SuccessThreshold)
+	for _, q := range qf.Rules {
+		switch q.Type {
+		case "", options.RuleTypeAlert, options.RuleTypeRecord:
+		default:
+			return nil, nil, fmt.Errorf("rules query %q in --queries-file type %q is invalid", q.Name, q.Type)
 		}
+
+		queries = append(queries, q)
+	}
+
+	for _, q := range qf.Alerts {
+		queries = append(queries, q)
 	}
-}
 
-func reportResults(l log.Logger, ch chan error, c *prometheus.CounterVec, threshold float64) error {
-	metrics := make(chan prometheus.Metric, numOfEndpoints)
-	c.Collect(metrics)
-	close(metrics)
+	if len(qf.Diffs) > 0 && endpointType != options.MetricsEndpointType {
+		return nil, nil, fmt.Errorf("diff queries in --queries-file require --endpoint-type=metrics")
+	}
 
-	var success, failures float64
+	for _, q := range qf.Diffs {
+		if _, err := parser.ParseExpr(q.Query); err != nil {
+			return nil, nil, fmt.Errorf("diff query %q in --queries-file content is invalid: %w", q.Name, err)
+		}
 
-	for m := range metrics {
-		m1 := &dto.Metric{}
-		if err := m.Write(m1); err != nil {
-			level.Warn(l).Log("msg", "cannot read success and error count from prometheus counter", "err", err)
+		if len(q.Endpoints) != 2 {
+			return nil, nil, fmt.Errorf("diff query %q in --queries-file must have two endpoints", q.Name)
 		}
 
-		for _, l := range m1.Label {
-			switch *l.Value {
-			case labelError:
-				failures = m1.GetCounter().GetValue()
-			case labelSuccess:
-				success = m1.GetCounter().GetValue()
+		for _, e := range q.Endpoints {
+			if _, err := url.ParseRequestURI(e); err != nil {
+				return nil, nil, fmt.Errorf("diff query %q in --queries-file endpoint is invalid: %w", q.Name, err)
 			}
 		}
+
+		if q.Tolerance < 0 {
+			return nil, nil, fmt.Errorf("diff query %q in --queries-file tolerance cannot be negative", q.Name)
+		}
+
+		queries = append(queries, q)
 	}
 
-	level.Info(l).Log("msg", "number of requests", "success", success, "errors", failures)
+	names := map[string]struct{}{}
 
-	ratio := success / (success + failures)
-	if ratio < threshold {
-		level.Error(l).Log("msg", "ratio is below threshold")
+	for _, sc := range qf.Scenarios {
+		if err := sc.Validate(endpointType); err != nil {
+			return nil, nil, fmt.Errorf("scenario %q in --queries-file is invalid: %w", sc.Name, err)
+		}
 
-		err := errors.Errorf("failed with less than %2.f%% success ratio - actual %2.f%%", threshold*100, ratio*100)
-		ch <- err
+		if _, ok := names[sc.Name]; ok {
+			return nil, nil, fmt.Errorf("scenario %q in --queries-file is defined more than once", sc.Name)
+		}
 
-		return err
+		names[sc.Name] = struct{}{}
 	}
 
-	return nil
+	// A token file that cannot be read would otherwise only fail the query once it is run.
+	for _, q := range queries {
+		if c := q.GetCommon(); c.TokenFile != "" {
+			if _, err := c.EffectiveToken(nil).Get(); err != nil {
+				return nil, nil, fmt.Errorf("query %q in --queries-file token_file is invalid: %w", q.GetName(), err)
+			}
+		}
+	}
+
+	l.Log("msg", fmt.Sprintf("%d queries and %d scenarios configured to be run periodically", len(queries), len(qf.Scenarios)))
+
+	return queries, qf.Scenarios, nil
 }
 
-// Helpers
+// parseLogsVolume validates the flags generating log lines. Lines are generated from the default template
+// if only their volume is configured.
+func parseLogsVolume(opts *options.Options) error {
+	// Static lines are deduplicated by Loki once pushed repeatedly, so reading back ranges requires generated lines.
+	generated := opts.LogsLineSize > 0 || opts.LogsLinesPerPush > 1 || opts.LogsStreamsPerPush > 1 ||
+		(opts.ReadMode == options.RangeReadMode && opts.EndpointType == options.LogsEndpointType)
 
-func parseFlags(l log.Logger) (options.Options, error) {
-	var (
-		rawEndpointType  string
-		rawWriteEndpoint string
-		rawReadEndpoint  string
-		rawLogLevel      string
-		queriesFileName  string
-		logsFileName     string
-		tokenFile        string
-		token            string
-	)
+	if (opts.LogsTemplate != "" || generated) && opts.EndpointType != options.LogsEndpointType {
+		return errors.Errorf("--logs-template, --logs-line-size, --logs-lines-per-push and --logs-streams-per-push " +
+			"are only supported for logs")
+	}
 
-	opts := options.Options{}
+	if (opts.LogsTemplate != "" || generated) && (len(opts.Logs) > 0 || opts.LogsFile != "") {
+		return errors.Errorf("generated log lines cannot be used with --logs or --logs-file")
+	}
 
-	flag.StringVar(&rawLogLevel, "log.level", "info", "The log filtering level. Options: 'error', 'warn', 'info', 'debug'.")
-	flag.StringVar(&rawEndpointType, "endpoint-type", "metrics", "The endpoint type. Options: 'logs', 'metrics'.")
-	flag.StringVar(&rawWriteEndpoint, "endpoint-write", "", "The endpoint to which to make remote-write requests.")
-	flag.StringVar(&rawReadEndpoint, "endpoint-read", "", "The endpoint to which to make query requests.")
-	flag.Var(&opts.Labels, "labels", "The labels in addition to '__name__' that should be applied to remote-write requests.")
-	flag.StringVar(&opts.Listen, "listen", ":8080", "The address on which internal server runs.")
-	flag.Var(&opts.Logs, "logs", "The logs that should be sent to remote-write requests.")
-	flag.StringVar(&logsFileName, "logs-file", "", "A file containing logs to send against the logs write endpoint.")
-	flag.StringVar(&opts.Name, "name", "up", "The name of the metric to send in remote-write requests.")
-	flag.StringVar(&token, "token", "",
-		"The bearer token to set in the authorization header on requests. Takes predence over --token-file if set.")
-	flag.StringVar(&tokenFile, "token-file", "",
-		"The file from which to read a bearer token to set in the authorization header on requests.")
-	flag.StringVar(&queriesFileName, "queries-file", "", "A file containing queries to run against the read endpoint.")
-	flag.DurationVar(&opts.Period, "period", 5*time.Second, "The time to wait between remote-write requests.")
-	flag.DurationVar(&opts.Duration, "duration", 5*time.Minute,
-		"The duration of the up command to run until it stops. If 0 it will not stop until the process is terminated.")
-	flag.Float64Var(&opts.SuccessThreshold, "threshold", 0.9, "The percentage of successful requests needed to succeed overall. 0 - 1.")
-	flag.DurationVar(&opts.Latency, "latency", 15*time.Second,
-		"The maximum allowable latency between writing and reading.")
-	flag.DurationVar(&opts.InitialQueryDelay, "initial-query-delay", 10*time.Second,
-		"The time to wait before executing the first query.")
-	flag.DurationVar(&opts.DefaultStep, "step", 5*time.Minute, "Default step duration for range queries. "+
-		"Can be overridden if step is set in query spec.")
+	if opts.LogsChurnInterval > 0 && opts.EndpointType != options.LogsEndpointType {
+		return errors.Errorf("--logs-churn-interval is only supported for logs")
+	}
 
-	flag.StringVar(&opts.TLS.Cert, "tls-client-cert-file", "",
-		"File containing the default x509 Certificate for HTTPS. Leave blank to disable TLS.")
-	flag.StringVar(&opts.TLS.Key, "tls-client-private-key-file", "",
-		"File containing the default x509 private key matching --tls-cert-file. Leave blank to disable TLS.")
-	flag.StringVar(&opts.TLS.CACert, "tls-ca-file", "",
-		"File containing the TLS CA to use against servers for verification. If no CA is specified, there won't be any verification.")
-	flag.StringVar(&opts.TenantHeader, "tenant-header", "tenant_id",
-		"Name of HTTP header used to determine tenant for write requests.")
-	flag.StringVar(&opts.Tenant, "tenant", "", "Tenant ID to used to determine tenant for write requests.")
-	flag.Parse()
+	if opts.LogsChurnInterval > 0 && opts.ReadMode == options.RangeReadMode {
+		return errors.Errorf("--logs-churn-interval is not supported with the range read mode")
+	}
 
-	return buildOptionsFromFlags(
-		l, opts, rawLogLevel, rawEndpointType, rawWriteEndpoint, rawReadEndpoint, queriesFileName, logsFileName, token, tokenFile,
-	)
-}
+	if len(opts.LogsTenants) > 0 && opts.EndpointType != options.LogsEndpointType {
+		return errors.Errorf("--logs-tenants is only supported for logs")
+	}
 
-func buildOptionsFromFlags(
-	l log.Logger,
-	opts options.Options,
-	rawLogLevel, rawEndpointType, rawWriteEndpoint, rawReadEndpoint, queriesFileName, logsFileName, token, tokenFile string,
-) (options.Options, error) {
-	var err error
+	if len(opts.LogsTenants) > 0 && opts.Tenant != "" {
+		return errors.Errorf("--logs-tenants cannot be used with --tenant")
+	}
 
-	err = parseLogLevel(&opts, rawLogLevel)
-	if err != nil {
-		return opts, errors.Wrap(err, "parsing log level")
+	// Every tenant only receives some of the pushes, so ranges of a tenant are never complete.
+	if len(opts.LogsTenants) > 0 && opts.ReadMode == options.RangeReadMode {
+		return errors.Errorf("--logs-tenants is not supported with the range read mode")
 	}
 
-	err = parseEndpointType(&opts, rawEndpointType)
-	if err != nil {
-		return opts, errors.Wrap(err, "parsing endpoint type")
+	if opts.LogsLineSize < 0 {
+		return errors.Errorf("--logs-line-size cannot be negative")
 	}
 
-	err = parseWriteEndpoint(&opts, l, rawWriteEndpoint)
-	if err != nil {
-		return opts, errors.Wrap(err, "parsing write endpoint")
+	if opts.LogsLinesPerPush < 1 || opts.LogsStreamsPerPush < 1 {
+		return errors.Errorf("--logs-lines-per-push and --logs-streams-per-push must be at least 1")
 	}
 
-	err = parseReadEndpoint(&opts, l, rawReadEndpoint)
-	if err != nil {
-		return opts, errors.Wrap(err, "parsing read endpoint")
+	if opts.LogsTemplate == "" && generated {
+		opts.LogsTemplate = logs.DefaultLineTemplate
 	}
 
-	err = parseQueriesFileName(&opts, l, queriesFileName)
-	if err != nil {
-		return opts, errors.Wrap(err, "parsing queries file name")
+	return nil
+}
+
+func parseLogsFileName(opts *options.Options, l log.Logger, logsFileName string) error {
+	if logsFileName != "" {
+		b, err := ioutil.ReadFile(logsFileName)
+		if err != nil {
+			return fmt.Errorf("--logs-file is invalid: %w", err)
+		}
+
+		if opts.ExpandEnv {
+			if b, err = expandEnv(b); err != nil {
+				return fmt.Errorf("--logs-file is invalid: %w", err)
+			}
+		}
+
+		spec, err := parseLogs(l, b)
+		if err != nil {
+			return err
+		}
+
+		opts.Logs = spec.Logs
+		opts.LogsStreams = spec.Streams
+		opts.StructuredMetadata = spec.StructuredMetadata
+
+		opts.LogsFile = logsFileName
 	}
 
-	err = parseLogsFileName(&opts, l, logsFileName)
-	if err != nil {
-		return opts, errors.Wrap(err, "parsing logs file name")
+	return nil
+}
+
+// parseLogs parses and validates the content of a logs file.
+func parseLogs(l log.Logger, b []byte) (options.LogsSpec, error) {
+	lf := logsFile{}
+	if err := yaml.Unmarshal(b, &lf); err != nil { //nolint:typecheck
+		return lf.Spec, fmt.Errorf("--logs-file content is invalid: %w", err)
 	}
 
-	if opts.Latency <= opts.Period {
-		return opts, errors.Errorf("--latency cannot be less than period")
+	for k := range lf.Spec.StructuredMetadata {
+		if !model.LabelName(k).IsValid() {
+			return lf.Spec, fmt.Errorf("--logs-file structured metadata name %q is invalid", k)
+		}
 	}
 
-	opts.Labels = append(opts.Labels, prompb.Label{
-		Name:  "__name__",
-		Value: opts.Name,
-	})
-	// We need to ensure labels are sorted before we proceed.
-	opts.Labels.Sort()
+	if err := validateStreams(lf.Spec); err != nil {
+		return lf.Spec, err
+	}
 
-	opts.Token = tokenProvider(token, tokenFile)
+	if len(lf.Spec.Streams) > 0 {
+		l.Log("msg", fmt.Sprintf("%d log streams configured to be written periodically", len(lf.Spec.Streams)))
+		return lf.Spec, nil
+	}
 
-	return opts, err
+	l.Log("msg", fmt.Sprintf("%d logs configured to be written periodically", len(lf.Spec.Logs)))
+
+	return lf.Spec, nil
 }
 
-func parseLogLevel(opts *options.Options, rawLogLevel string) error {
-	switch rawLogLevel {
-	case "error":
-		opts.LogLevel = level.AllowError()
-	case "warn":
-		opts.LogLevel = level.AllowWarn()
-	case "info":
-		opts.LogLevel = level.AllowInfo()
-	case "debug":
-		opts.LogLevel = level.AllowDebug()
-	default:
-		return errors.Errorf("unexpected log level")
+// validateStreams returns an error if the streams of the logs file cannot be told apart when reading them back.
+func validateStreams(spec options.LogsSpec) error {
+	if len(spec.Streams) > 0 && len(spec.Logs) > 0 {
+		return fmt.Errorf("--logs-file cannot contain both logs and streams")
 	}
 
-	return nil
-}
+	seen := map[string]struct{}{}
 
-func parseEndpointType(opts *options.Options, rawEndpointType string) error {
-	switch options.EndpointType(rawEndpointType) {
-	case options.LogsEndpointType:
-		opts.EndpointType = options.LogsEndpointType
-	case options.MetricsEndpointType:
-		opts.EndpointType = options.MetricsEndpointType
-	default:
-		return errors.Errorf("unexpected endpoint type")
+	for i, s := range spec.Streams {
+		if len(s.Labels) == 0 {
+			return fmt.Errorf("--logs-file stream %d must have labels", i)
+		}
+
+		if len(s.Logs) == 0 {
+			return fmt.Errorf("--logs-file stream %d must have logs", i)
+		}
+
+		for k := range s.Labels {
+			if !model.LabelName(k).IsValid() {
+				return fmt.Errorf("--logs-file stream %d label name %q is invalid", i, k)
+			}
+		}
+
+		key := logs.Selector(logs.StreamLabels(nil, s.Labels))
+		if _, ok := seen[key]; ok {
+			return fmt.Errorf("--logs-file stream %d has the same labels as another stream", i)
+		}
+
+		seen[key] = struct{}{}
 	}
 
 	return nil
 }
 
-func parseWriteEndpoint(opts *options.Options, l log.Logger, rawWriteEndpoint string) error {
-	if rawWriteEndpoint != "" {
-		writeEndpoint, err := url.ParseRequestURI(rawWriteEndpoint)
+func parseBaselineFileName(opts *options.Options, baselineFileName string) error {
+	if baselineFileName != "" {
+		s, err := report.ReadFile(baselineFileName)
 		if err != nil {
-			return fmt.Errorf("--endpoint-write is invalid: %w", err)
+			return fmt.Errorf("--baseline-file is invalid: %w", err)
 		}
 
-		opts.WriteEndpoint = writeEndpoint
-	} else {
-		l.Log("msg", "no write endpoint specified, no write tests being performed")
+		opts.Baseline = &s
 	}
 
 	return nil
 }
 
-func parseReadEndpoint(opts *options.Options, l log.Logger, rawReadEndpoint string) error {
-	if rawReadEndpoint != "" {
-		readEndpoint, err := url.ParseRequestURI(rawReadEndpoint)
-		if err != nil {
-			return fmt.Errorf("--endpoint-read is invalid: %w", err)
+func parseLabelsFileName(opts *options.Options, labelsFileName string) error {
+	if labelsFileName == "" {
+		return nil
+	}
+
+	b, _, err := readFile(context.Background(), labelsFileName, "")
+	if err != nil {
+		return fmt.Errorf("--labels-file is invalid: %w", err)
+	}
+
+	if opts.ExpandEnv {
+		if b, err = expandEnv(b); err != nil {
+			return fmt.Errorf("--labels-file is invalid: %w", err)
 		}
+	}
 
-		opts.ReadEndpoint = readEndpoint
-	} else {
-		l.Log("msg", "no read endpoint specified, no read tests being performed")
+	// JSON is valid YAML, so both are parsed alike.
+	labels := map[string]string{}
+	if err := yaml.Unmarshal(b, &labels); err != nil { //nolint:typecheck
+		return fmt.Errorf("--labels-file content is invalid: %w", err)
+	}
+
+	if err := opts.Labels.Merge(labels); err != nil {
+		return fmt.Errorf("--labels-file content is invalid: %w", err)
 	}
 
 	return nil
 }
 
-func parseQueriesFileName(opts *options.Options, l log.Logger, queriesFileName string) error {
-	if queriesFileName != "" {
-		b, err := ioutil.ReadFile(queriesFileName)
+func parseInstance(opts *options.Options) error {
+	if opts.Instance.Label == "" {
+		return nil
+	}
+
+	if opts.Instance.ID == "" {
+		hostname, err := os.Hostname()
 		if err != nil {
-			return fmt.Errorf("--queries-file is invalid: %w", err)
+			return fmt.Errorf("--instance-id is not set and the hostname is unknown: %w", err)
 		}
 
-		qf := CallsFile{}
-		err = yaml.Unmarshal(b, &qf) //nolint:typecheck
+		opts.Instance.ID = hostname
+	}
 
-		if err != nil {
-			return fmt.Errorf("--queries-file content is invalid: %w", err)
+	// The other labels take precedence, the metrics of up are labeled like the written series.
+	if err := opts.Labels.Merge(map[string]string{opts.Instance.Label: opts.Instance.ID}); err != nil {
+		return fmt.Errorf("--instance-label is invalid: %w", err)
+	}
+
+	for _, l := range opts.Labels {
+		if l.Name == opts.Instance.Label {
+			opts.Instance.ID = l.Value
 		}
+	}
 
-		l.Log("msg", fmt.Sprintf("%d queries configured to be queried periodically", len(qf.Queries)))
+	return nil
+}
 
-		// validate queries
-		for _, q := range qf.Queries {
-			_, err = parser.ParseExpr(q.Query)
-			if err != nil {
-				return fmt.Errorf("query %q in --queries-file content is invalid: %w", q.Name, err)
-			}
+func parseEndpointsFileName(opts *options.Options, endpointsFileName string) error {
+	if endpointsFileName == "" {
+		return nil
+	}
+
+	b, _, err := readFile(context.Background(), endpointsFileName, "")
+	if err != nil {
+		return fmt.Errorf("--endpoints-file is invalid: %w", err)
+	}
 
-			opts.Queries = append(opts.Queries, q)
+	if opts.ExpandEnv {
+		if b, err = expandEnv(b); err != nil {
+			return fmt.Errorf("--endpoints-file is invalid: %w", err)
 		}
+	}
 
-		for _, q := range qf.Series {
-			if len(q.Matchers) == 0 {
-				return fmt.Errorf("series query %q in --queries-file matchers cannot be empty", q.Name)
-			}
+	es := options.EndpointsSpec{}
+	if err := yaml.UnmarshalStrict(b, &es); err != nil { //nolint:typecheck
+		return fmt.Errorf("--endpoints-file content is invalid: %w", err)
+	}
 
-			if len(q.Matchers) > 0 {
-				for _, s := range q.Matchers {
-					if _, err := parser.ParseMetricSelector(s); err != nil {
-						return fmt.Errorf("series query %q in --queries-file matchers are invalid: %w", q.Name, err)
-					}
-				}
-			}
+	for name, e := range map[string]*options.EndpointSpec{"write": es.Write, "read": es.Read, "store": es.Store} {
+		if e == nil {
+			continue
+		}
 
-			opts.Queries = append(opts.Queries, q)
+		if e.TLS != nil && (e.TLS.Cert == "") != (e.TLS.Key == "") {
+			return fmt.Errorf("--endpoints-file %s endpoint TLS cert_file and key_file must be set together", name)
 		}
 
-		for _, q := range qf.Labels {
-			if len(q.Label) > 0 && !model.LabelNameRE.MatchString(q.Label) {
-				return fmt.Errorf("label_values query %q in --queries-file label is invalid: %w", q.Name, err)
+		for k, v := range e.Headers {
+			if !httpguts.ValidHeaderFieldName(k) || !httpguts.ValidHeaderFieldValue(v) {
+				return fmt.Errorf("--endpoints-file %s endpoint header %q is invalid", name, k)
 			}
+		}
 
-			opts.Queries = append(opts.Queries, q)
+		if e.ProxyURL != "" {
+			if err := validateProxyURL(fmt.Sprintf("--endpoints-file %s endpoint proxy_url", name), e.ProxyURL); err != nil {
+				return err
+			}
 		}
 	}
 
+	// The store endpoint is requested over gRPC, without HTTP headers and proxies.
+	if es.Store != nil && len(es.Store.Headers) > 0 {
+		return errors.Errorf("--endpoints-file store endpoint cannot have headers")
+	}
+
+	if es.Store != nil && es.Store.ProxyURL != "" {
+		return errors.Errorf("--endpoints-file store endpoint cannot have a proxy_url")
+	}
+
+	opts.Endpoints = es
+
 	return nil
 }
 
-func parseLogsFileName(opts *options.Options, l log.Logger, logsFileName string) error {
-	if logsFileName != "" {
-		b, err := ioutil.ReadFile(logsFileName)
-		if err != nil {
-			return fmt.Errorf("--logs-file is invalid: %w", err)
+func parseTenantsFileName(opts *options.Options, l log.Logger, tenantsFileName string) error {
+	if tenantsFileName == "" {
+		return nil
+	}
+
+	b, _, err := readFile(context.Background(), tenantsFileName, "")
+	if err != nil {
+		return fmt.Errorf("--tenants-file is invalid: %w", err)
+	}
+
+	if opts.ExpandEnv {
+		if b, err = expandEnv(b); err != nil {
+			return fmt.Errorf("--tenants-file is invalid: %w", err)
 		}
+	}
 
-		lf := logsFile{}
-		err = yaml.Unmarshal(b, &lf) //nolint:typecheck
+	tf := tenantsFile{}
+	if err := yaml.Unmarshal(b, &tf); err != nil { //nolint:typecheck
+		return fmt.Errorf("--tenants-file content is invalid: %w", err)
+	}
 
-		if err != nil {
-			return fmt.Errorf("--logs-file content is invalid: %w", err)
+	if len(tf.Tenants) == 0 {
+		return fmt.Errorf("--tenants-file must contain at least one tenant")
+	}
+
+	if opts.Tenant != "" || len(opts.LogsTenants) > 0 {
+		return errors.Errorf("--tenants-file cannot be used with --tenant or --logs-tenants")
+	}
+
+	names := map[string]struct{}{}
+
+	for _, t := range tf.Tenants {
+		if t.Name == "" {
+			return fmt.Errorf("--tenants-file tenant must have a name")
+		}
+
+		if _, ok := names[t.Name]; ok {
+			return fmt.Errorf("tenant %q in --tenants-file is defined more than once", t.Name)
 		}
 
-		l.Log("msg", fmt.Sprintf("%d logs configured to be written periodically", len(lf.Spec.Logs)))
+		names[t.Name] = struct{}{}
+
+		for k := range t.Labels {
+			if !model.LabelName(k).IsValid() || k == model.MetricNameLabel {
+				return fmt.Errorf("--tenants-file tenant %q label name %q is invalid", t.Name, k)
+			}
+		}
 
-		opts.Logs = lf.Spec.Logs
+		if t.Threshold != nil && (*t.Threshold < 0 || *t.Threshold > 1) {
+			return fmt.Errorf("--tenants-file tenant %q threshold must be between 0 and 1", t.Name)
+		}
 	}
 
+	l.Log("msg", fmt.Sprintf("%d tenants configured to write and read periodically", len(tf.Tenants)))
+
+	opts.Tenants = tf.Tenants
+
 	return nil
 }
 
@@ -630,16 +2571,18 @@ func tokenProvider(token, tokenFile string) auth.TokenProvider {
 	return res
 }
 
-func scheduleHTTPServer(l log.Logger, opts options.Options, reg *prometheus.Registry, g *run.Group) {
+func scheduleHTTPServer(l log.Logger, opts options.Options, cfg *liveConfig, rd *readiness, st *statuses, reg *prometheus.Registry,
+	r prometheus.Registerer, g *run.Group, reloads chan<- chan error) {
 	logger := log.With(l, "component", "http")
-	router := http.NewServeMux()
-	router.Handle("/metrics", promhttp.InstrumentMetricHandler(reg, promhttp.HandlerFor(reg, promhttp.HandlerOpts{})))
-	router.HandleFunc("/debug/pprof/", pprof.Index)
-
-	srv := &http.Server{Addr: opts.Listen, Handler: router}
+	srv := &http.Server{Addr: opts.Listen, Handler: newRouter(opts, cfg, rd, st, reg, r, reloads)}
 
 	g.Add(func() error {
-		level.Info(logger).Log("msg", "starting the HTTP server", "address", opts.Listen)
+		level.Info(logger).Log("msg", "starting the HTTP server", "address", opts.Listen, "tls", opts.Internal.TLS.Cert != "")
+
+		if opts.Internal.TLS.Cert != "" {
+			return srv.ListenAndServeTLS(opts.Internal.TLS.Cert, opts.Internal.TLS.Key)
+		}
+
 		return srv.ListenAndServe()
 	}, func(err error) {
 		if errors.Is(err, http.ErrServerClosed) {
@@ -654,3 +2597,29 @@ func scheduleHTTPServer(l log.Logger, opts options.Options, reg *prometheus.Regi
 		}
 	})
 }
+
+// newRouter returns the handler of the internal server. All endpoints but the probes require the credentials of the
+// internal server, if configured.
+func newRouter(opts options.Options, cfg *liveConfig, rd *readiness, st *statuses, reg *prometheus.Registry,
+	r prometheus.Registerer, reloads chan<- chan error) *http.ServeMux {
+	router := http.NewServeMux()
+	router.Handle("/metrics", authenticate(opts.Internal, promhttp.InstrumentMetricHandler(r,
+		promhttp.HandlerFor(instr.FilterGatherer(reg, opts.MetricsFilter), promhttp.HandlerOpts{EnableOpenMetrics: true}))))
+	router.Handle("/debug/pprof/", authenticate(opts.Internal, http.HandlerFunc(pprof.Index)))
+
+	// The command line is not served, as it can contain secrets, e.g. of --token.
+	if opts.Profiling.Enabled {
+		router.Handle("/debug/pprof/profile", authenticate(opts.Internal, http.HandlerFunc(pprof.Profile)))
+		router.Handle("/debug/pprof/symbol", authenticate(opts.Internal, http.HandlerFunc(pprof.Symbol)))
+		router.Handle("/debug/pprof/trace", authenticate(opts.Internal, http.HandlerFunc(pprof.Trace)))
+	}
+
+	router.Handle("/-/reload", authenticate(opts.Internal, reloadHandler(reloads)))
+	router.Handle("/-/config", authenticate(opts.Internal, configHandler(opts, cfg)))
+	router.Handle("/-/status", authenticate(opts.Internal, statusHandler(st, cfg)))
+	// Probes are not authenticated, as the kubelet cannot set credentials.
+	router.HandleFunc("/-/healthy", healthyHandler)
+	router.HandleFunc("/-/ready", readyHandler(rd))
+
+	return router
+}
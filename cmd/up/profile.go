@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/go-kit/log"
+	"gopkg.in/yaml.v2"
+)
+
+// profilesFile defines named run profiles, e.g. 'smoke' or 'soak', as values of flags.
+type profilesFile struct {
+	Profiles map[string]map[string]string `yaml:"profiles"`
+}
+
+// applyProfile sets the flags of the profile of the profiles file, unless they were set on the command line,
+// which take precedence over the profile.
+func applyProfile(l log.Logger, fs *flag.FlagSet, profile, profilesFileName string, expand bool) error {
+	if profile == "" && profilesFileName == "" {
+		return nil
+	}
+
+	if profile == "" || profilesFileName == "" {
+		return fmt.Errorf("--profile and --profiles-file must be set together")
+	}
+
+	b, _, err := readFile(context.Background(), profilesFileName, "")
+	if err != nil {
+		return fmt.Errorf("--profiles-file is invalid: %w", err)
+	}
+
+	if expand {
+		if b, err = expandEnv(b); err != nil {
+			return fmt.Errorf("--profiles-file is invalid: %w", err)
+		}
+	}
+
+	pf := profilesFile{}
+	if err := yaml.Unmarshal(b, &pf); err != nil { //nolint:typecheck
+		return fmt.Errorf("--profiles-file content is invalid: %w", err)
+	}
+
+	values, ok := pf.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("profile %q is not defined in --profiles-file", profile)
+	}
+
+	set := map[string]struct{}{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = struct{}{} })
+
+	// Apply the flags in a stable order, so errors do not depend on the order of the map.
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "profile" || name == "profiles-file" {
+			return fmt.Errorf("profile %q cannot set --%s", profile, name)
+		}
+
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("profile %q sets the unknown flag --%s", profile, name)
+		}
+
+		if _, ok := set[name]; ok {
+			continue
+		}
+
+		if err := fs.Set(name, values[name]); err != nil {
+			return fmt.Errorf("profile %q sets --%s to an invalid value: %w", profile, name, err)
+		}
+	}
+
+	l.Log("msg", "applied profile", "profile", profile)
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/observatorium/up/pkg/metrics"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/observatorium/up/pkg/transport"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+const (
+	// pushTimeout bounds every push, which happens after the run when the context is canceled already.
+	pushTimeout = 30 * time.Second
+	// pushPrefix is the prefix of the metrics pushed, leaving out the Go and process metrics of up.
+	pushPrefix = "up_"
+)
+
+// pushMetrics pushes the metrics of up to the Pushgateway and writes them to the remote-write endpoint of the options.
+func pushMetrics(l log.Logger, g prometheus.Gatherer, opts options.Options) error {
+	if opts.Push.GatewayURL == nil && opts.Push.RemoteWriteURL == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+
+	if opts.Push.GatewayURL != nil {
+		if err := pushGateway(ctx, g, opts); err != nil {
+			return errors.Wrap(err, "pushing to Pushgateway")
+		}
+
+		level.Info(l).Log("msg", "metrics pushed", "url", opts.Push.GatewayURL, "job", opts.Push.Job)
+	}
+
+	if opts.Push.RemoteWriteURL != nil {
+		if err := pushRemoteWrite(ctx, l, g, opts); err != nil {
+			return errors.Wrap(err, "remote-writing metrics")
+		}
+
+		level.Info(l).Log("msg", "metrics remote-written", "url", opts.Push.RemoteWriteURL, "job", opts.Push.Job)
+	}
+
+	return nil
+}
+
+func pushGateway(ctx context.Context, g prometheus.Gatherer, opts options.Options) error {
+	prefixed := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+
+		filtered := mfs[:0]
+		for _, mf := range mfs {
+			if strings.HasPrefix(mf.GetName(), pushPrefix) {
+				filtered = append(filtered, mf)
+			}
+		}
+
+		return filtered, err
+	})
+
+	client := &http.Client{Transport: transport.InstrumentedRoundTripper(http.DefaultTransport)}
+
+	p := push.New(opts.Push.GatewayURL.String(), opts.Push.Job).Gatherer(prefixed).Client(client)
+
+	// Replicas push to their own groups, rather than replacing the metrics of each other.
+	if opts.Instance.Label != "" {
+		p = p.Grouping(opts.Instance.Label, opts.Instance.ID)
+	}
+
+	return p.PushContext(ctx)
+}
+
+func pushRemoteWrite(ctx context.Context, l log.Logger, g prometheus.Gatherer, opts options.Options) error {
+	wreq, err := metrics.GenerateFromGatherer(g, pushPrefix, []prompb.Label{{Name: "job", Value: opts.Push.Job}},
+		time.Now().UnixNano()/int64(time.Millisecond))
+	if err != nil {
+		return err
+	}
+
+	_, err = metrics.Write(ctx, opts.Push.RemoteWriteURL, opts.Token, wreq, l, opts.TLS, opts.TenantHeader, opts.Tenant)
+
+	return err
+}
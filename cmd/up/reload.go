@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/kube"
+	"github.com/observatorium/up/pkg/options"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/run"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// liveConfig holds the queries, scenarios and logs read from files, which are swapped when the files are reloaded.
+type liveConfig struct {
+	mtx       sync.RWMutex
+	queries   []options.Query
+	scenarios []options.ScenarioSpec
+	logs      [][]string
+	streams   []options.LogsStream
+	metadata  map[string]string
+}
+
+func newLiveConfig(opts options.Options) *liveConfig {
+	return &liveConfig{
+		queries:   opts.Queries,
+		scenarios: opts.Scenarios,
+		logs:      opts.Logs,
+		streams:   opts.LogsStreams,
+		metadata:  opts.StructuredMetadata,
+	}
+}
+
+func (c *liveConfig) Queries() []options.Query {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	return c.queries
+}
+
+func (c *liveConfig) Scenarios() []options.ScenarioSpec {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	return c.scenarios
+}
+
+func (c *liveConfig) Logs() [][]string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	return c.logs
+}
+
+func (c *liveConfig) Streams() []options.LogsStream {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	return c.streams
+}
+
+func (c *liveConfig) StructuredMetadata() map[string]string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	return c.metadata
+}
+
+func (c *liveConfig) setQueries(queries []options.Query, scenarios []options.ScenarioSpec) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.queries = queries
+	c.scenarios = scenarios
+}
+
+func (c *liveConfig) setLogs(spec options.LogsSpec) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.logs = spec.Logs
+	c.streams = spec.Streams
+	c.metadata = spec.StructuredMetadata
+}
+
+// remoteFileTimeout is the timeout for fetching a remote file.
+const remoteFileTimeout = 30 * time.Second
+
+// isRemoteFile returns whether the file name is an HTTP(S) URL.
+func isRemoteFile(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// readFile reads a local file or fetches a remote one, either over HTTP(S) or from a key of a Kubernetes ConfigMap
+// or Secret. For remote files, a non-empty etag, or resource version, is sent as If-None-Match and nil content
+// is returned if the file was not modified.
+func readFile(ctx context.Context, name, etag string) ([]byte, string, error) {
+	if o, ok, err := kube.ParseObject(name); ok {
+		if err != nil {
+			return nil, "", err
+		}
+
+		c, err := kube.NewInClusterClient(log.NewNopLogger())
+		if err != nil {
+			return nil, "", err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, remoteFileTimeout)
+		defer cancel()
+
+		return c.Get(ctx, o, etag)
+	}
+
+	if !isRemoteFile(name) {
+		b, err := ioutil.ReadFile(name)
+		return b, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, remoteFileTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "creating request")
+	}
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "making request")
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		return nil, etag, nil
+	case http.StatusOK:
+	default:
+		return nil, "", errors.Errorf("non-200 status: %s", res.Status)
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "reading response body")
+	}
+
+	return b, res.Header.Get("ETag"), nil
+}
+
+// watchedFile is a configuration file applied again whenever its content changes.
+type watchedFile struct {
+	flag    string
+	name    string
+	etag    string
+	content []byte
+	// expandEnv expands environment variables in the content before applying it.
+	expandEnv bool
+	apply     func(b []byte) error
+	// err is the error applying the current content, which is kept until the content changes.
+	err error
+}
+
+// reload applies the content of the file if it changed. It returns the error reading the file or applying its
+// current content, in which case the previous configuration is kept.
+func (f *watchedFile) reload(ctx context.Context, l log.Logger, m instr.Metrics) error {
+	b, etag, err := readFile(ctx, f.name, f.etag)
+	if err != nil {
+		m.ConfigReloads.WithLabelValues(f.flag, labelError).Inc()
+		level.Error(l).Log("msg", "failed to read file for reload", "file", f.name, "err", err)
+
+		return errors.Wrapf(err, "reading --%s", f.flag)
+	}
+
+	f.etag = etag
+
+	if b == nil || bytes.Equal(b, f.content) {
+		return f.err
+	}
+
+	// Remember the content even if it is invalid, so the error is logged once per change.
+	f.content = b
+
+	if f.expandEnv {
+		b, err = expandEnv(b)
+	}
+
+	if err == nil {
+		err = f.apply(b)
+	}
+
+	if err != nil {
+		f.err = errors.Wrapf(err, "applying --%s", f.flag)
+
+		m.ConfigReloads.WithLabelValues(f.flag, labelError).Inc()
+		level.Error(l).Log("msg", "failed to reload file, keeping the previous configuration", "file", f.name, "err", err)
+
+		return f.err
+	}
+
+	f.err = nil
+
+	m.ConfigReloads.WithLabelValues(f.flag, labelSuccess).Inc()
+	level.Info(l).Log("msg", "reloaded file", "file", f.name)
+
+	return nil
+}
+
+// reloadHandler serves POST requests reloading the configuration files through the reloader, which responds
+// with the error of a file that failed to reload.
+func reloadHandler(reloads chan<- chan error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			http.Error(w, "only POST and PUT requests reload the configuration", http.StatusMethodNotAllowed)
+			return
+		}
+
+		errc := make(chan error, 1)
+
+		select {
+		case reloads <- errc:
+		case <-r.Context().Done():
+			return
+		}
+
+		select {
+		case err := <-errc:
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		case <-r.Context().Done():
+		}
+	}
+}
+
+// configHandler serves the effective configuration as YAML, with secrets redacted and the currently loaded
+// queries and scenarios.
+func configHandler(opts options.Options, cfg *liveConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts.Queries, opts.Scenarios = cfg.Queries(), cfg.Scenarios()
+
+		c, err := opts.Config()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		b, err := yaml.Marshal(c)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(b) //nolint:errcheck
+	}
+}
+
+// addConfigReloaderRunGroup reloads the queries and logs files on the --reload-interval, on SIGHUP, on requests
+// to the /-/reload endpoint and when a watched file changes. The token file needs no reload, as it is read for every request.
+func addConfigReloaderRunGroup(ctx context.Context, g *run.Group, l log.Logger, opts options.Options, m instr.Metrics,
+	cfg *liveConfig, reloads <-chan chan error, cancel func()) {
+	l = log.With(l, "component", "config-reloader")
+
+	var files []*watchedFile
+
+	if opts.QueriesFile != "" {
+		files = append(files, &watchedFile{flag: "queries-file", name: opts.QueriesFile, expandEnv: opts.ExpandEnv, apply: func(b []byte) error {
+			queries, scenarios, err := parseQueries(l, opts.EndpointType, b)
+			if err != nil {
+				return err
+			}
+
+			cfg.setQueries(queries, scenarios)
+
+			return nil
+		}})
+	}
+
+	if opts.LogsFile != "" {
+		files = append(files, &watchedFile{flag: "logs-file", name: opts.LogsFile, expandEnv: opts.ExpandEnv, apply: func(b []byte) error {
+			spec, err := parseLogs(l, b)
+			if err != nil {
+				return err
+			}
+
+			cfg.setLogs(spec)
+
+			return nil
+		}})
+	}
+
+	// The files were parsed at startup already, only later changes are applied.
+	for _, f := range files {
+		f.content, f.etag, _ = readFile(ctx, f.name, "")
+	}
+
+	reload := func() error {
+		var errs []string
+
+		for _, f := range files {
+			if err := f.reload(ctx, l, m); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+
+		if len(errs) > 0 {
+			return errors.New(strings.Join(errs, "; "))
+		}
+
+		return nil
+	}
+
+	// Signal chans must be buffered.
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	// Files in ConfigMaps and Secrets are watched to reload them as soon as they change.
+	changes := make(chan struct{}, 1)
+
+	g.Add(func() error {
+		level.Info(l).Log("msg", "starting the config reloader", "interval", opts.ReloadInterval)
+
+		for _, f := range files {
+			o, ok, _ := kube.ParseObject(f.name)
+			if !ok {
+				continue
+			}
+
+			c, err := kube.NewInClusterClient(l)
+			if err != nil {
+				level.Error(l).Log("msg", "failed to watch file, it is only reloaded on the interval", "file", f.name, "err", err)
+				continue
+			}
+
+			go c.Watch(ctx, l, o, func() {
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			})
+		}
+
+		var tick <-chan time.Time
+
+		if opts.ReloadInterval > 0 {
+			t := time.NewTicker(opts.ReloadInterval)
+			defer t.Stop()
+
+			tick = t.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-tick:
+				_ = reload()
+			case <-changes:
+				_ = reload()
+			case <-sighup:
+				level.Info(l).Log("msg", "caught SIGHUP, reloading the configuration")
+
+				_ = reload()
+			case errc := <-reloads:
+				errc <- reload()
+			}
+		}
+	}, func(_ error) {
+		signal.Stop(sighup)
+		cancel()
+	})
+}
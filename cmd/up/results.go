@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/observatorium/up/pkg/options"
+
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+// resultRecord is a line of the results file, describing a single execution of a custom query.
+type resultRecord struct {
+	Name            string             `json:"name"`
+	Type            string             `json:"type"`
+	Timestamp       time.Time          `json:"timestamp"`
+	DurationSeconds float64            `json:"duration_seconds"`
+	Status          string             `json:"status"`
+	HTTPCode        int                `json:"http_code,omitempty"`
+	Error           string             `json:"error,omitempty"`
+	Warnings        promapiv1.Warnings `json:"warnings,omitempty"`
+	// Result is the data of the response, cut to the configured size if Truncated is true.
+	Result    string `json:"result,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// resultsWriter appends the results of custom queries as JSON lines to a file. A nil writer discards them.
+type resultsWriter struct {
+	mtx   sync.Mutex
+	f     *os.File
+	enc   *json.Encoder
+	limit int
+}
+
+func newResultsWriter(file string, limit int) (*resultsWriter, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resultsWriter{f: f, enc: json.NewEncoder(f), limit: limit}, nil
+}
+
+// write appends the result of an execution of the query. Only the first limit bytes of the response data are kept.
+func (w *resultsWriter) write(q options.Query, start time.Time, duration float64, httpCode int,
+	warn promapiv1.Warnings, data []byte, err error) error {
+	if w == nil {
+		return nil
+	}
+
+	r := resultRecord{
+		Name:            q.GetName(),
+		Type:            q.GetType(),
+		Timestamp:       start.UTC(),
+		DurationSeconds: duration,
+		Status:          labelSuccess,
+		HTTPCode:        httpCode,
+		Warnings:        warn,
+	}
+
+	if err != nil {
+		r.Status = labelError
+		r.Error = err.Error()
+	}
+
+	if w.limit > 0 {
+		if len(data) > w.limit {
+			data, r.Truncated = data[:w.limit], true
+		}
+
+		r.Result = string(data)
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return w.enc.Encode(r)
+}
+
+func (w *resultsWriter) Close() error {
+	if w == nil {
+		return nil
+	}
+
+	return w.f.Close()
+}
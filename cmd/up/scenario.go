@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/observatorium/up/pkg/scenario"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/run"
+	"github.com/pkg/errors"
+)
+
+func addScenarioRunGroup(ctx context.Context, g *run.Group, l log.Logger, opts options.Options, m instr.Metrics,
+	cfg *liveConfig, ch chan error, cancel func()) {
+	write, read := opts.Endpoints.Write.Apply(opts), opts.Endpoints.Read.Apply(opts)
+
+	env := scenario.Env{
+		EndpointType:    opts.EndpointType,
+		WriteEndpoint:   opts.WriteEndpoint,
+		ReadEndpoint:    opts.ReadEndpoint,
+		WriteToken:      write.Token,
+		WriteTLS:        &write.TLS,
+		Token:           read.Token,
+		TLS:             read.TLS,
+		TenantHeader:    opts.TenantHeader,
+		Tenant:          opts.Tenant,
+		LogsEncoding:    opts.LogsEncoding,
+		LogsCompression: opts.LogsCompression,
+		Metrics:         m,
+	}
+
+	g.Add(func() error {
+		l := log.With(l, "component", "scenario-runner")
+		level.Info(l).Log("msg", "starting the scenario runner")
+
+		// Results are kept by scenario name, so they survive reloads of the queries file.
+		results := map[string]*queryResults{}
+
+		for {
+			for _, s := range cfg.Scenarios() {
+				err := executeScenario(ctx, l, m, s, env)
+				if ctx.Err() != nil {
+					// Runs interrupted by the end of up are not counted.
+					return reportScenarioResults(l, ch, cfg.Scenarios(), results, opts.QueriesThreshold)
+				}
+
+				r, ok := results[s.Name]
+				if !ok {
+					r = &queryResults{}
+					results[s.Name] = r
+				}
+
+				if err != nil {
+					r.failures++
+
+					if opts.FailFast {
+						return failFast(l, ch, errors.Wrapf(err, "scenario %q failed", s.Name))
+					}
+				} else {
+					r.success++
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return reportScenarioResults(l, ch, cfg.Scenarios(), results, opts.QueriesThreshold)
+			case <-time.After(opts.Period):
+			}
+		}
+	}, func(_ error) {
+		cancel()
+	})
+}
+
+// executeScenario runs a scenario once, records its metrics and returns why it failed, if so.
+func executeScenario(ctx context.Context, l log.Logger, m instr.Metrics, s options.ScenarioSpec, env scenario.Env) error {
+	t := time.Now()
+	err := scenario.Run(ctx, l, s, env)
+	duration := time.Since(t).Seconds()
+
+	if ctx.Err() != nil {
+		return err
+	}
+
+	if err != nil {
+		level.Info(l).Log("msg", "scenario failed", "scenario", s.Name, "duration", duration, "err", err)
+		m.ScenarioRuns.WithLabelValues(s.Name, labelError).Inc()
+
+		var sErr *scenario.StepError
+		if errors.As(err, &sErr) {
+			m.ScenarioStepFailures.WithLabelValues(s.Name, sErr.Step).Inc()
+		}
+
+		return err
+	}
+
+	level.Debug(l).Log("msg", "scenario succeeded", "scenario", s.Name, "duration", duration)
+	m.ScenarioRuns.WithLabelValues(s.Name, labelSuccess).Inc()
+	m.ScenarioDuration.WithLabelValues(s.Name).Observe(duration)
+
+	return nil
+}
+
+// reportScenarioResults evaluates the success ratio of every scenario with a threshold.
+func reportScenarioResults(l log.Logger, ch chan error, scenarios []options.ScenarioSpec, results map[string]*queryResults,
+	threshold float64) error {
+	var failed []string
+
+	for _, s := range scenarios {
+		t := threshold
+		if s.Threshold != nil {
+			t = *s.Threshold
+		}
+
+		if t <= 0 {
+			continue
+		}
+
+		r := queryResults{}
+		if res, ok := results[s.Name]; ok {
+			r = *res
+		}
+
+		level.Info(l).Log("msg", "number of scenario runs", "name", s.Name, "success", r.success, "errors", r.failures)
+
+		if reason, ok := r.belowThreshold(t); ok {
+			failed = append(failed, fmt.Sprintf("%s (%s)", s.Name, reason))
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	level.Error(l).Log("msg", "ratio of scenarios is below threshold")
+
+	err := errors.Errorf("scenarios failed with less than their success ratio: %s", strings.Join(failed, ", "))
+	ch <- err
+
+	return err
+}
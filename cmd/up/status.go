@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/observatorium/up/pkg/alertmanager"
+	"github.com/observatorium/up/pkg/up"
+)
+
+const statusPending = "pending"
+
+// checkStatus is the outcome of the last run of a check.
+type checkStatus struct {
+	Check   string     `json:"check"`
+	Tenant  string     `json:"tenant,omitempty"`
+	LastRun *time.Time `json:"lastRun,omitempty"`
+	// Status is "success" or "error" after the first run, "pending" before.
+	Status  string  `json:"status"`
+	Latency float64 `json:"latencySeconds"`
+	Error   string  `json:"error,omitempty"`
+	// TraceID is the ID of the trace of the last run, if it was traced.
+	TraceID string `json:"traceId,omitempty"`
+}
+
+// statuses are the statuses of the checks by check and tenant, served on the status page of the internal server.
+// Every run is also observed by the alerter, if any.
+type statuses struct {
+	mtx     sync.Mutex
+	checks  map[[2]string]*checkStatus
+	alerter *alertmanager.Alerter
+}
+
+func newStatuses(a *alertmanager.Alerter) *statuses {
+	return &statuses{checks: map[[2]string]*checkStatus{}, alerter: a}
+}
+
+// add lists the check before its first run.
+func (s *statuses) add(check, tenant string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.get(check, tenant)
+}
+
+func (s *statuses) get(check, tenant string) *checkStatus {
+	c, ok := s.checks[[2]string{check, tenant}]
+	if !ok {
+		c = &checkStatus{Check: check, Tenant: tenant, Status: statusPending}
+		s.checks[[2]string{check, tenant}] = c
+	}
+
+	return c
+}
+
+// record records a run of the check started at t with the trace of the ID, which failed if err is not nil.
+func (s *statuses) record(check, tenant string, t time.Time, traceID string, err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	c := s.get(check, tenant)
+	c.LastRun, c.Latency, c.Status, c.Error, c.TraceID = &t, time.Since(t).Seconds(), labelSuccess, "", traceID
+
+	if err != nil {
+		c.Status, c.Error = labelError, err.Error()
+	}
+
+	s.alerter.Observe(check, tenant, err)
+}
+
+// list returns the statuses sorted by check and tenant, listing the queries that did not run yet as pending.
+func (s *statuses) list(queries []string) []checkStatus {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, q := range queries {
+		s.get(up.QueryCheckPrefix+q, "")
+	}
+
+	res := make([]checkStatus, 0, len(s.checks))
+	for _, c := range s.checks {
+		res = append(res, *c)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Check != res[j].Check {
+			return res[i].Check < res[j].Check
+		}
+
+		return res[i].Tenant < res[j].Tenant
+	})
+
+	return res
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head><title>up status</title></head>
+<body>
+<h1>up status</h1>
+<table border="1" cellpadding="4">
+<tr><th>Check</th><th>Tenant</th><th>Status</th><th>Last run</th><th>Latency (s)</th><th>Trace ID</th><th>Error</th></tr>
+{{- range . }}
+<tr><td>{{ .Check }}</td><td>{{ .Tenant }}</td><td>{{ .Status }}</td>
+<td>{{ with .LastRun }}{{ .Format "2006-01-02T15:04:05Z07:00" }}{{ end }}</td>
+<td>{{ printf "%.3f" .Latency }}</td><td>{{ .TraceID }}</td><td>{{ .Error }}</td></tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
+
+// statusHandler serves the status of every check as HTML, or as JSON if requested by the Accept header
+// or by ?format=json.
+func statusHandler(s *statuses, cfg *liveConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		queries := cfg.Queries()
+		names := make([]string, 0, len(queries))
+
+		for _, q := range queries {
+			names = append(names, q.GetName())
+		}
+
+		checks := s.list(names)
+
+		if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(checks) //nolint:errcheck
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		statusTemplate.Execute(w, checks) //nolint:errcheck
+	}
+}
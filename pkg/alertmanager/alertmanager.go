@@ -0,0 +1,238 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const (
+	// resendInterval is the time between sends of the firing alerts, as Alertmanager resolves alerts not sent again
+	// until they end.
+	resendInterval = time.Minute
+	// expiry is the time after the last send firing alerts end at, resolving them if up stops sending them.
+	expiry = 4 * resendInterval
+	// sendTimeout is the timeout of a single send.
+	sendTimeout = 10 * time.Second
+
+	// AlertName is the alertname label of the alerts, unless set otherwise.
+	AlertName = "UpCheckFailed"
+)
+
+// Alert is an alert of the v2 API of Alertmanager.
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+// Alerter fires an alert for every failing check and tenant, resolving it when the check recovers.
+// All methods are safe to call on a nil alerter, which fires no alerts.
+type Alerter struct {
+	l           log.Logger
+	endpoint    string
+	labels      map[string]string
+	annotations map[string]string
+	client      *http.Client
+
+	mtx      sync.Mutex
+	firing   map[[2]string]*Alert
+	resolved []*Alert
+	notify   chan struct{}
+}
+
+// NewAlerter returns an alerter sending to the v2 API of the Alertmanager of the endpoint. The labels and the
+// annotations are added to every alert.
+func NewAlerter(l log.Logger, endpoint *url.URL, labels, annotations map[string]string) *Alerter {
+	return &Alerter{
+		l:           log.With(l, "component", "alertmanager"),
+		endpoint:    strings.TrimSuffix(endpoint.String(), "/") + "/api/v2/alerts",
+		labels:      labels,
+		annotations: annotations,
+		client:      &http.Client{Timeout: sendTimeout},
+		firing:      map[[2]string]*Alert{},
+		notify:      make(chan struct{}, 1),
+	}
+}
+
+// Observe observes a run of the check of the tenant, which failed if err is not nil. The alert of the check fires
+// on the first failure and resolves on the first success afterwards.
+func (a *Alerter) Observe(check, tenant string, err error) {
+	if a == nil {
+		return
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	key := [2]string{check, tenant}
+	alert, ok := a.firing[key]
+
+	switch {
+	case err != nil && !ok:
+		a.firing[key] = a.alert(check, tenant, err)
+	case err != nil:
+		// The description follows the last error of the check.
+		alert.Annotations["description"] = err.Error()
+		return
+	case ok:
+		delete(a.firing, key)
+
+		alert.EndsAt = time.Now()
+		a.resolved = append(a.resolved, alert)
+	default:
+		return
+	}
+
+	select {
+	case a.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (a *Alerter) alert(check, tenant string, err error) *Alert {
+	alert := &Alert{
+		Labels:      map[string]string{"alertname": AlertName},
+		Annotations: map[string]string{},
+		StartsAt:    time.Now(),
+	}
+
+	for k, v := range a.labels {
+		alert.Labels[k] = v
+	}
+
+	alert.Labels["check"] = check
+	if tenant != "" {
+		alert.Labels["tenant"] = tenant
+	}
+
+	alert.Annotations["summary"] = "The " + check + " check of up is failing."
+	alert.Annotations["description"] = err.Error()
+
+	for k, v := range a.annotations {
+		alert.Annotations[k] = v
+	}
+
+	return alert
+}
+
+// Run sends the alerts that fired or resolved, and the firing alerts again periodically, until the context is canceled.
+// The alerts resolved until then are sent once more, the alerts firing then end after they expire.
+func (a *Alerter) Run(ctx context.Context) error {
+	if a == nil {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(resendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := a.send(context.Background()); err != nil {
+				level.Warn(a.l).Log("msg", "failed to send alerts", "err", err)
+			}
+
+			return nil
+		case <-ticker.C:
+		case <-a.notify:
+		}
+
+		if err := a.send(context.Background()); err != nil {
+			level.Warn(a.l).Log("msg", "failed to send alerts", "err", err)
+		}
+	}
+}
+
+// pending returns the resolved alerts not sent yet and the firing alerts sorted by their labels, ending when they
+// expire. A check failing again after resolving fires a new alert, which comes after the resolved one to take
+// precedence. It also returns the number of resolved alerts, which are dropped once sent.
+func (a *Alerter) pending() ([]Alert, int) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	alerts := make([]Alert, 0, len(a.resolved)+len(a.firing))
+
+	for _, alert := range a.resolved {
+		alerts = append(alerts, *alert)
+	}
+
+	keys := make([][2]string, 0, len(a.firing))
+	for key := range a.firing {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+
+		return keys[i][1] < keys[j][1]
+	})
+
+	for _, key := range keys {
+		al := *a.firing[key]
+		al.Labels, al.Annotations = copyMap(al.Labels), copyMap(al.Annotations)
+		al.EndsAt = time.Now().Add(expiry)
+		alerts = append(alerts, al)
+	}
+
+	return alerts, len(a.resolved)
+}
+
+func (a *Alerter) send(ctx context.Context) error {
+	alerts, resolved := a.pending()
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(alerts)
+	if err != nil {
+		return errors.Wrap(err, "encoding alerts")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "making request")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return errors.Errorf("sending %d alerts: non-2xx status: %s", len(alerts), res.Status)
+	}
+
+	// Alerts resolved while sending are sent next time.
+	a.mtx.Lock()
+	a.resolved = a.resolved[resolved:]
+	a.mtx.Unlock()
+
+	return nil
+}
+
+func copyMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+
+	return c
+}
@@ -0,0 +1,78 @@
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+)
+
+func TestAlerter(t *testing.T) {
+	var sent [][]Alert
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testutil.Equals(t, "/api/v2/alerts", r.URL.Path)
+
+		var alerts []Alert
+		testutil.Ok(t, json.NewDecoder(r.Body).Decode(&alerts))
+
+		sent = append(sent, alerts)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	testutil.Ok(t, err)
+
+	a := NewAlerter(log.NewNopLogger(), u, map[string]string{"severity": "critical"}, map[string]string{"runbook_url": "http://runbook"})
+
+	// Nothing is sent while the checks succeed.
+	a.Observe("writer", "", nil)
+	testutil.Ok(t, a.send(context.Background()))
+	testutil.Equals(t, 0, len(sent))
+
+	a.Observe("writer", "", errors.New("non-200 status"))
+	a.Observe("writer", "", errors.New("timeout"))
+	a.Observe("reader", "tenant-a", errors.New("stale"))
+	testutil.Ok(t, a.send(context.Background()))
+	testutil.Equals(t, 1, len(sent))
+	testutil.Equals(t, 2, len(sent[0]))
+
+	reader, writer := sent[0][0], sent[0][1]
+	testutil.Equals(t, map[string]string{
+		"alertname": AlertName, "check": "reader", "tenant": "tenant-a", "severity": "critical",
+	}, reader.Labels)
+	testutil.Equals(t, map[string]string{"alertname": AlertName, "check": "writer", "severity": "critical"}, writer.Labels)
+	testutil.Equals(t, "timeout", writer.Annotations["description"])
+	testutil.Equals(t, "http://runbook", writer.Annotations["runbook_url"])
+	testutil.Assert(t, writer.EndsAt.After(time.Now()), "firing alerts must end in the future")
+
+	// The writer recovers, its alert is resolved once while the reader keeps firing.
+	a.Observe("writer", "", nil)
+	testutil.Ok(t, a.send(context.Background()))
+	testutil.Ok(t, a.send(context.Background()))
+	testutil.Equals(t, 3, len(sent))
+	testutil.Equals(t, 2, len(sent[1]))
+	testutil.Equals(t, "writer", sent[1][0].Labels["check"])
+	testutil.Assert(t, !sent[1][0].EndsAt.After(time.Now()), "resolved alerts must have ended")
+	testutil.Equals(t, writer.StartsAt.Unix(), sent[1][0].StartsAt.Unix())
+	testutil.Equals(t, 1, len(sent[2]))
+	testutil.Equals(t, "reader", sent[2][0].Labels["check"])
+}
+
+func TestAlerter_Nil(t *testing.T) {
+	var a *Alerter
+
+	a.Observe("writer", "", errors.New("failed"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testutil.Ok(t, a.Run(ctx))
+}
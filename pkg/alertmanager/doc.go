@@ -0,0 +1,3 @@
+// Package alertmanager fires alerts of failing checks to the v2 API of an Alertmanager and resolves them when
+// the checks recover.
+package alertmanager
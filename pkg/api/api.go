@@ -25,6 +25,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/observatorium/up/pkg/tracing"
+
 	promapi "github.com/prometheus/client_golang/api"
 	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
@@ -38,11 +40,19 @@ const (
 	ErrServer      promapiv1.ErrorType = "server_error"
 	ErrClient      promapiv1.ErrorType = "client_error"
 
-	epQuery       = "/api/v1/query"
-	epQueryRange  = "/api/v1/query_range"
-	epSeries      = "/api/v1/series"
-	epLabels      = "/api/v1/labels"
-	epLabelValues = "/api/v1/label/:name/values"
+	epQuery        = "/api/v1/query"
+	epQueryRange   = "/api/v1/query_range"
+	epSeries       = "/api/v1/series"
+	epLabels       = "/api/v1/labels"
+	epLabelValues  = "/api/v1/label/:name/values"
+	epExemplars    = "/api/v1/query_exemplars"
+	epMetadata     = "/api/v1/metadata"
+	epDeleteSeries = "/api/v1/admin/tsdb/delete_series"
+	epTargets      = "/api/v1/targets"
+	epRules        = "/api/v1/rules"
+	epAlerts       = "/api/v1/alerts"
+	epBuildInfo    = "/api/v1/status/buildinfo"
+	epRuntimeInfo  = "/api/v1/status/runtimeinfo"
 )
 
 func errorTypeAndMsgFor(resp *http.Response) (promapiv1.ErrorType, string) {
@@ -68,12 +78,15 @@ type queryResult struct {
 
 	// The decoded value.
 	v model.Value
+	// The engine statistics, if requested.
+	stats *EngineStats
 }
 
 func (qr *queryResult) UnmarshalJSON(b []byte) error {
 	v := struct {
 		Type   model.ValueType `json:"resultType"`
 		Result json.RawMessage `json:"result"`
+		Stats  *EngineStats    `json:"stats"`
 	}{}
 
 	err := json.Unmarshal(b, &v)
@@ -81,6 +94,8 @@ func (qr *queryResult) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
+	qr.stats = v.Stats
+
 	switch v.Type {
 	case model.ValScalar:
 		var sv model.Scalar
@@ -113,11 +128,27 @@ type apiResponse struct {
 }
 
 func do(ctx context.Context, client promapi.Client, req *http.Request) (*http.Response, []byte, promapiv1.Warnings, error) {
+	ctx, span := tracing.Start(ctx, "api "+req.URL.Path)
+
+	resp, body, warn, err := doRequest(ctx, client, req)
+	span.SetAttributes("warnings", len(warn))
+	span.End(err)
+
+	return resp, body, warn, err
+}
+
+func doRequest(ctx context.Context, client promapi.Client, req *http.Request) (*http.Response, []byte, promapiv1.Warnings, error) {
+	start := time.Now()
 	resp, body, err := client.Do(ctx, req)
+	statsFrom(ctx).recordRequest(time.Since(start))
+	traceFrom(ctx).record(ctx, resp)
+
 	if err != nil {
 		return resp, body, nil, err
 	}
 
+	statsFrom(ctx).recordBytes(body)
+
 	code := resp.StatusCode
 
 	if code/100 != 2 && !apiError(code) {
@@ -133,7 +164,11 @@ func do(ctx context.Context, client promapi.Client, req *http.Request) (*http.Re
 	var result apiResponse
 
 	if http.StatusNoContent != code {
-		if jsonErr := json.Unmarshal(body, &result); jsonErr != nil {
+		start = time.Now()
+		jsonErr := json.Unmarshal(body, &result)
+		statsFrom(ctx).recordDecode(time.Since(start))
+
+		if jsonErr != nil {
 			return resp, body, nil, &promapiv1.Error{
 				Type: ErrBadResponse,
 				Msg:  jsonErr.Error(),
@@ -155,6 +190,10 @@ func do(ctx context.Context, client promapi.Client, req *http.Request) (*http.Re
 		}
 	}
 
+	if err == nil {
+		statsFrom(ctx).recordData(result.Data)
+	}
+
 	return resp, result.Data, result.Warnings, err
 }
 
@@ -197,14 +236,87 @@ func doGetFallback(
 	return resp, data, warnings, err
 }
 
+// doGet does a GET request, for the endpoints that do not accept POST, e.g. the status ones.
+func doGet(ctx context.Context, client promapi.Client, u *url.URL, cache bool) (*http.Response, []byte, promapiv1.Warnings, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if !cache {
+		req.Header.Set("Cache-Control", "no-store")
+	}
+
+	return do(ctx, client, req)
+}
+
+// QueryParams are optional parameters for instant and range queries.
+type QueryParams struct {
+	// Dedup enables or disables the deduplication of Thanos replicas.
+	Dedup *bool
+	// PartialResponse allows or forbids Thanos returning partial results when some stores are unavailable.
+	PartialResponse *bool
+	// MaxSourceResolution is the maximum Thanos downsampling resolution to use, e.g. 0s, 5m, 1h or auto.
+	MaxSourceResolution string
+	// Stats requests the statistics of the query engine.
+	Stats bool
+	// Timeout is the timeout of the evaluation of the query on the server, the default of the server if 0.
+	Timeout time.Duration
+	// LookbackDelta is how far back samples are looked up for instant vectors, the default of the server if 0.
+	LookbackDelta time.Duration
+}
+
+// EngineStats are the statistics of the Prometheus query engine for a query.
+type EngineStats struct {
+	Timings struct {
+		EvalTotalTime        float64 `json:"evalTotalTime"`
+		ResultSortTime       float64 `json:"resultSortTime"`
+		QueryPreparationTime float64 `json:"queryPreparationTime"`
+		InnerEvalTime        float64 `json:"innerEvalTime"`
+		ExecQueueTime        float64 `json:"execQueueTime"`
+		ExecTotalTime        float64 `json:"execTotalTime"`
+	} `json:"timings"`
+	Samples struct {
+		TotalQueryableSamples int64 `json:"totalQueryableSamples"`
+		PeakSamples           int64 `json:"peakSamples"`
+	} `json:"samples"`
+}
+
+func (p QueryParams) set(q url.Values) {
+	if p.Dedup != nil {
+		q.Set("dedup", strconv.FormatBool(*p.Dedup))
+	}
+
+	if p.PartialResponse != nil {
+		q.Set("partial_response", strconv.FormatBool(*p.PartialResponse))
+	}
+
+	if p.MaxSourceResolution != "" {
+		q.Set("max_source_resolution", p.MaxSourceResolution)
+	}
+
+	if p.Stats {
+		q.Set("stats", "all")
+	}
+
+	if p.Timeout > 0 {
+		q.Set("timeout", strconv.FormatFloat(p.Timeout.Seconds(), 'f', -1, 64))
+	}
+
+	if p.LookbackDelta > 0 {
+		q.Set("lookback_delta", strconv.FormatFloat(p.LookbackDelta.Seconds(), 'f', -1, 64))
+	}
+}
+
 func QueryRange(ctx context.Context, client promapi.Client, query string, r promapiv1.Range,
-	cache bool) (model.Value, int, promapiv1.Warnings, error) {
+	cache bool, params QueryParams) (model.Value, int, promapiv1.Warnings, error) {
 	u := client.URL(epQueryRange, nil)
 	q := u.Query()
 	q.Set("query", query)
 	q.Set("start", formatTime(r.Start))
 	q.Set("end", formatTime(r.End))
 	q.Set("step", strconv.FormatFloat(r.Step.Seconds(), 'f', -1, 64))
+	params.set(q)
 
 	resp, data, warnings, err := doGetFallback(ctx, client, u, q, cache) //nolint:bodyclose
 	if err != nil {
@@ -216,16 +328,23 @@ func QueryRange(ctx context.Context, client promapi.Client, query string, r prom
 	}
 
 	var qres queryResult
+	if err := json.Unmarshal(data, &qres); err != nil {
+		return nil, resp.StatusCode, warnings, err
+	}
+
+	statsFrom(ctx).recordValue(qres.v)
+	statsFrom(ctx).recordEngine(qres.stats)
 
-	return qres.v, resp.StatusCode, warnings, json.Unmarshal(data, &qres)
+	return qres.v, resp.StatusCode, warnings, nil
 }
 
 func Query(ctx context.Context, client promapi.Client, query string, ts time.Time,
-	cache bool) (model.Value, int, promapiv1.Warnings, error) {
+	cache bool, params QueryParams) (model.Value, int, promapiv1.Warnings, error) {
 	u := client.URL(epQuery, nil)
 	q := u.Query()
 
 	q.Set("query", query)
+	params.set(q)
 
 	if !ts.IsZero() {
 		q.Set("time", formatTime(ts))
@@ -242,8 +361,14 @@ func Query(ctx context.Context, client promapi.Client, query string, ts time.Tim
 	}
 
 	var qres queryResult
+	if err := json.Unmarshal(data, &qres); err != nil {
+		return nil, resp.StatusCode, warnings, err
+	}
 
-	return qres.v, resp.StatusCode, warnings, json.Unmarshal(data, &qres)
+	statsFrom(ctx).recordValue(qres.v)
+	statsFrom(ctx).recordEngine(qres.stats)
+
+	return qres.v, resp.StatusCode, warnings, nil
 }
 
 func Series(ctx context.Context, client promapi.Client, matches []string, startTime time.Time, endTime time.Time,
@@ -268,8 +393,15 @@ func Series(ctx context.Context, client promapi.Client, matches []string, startT
 	}
 
 	var mset []model.LabelSet
+	if err := json.Unmarshal(body, &mset); err != nil {
+		return nil, resp.StatusCode, warnings, err
+	}
 
-	return mset, resp.StatusCode, warnings, json.Unmarshal(body, &mset)
+	if s := statsFrom(ctx); s != nil {
+		s.Series = len(mset)
+	}
+
+	return mset, resp.StatusCode, warnings, nil
 }
 
 func LabelNames(ctx context.Context, client promapi.Client, startTime time.Time, endTime time.Time,
@@ -328,6 +460,205 @@ func LabelValues(ctx context.Context, client promapi.Client, label string, start
 	return labelValues, resp.StatusCode, warnings, json.Unmarshal(body, &labelValues)
 }
 
+func Targets(ctx context.Context, client promapi.Client, state string,
+	cache bool) (promapiv1.TargetsResult, int, promapiv1.Warnings, error) {
+	u := client.URL(epTargets, nil)
+	q := u.Query()
+
+	if state != "" {
+		q.Set("state", state)
+	}
+
+	var res promapiv1.TargetsResult
+
+	resp, body, warnings, err := doGetFallback(ctx, client, u, q, cache) //nolint:bodyclose
+	if err != nil {
+		if resp == nil {
+			// Unknown error.
+			return res, 0, warnings, err
+		}
+
+		return res, resp.StatusCode, warnings, err
+	}
+
+	return res, resp.StatusCode, warnings, json.Unmarshal(body, &res)
+}
+
+func Rules(ctx context.Context, client promapi.Client, ruleType string,
+	cache bool) (promapiv1.RulesResult, int, promapiv1.Warnings, error) {
+	u := client.URL(epRules, nil)
+	q := u.Query()
+
+	if ruleType != "" {
+		q.Set("type", ruleType)
+	}
+
+	var res promapiv1.RulesResult
+
+	resp, body, warnings, err := doGetFallback(ctx, client, u, q, cache) //nolint:bodyclose
+	if err != nil {
+		if resp == nil {
+			// Unknown error.
+			return res, 0, warnings, err
+		}
+
+		return res, resp.StatusCode, warnings, err
+	}
+
+	return res, resp.StatusCode, warnings, json.Unmarshal(body, &res)
+}
+
+func Alerts(ctx context.Context, client promapi.Client,
+	cache bool) (promapiv1.AlertsResult, int, promapiv1.Warnings, error) {
+	u := client.URL(epAlerts, nil)
+
+	var res promapiv1.AlertsResult
+
+	resp, body, warnings, err := doGetFallback(ctx, client, u, u.Query(), cache) //nolint:bodyclose
+	if err != nil {
+		if resp == nil {
+			// Unknown error.
+			return res, 0, warnings, err
+		}
+
+		return res, resp.StatusCode, warnings, err
+	}
+
+	return res, resp.StatusCode, warnings, json.Unmarshal(body, &res)
+}
+
+// DeleteSeries deletes the data of the series matching any of the matchers, using the TSDB admin API.
+func DeleteSeries(ctx context.Context, client promapi.Client, matches []string, startTime time.Time,
+	endTime time.Time) (int, error) {
+	u := client.URL(epDeleteSeries, nil)
+	q := u.Query()
+
+	for _, m := range matches {
+		q.Add("match[]", m)
+	}
+
+	q.Set("start", formatTime(startTime))
+	q.Set("end", formatTime(endTime))
+
+	resp, _, _, err := doGetFallback(ctx, client, u, q, true) //nolint:bodyclose
+	if err != nil {
+		if resp == nil {
+			// Unknown error.
+			return 0, err
+		}
+
+		return resp.StatusCode, err
+	}
+
+	return resp.StatusCode, nil
+}
+
+func QueryExemplars(ctx context.Context, client promapi.Client, query string, startTime time.Time, endTime time.Time,
+	cache bool) ([]promapiv1.ExemplarQueryResult, int, promapiv1.Warnings, error) {
+	u := client.URL(epExemplars, nil)
+	q := u.Query()
+	q.Set("query", query)
+	q.Set("start", formatTime(startTime))
+	q.Set("end", formatTime(endTime))
+
+	resp, body, warnings, err := doGetFallback(ctx, client, u, q, cache) //nolint:bodyclose
+	if err != nil {
+		if resp == nil {
+			// Unknown error.
+			return nil, 0, warnings, err
+		}
+
+		return nil, resp.StatusCode, warnings, err
+	}
+
+	var res []promapiv1.ExemplarQueryResult
+
+	return res, resp.StatusCode, warnings, json.Unmarshal(body, &res)
+}
+
+func Metadata(ctx context.Context, client promapi.Client, metric string,
+	cache bool) (map[string][]promapiv1.Metadata, int, promapiv1.Warnings, error) {
+	u := client.URL(epMetadata, nil)
+	q := u.Query()
+
+	if metric != "" {
+		q.Set("metric", metric)
+	}
+
+	resp, body, warnings, err := doGetFallback(ctx, client, u, q, cache) //nolint:bodyclose
+	if err != nil {
+		if resp == nil {
+			// Unknown error.
+			return nil, 0, warnings, err
+		}
+
+		return nil, resp.StatusCode, warnings, err
+	}
+
+	var res map[string][]promapiv1.Metadata
+
+	return res, resp.StatusCode, warnings, json.Unmarshal(body, &res)
+}
+
+// BuildInfo returns the version and build information of the server.
+func BuildInfo(ctx context.Context, client promapi.Client,
+	cache bool) (promapiv1.BuildinfoResult, int, promapiv1.Warnings, error) {
+	var res promapiv1.BuildinfoResult
+
+	resp, body, warnings, err := doGet(ctx, client, client.URL(epBuildInfo, nil), cache) //nolint:bodyclose
+	if err != nil {
+		if resp == nil {
+			// Unknown error.
+			return res, 0, warnings, err
+		}
+
+		return res, resp.StatusCode, warnings, err
+	}
+
+	return res, resp.StatusCode, warnings, json.Unmarshal(body, &res)
+}
+
+// RuntimeInfo returns the runtime information of the server, e.g. its start time and whether its configuration reloaded.
+func RuntimeInfo(ctx context.Context, client promapi.Client,
+	cache bool) (promapiv1.RuntimeinfoResult, int, promapiv1.Warnings, error) {
+	var res promapiv1.RuntimeinfoResult
+
+	resp, body, warnings, err := doGet(ctx, client, client.URL(epRuntimeInfo, nil), cache) //nolint:bodyclose
+	if err != nil {
+		if resp == nil {
+			// Unknown error.
+			return res, 0, warnings, err
+		}
+
+		return res, resp.StatusCode, warnings, err
+	}
+
+	return res, resp.StatusCode, warnings, json.Unmarshal(body, &res)
+}
+
+// Empty returns whether the result of a query contains no data.
+func Empty(v model.Value) bool {
+	switch r := v.(type) {
+	case model.Vector:
+		return len(r) == 0
+	case model.Matrix:
+		return len(r) == 0
+	case nil:
+		return true
+	}
+
+	return false
+}
+
+// WarningsError returns an error listing the warnings returned by a query, or nil if there are none.
+func WarningsError(warnings promapiv1.Warnings) error {
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("query returned warnings: %s", strings.Join(warnings, "; "))
+}
+
 func formatTime(t time.Time) string {
 	return strconv.FormatFloat(float64(t.Unix())+float64(t.Nanosecond())/1e9, 'f', -1, 64)
 }
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	promapi "github.com/prometheus/client_golang/api"
+	"github.com/prometheus/common/model"
+)
+
+func TestQueryResult_UnmarshalJSON(t *testing.T) {
+	var qr queryResult
+	testutil.Ok(t, json.Unmarshal([]byte(`{
+		"resultType": "vector",
+		"result": [{"metric": {"job": "up"}, "value": [1, "1"]}],
+		"stats": {
+			"timings": {"evalTotalTime": 0.5, "execTotalTime": 0.75},
+			"samples": {"totalQueryableSamples": 120, "peakSamples": 12}
+		}
+	}`), &qr))
+
+	testutil.Equals(t, 1, len(qr.v.(model.Vector)))
+	testutil.Assert(t, qr.stats != nil, "expected engine stats")
+	testutil.Equals(t, 0.5, qr.stats.Timings.EvalTotalTime)
+	testutil.Equals(t, 0.75, qr.stats.Timings.ExecTotalTime)
+	testutil.Equals(t, int64(120), qr.stats.Samples.TotalQueryableSamples)
+	testutil.Equals(t, int64(12), qr.stats.Samples.PeakSamples)
+
+	qr = queryResult{}
+	testutil.Ok(t, json.Unmarshal([]byte(`{"resultType": "scalar", "result": [1, "1"]}`), &qr))
+	testutil.Assert(t, qr.stats == nil, "expected no engine stats")
+}
+
+func TestQueryParams_set(t *testing.T) {
+	dedup := false
+
+	for i, tc := range []struct {
+		params   QueryParams
+		expected url.Values
+	}{
+		{params: QueryParams{}, expected: url.Values{}},
+		{
+			params:   QueryParams{Dedup: &dedup, MaxSourceResolution: "5m", Stats: true},
+			expected: url.Values{"dedup": {"false"}, "max_source_resolution": {"5m"}, "stats": {"all"}},
+		},
+		// The timeout is sent in seconds, like the step.
+		{params: QueryParams{Timeout: 1500 * time.Millisecond}, expected: url.Values{"timeout": {"1.5"}}},
+		{params: QueryParams{LookbackDelta: 30 * time.Second}, expected: url.Values{"lookback_delta": {"30"}}},
+	} {
+		q := url.Values{}
+		tc.params.set(q)
+		testutil.Equals(t, tc.expected, q, "case #%d", i)
+	}
+}
+
+func TestLokiMetadataAPIs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testutil.Equals(t, `{job="up"}`, r.FormValue("query"))
+
+		switch r.URL.Path {
+		case epLokiIndexStats:
+			// The statistics are not wrapped like the responses of queries.
+			fmt.Fprint(w, `{"streams": 1, "chunks": 2, "entries": 3, "bytes": 4}`)
+		case epLokiFields:
+			fmt.Fprint(w, `{"fields": [{"label": "seq", "type": "int", "cardinality": 10, "parsers": ["logfmt"]}], "limit": 1000}`)
+		case epLokiPatterns:
+			fmt.Fprint(w, `{"status": "success", "data": [{"pattern": "ts=<_> seq=<_>", "samples": [[1711839260, 3]]}]}`)
+		case epLokiVolume:
+			testutil.Equals(t, "job,instance", r.FormValue("targetLabels"))
+			fmt.Fprint(w, `{"status": "success", "data": {"resultType": "vector", "result": [{"metric": {"job": "up"}, "value": [1, "42"]}]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := promapi.NewClient(promapi.Config{Address: srv.URL})
+	testutil.Ok(t, err)
+
+	stats, _, err := LokiIndexStats(context.Background(), c, `{job="up"}`, time.Unix(0, 0), time.Now(), false)
+	testutil.Ok(t, err)
+	testutil.Equals(t, LokiIndexStatsResult{Streams: 1, Chunks: 2, Entries: 3, Bytes: 4}, *stats)
+
+	res, _, _, err := LokiVolume(context.Background(), c, `{job="up"}`, time.Unix(0, 0), time.Now(), false,
+		LokiVolumeParams{TargetLabels: []string{"job", "instance"}})
+	testutil.Ok(t, err)
+	testutil.Equals(t, model.SampleValue(42), res.Value.(model.Vector)[0].Value)
+
+	fields, _, err := LokiDetectedFields(context.Background(), c, `{job="up"}`, time.Unix(0, 0), time.Now(), false, 0)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []LokiDetectedField{{Label: "seq", Type: "int", Cardinality: 10, Parsers: []string{"logfmt"}}}, fields)
+
+	patterns, _, _, err := LokiPatterns(context.Background(), c, `{job="up"}`, time.Unix(0, 0), time.Now(), false)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []LokiPattern{{Pattern: "ts=<_> seq=<_>", Samples: [][2]int64{{1711839260, 3}}}}, patterns)
+}
+
+func TestStatusAPIs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The status endpoints only accept GET.
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		switch r.URL.Path {
+		case epBuildInfo:
+			fmt.Fprint(w, `{"status": "success", "data": {"version": "0.34.0", "revision": "abc", "branch": "main", "goVersion": "go1.21"}}`)
+		case epRuntimeInfo:
+			fmt.Fprint(w, `{"status": "success", "data": {"reloadConfigSuccess": true, "goroutineCount": 42}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := promapi.NewClient(promapi.Config{Address: srv.URL})
+	testutil.Ok(t, err)
+
+	bi, code, _, err := BuildInfo(context.Background(), c, false)
+	testutil.Ok(t, err)
+	testutil.Equals(t, http.StatusOK, code)
+	testutil.Equals(t, "0.34.0", bi.Version)
+	testutil.Equals(t, "abc", bi.Revision)
+	testutil.Equals(t, "go1.21", bi.GoVersion)
+
+	ri, _, _, err := RuntimeInfo(context.Background(), c, false)
+	testutil.Ok(t, err)
+	testutil.Assert(t, ri.ReloadConfigSuccess, "expected successful config reload")
+	testutil.Equals(t, 42, ri.GoroutineCount)
+}
+
+func TestWithTrace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The traced queries are their own trace IDs.
+		if q := r.FormValue("query"); q != "untraced" {
+			w.Header().Set(ThanosTraceIDHeader, q)
+		}
+
+		fmt.Fprint(w, `{"status": "success", "data": {"resultType": "vector", "result": []}}`)
+	}))
+	defer srv.Close()
+
+	c, err := promapi.NewClient(promapi.Config{Address: srv.URL})
+	testutil.Ok(t, err)
+
+	for i, tc := range []struct {
+		query string
+		id    string
+	}{
+		{query: "4bf92f3577b34da6a3ce929d0e0e4736", id: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{query: "untraced"},
+	} {
+		ctx, trace := WithTrace(context.Background())
+
+		_, _, _, err = Query(ctx, c, tc.query, time.Now(), false, QueryParams{})
+		testutil.Ok(t, err, "case #%d", i)
+		testutil.Equals(t, tc.id, trace.ID, "case #%d", i)
+		testutil.Equals(t, tc.id, TraceID(ctx), "case #%d", i)
+	}
+
+	// Concurrent queries record their own trace IDs.
+	var wg sync.WaitGroup
+
+	ids := make([]string, 20)
+
+	for i := range ids {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			ctx, trace := WithTrace(context.Background())
+			if _, _, _, err := Query(ctx, c, fmt.Sprintf("trace-%d", i), time.Now(), false, QueryParams{}); err == nil {
+				ids[i] = trace.ID
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, id := range ids {
+		testutil.Equals(t, fmt.Sprintf("trace-%d", i), id)
+	}
+
+	testutil.Equals(t, "", TraceID(context.Background()))
+}
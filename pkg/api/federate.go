@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/observatorium/up/pkg/tracing"
+
+	promapi "github.com/prometheus/client_golang/api"
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+const epFederate = "/federate"
+
+// Federate scrapes the latest samples of the series matching any of the matchers from the federation endpoint,
+// which responds in the exposition format rather than like the query API.
+func Federate(ctx context.Context, client promapi.Client, matches []string) ([]*dto.MetricFamily, int, error) {
+	u := client.URL(epFederate, nil)
+	q := u.Query()
+
+	for _, m := range matches {
+		q.Add("match[]", m)
+	}
+
+	u.RawQuery = q.Encode()
+
+	ctx, span := tracing.Start(ctx, "api "+u.Path)
+
+	families, code, err := doFederate(ctx, client, u.String())
+	span.End(err)
+
+	return families, code, err
+}
+
+func doFederate(ctx context.Context, client promapi.Client, u string) ([]*dto.MetricFamily, int, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req.Header.Set("Accept", string(expfmt.FmtText))
+
+	start := time.Now()
+	resp, body, err := client.Do(ctx, req)
+	statsFrom(ctx).recordRequest(time.Since(start))
+	traceFrom(ctx).record(ctx, resp)
+
+	if err != nil {
+		if resp == nil {
+			// Unknown error.
+			return nil, 0, err
+		}
+
+		return nil, resp.StatusCode, err
+	}
+
+	statsFrom(ctx).recordBytes(body)
+
+	if resp.StatusCode/100 != 2 {
+		errorType, errorMsg := errorTypeAndMsgFor(resp)
+
+		return nil, resp.StatusCode, &promapiv1.Error{
+			Type:   errorType,
+			Msg:    errorMsg,
+			Detail: string(body),
+		}
+	}
+
+	start = time.Now()
+	defer func() { statsFrom(ctx).recordDecode(time.Since(start)) }()
+
+	var (
+		families []*dto.MetricFamily
+		dec      = expfmt.NewDecoder(bytes.NewReader(body), expfmt.ResponseFormat(resp.Header))
+	)
+
+	for {
+		mf := &dto.MetricFamily{}
+		if err := dec.Decode(mf); err != nil {
+			if err == io.EOF {
+				return families, resp.StatusCode, nil
+			}
+
+			return nil, resp.StatusCode, &promapiv1.Error{Type: ErrBadResponse, Msg: err.Error()}
+		}
+
+		families = append(families, mf)
+	}
+}
@@ -0,0 +1,453 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/observatorium/up/pkg/tracing"
+
+	promapi "github.com/prometheus/client_golang/api"
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	epLokiQuery       = "/loki/api/v1/query"
+	epLokiQueryRange  = "/loki/api/v1/query_range"
+	epLokiLabels      = "/loki/api/v1/labels"
+	epLokiLabelValues = "/loki/api/v1/label/:name/values"
+	epLokiSeries      = "/loki/api/v1/series"
+	epLokiDelete      = "/loki/api/v1/delete"
+	epLokiIndexStats  = "/loki/api/v1/index/stats"
+	epLokiVolume      = "/loki/api/v1/index/volume"
+	epLokiFields      = "/loki/api/v1/detected_fields"
+	epLokiPatterns    = "/loki/api/v1/patterns"
+
+	lokiResultStreams = "streams"
+)
+
+// LokiResult is the result of a LogQL query, either log streams or the value of a metric query.
+type LokiResult struct {
+	Streams []LokiStream
+	Value   model.Value
+}
+
+// Empty returns whether the result contains no data.
+func (r *LokiResult) Empty() bool {
+	if r == nil {
+		return true
+	}
+
+	if r.Value != nil {
+		return Empty(r.Value)
+	}
+
+	return len(r.Streams) == 0
+}
+
+func (r *LokiResult) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Type   string          `json:"resultType"`
+		Result json.RawMessage `json:"result"`
+	}{}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	if v.Type == lokiResultStreams {
+		return json.Unmarshal(v.Result, &r.Streams)
+	}
+
+	var qr queryResult
+	if err := json.Unmarshal(b, &qr); err != nil {
+		return err
+	}
+
+	r.Value = qr.v
+
+	return nil
+}
+
+// LokiStream is a stream of log entries with the same labels.
+type LokiStream struct {
+	Labels  model.LabelSet `json:"stream"`
+	Entries []LokiEntry    `json:"values"`
+}
+
+// LokiEntry is a single log entry of a stream.
+type LokiEntry struct {
+	Timestamp time.Time
+	Line      string
+	// StructuredMetadata is only returned separately from the stream labels if Loki categorizes labels.
+	StructuredMetadata map[string]string
+}
+
+func (e *LokiEntry) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	if len(raw) < 2 {
+		return fmt.Errorf("unexpected log entry with %d elements", len(raw))
+	}
+
+	var ts string
+	if err := json.Unmarshal(raw[0], &ts); err != nil {
+		return err
+	}
+
+	ns, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("parsing log entry timestamp: %w", err)
+	}
+
+	e.Timestamp = time.Unix(0, ns)
+
+	if err := json.Unmarshal(raw[1], &e.Line); err != nil {
+		return err
+	}
+
+	if len(raw) > 2 {
+		var categorized struct {
+			StructuredMetadata map[string]string `json:"structuredMetadata"`
+		}
+
+		if err := json.Unmarshal(raw[2], &categorized); err != nil {
+			return fmt.Errorf("parsing log entry metadata: %w", err)
+		}
+
+		e.StructuredMetadata = categorized.StructuredMetadata
+	}
+
+	return nil
+}
+
+// LokiParams are optional parameters for LogQL queries.
+type LokiParams struct {
+	// Limit is the maximum number of entries to return.
+	Limit int
+	// Direction is the sort order of the entries, either forward or backward.
+	Direction string
+}
+
+func (p LokiParams) set(q url.Values) {
+	if p.Limit > 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+
+	if p.Direction != "" {
+		q.Set("direction", p.Direction)
+	}
+}
+
+func LokiQuery(ctx context.Context, client promapi.Client, query string, ts time.Time,
+	cache bool, params LokiParams) (*LokiResult, int, promapiv1.Warnings, error) {
+	u := client.URL(epLokiQuery, nil)
+	q := u.Query()
+	q.Set("query", query)
+
+	if !ts.IsZero() {
+		q.Set("time", strconv.FormatInt(ts.UnixNano(), 10))
+	}
+
+	params.set(q)
+
+	return doLoki(ctx, client, u, q, cache)
+}
+
+func LokiQueryRange(ctx context.Context, client promapi.Client, query string, r promapiv1.Range,
+	cache bool, params LokiParams) (*LokiResult, int, promapiv1.Warnings, error) {
+	u := client.URL(epLokiQueryRange, nil)
+	q := u.Query()
+	q.Set("query", query)
+	setLokiRange(q, r.Start, r.End)
+	q.Set("step", strconv.FormatFloat(r.Step.Seconds(), 'f', -1, 64))
+	params.set(q)
+
+	return doLoki(ctx, client, u, q, cache)
+}
+
+func LokiLabelNames(ctx context.Context, client promapi.Client, startTime time.Time, endTime time.Time,
+	cache bool) ([]string, int, promapiv1.Warnings, error) {
+	u := client.URL(epLokiLabels, nil)
+	q := u.Query()
+	setLokiRange(q, startTime, endTime)
+
+	var labelNames []string
+
+	code, warnings, err := doLokiInto(ctx, client, u, q, cache, &labelNames)
+
+	return labelNames, code, warnings, err
+}
+
+func LokiLabelValues(ctx context.Context, client promapi.Client, label string, startTime time.Time, endTime time.Time,
+	cache bool) (model.LabelValues, int, promapiv1.Warnings, error) {
+	u := client.URL(epLokiLabelValues, map[string]string{"name": label})
+	q := u.Query()
+	setLokiRange(q, startTime, endTime)
+
+	var labelValues model.LabelValues
+
+	code, warnings, err := doLokiInto(ctx, client, u, q, cache, &labelValues)
+
+	return labelValues, code, warnings, err
+}
+
+func LokiSeries(ctx context.Context, client promapi.Client, matches []string, startTime time.Time, endTime time.Time,
+	cache bool) ([]model.LabelSet, int, promapiv1.Warnings, error) {
+	u := client.URL(epLokiSeries, nil)
+	q := u.Query()
+
+	for _, m := range matches {
+		q.Add("match[]", m)
+	}
+
+	setLokiRange(q, startTime, endTime)
+
+	var mset []model.LabelSet
+
+	code, warnings, err := doLokiInto(ctx, client, u, q, cache, &mset)
+	if s := statsFrom(ctx); s != nil && err == nil {
+		s.Series = len(mset)
+	}
+
+	return mset, code, warnings, err
+}
+
+// LokiIndexStatsResult are the statistics of the index for the streams matching a query.
+type LokiIndexStatsResult struct {
+	Streams int `json:"streams"`
+	Chunks  int `json:"chunks"`
+	Entries int `json:"entries"`
+	Bytes   int `json:"bytes"`
+}
+
+func LokiIndexStats(ctx context.Context, client promapi.Client, query string, startTime time.Time, endTime time.Time,
+	cache bool) (*LokiIndexStatsResult, int, error) {
+	u := client.URL(epLokiIndexStats, nil)
+	q := u.Query()
+	q.Set("query", query)
+	setLokiRange(q, startTime, endTime)
+
+	var stats LokiIndexStatsResult
+
+	code, err := doLokiRaw(ctx, client, u, q, cache, &stats)
+	if err != nil {
+		return nil, code, err
+	}
+
+	return &stats, code, nil
+}
+
+// LokiVolumeParams are optional parameters for volume queries.
+type LokiVolumeParams struct {
+	// Limit is the maximum number of series returned.
+	Limit int
+	// TargetLabels are the labels to aggregate the volume by instead of the labels of the selector.
+	TargetLabels []string
+	// AggregateBy is either series or labels.
+	AggregateBy string
+}
+
+// LokiVolume returns the volume in bytes of the streams matching the query as vector.
+func LokiVolume(ctx context.Context, client promapi.Client, query string, startTime time.Time, endTime time.Time,
+	cache bool, params LokiVolumeParams) (*LokiResult, int, promapiv1.Warnings, error) {
+	u := client.URL(epLokiVolume, nil)
+	q := u.Query()
+	q.Set("query", query)
+	setLokiRange(q, startTime, endTime)
+
+	if params.Limit > 0 {
+		q.Set("limit", strconv.Itoa(params.Limit))
+	}
+
+	if len(params.TargetLabels) > 0 {
+		q.Set("targetLabels", strings.Join(params.TargetLabels, ","))
+	}
+
+	if params.AggregateBy != "" {
+		q.Set("aggregateBy", params.AggregateBy)
+	}
+
+	return doLoki(ctx, client, u, q, cache)
+}
+
+// LokiDetectedField is a field Loki detected in the log lines, either by parsing them or in the structured metadata.
+type LokiDetectedField struct {
+	Label       string   `json:"label"`
+	Type        string   `json:"type"`
+	Cardinality int      `json:"cardinality"`
+	Parsers     []string `json:"parsers"`
+}
+
+func LokiDetectedFields(ctx context.Context, client promapi.Client, query string, startTime time.Time, endTime time.Time,
+	cache bool, limit int) ([]LokiDetectedField, int, error) {
+	u := client.URL(epLokiFields, nil)
+	q := u.Query()
+	q.Set("query", query)
+	setLokiRange(q, startTime, endTime)
+
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+
+	var res struct {
+		Fields []LokiDetectedField `json:"fields"`
+	}
+
+	code, err := doLokiRaw(ctx, client, u, q, cache, &res)
+
+	return res.Fields, code, err
+}
+
+// LokiPattern is a pattern of log lines detected by Loki with the number of matching lines over time.
+type LokiPattern struct {
+	Pattern string `json:"pattern"`
+	// Samples are pairs of a timestamp in seconds and the number of lines matching the pattern.
+	Samples [][2]int64 `json:"samples"`
+}
+
+func LokiPatterns(ctx context.Context, client promapi.Client, query string, startTime time.Time, endTime time.Time,
+	cache bool) ([]LokiPattern, int, promapiv1.Warnings, error) {
+	u := client.URL(epLokiPatterns, nil)
+	q := u.Query()
+	q.Set("query", query)
+	setLokiRange(q, startTime, endTime)
+
+	var patterns []LokiPattern
+
+	code, warnings, err := doLokiInto(ctx, client, u, q, cache, &patterns)
+
+	return patterns, code, warnings, err
+}
+
+// LokiDelete requests the deletion of the log entries matching the query within the time range.
+// Loki accepts the request right away and deletes the entries in the background.
+func LokiDelete(ctx context.Context, client promapi.Client, query string, startTime time.Time, endTime time.Time) (int, error) {
+	u := client.URL(epLokiDelete, nil)
+	q := u.Query()
+	q.Set("query", query)
+	// The delete API takes the range in seconds and only from the URL, not from a form.
+	q.Set("start", strconv.FormatInt(startTime.Unix(), 10))
+	q.Set("end", strconv.FormatInt(endTime.Unix(), 10))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, _, _, err := do(ctx, client, req) //nolint:bodyclose
+	if err != nil {
+		if resp == nil {
+			// Unknown error.
+			return 0, err
+		}
+
+		return resp.StatusCode, err
+	}
+
+	return resp.StatusCode, nil
+}
+
+// setLokiRange sets the time range in nanoseconds, as Loki reads integer timestamps as such.
+func setLokiRange(q url.Values, startTime time.Time, endTime time.Time) {
+	q.Set("start", strconv.FormatInt(startTime.UnixNano(), 10))
+	q.Set("end", strconv.FormatInt(endTime.UnixNano(), 10))
+}
+
+func doLoki(ctx context.Context, client promapi.Client, u *url.URL, q url.Values,
+	cache bool) (*LokiResult, int, promapiv1.Warnings, error) {
+	var res LokiResult
+
+	code, warnings, err := doLokiInto(ctx, client, u, q, cache, &res)
+	if err != nil {
+		return nil, code, warnings, err
+	}
+
+	statsFrom(ctx).recordLoki(&res)
+
+	return &res, code, warnings, nil
+}
+
+// doLokiRaw executes a GET request against an endpoint of Loki which does not wrap its response like the query API.
+func doLokiRaw(ctx context.Context, client promapi.Client, u *url.URL, q url.Values, cache bool, v interface{}) (int, error) {
+	ctx, span := tracing.Start(ctx, "api "+u.Path)
+
+	code, err := doLokiRequest(ctx, client, u, q, cache, v)
+	span.End(err)
+
+	return code, err
+}
+
+func doLokiRequest(ctx context.Context, client promapi.Client, u *url.URL, q url.Values, cache bool, v interface{}) (int, error) {
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if !cache {
+		req.Header.Set("Cache-Control", "no-store")
+	}
+
+	start := time.Now()
+	resp, body, err := client.Do(ctx, req)
+	statsFrom(ctx).recordRequest(time.Since(start))
+	traceFrom(ctx).record(ctx, resp)
+
+	if err != nil {
+		if resp == nil {
+			// Unknown error.
+			return 0, err
+		}
+
+		return resp.StatusCode, err
+	}
+
+	statsFrom(ctx).recordBytes(body)
+
+	if resp.StatusCode/100 != 2 {
+		errorType, errorMsg := errorTypeAndMsgFor(resp)
+
+		return resp.StatusCode, &promapiv1.Error{
+			Type:   errorType,
+			Msg:    errorMsg,
+			Detail: string(body),
+		}
+	}
+
+	return resp.StatusCode, decode(ctx, body, v)
+}
+
+func doLokiInto(ctx context.Context, client promapi.Client, u *url.URL, q url.Values,
+	cache bool, v interface{}) (int, promapiv1.Warnings, error) {
+	resp, body, warnings, err := doGetFallback(ctx, client, u, q, cache) //nolint:bodyclose
+	if err != nil {
+		if resp == nil {
+			// Unknown error.
+			return 0, warnings, err
+		}
+
+		return resp.StatusCode, warnings, err
+	}
+
+	return resp.StatusCode, warnings, decode(ctx, body, v)
+}
+
+// decode unmarshals the data of a response, recording the time spent.
+func decode(ctx context.Context, data []byte, v interface{}) error {
+	start := time.Now()
+	defer func() { statsFrom(ctx).recordDecode(time.Since(start)) }()
+
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+type statsKey struct{}
+
+// Stats is the size of a query result. It is recorded by queries made with a context returned by WithStats.
+type Stats struct {
+	// Series is the number of series, or log streams, in the result.
+	Series int
+	// Samples is the number of samples, or log entries, in the result.
+	Samples int
+	// Bytes is the size of the response body.
+	Bytes int
+	// Data is the data of a successful response, undecoded.
+	Data []byte
+	// Engine are the statistics of the query engine, if requested and returned.
+	Engine *EngineStats
+	// RequestDuration is the time spent on requests until their response body was read.
+	RequestDuration time.Duration
+	// DecodeDuration is the time spent decoding the response bodies.
+	DecodeDuration time.Duration
+}
+
+// WithStats returns a context recording the size of the result of the query made with it.
+func WithStats(ctx context.Context) (context.Context, *Stats) {
+	s := &Stats{}
+
+	return context.WithValue(ctx, statsKey{}, s), s
+}
+
+func statsFrom(ctx context.Context) *Stats {
+	s, _ := ctx.Value(statsKey{}).(*Stats)
+
+	return s
+}
+
+func (s *Stats) recordBytes(body []byte) {
+	if s == nil {
+		return
+	}
+
+	s.Bytes = len(body)
+}
+
+// recordRequest adds the duration of a request, which includes the requests failed over by the GET fallback.
+func (s *Stats) recordRequest(d time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.RequestDuration += d
+}
+
+func (s *Stats) recordDecode(d time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.DecodeDuration += d
+}
+
+func (s *Stats) recordData(data []byte) {
+	if s == nil {
+		return
+	}
+
+	s.Data = data
+}
+
+func (s *Stats) recordEngine(e *EngineStats) {
+	if s == nil {
+		return
+	}
+
+	s.Engine = e
+}
+
+func (s *Stats) recordValue(v model.Value) {
+	if s == nil {
+		return
+	}
+
+	switch v := v.(type) {
+	case model.Vector:
+		s.Series, s.Samples = len(v), len(v)
+	case model.Matrix:
+		s.Series, s.Samples = len(v), 0
+		for _, ss := range v {
+			s.Samples += len(ss.Values) + len(ss.Histograms)
+		}
+	case *model.Scalar, *model.String:
+		s.Series, s.Samples = 1, 1
+	}
+}
+
+func (s *Stats) recordLoki(r *LokiResult) {
+	if s == nil {
+		return
+	}
+
+	if r.Value != nil {
+		s.recordValue(r.Value)
+		return
+	}
+
+	s.Series, s.Samples = len(r.Streams), 0
+	for _, st := range r.Streams {
+		s.Samples += len(st.Entries)
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/observatorium/up/pkg/tracing"
+)
+
+// ThanosTraceIDHeader is the header in which Thanos returns the ID of the trace of a request.
+const ThanosTraceIDHeader = "X-Thanos-Trace-Id"
+
+type traceKey struct{}
+
+// Trace is the trace of a query, recorded by queries made with a context returned by WithTrace.
+type Trace struct {
+	// ID is the ID of the trace of the last response, or empty if it was not traced.
+	ID string
+}
+
+// WithTrace returns a context recording the trace of the query made with it.
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	t := &Trace{}
+
+	return context.WithValue(ctx, traceKey{}, t), t
+}
+
+func traceFrom(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+
+	return t
+}
+
+// TraceID returns the ID of the trace of the last response recorded by the context, or an empty string.
+// Every query records its own trace, so the ID is that of the query even if queries run concurrently.
+func TraceID(ctx context.Context) string {
+	if t := traceFrom(ctx); t != nil {
+		return t.ID
+	}
+
+	return ""
+}
+
+// record records the trace ID returned by Thanos, or else the ID of the sampled trace of up propagated to the server.
+func (t *Trace) record(ctx context.Context, resp *http.Response) {
+	if t == nil {
+		return
+	}
+
+	if resp != nil {
+		if id := resp.Header.Get(ThanosTraceIDHeader); id != "" {
+			t.ID = id
+			return
+		}
+	}
+
+	if s := tracing.SpanFromContext(ctx); s.Sampled() {
+		t.ID = s.TraceID()
+	}
+}
@@ -1,2 +1,2 @@
-// Package auth provides the token and roundtripper for bearer based authentication.
+// Package auth provides the token, roundtrippers and gRPC credentials for bearer based authentication, tenancy and custom headers.
 package auth
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"context"
+)
+
+// TokenCredentials are gRPC per-call credentials setting the bearer token on every call.
+type TokenCredentials struct {
+	t TokenProvider
+}
+
+// NewTokenCredentials returns the gRPC per-call credentials for the token provider.
+func NewTokenCredentials(t TokenProvider) *TokenCredentials {
+	return &TokenCredentials{t: t}
+}
+
+func (c *TokenCredentials) GetRequestMetadata(_ context.Context, _ ...string) (map[string]string, error) {
+	token, err := c.t.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	if token == "" {
+		return nil, nil
+	}
+
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+func (c *TokenCredentials) RequireTransportSecurity() bool { return false }
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"net/http"
+)
+
+// HeadersRoundTripper sets headers on every request, e.g. the headers of a custom query.
+type HeadersRoundTripper struct {
+	r       http.RoundTripper
+	headers map[string]string
+}
+
+// NewHeadersRoundTripper returns a round tripper setting the given headers, replacing existing values.
+func NewHeadersRoundTripper(headers map[string]string, r http.RoundTripper) *HeadersRoundTripper {
+	if r == nil {
+		r = http.DefaultTransport
+	}
+
+	return &HeadersRoundTripper{
+		r:       r,
+		headers: headers,
+	}
+}
+
+func (r *HeadersRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(r.headers) == 0 {
+		return r.r.RoundTrip(req)
+	}
+
+	// Round trippers must not modify the request, it can be sent again on retries.
+	req = req.Clone(req.Context())
+
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+
+	return r.r.RoundTrip(req)
+}
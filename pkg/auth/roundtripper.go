@@ -7,10 +7,9 @@ import (
 )
 
 type BearerTokenRoundTripper struct {
-	l       log.Logger
-	r       http.RoundTripper
-	t       TokenProvider
-	TraceID string
+	l log.Logger
+	r http.RoundTripper
+	t TokenProvider
 }
 
 func NewBearerTokenRoundTripper(l log.Logger, t TokenProvider, r http.RoundTripper) *BearerTokenRoundTripper {
@@ -32,15 +31,10 @@ func (r *BearerTokenRoundTripper) RoundTrip(req *http.Request) (*http.Response,
 	}
 
 	if token != "" {
-		req.Header.Add("Authorization", "Bearer "+token)
+		// Round trippers must not modify the request, it can be sent again on retries.
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
 
-	resp, err := r.r.RoundTrip(req)
-	if err != nil {
-		return resp, err
-	}
-
-	r.TraceID = resp.Header.Get("X-Thanos-Trace-Id")
-
-	return resp, err
+	return r.r.RoundTrip(req)
 }
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"net/http"
+)
+
+type TenantRoundTripper struct {
+	r      http.RoundTripper
+	header string
+	tenant string
+}
+
+// NewTenantRoundTripper returns a round tripper setting the tenant in the given header. No header is set for an empty tenant.
+func NewTenantRoundTripper(header, tenant string, r http.RoundTripper) *TenantRoundTripper {
+	if r == nil {
+		r = http.DefaultTransport
+	}
+
+	return &TenantRoundTripper{
+		r:      r,
+		header: header,
+		tenant: tenant,
+	}
+}
+
+func (r *TenantRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.tenant != "" {
+		// Round trippers must not modify the request, it can be sent again on retries.
+		req = req.Clone(req.Context())
+		req.Header.Set(r.header, r.tenant)
+	}
+
+	return r.r.RoundTrip(req)
+}
@@ -0,0 +1,164 @@
+package instr
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// FilterGatherer returns a gatherer of the metrics of g served by the filter, without the dropped labels. The series
+// that only differ by the dropped labels are aggregated: counters, untyped metrics, summaries and histograms are
+// summed, without the quantiles of summaries and the native buckets of histograms, and gauges take the maximum,
+// e.g. the longest duration or the latest timestamp.
+func FilterGatherer(g prometheus.Gatherer, f options.MetricsFilter) prometheus.Gatherer {
+	if f.IsEmpty() {
+		return g
+	}
+
+	drop := make(map[string]struct{}, len(f.DropLabels))
+	for _, name := range f.DropLabels {
+		drop[name] = struct{}{}
+	}
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		// The families are gathered anew every time, they can be modified.
+		mfs, err := g.Gather()
+
+		res := mfs[:0]
+
+		for _, mf := range mfs {
+			if !f.Serves(mf.GetName()) {
+				continue
+			}
+
+			if len(drop) > 0 {
+				mf.Metric = dropLabels(mf.GetType(), mf.Metric, drop)
+			}
+
+			res = append(res, mf)
+		}
+
+		return res, err
+	})
+}
+
+func dropLabels(t dto.MetricType, ms []*dto.Metric, drop map[string]struct{}) []*dto.Metric {
+	var (
+		res   = ms[:0]
+		byKey = make(map[string]*dto.Metric, len(ms))
+	)
+
+	for _, m := range ms {
+		lps := m.Label[:0]
+
+		for _, lp := range m.Label {
+			if _, ok := drop[lp.GetName()]; !ok {
+				lps = append(lps, lp)
+			}
+		}
+
+		m.Label = lps
+
+		key := labelsKey(lps)
+
+		agg, ok := byKey[key]
+		if !ok {
+			byKey[key] = m
+			res = append(res, m)
+
+			continue
+		}
+
+		merge(t, agg, m)
+	}
+
+	// The exposition expects the series sorted by their labels, as the registry sorts them.
+	sort.Slice(res, func(i, j int) bool { return labelsKey(res[i].Label) < labelsKey(res[j].Label) })
+
+	return res
+}
+
+// labelsKey returns a key of the sorted label pairs of a metric.
+func labelsKey(lps []*dto.LabelPair) string {
+	var b strings.Builder
+
+	for _, lp := range lps {
+		b.WriteString(lp.GetName())
+		b.WriteByte('=')
+		b.WriteString(lp.GetValue())
+		b.WriteByte(0xff)
+	}
+
+	return b.String()
+}
+
+// merge aggregates the metric into agg, dropping what cannot be aggregated, e.g. exemplars.
+func merge(t dto.MetricType, agg, m *dto.Metric) {
+	agg.TimestampMs = nil
+
+	switch t {
+	case dto.MetricType_COUNTER:
+		agg.Counter.Value = add(agg.Counter.Value, m.Counter.GetValue())
+		agg.Counter.Exemplar, agg.Counter.CreatedTimestamp = nil, nil
+	case dto.MetricType_GAUGE:
+		agg.Gauge.Value = floatPtr(math.Max(agg.Gauge.GetValue(), m.Gauge.GetValue()))
+	case dto.MetricType_UNTYPED:
+		agg.Untyped.Value = add(agg.Untyped.Value, m.Untyped.GetValue())
+	case dto.MetricType_SUMMARY:
+		agg.Summary.SampleCount = uintPtr(agg.Summary.GetSampleCount() + m.Summary.GetSampleCount())
+		agg.Summary.SampleSum = add(agg.Summary.SampleSum, m.Summary.GetSampleSum())
+		agg.Summary.Quantile, agg.Summary.CreatedTimestamp = nil, nil
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		mergeHistograms(agg.Histogram, m.Histogram)
+	}
+}
+
+func mergeHistograms(agg, h *dto.Histogram) {
+	agg.SampleCount = uintPtr(agg.GetSampleCount() + h.GetSampleCount())
+	agg.SampleSum = add(agg.SampleSum, h.GetSampleSum())
+
+	if agg.SampleCountFloat != nil || h.SampleCountFloat != nil {
+		agg.SampleCountFloat = add(agg.SampleCountFloat, h.GetSampleCountFloat())
+	}
+
+	// The series of a metric have the same buckets, unless they are configured by the series.
+	sameBuckets := len(agg.Bucket) == len(h.Bucket)
+	for i := 0; sameBuckets && i < len(agg.Bucket); i++ {
+		sameBuckets = agg.Bucket[i].GetUpperBound() == h.Bucket[i].GetUpperBound()
+	}
+
+	if sameBuckets {
+		for i, b := range agg.Bucket {
+			b.CumulativeCount = uintPtr(b.GetCumulativeCount() + h.Bucket[i].GetCumulativeCount())
+			b.Exemplar = nil
+
+			if b.CumulativeCountFloat != nil || h.Bucket[i].CumulativeCountFloat != nil {
+				b.CumulativeCountFloat = add(b.CumulativeCountFloat, h.Bucket[i].GetCumulativeCountFloat())
+			}
+		}
+	} else {
+		agg.Bucket = nil
+	}
+
+	agg.CreatedTimestamp = nil
+	agg.Schema, agg.ZeroThreshold, agg.ZeroCount, agg.ZeroCountFloat = nil, nil, nil, nil
+	agg.NegativeSpan, agg.NegativeDelta, agg.NegativeCount = nil, nil, nil
+	agg.PositiveSpan, agg.PositiveDelta, agg.PositiveCount = nil, nil, nil
+}
+
+func add(v *float64, d float64) *float64 {
+	var f float64
+	if v != nil {
+		f = *v
+	}
+
+	return floatPtr(f + d)
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+func uintPtr(u uint64) *uint64 { return &u }
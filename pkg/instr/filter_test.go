@@ -0,0 +1,56 @@
+package instr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFilterGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	executed := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "up_custom_query_executed_total", Help: "Executed."},
+		[]string{"type", "query"})
+	duration := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "up_custom_query_last_duration", Help: "Duration."},
+		[]string{"query"})
+	requests := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "up_custom_query_duration_seconds", Help: "Requests.",
+		Buckets: []float64{1, 10}}, []string{"query"})
+	goroutines := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_goroutines", Help: "Goroutines."})
+	reg.MustRegister(executed, duration, requests, goroutines)
+
+	executed.WithLabelValues("query", "a").Add(1)
+	executed.WithLabelValues("query", "b").Add(2)
+	executed.WithLabelValues("labels", "c").Add(4)
+	duration.WithLabelValues("a").Set(3)
+	duration.WithLabelValues("b").Set(5)
+	requests.WithLabelValues("a").Observe(0.5)
+	requests.WithLabelValues("b").Observe(5)
+
+	var f options.MetricsFilter
+	testutil.Ok(t, f.Deny.Set("go_.*"))
+	testutil.Ok(t, f.DropLabels.Set("query"))
+
+	testutil.Ok(t, promtestutil.GatherAndCompare(FilterGatherer(reg, f), strings.NewReader(`
+# HELP up_custom_query_duration_seconds Requests.
+# TYPE up_custom_query_duration_seconds histogram
+up_custom_query_duration_seconds_bucket{le="1"} 1
+up_custom_query_duration_seconds_bucket{le="10"} 2
+up_custom_query_duration_seconds_bucket{le="+Inf"} 2
+up_custom_query_duration_seconds_sum 5.5
+up_custom_query_duration_seconds_count 2
+# HELP up_custom_query_executed_total Executed.
+# TYPE up_custom_query_executed_total counter
+up_custom_query_executed_total{type="labels"} 4
+up_custom_query_executed_total{type="query"} 3
+# HELP up_custom_query_last_duration Duration.
+# TYPE up_custom_query_last_duration gauge
+up_custom_query_last_duration 5
+`)))
+
+	// Without filter the gatherer is returned as is.
+	testutil.Equals(t, prometheus.Gatherer(reg), FilterGatherer(reg, options.MetricsFilter{}))
+}
@@ -1,64 +1,488 @@
 package instr
 
 import (
+	"time"
+
+	"github.com/observatorium/up/pkg/api"
+	"github.com/observatorium/up/pkg/options"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// ReadQueryType is the query type of reads in the result size metrics.
+const ReadQueryType = "read"
+
+// The resolution of native histograms, at most 10% growth from bucket to bucket, and the number of buckets
+// at which they are reset, at most once an hour.
+const (
+	nativeHistogramBucketFactor     = 1.1
+	nativeHistogramMaxBucketNumber  = 160
+	nativeHistogramMinResetDuration = time.Hour
+)
+
+// The phases of reading back logs in the logs read duration metric.
+const (
+	TotalReadPhase   = "total"
+	RequestReadPhase = "request"
+	DecodeReadPhase  = "decode"
+)
+
 type Metrics struct {
-	RemoteWriteRequests        *prometheus.CounterVec
-	RemoteWriteRequestDuration prometheus.Histogram
-	QueryResponses             *prometheus.CounterVec
-	QueryResponseDuration      prometheus.Histogram
-	MetricValueDifference      prometheus.Histogram
-	CustomQueryExecuted        *prometheus.CounterVec
-	CustomQueryErrors          *prometheus.CounterVec
-	CustomQueryRequestDuration *prometheus.HistogramVec
-	CustomQueryLastDuration    *prometheus.GaugeVec
+	RemoteWriteRequests          *prometheus.CounterVec
+	RemoteWriteRequestDuration   prometheus.Histogram
+	QueryResponses               *prometheus.CounterVec
+	QueryResponseDuration        prometheus.Histogram
+	MetricValueDifference        prometheus.Histogram
+	ClockSkew                    prometheus.Gauge
+	ExemplarQueries              *prometheus.CounterVec
+	ExemplarQueryDuration        prometheus.Histogram
+	MetadataQueries              *prometheus.CounterVec
+	MetadataQueryDuration        prometheus.Histogram
+	StoreSeriesRequests          *prometheus.CounterVec
+	StoreSeriesRequestDuration   prometheus.Histogram
+	StoreMetricValueDifference   prometheus.Histogram
+	ReadbackGaps                 prometheus.Counter
+	ReadbackGapDuration          prometheus.Histogram
+	ReadbackDuplicates           *prometheus.CounterVec
+	CustomQueryExecuted          *prometheus.CounterVec
+	CustomQueryErrors            *prometheus.CounterVec
+	CustomQueryAssertionFailures *prometheus.CounterVec
+	CustomQueryDiffMismatches    *prometheus.CounterVec
+	CustomQueryRequestDuration   *prometheus.HistogramVec
+	CustomQueryLastDuration      *prometheus.GaugeVec
+	CustomQueryUncachedDuration  *prometheus.GaugeVec
+	CustomQueryCacheSavings      *prometheus.GaugeVec
+	CustomQueryEngineSamples     *prometheus.GaugeVec
+	CustomQueryEngineTime        *prometheus.GaugeVec
+	QueryResultSeries            *prometheus.HistogramVec
+	QueryResultSamples           *prometheus.HistogramVec
+	QueryResponseSize            *prometheus.HistogramVec
+	ConfigReloads                *prometheus.CounterVec
+	ScenarioRuns                 *prometheus.CounterVec
+	ScenarioDuration             *prometheus.HistogramVec
+	ScenarioStepFailures         *prometheus.CounterVec
+	LogsWrites                   *prometheus.CounterVec
+	LogsWriteDuration            prometheus.Histogram
+	LogsQueries                  *prometheus.CounterVec
+	LogsQueryDuration            prometheus.Histogram
+	LogsPushSize                 prometheus.Histogram
+	LogsPushCompressedSize       prometheus.Histogram
+	LogsPushEntries              prometheus.Histogram
+	LogsReadEntries              prometheus.Histogram
+	LogsBytesWritten             prometheus.Counter
+	LogsActiveStreams            prometheus.Gauge
+	LogsStreamsCreated           prometheus.Counter
+	LogsOutOfOrder               prometheus.Counter
+	LogsDuplicates               prometheus.Counter
+	LogsTenantWrites             *prometheus.CounterVec
+	LogsReadDuration             *prometheus.HistogramVec
+	CheckWindowFailed            *prometheus.CounterVec
+	RequestPhaseDuration         *prometheus.HistogramVec
+	LastSuccessfulWrite          *prometheus.GaugeVec
+	LastSuccessfulRead           *prometheus.GaugeVec
+	LastSuccessfulQuery          *prometheus.GaugeVec
+	QueryWarnings                *prometheus.CounterVec
+	TLSCertReloads               *prometheus.CounterVec
+	TLSCertExpiry                *prometheus.GaugeVec
+	RequestEncodings             *prometheus.CounterVec
+	RequestRetries               *prometheus.CounterVec
+	RequestsThrottled            *prometheus.CounterVec
+	BuildInfoQueries             *prometheus.CounterVec
+	BuildInfoQueryDuration       prometheus.Histogram
+	TargetBuildInfo              *prometheus.GaugeVec
+	ResponsesTooLarge            *prometheus.CounterVec
+	FederateQueries              *prometheus.CounterVec
+	FederateQueryDuration        prometheus.Histogram
 }
 
-func RegisterMetrics(reg *prometheus.Registry) Metrics {
+func RegisterMetrics(reg prometheus.Registerer, b options.Buckets) Metrics {
 	m := Metrics{
 		RemoteWriteRequests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name: "up_remote_writes_total",
 			Help: "Total number of remote write requests.",
 		}, []string{"result", "http_code"}),
-		RemoteWriteRequestDuration: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
-			Name: "up_remote_writes_duration_seconds",
-			Help: "Duration of remote write requests.",
-		}),
+		RemoteWriteRequestDuration: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
+			Name:    "up_remote_writes_duration_seconds",
+			Help:    "Duration of remote write requests.",
+			Buckets: bucketsOrDefault(b.WriteDuration, prometheus.DefBuckets),
+		})),
 		QueryResponses: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name: "up_queries_total",
 			Help: "The total number of queries made.",
 		}, []string{"result", "http_code"}),
-		QueryResponseDuration: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
-			Name: "up_queries_duration_seconds",
-			Help: "Duration of up queries.",
-		}),
-		MetricValueDifference: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+		QueryResponseDuration: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
+			Name:    "up_queries_duration_seconds",
+			Help:    "Duration of up queries.",
+			Buckets: bucketsOrDefault(b.QueryDuration, prometheus.DefBuckets),
+		})),
+		MetricValueDifference: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
 			Name:    "up_metric_value_difference",
 			Help:    "The time difference between the current timestamp and the timestamp in the metrics value.",
+			Buckets: bucketsOrDefault(b.MetricValueDifference, prometheus.LinearBuckets(4, 0.25, 16)),
+		})),
+		ClockSkew: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "up_clock_skew_seconds",
+			Help: "The estimated clock skew to the read endpoint. Positive if the server clock is ahead.",
+		}),
+		ExemplarQueries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_exemplar_queries_total",
+			Help: "The total number of exemplar queries made.",
+		}, []string{"result", "http_code"}),
+		ExemplarQueryDuration: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
+			Name: "up_exemplar_queries_duration_seconds",
+			Help: "Duration of up exemplar queries.",
+		})),
+		MetadataQueries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_metadata_queries_total",
+			Help: "The total number of metadata queries made.",
+		}, []string{"result", "http_code"}),
+		MetadataQueryDuration: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
+			Name: "up_metadata_queries_duration_seconds",
+			Help: "Duration of up metadata queries.",
+		})),
+		StoreSeriesRequests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_store_series_requests_total",
+			Help: "The total number of StoreAPI series requests made.",
+		}, []string{"result", "grpc_code"}),
+		StoreSeriesRequestDuration: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
+			Name: "up_store_series_requests_duration_seconds",
+			Help: "Duration of StoreAPI series requests.",
+		})),
+		StoreMetricValueDifference: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
+			Name:    "up_store_metric_value_difference",
+			Help:    "The time difference between the current timestamp and the timestamp in the metrics value read from StoreAPI.",
 			Buckets: prometheus.LinearBuckets(4, 0.25, 16),
+		})),
+		ReadbackGaps: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "up_readback_gaps_total",
+			Help: "The total number of gaps between written samples detected by range readback.",
 		}),
+		ReadbackGapDuration: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
+			Name:    "up_readback_gap_duration_seconds",
+			Help:    "The duration of gaps between written samples detected by range readback.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+		})),
+		ReadbackDuplicates: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_readback_duplicates_total",
+			Help: "The total number of duplicated series and samples detected by range readback.",
+		}, []string{"type"}),
 		CustomQueryExecuted: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name: "up_custom_query_executed_total",
 			Help: "The total number of custom specified queries executed.",
 		}, []string{"type", "query", "http_code"}),
-		CustomQueryRequestDuration: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+		CustomQueryRequestDuration: promauto.With(reg).NewHistogramVec(native(b, prometheus.HistogramOpts{
 			Name: "up_custom_query_duration_seconds",
 			Help: "Duration of custom specified queries",
 			// We deliberately chose quite large buckets as we want to be able to accurately measure heavy queries.
-			Buckets: []float64{0.1, 0.25, 0.5, 1, 5, 10, 20, 30, 45, 60, 100, 120},
-		}, []string{"type", "query", "http_code"}),
+			Buckets: bucketsOrDefault(b.CustomQueryDuration, []float64{0.1, 0.25, 0.5, 1, 5, 10, 20, 30, 45, 60, 100, 120}),
+		}), []string{"type", "query", "http_code"}),
 		CustomQueryErrors: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name: "up_custom_query_errors_total",
 			Help: "The total number of custom specified queries executed.",
 		}, []string{"type", "query", "http_code"}),
+		CustomQueryAssertionFailures: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_custom_query_assertion_failures_total",
+			Help: "The total number of custom specified queries with a result not matching their assertions.",
+		}, []string{"type", "query"}),
+		CustomQueryDiffMismatches: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_custom_query_diff_mismatches_total",
+			Help: "The total number of series differing between the endpoints of diff queries, by series set or values.",
+		}, []string{"query", "type"}),
 		CustomQueryLastDuration: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
 			Name: "up_custom_query_last_duration",
 			Help: "The duration of the query execution last time the query was executed successfully.",
 		}, []string{"type", "query", "http_code"}),
+		CustomQueryUncachedDuration: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "up_custom_query_uncached_last_duration",
+			Help: "The duration of the last successful execution of a cached custom query bypassing caches.",
+		}, []string{"type", "query"}),
+		CustomQueryCacheSavings: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "up_custom_query_cache_savings_seconds",
+			Help: "The uncached minus the cached duration of the last execution of a cached custom query. Negative if caching is slower.",
+		}, []string{"type", "query"}),
+		CustomQueryEngineSamples: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "up_custom_query_engine_samples",
+			Help: "The samples processed by the query engine in the last execution of custom queries requesting stats.",
+		}, []string{"type", "query", "samples"}),
+		CustomQueryEngineTime: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "up_custom_query_engine_seconds",
+			Help: "The time spent by the query engine per phase in the last execution of custom queries requesting stats.",
+		}, []string{"type", "query", "phase"}),
+		QueryResultSeries: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "up_query_result_series",
+			Help:    "The number of series, or log streams, in the results of reads and custom queries.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 10),
+		}, []string{"type", "query"}),
+		QueryResultSamples: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "up_query_result_samples",
+			Help:    "The number of samples, or log entries, in the results of reads and custom queries.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 12),
+		}, []string{"type", "query"}),
+		QueryResponseSize: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "up_query_response_size_bytes",
+			Help:    "The size of the response bodies of reads and custom queries.",
+			Buckets: prometheus.ExponentialBuckets(256, 4, 10),
+		}, []string{"type", "query"}),
+		ConfigReloads: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_config_reloads_total",
+			Help: "The total number of reloads of changed queries and logs files.",
+		}, []string{"file", "result"}),
+		ScenarioRuns: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_scenario_runs_total",
+			Help: "The total number of scenario runs.",
+		}, []string{"scenario", "result"}),
+		ScenarioDuration: promauto.With(reg).NewHistogramVec(native(b, prometheus.HistogramOpts{
+			Name:    "up_scenario_duration_seconds",
+			Help:    "Duration of successful scenario runs, including the waits between steps.",
+			Buckets: []float64{1, 5, 10, 30, 60, 120, 300, 600},
+		}), []string{"scenario"}),
+		ScenarioStepFailures: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_scenario_step_failures_total",
+			Help: "The total number of failed scenario steps, which end the run of their scenario.",
+		}, []string{"scenario", "step"}),
+		LogsWrites: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_logs_writes_total",
+			Help: "Total number of log push requests.",
+		}, []string{"result", "http_code", "encoding"}),
+		LogsWriteDuration: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
+			Name:    "up_logs_writes_duration_seconds",
+			Help:    "Duration of log push requests.",
+			Buckets: bucketsOrDefault(b.WriteDuration, prometheus.DefBuckets),
+		})),
+		LogsQueries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_logs_queries_total",
+			Help: "The total number of queries reading back the pushed logs.",
+		}, []string{"result", "http_code"}),
+		LogsQueryDuration: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
+			Name:    "up_logs_queries_duration_seconds",
+			Help:    "Duration of queries reading back the pushed logs.",
+			Buckets: bucketsOrDefault(b.QueryDuration, prometheus.DefBuckets),
+		})),
+		LogsPushSize: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:    "up_logs_push_size_bytes",
+			Help:    "The size of the encoded payload of log push requests before compression.",
+			Buckets: prometheus.ExponentialBuckets(256, 4, 8),
+		}),
+		LogsPushCompressedSize: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:    "up_logs_push_compressed_size_bytes",
+			Help:    "The size of the compressed body of log push requests, either snappy compressed protobuf or gzipped.",
+			Buckets: prometheus.ExponentialBuckets(256, 4, 8),
+		}),
+		LogsPushEntries: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:    "up_logs_push_entries",
+			Help:    "The number of entries of log push requests.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 8),
+		}),
+		LogsReadEntries: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:    "up_logs_read_entries",
+			Help:    "The number of entries returned by queries reading back the pushed logs.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 8),
+		}),
+		LogsBytesWritten: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "up_logs_bytes_written_total",
+			Help: "The total number of bytes of log lines pushed successfully.",
+		}),
+		LogsActiveStreams: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "up_logs_active_streams",
+			Help: "The number of synthetic streams pushed to by the latest successful push.",
+		}),
+		LogsStreamsCreated: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "up_logs_streams_created_total",
+			Help: "The total number of synthetic streams created by churning the stream labels.",
+		}),
+		LogsOutOfOrder: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "up_logs_out_of_order_total",
+			Help: "The total number of log entries read back out of timestamp or sequence order.",
+		}),
+		LogsDuplicates: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "up_logs_duplicates_total",
+			Help: "The total number of log entries read back with a duplicated sequence number.",
+		}),
+		LogsTenantWrites: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_logs_tenant_writes_total",
+			Help: "Total number of log push requests per tenant rotated through by --logs-tenants.",
+		}, []string{"tenant", "result"}),
+		LogsReadDuration: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name: "up_logs_read_duration_seconds",
+			Help: "Duration of reading back the pushed logs by phase: the total, the requests and decoding the responses.",
+			// Sub-second differences are resolved by the default buckets and, if scraped, the native histogram.
+			Buckets:                         bucketsOrDefault(b.LogsReadDuration, prometheus.ExponentialBuckets(0.001, 2, 16)),
+			NativeHistogramBucketFactor:     nativeHistogramBucketFactor,
+			NativeHistogramMaxBucketNumber:  nativeHistogramMaxBucketNumber,
+			NativeHistogramMinResetDuration: nativeHistogramMinResetDuration,
+		}, []string{"phase"}),
+		CheckWindowFailed: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_check_window_failed_total",
+			Help: "The total number of evaluation windows in which the success ratio of a check was below the threshold.",
+		}, []string{"check", "tenant"}),
+		RequestPhaseDuration: promauto.With(reg).NewHistogramVec(native(b, prometheus.HistogramOpts{
+			Name: "up_request_phase_duration_seconds",
+			Help: "Duration of the phases of requests by endpoint, protocol, h1 or h2, and address family, ip4 or ip6: " +
+				"the DNS lookup, connecting, the TLS handshake and the time from writing the request to the first byte of the response.",
+			Buckets: bucketsOrDefault(b.RequestPhaseDuration, prometheus.ExponentialBuckets(0.001, 2, 16)),
+		}), []string{"endpoint", "phase", "protocol", "family"}),
+		LastSuccessfulWrite: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "up_last_successful_write_timestamp_seconds",
+			Help: "The Unix timestamp of the last successful write, by tenant. It is empty until the first success.",
+		}, []string{"tenant"}),
+		LastSuccessfulRead: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "up_last_successful_read_timestamp_seconds",
+			Help: "The Unix timestamp of the last successful read back, by tenant. It is empty until the first success.",
+		}, []string{"tenant"}),
+		LastSuccessfulQuery: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "up_last_successful_custom_query_timestamp_seconds",
+			Help: "The Unix timestamp of the last successful execution of a custom query. It is empty until the first success.",
+		}, []string{"type", "query"}),
+		QueryWarnings: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_query_warnings_total",
+			Help: "The total number of warnings returned by queries, e.g. of partial responses. Reads have the type 'read'.",
+		}, []string{"type", "query"}),
+		TLSCertReloads: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_tls_cert_reloads_total",
+			Help: "Total number of reloads of the client TLS certificates and CA after their files changed, by result.",
+		}, []string{"result"}),
+		TLSCertExpiry: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "up_tls_cert_expiry_timestamp_seconds",
+			Help: "The Unix timestamp the loaded client TLS certificate expires at, by file.",
+		}, []string{"file"}),
+		RequestEncodings: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_request_encodings_total",
+			Help: "Total number of bodies of requests and responses by endpoint, direction and the content encoding used.",
+		}, []string{"endpoint", "direction", "encoding"}),
+		RequestRetries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_request_retries_total",
+			Help: "Total number of retries of failed requests by endpoint and reason, the status code or 'error'.",
+		}, []string{"endpoint", "reason"}),
+		RequestsThrottled: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_requests_throttled_total",
+			Help: "Total number of requests that waited for the client-side rate limits, by endpoint.",
+		}, []string{"endpoint"}),
+		BuildInfoQueries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_build_info_queries_total",
+			Help: "The total number of build info queries made.",
+		}, []string{"result", "http_code"}),
+		BuildInfoQueryDuration: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
+			Name: "up_build_info_queries_duration_seconds",
+			Help: "Duration of up build info queries.",
+		})),
+		TargetBuildInfo: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "up_target_build_info",
+			Help: "A metric with a constant '1' value labeled by the version, revision, branch and Go version of the server " +
+				"behind the read endpoint, as of the latest build info query.",
+		}, []string{"version", "revision", "branch", "goversion"}),
+		ResponsesTooLarge: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_responses_too_large_total",
+			Help: "Total number of responses whose body exceeded the --max-response-size, by endpoint.",
+		}, []string{"endpoint"}),
+		FederateQueries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_federate_queries_total",
+			Help: "The total number of federation scrapes made.",
+		}, []string{"result", "http_code"}),
+		FederateQueryDuration: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
+			Name: "up_federate_queries_duration_seconds",
+			Help: "Duration of up federation scrapes.",
+		})),
 	}
 
 	return m
 }
+
+// TenantMetrics are the metrics of the write and read pipelines of a tenant of the --tenants-file.
+// They carry the tenant as a constant label, so the success ratio of every tenant is evaluated on its own.
+type TenantMetrics struct {
+	Writes        *prometheus.CounterVec
+	WriteDuration prometheus.Histogram
+	Reads         *prometheus.CounterVec
+	ReadDuration  prometheus.Histogram
+}
+
+func RegisterTenantMetrics(reg prometheus.Registerer, b options.Buckets, tenant string) TenantMetrics {
+	reg = prometheus.WrapRegistererWith(prometheus.Labels{"tenant": tenant}, reg)
+
+	return TenantMetrics{
+		Writes: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_tenant_writes_total",
+			Help: "Total number of write requests of the tenant.",
+		}, []string{"result", "http_code"}),
+		WriteDuration: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
+			Name:    "up_tenant_writes_duration_seconds",
+			Help:    "Duration of write requests of the tenant.",
+			Buckets: bucketsOrDefault(b.WriteDuration, prometheus.DefBuckets),
+		})),
+		Reads: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "up_tenant_reads_total",
+			Help: "Total number of queries reading back the data written by the tenant.",
+		}, []string{"result", "http_code"}),
+		ReadDuration: promauto.With(reg).NewHistogram(native(b, prometheus.HistogramOpts{
+			Name:    "up_tenant_reads_duration_seconds",
+			Help:    "Duration of queries reading back the data written by the tenant.",
+			Buckets: bucketsOrDefault(b.QueryDuration, prometheus.DefBuckets),
+		})),
+	}
+}
+
+// ObserveEngineStats records the statistics of the query engine for the custom query.
+func (m Metrics) ObserveEngineStats(queryType, name string, e *api.EngineStats) {
+	m.CustomQueryEngineSamples.WithLabelValues(queryType, name, "total_queryable").Set(float64(e.Samples.TotalQueryableSamples))
+	m.CustomQueryEngineSamples.WithLabelValues(queryType, name, "peak").Set(float64(e.Samples.PeakSamples))
+
+	for phase, v := range map[string]float64{
+		"eval_total":        e.Timings.EvalTotalTime,
+		"result_sort":       e.Timings.ResultSortTime,
+		"query_preparation": e.Timings.QueryPreparationTime,
+		"inner_eval":        e.Timings.InnerEvalTime,
+		"exec_queue":        e.Timings.ExecQueueTime,
+		"exec_total":        e.Timings.ExecTotalTime,
+	} {
+		m.CustomQueryEngineTime.WithLabelValues(queryType, name, phase).Set(v)
+	}
+}
+
+// ObserveResultSize records the size of a query result. Reads have the type ReadQueryType and no query name.
+func (m Metrics) ObserveResultSize(queryType, name string, s api.Stats) {
+	m.QueryResultSeries.WithLabelValues(queryType, name).Observe(float64(s.Series))
+	m.QueryResultSamples.WithLabelValues(queryType, name).Observe(float64(s.Samples))
+	m.QueryResponseSize.WithLabelValues(queryType, name).Observe(float64(s.Bytes))
+}
+
+// ObserveWarnings counts the warnings returned by a query. Reads have the type ReadQueryType and no query name.
+// The series of the query is created on its first execution, so increases from zero are seen.
+func (m Metrics) ObserveWarnings(queryType, name string, warn []string) {
+	m.QueryWarnings.WithLabelValues(queryType, name).Add(float64(len(warn)))
+}
+
+// ObserveLogsRead records the duration of the phases of reading back logs besides the total.
+func (m Metrics) ObserveLogsRead(s api.Stats) {
+	m.LogsReadDuration.WithLabelValues(RequestReadPhase).Observe(s.RequestDuration.Seconds())
+	m.LogsReadDuration.WithLabelValues(DecodeReadPhase).Observe(s.DecodeDuration.Seconds())
+}
+
+// ObserveWithTrace observes the duration of a request, with the ID of its trace as exemplar if it was traced,
+// which links slow requests to their traces. Exemplars are only exposed in the OpenMetrics format.
+func ObserveWithTrace(o prometheus.Observer, duration float64, t api.Trace) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && t.ID != "" {
+		eo.ObserveWithExemplar(duration, prometheus.Labels{"trace_id": t.ID})
+		return
+	}
+
+	o.Observe(duration)
+}
+
+// native returns the options of a duration or freshness histogram, also emitted as native histogram if enabled.
+func native(b options.Buckets, o prometheus.HistogramOpts) prometheus.HistogramOpts {
+	if b.Native {
+		o.NativeHistogramBucketFactor = nativeHistogramBucketFactor
+		o.NativeHistogramMaxBucketNumber = nativeHistogramMaxBucketNumber
+		o.NativeHistogramMinResetDuration = nativeHistogramMinResetDuration
+	}
+
+	return o
+}
+
+func bucketsOrDefault(b, def []float64) []float64 {
+	if len(b) == 0 {
+		return def
+	}
+
+	return b
+}
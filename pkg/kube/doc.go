@@ -0,0 +1,3 @@
+// Package kube reads and watches the keys of Kubernetes ConfigMaps and Secrets holding configuration files,
+// using the in-cluster service account.
+package kube
@@ -0,0 +1,243 @@
+package kube
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/observatorium/up/pkg/transport"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ConfigMapScheme and SecretScheme are the schemes of file names referring to a key of a ConfigMap or Secret,
+	// e.g. 'configmap://monitoring/up-queries/queries.yaml'.
+	ConfigMapScheme = "configmap"
+	SecretScheme    = "secret"
+
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// watchRetryInterval is the time to wait before watching again after a watch failed.
+	watchRetryInterval = 5 * time.Second
+)
+
+// Object is a key of a ConfigMap or Secret.
+type Object struct {
+	// Resource is either 'configmaps' or 'secrets'.
+	Resource  string
+	Namespace string
+	Name      string
+	Key       string
+}
+
+// ParseObject parses a file name referring to a key of a ConfigMap or Secret. It returns false for other file names.
+func ParseObject(name string) (Object, bool, error) {
+	u, err := url.Parse(name)
+	if err != nil || (u.Scheme != ConfigMapScheme && u.Scheme != SecretScheme) {
+		return Object{}, false, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	if u.Host == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Object{}, true, errors.Errorf("%q must have the format %s://<namespace>/<name>/<key>", name, u.Scheme)
+	}
+
+	return Object{Resource: u.Scheme + "s", Namespace: u.Host, Name: parts[0], Key: parts[1]}, true, nil
+}
+
+func (o Object) String() string {
+	return fmt.Sprintf("%s %s/%s key %s", strings.TrimSuffix(o.Resource, "s"), o.Namespace, o.Name, o.Key)
+}
+
+// Client is a minimal client of the Kubernetes API, only reading ConfigMaps and Secrets.
+type Client struct {
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewInClusterClient returns a client authenticated as the service account of the pod it runs in.
+func NewInClusterClient(l log.Logger) (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+
+	rt, err := transport.NewTLSTransport(l, options.TLS{CACert: serviceAccountDir + "/ca.crt"})
+	if err != nil {
+		return nil, errors.Wrap(err, "create round tripper")
+	}
+
+	// The token is read for every request, as the kubelet rotates it.
+	tp := auth.NewFileToken(serviceAccountDir + "/token")
+
+	return &Client{
+		endpoint: &url.URL{Scheme: transport.HTTPS, Host: net.JoinHostPort(host, port)},
+		client:   &http.Client{Transport: auth.NewBearerTokenRoundTripper(l, tp, rt)},
+	}, nil
+}
+
+// metadata is the metadata of objects needed to tell their versions apart.
+type metadata struct {
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+// Get returns the content of the key and the resource version of its object. If the resource version equals
+// the given one, nil content is returned as the key is unchanged.
+func (c *Client) Get(ctx context.Context, o Object, resourceVersion string) ([]byte, string, error) {
+	u := *c.endpoint
+	u.Path = fmt.Sprintf("/api/v1/namespaces/%s/%s/%s", o.Namespace, o.Resource, o.Name)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "creating request")
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "making request")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", errors.Errorf("getting %s: non-200 status: %s", o, res.Status)
+	}
+
+	var obj struct {
+		Metadata metadata          `json:"metadata"`
+		Data     map[string]string `json:"data"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&obj); err != nil {
+		return nil, "", errors.Wrapf(err, "decoding %s", o)
+	}
+
+	if obj.Metadata.ResourceVersion != "" && obj.Metadata.ResourceVersion == resourceVersion {
+		return nil, resourceVersion, nil
+	}
+
+	v, ok := obj.Data[o.Key]
+	if !ok {
+		return nil, "", errors.Errorf("%s does not exist", o)
+	}
+
+	// The data of Secrets is base64 encoded.
+	if o.Resource == SecretScheme+"s" {
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "decoding %s", o)
+		}
+
+		return b, obj.Metadata.ResourceVersion, nil
+	}
+
+	return []byte(v), obj.Metadata.ResourceVersion, nil
+}
+
+// event is an event of a watch.
+type event struct {
+	Type   string `json:"type"`
+	Object struct {
+		Metadata metadata `json:"metadata"`
+	} `json:"object"`
+}
+
+// Watch calls changed whenever the object changes, until the context is canceled. Failed watches are started again,
+// also calling changed, as changes could have been missed in the meantime.
+func (c *Client) Watch(ctx context.Context, l log.Logger, o Object, changed func()) {
+	var resourceVersion string
+
+	for {
+		rv, err := c.watch(ctx, o, resourceVersion, changed)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			level.Warn(l).Log("msg", "watch failed, watching again", "object", o, "err", err)
+
+			// The changes since the resource version can be gone, start over from the current version.
+			resourceVersion = ""
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchRetryInterval):
+			}
+
+			changed()
+
+			continue
+		}
+
+		// The API server ends watches after a timeout, continue where it ended.
+		resourceVersion = rv
+	}
+}
+
+// watch watches the object from the resource version until the watch ends, returning the last resource version seen.
+func (c *Client) watch(ctx context.Context, o Object, resourceVersion string, changed func()) (string, error) {
+	u := *c.endpoint
+	u.Path = fmt.Sprintf("/api/v1/namespaces/%s/%s", o.Namespace, o.Resource)
+
+	q := url.Values{}
+	q.Set("watch", "true")
+	q.Set("fieldSelector", "metadata.name="+o.Name)
+
+	if resourceVersion != "" {
+		q.Set("resourceVersion", resourceVersion)
+	}
+
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "creating request")
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "making request")
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", errors.Errorf("watching %s: non-200 status: %s", o, res.Status)
+	}
+
+	dec := json.NewDecoder(res.Body)
+
+	for {
+		var e event
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				return resourceVersion, nil
+			}
+
+			return "", errors.Wrap(err, "decoding event")
+		}
+
+		switch e.Type {
+		case "ERROR":
+			// Most likely the resource version is too old.
+			return "", errors.Errorf("watch of %s failed", o)
+		case "BOOKMARK":
+		default:
+			changed()
+		}
+
+		resourceVersion = e.Object.Metadata.ResourceVersion
+	}
+}
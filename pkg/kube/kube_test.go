@@ -0,0 +1,131 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+)
+
+func TestParseObject(t *testing.T) {
+	for i, tc := range []struct {
+		name     string
+		expected Object
+		ok       bool
+		err      bool
+	}{
+		{
+			name:     "configmap://monitoring/up/queries.yaml",
+			expected: Object{Resource: "configmaps", Namespace: "monitoring", Name: "up", Key: "queries.yaml"},
+			ok:       true,
+		},
+		{
+			name:     "secret://monitoring/up/queries.yaml",
+			expected: Object{Resource: "secrets", Namespace: "monitoring", Name: "up", Key: "queries.yaml"},
+			ok:       true,
+		},
+		{name: "queries.yaml"},
+		{name: "https://example.com/queries.yaml"},
+		{name: "configmap://monitoring/up", ok: true, err: true},
+		{name: "configmap://monitoring/up/queries/yaml", ok: true, err: true},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			o, ok, err := ParseObject(tc.name)
+			testutil.Equals(t, tc.ok, ok)
+
+			if tc.err {
+				testutil.NotOk(t, err)
+				return
+			}
+
+			testutil.Ok(t, err)
+			testutil.Equals(t, tc.expected, o)
+		})
+	}
+}
+
+func TestClient_Get(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/namespaces/monitoring/configmaps/up":
+			fmt.Fprint(w, `{"kind": "ConfigMap", "metadata": {"resourceVersion": "1"}, "data": {"queries.yaml": "queries: []"}}`)
+		case "/api/v1/namespaces/monitoring/secrets/up":
+			fmt.Fprint(w, `{"kind": "Secret", "metadata": {"resourceVersion": "2"}, "data": {"queries.yaml": "cXVlcmllczogW10="}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	testutil.Ok(t, err)
+
+	c := &Client{endpoint: u, client: s.Client()}
+	cm := Object{Resource: "configmaps", Namespace: "monitoring", Name: "up", Key: "queries.yaml"}
+
+	b, rv, err := c.Get(context.Background(), cm, "")
+	testutil.Ok(t, err)
+	testutil.Equals(t, "queries: []", string(b))
+	testutil.Equals(t, "1", rv)
+
+	// Unchanged objects return no content.
+	b, rv, err = c.Get(context.Background(), cm, "1")
+	testutil.Ok(t, err)
+	testutil.Assert(t, b == nil, "unchanged object must not return content")
+	testutil.Equals(t, "1", rv)
+
+	b, rv, err = c.Get(context.Background(), Object{Resource: "secrets", Namespace: "monitoring", Name: "up", Key: "queries.yaml"}, "1")
+	testutil.Ok(t, err)
+	testutil.Equals(t, "queries: []", string(b))
+	testutil.Equals(t, "2", rv)
+
+	_, _, err = c.Get(context.Background(), Object{Resource: "configmaps", Namespace: "monitoring", Name: "up", Key: "logs.yaml"}, "")
+	testutil.NotOk(t, err)
+
+	_, _, err = c.Get(context.Background(), Object{Resource: "configmaps", Namespace: "monitoring", Name: "other", Key: "queries.yaml"}, "")
+	testutil.NotOk(t, err)
+}
+
+func TestClient_Watch(t *testing.T) {
+	var resourceVersions []string
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testutil.Equals(t, "/api/v1/namespaces/monitoring/configmaps", r.URL.Path)
+		testutil.Equals(t, "true", r.URL.Query().Get("watch"))
+		testutil.Equals(t, "metadata.name=up", r.URL.Query().Get("fieldSelector"))
+
+		resourceVersions = append(resourceVersions, r.URL.Query().Get("resourceVersion"))
+
+		// The watch ends after the events, as after the timeout of the API server.
+		fmt.Fprintln(w, `{"type": "ADDED", "object": {"metadata": {"resourceVersion": "1"}}}`)
+		fmt.Fprintln(w, `{"type": "BOOKMARK", "object": {"metadata": {"resourceVersion": "2"}}}`)
+		fmt.Fprintln(w, `{"type": "MODIFIED", "object": {"metadata": {"resourceVersion": "3"}}}`)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	testutil.Ok(t, err)
+
+	c := &Client{endpoint: u, client: s.Client()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := 0
+
+	c.Watch(ctx, log.NewNopLogger(), Object{Resource: "configmaps", Namespace: "monitoring", Name: "up", Key: "queries.yaml"}, func() {
+		changes++
+		if changes == 4 {
+			cancel()
+		}
+	})
+
+	// Bookmarks are no changes, and the second watch continues from the last resource version.
+	testutil.Equals(t, 4, changes)
+	testutil.Equals(t, []string{"", "3"}, resourceVersions)
+}
@@ -0,0 +1,90 @@
+package logs
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// ChurnLabel is the stream label rotated by Churn.
+const ChurnLabel = "churn"
+
+// Churn rotates the value of the churn label on an interval, so that every interval pushes new streams,
+// exercising stream creation and the stream limits of Loki. A nil Churn leaves the labels unchanged.
+type Churn struct {
+	interval time.Duration
+	started  time.Time
+
+	mtx        sync.Mutex
+	generation int64
+	pushed     []prompb.Label
+}
+
+// NewChurn returns a Churn rotating the churn label every interval, starting now.
+func NewChurn(interval time.Duration) *Churn {
+	return &Churn{interval: interval, started: time.Now(), generation: -1}
+}
+
+// Labels returns the labels with the churn label of the generation at the given time.
+func (c *Churn) Labels(labels []prompb.Label, now time.Time) []prompb.Label {
+	if c == nil {
+		return labels
+	}
+
+	generation := int64(now.Sub(c.started) / c.interval)
+
+	res := make([]prompb.Label, 0, len(labels)+1)
+	res = append(res, labels...)
+	res = append(res, prompb.Label{Name: ChurnLabel, Value: strconv.FormatInt(generation, 10)})
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res
+}
+
+// Pushed records the labels of a successful push, returning whether they started a new generation of streams.
+func (c *Churn) Pushed(labels []prompb.Label) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var generation int64
+
+	for _, l := range labels {
+		if l.Name == ChurnLabel {
+			generation, _ = strconv.ParseInt(l.Value, 10, 64)
+		}
+	}
+
+	if generation <= c.generation {
+		return false
+	}
+
+	c.generation = generation
+	c.pushed = labels
+
+	return true
+}
+
+// ReadLabels returns the labels of the newest generation pushed successfully, which the reader expects
+// to find. The labels are returned unchanged until the first push succeeded.
+func (c *Churn) ReadLabels(labels []prompb.Label) []prompb.Label {
+	if c == nil {
+		return labels
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.pushed == nil {
+		return labels
+	}
+
+	return c.pushed
+}
@@ -0,0 +1,35 @@
+package logs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestChurn(t *testing.T) {
+	labels := []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "up"}}
+
+	var nilChurn *Churn
+	testutil.Equals(t, labels, nilChurn.Labels(labels, time.Now()))
+	testutil.Equals(t, labels, nilChurn.ReadLabels(labels))
+	testutil.Assert(t, !nilChurn.Pushed(labels), "nil churn must not create streams")
+
+	c := NewChurn(time.Minute)
+	testutil.Equals(t, labels, c.ReadLabels(labels))
+
+	first := c.Labels(labels, c.started.Add(30*time.Second))
+	testutil.Equals(t, []prompb.Label{{Name: "__name__", Value: "up"}, {Name: ChurnLabel, Value: "0"}, {Name: "job", Value: "up"}}, first)
+	testutil.Assert(t, c.Pushed(first), "first push must create streams")
+	testutil.Assert(t, !c.Pushed(first), "repeated push must not create streams")
+	testutil.Equals(t, first, c.ReadLabels(labels))
+
+	second := c.Labels(labels, c.started.Add(90*time.Second))
+	testutil.Equals(t, "1", second[1].Value)
+	testutil.Assert(t, c.Pushed(second), "push of the next generation must create streams")
+
+	// A late push of an older generation does not change the streams read back.
+	testutil.Assert(t, !c.Pushed(first), "older generation must not create streams")
+	testutil.Equals(t, second, c.ReadLabels(labels))
+}
@@ -0,0 +1,155 @@
+package logs
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/observatorium/up/pkg/tracing"
+	"github.com/observatorium/up/pkg/transport"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// pushMethod is the method of the distributor receiving pushes, see
+// https://github.com/grafana/loki/blob/main/pkg/push/push.proto.
+const pushMethod = "/logproto.Pusher/Push"
+
+// IsGRPC returns whether logs are pushed to the endpoint over gRPC instead of HTTP.
+func IsGRPC(endpoint *url.URL) bool {
+	return endpoint.Scheme == transport.GRPC || endpoint.Scheme == transport.GRPCS
+}
+
+// writeGRPC pushes to the gRPC API of the Loki distributor, bypassing the HTTP gateway. It returns the HTTP
+// status code equivalent to the gRPC status of the call.
+func writeGRPC(ctx context.Context, endpoint *url.URL, t auth.TokenProvider, wreq *PushRequest, m instr.Metrics, l log.Logger,
+	tls options.TLS, tenantHeader, tenant string) (int, error) {
+	buf, err := wreq.marshalProto()
+	if err != nil {
+		return 0, errors.Wrap(err, "marshalling proto")
+	}
+
+	m.LogsPushSize.Observe(float64(len(buf)))
+	m.LogsPushEntries.Observe(float64(wreq.entries()))
+
+	dialOpts := []grpc.DialOption{
+		grpc.WithContextDialer(transport.DialContext),
+		grpc.WithPerRPCCredentials(auth.NewTokenCredentials(t)),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})),
+	}
+
+	if endpoint.Scheme == transport.GRPCS {
+		tlsConfig, err := transport.NewTLSConfig(l, tls)
+		if err != nil {
+			return 0, errors.Wrap(err, "tls config")
+		}
+
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	conn, err := grpc.DialContext(ctx, endpoint.Host, dialOpts...)
+	if err != nil {
+		return 0, errors.Wrap(err, "dialing distributor")
+	}
+
+	defer func() {
+		if err := conn.Close(); err != nil {
+			level.Warn(l).Log("msg", "detected close error", "err", errors.Wrap(err, "distributor connection close"))
+		}
+	}()
+
+	// gRPC metadata keys are lower case.
+	if tenant != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(tenantHeader), tenant)
+	}
+
+	ctx, span := tracing.StartClient(ctx, "gRPC "+pushMethod)
+	if span != nil {
+		ctx = metadata.AppendToOutgoingContext(ctx, tracing.TraceParentHeader, span.TraceParent())
+		span.SetAttributes("rpc.system", "grpc", "rpc.method", pushMethod, "net.peer.name", endpoint.Host)
+	}
+
+	var res []byte
+
+	err = conn.Invoke(ctx, pushMethod, buf, &res)
+	span.SetAttributes("rpc.grpc.status_code", int(status.Code(err)))
+	span.End(err)
+
+	if err != nil {
+		return httpCode(err), errors.Wrap(err, "push request failed")
+	}
+
+	m.LogsBytesWritten.Add(float64(wreq.LineBytes()))
+
+	// Loki answers successful HTTP pushes with no content.
+	return http.StatusNoContent, nil
+}
+
+// httpCode returns the HTTP status code of a failed gRPC call. Loki fails calls with HTTP status codes as gRPC codes,
+// other codes are mapped to the closest HTTP status code.
+func httpCode(err error) int {
+	code := status.Code(err)
+	if code >= 100 && code < 600 {
+		return int(code)
+	}
+
+	switch code {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+
+	return http.StatusInternalServerError
+}
+
+// rawCodec sends and receives the encoded messages as is, as the push request is encoded by hand.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, errors.Errorf("unexpected message of type %T", v)
+	}
+
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return errors.Errorf("unexpected message of type %T", v)
+	}
+
+	*b = append((*b)[:0], data...)
+
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
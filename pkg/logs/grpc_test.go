@@ -0,0 +1,82 @@
+package logs
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestWrite_GRPC(t *testing.T) {
+	var (
+		method string
+		md     metadata.MD
+		pushed []byte
+		fail   error
+	)
+
+	srv := grpc.NewServer(grpc.ForceServerCodec(rawCodec{}), grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		method, _ = grpc.MethodFromServerStream(stream)
+		md, _ = metadata.FromIncomingContext(stream.Context())
+
+		if err := stream.RecvMsg(&pushed); err != nil {
+			return err
+		}
+
+		if fail != nil {
+			return fail
+		}
+
+		return stream.SendMsg([]byte{})
+	}))
+	defer srv.Stop()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	testutil.Ok(t, err)
+
+	go func() { _ = srv.Serve(lis) }()
+
+	u, err := url.Parse("grpc://" + lis.Addr().String())
+	testutil.Ok(t, err)
+
+	wreq := Generate(nil, [][]string{{"1", "line"}}, nil)
+	m := instr.RegisterMetrics(prometheus.NewRegistry(), options.Buckets{})
+
+	code, err := Write(context.Background(), u, auth.NewStaticToken("secret"), wreq, m, log.NewNopLogger(), options.TLS{},
+		options.ProtobufPushEncoding, options.NoPushCompression, "X-Scope-OrgID", "team-a")
+	testutil.Ok(t, err)
+	testutil.Equals(t, http.StatusNoContent, code)
+	testutil.Equals(t, pushMethod, method)
+	testutil.Equals(t, []string{"team-a"}, md.Get("x-scope-orgid"))
+	testutil.Equals(t, []string{"Bearer secret"}, md.Get("authorization"))
+
+	// The push is not snappy compressed, unlike over HTTP.
+	streams := consumeMessage(t, pushed)[1]
+	testutil.Equals(t, 1, len(streams))
+	testutil.Equals(t, []interface{}{[]byte("{}")}, consumeMessage(t, streams[0].([]byte))[protowire.Number(1)])
+
+	// Loki returns HTTP status codes as gRPC codes.
+	fail = status.Error(codes.Code(http.StatusTooManyRequests), "rate limited")
+	code, err = Write(context.Background(), u, auth.NewStaticToken(""), wreq, m, log.NewNopLogger(), options.TLS{},
+		options.ProtobufPushEncoding, options.NoPushCompression, "X-Scope-OrgID", "")
+	testutil.NotOk(t, err)
+	testutil.Equals(t, http.StatusTooManyRequests, code)
+
+	fail = status.Error(codes.Unavailable, "stopping")
+	code, _ = Write(context.Background(), u, auth.NewStaticToken(""), wreq, m, log.NewNopLogger(), options.TLS{},
+		options.ProtobufPushEncoding, options.NoPushCompression, "X-Scope-OrgID", "")
+	testutil.Equals(t, http.StatusServiceUnavailable, code)
+}
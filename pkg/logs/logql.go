@@ -0,0 +1,914 @@
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// ValidateQuery returns an error if the query is not a valid LogQL log or metric query.
+// The structure of the query is checked without evaluating it, so it is lenient about semantics
+// like which range aggregations require unwrapped labels.
+func ValidateQuery(query string) error {
+	p, err := newLogQLParser(query)
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.expr(0); err != nil {
+		return err
+	}
+
+	return p.expectEOF()
+}
+
+// ValidateSelector returns an error if the query is not a valid LogQL stream selector.
+func ValidateSelector(selector string) error {
+	p, err := newLogQLParser(selector)
+	if err != nil {
+		return err
+	}
+
+	if err := p.selector(); err != nil {
+		return err
+	}
+
+	return p.expectEOF()
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenDuration
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	pos  int
+}
+
+// Operators, longest first so they are lexed greedily. The flags of parsers are lexed as operators like by Loki.
+var logQLOps = []string{
+	"--keep-empty", "--strict",
+	"|=", "|~", "|>", "!=", "!~", "!>", "=~", "==", ">=", "<=",
+	"{", "}", "(", ")", "[", "]", ",", "|", "=", ">", "<", "+", "-", "*", "/", "%", "^",
+}
+
+var bytesRE = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?([KMGTPE]i?)?[Bb]$`)
+
+func lexLogQL(query string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(query); {
+		c := query[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#':
+			// Comments run to the end of the line.
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '"' || c == '`':
+			end, err := stringEnd(query, i)
+			if err != nil {
+				return nil, err
+			}
+
+			s, err := strconv.Unquote(query[i:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %w", i, err)
+			}
+
+			tokens = append(tokens, token{kind: tokenString, val: s, pos: i})
+			i = end
+		case c >= '0' && c <= '9':
+			end := i
+			for end < len(query) && (isIdentChar(query[end]) || query[end] == '.') {
+				end++
+			}
+
+			kind, err := classifyNumber(query[i:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid number at position %d: %w", i, err)
+			}
+
+			tokens = append(tokens, token{kind: kind, val: query[i:end], pos: i})
+			i = end
+		case isIdentChar(c):
+			end := i
+			for end < len(query) && isIdentChar(query[end]) {
+				end++
+			}
+
+			tokens = append(tokens, token{kind: tokenIdent, val: query[i:end], pos: i})
+			i = end
+		default:
+			op := ""
+
+			for _, o := range logQLOps {
+				if strings.HasPrefix(query[i:], o) {
+					op = o
+					break
+				}
+			}
+
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+
+			tokens = append(tokens, token{kind: tokenOp, val: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(query)}), nil
+}
+
+// stringEnd returns the position after the string starting at i.
+func stringEnd(query string, i int) (int, error) {
+	quote := query[i]
+
+	for j := i + 1; j < len(query); j++ {
+		switch query[j] {
+		case '\\':
+			if quote == '"' {
+				j++
+			}
+		case quote:
+			return j + 1, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unterminated string at position %d", i)
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// classifyNumber distinguishes numbers, including byte sizes, from durations.
+func classifyNumber(s string) (tokenKind, error) {
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return tokenNumber, nil
+	}
+
+	if bytesRE.MatchString(s) {
+		return tokenNumber, nil
+	}
+
+	if _, err := model.ParseDuration(s); err == nil {
+		return tokenDuration, nil
+	}
+
+	if _, err := time.ParseDuration(s); err == nil {
+		return tokenDuration, nil
+	}
+
+	return 0, fmt.Errorf("%q is neither a number, byte size nor duration", s)
+}
+
+type exprKind int
+
+const (
+	logExpr exprKind = iota
+	metricExpr
+)
+
+var (
+	rangeAggregations = map[string]bool{
+		"rate": true, "rate_counter": true, "count_over_time": true, "bytes_rate": true, "bytes_over_time": true,
+		"absent_over_time": true, "sum_over_time": true, "avg_over_time": true, "max_over_time": true,
+		"min_over_time": true, "stdvar_over_time": true, "stddev_over_time": true, "quantile_over_time": true,
+		"first_over_time": true, "last_over_time": true,
+	}
+	vectorAggregations = map[string]bool{
+		"sum": true, "avg": true, "min": true, "max": true, "count": true, "stddev": true, "stdvar": true,
+		"topk": true, "bottomk": true, "sort": true, "sort_desc": true,
+	}
+	binaryPrecedence = map[string]int{
+		"or": 1, "and": 2, "unless": 2,
+		"==": 3, "!=": 3, ">": 3, "<": 3, ">=": 3, "<=": 3,
+		"+": 4, "-": 4, "*": 5, "/": 5, "%": 5, "^": 6,
+	}
+	labelFilterOps = map[string]bool{"=": true, "!=": true, "=~": true, "!~": true, ">": true, ">=": true, "<": true, "<=": true, "==": true}
+	lineFilterOps  = map[string]bool{"|=": true, "!=": true, "|~": true, "!~": true, "|>": true, "!>": true}
+)
+
+type logQLParser struct {
+	tokens []token
+	i      int
+}
+
+func newLogQLParser(query string) (*logQLParser, error) {
+	tokens, err := lexLogQL(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logQLParser{tokens: tokens}, nil
+}
+
+func (p *logQLParser) peek() token { return p.tokens[p.i] }
+
+func (p *logQLParser) peekAt(n int) token {
+	if p.i+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+
+	return p.tokens[p.i+n]
+}
+
+func (p *logQLParser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+
+	return t
+}
+
+func (p *logQLParser) is(kind tokenKind, val string) bool {
+	t := p.peek()
+	return t.kind == kind && t.val == val
+}
+
+func (p *logQLParser) unexpected(t token) error {
+	if t.kind == tokenEOF {
+		return fmt.Errorf("parse error: unexpected end of query")
+	}
+
+	return fmt.Errorf("parse error at position %d: unexpected %q", t.pos, t.val)
+}
+
+func (p *logQLParser) expect(kind tokenKind, val string) (token, error) {
+	t := p.next()
+	if t.kind != kind || (val != "" && t.val != val) {
+		return t, p.unexpected(t)
+	}
+
+	return t, nil
+}
+
+func (p *logQLParser) expectEOF() error {
+	if t := p.peek(); t.kind != tokenEOF {
+		return p.unexpected(t)
+	}
+
+	return nil
+}
+
+// binaryOp returns the precedence of the binary operator at the current position, if any.
+func (p *logQLParser) binaryOp() (string, int, bool) {
+	t := p.peek()
+	if t.kind != tokenOp && t.kind != tokenIdent {
+		return "", 0, false
+	}
+
+	prec, ok := binaryPrecedence[t.val]
+
+	return t.val, prec, ok
+}
+
+func (p *logQLParser) expr(minPrec int) (exprKind, error) {
+	kind, err := p.unary()
+	if err != nil {
+		return kind, err
+	}
+
+	for {
+		op, prec, ok := p.binaryOp()
+		if !ok || prec < minPrec {
+			return kind, nil
+		}
+
+		t := p.next()
+
+		if err := p.binaryModifiers(); err != nil {
+			return kind, err
+		}
+
+		// Exponentiation is right associative.
+		nextPrec := prec + 1
+		if op == "^" {
+			nextPrec = prec
+		}
+
+		right, err := p.expr(nextPrec)
+		if err != nil {
+			return kind, err
+		}
+
+		if kind != metricExpr || right != metricExpr {
+			return kind, fmt.Errorf("parse error at position %d: binary operation %q requires metric queries", t.pos, op)
+		}
+	}
+}
+
+func (p *logQLParser) binaryModifiers() error {
+	if p.is(tokenIdent, "bool") {
+		p.next()
+	}
+
+	if p.is(tokenIdent, "on") || p.is(tokenIdent, "ignoring") {
+		p.next()
+
+		if err := p.labelList(); err != nil {
+			return err
+		}
+
+		if p.is(tokenIdent, "group_left") || p.is(tokenIdent, "group_right") {
+			p.next()
+
+			if p.is(tokenOp, "(") {
+				return p.labelList()
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *logQLParser) unary() (exprKind, error) {
+	if p.is(tokenOp, "-") || p.is(tokenOp, "+") {
+		t := p.next()
+
+		kind, err := p.unary()
+		if err != nil {
+			return kind, err
+		}
+
+		if kind != metricExpr {
+			return kind, fmt.Errorf("parse error at position %d: unary %q requires a metric query", t.pos, t.val)
+		}
+
+		return metricExpr, nil
+	}
+
+	return p.primary()
+}
+
+func (p *logQLParser) primary() (exprKind, error) {
+	t := p.peek()
+
+	switch {
+	case t.kind == tokenNumber:
+		p.next()
+		return metricExpr, nil
+	case t.kind == tokenOp && t.val == "(":
+		p.next()
+
+		kind, err := p.expr(0)
+		if err != nil {
+			return kind, err
+		}
+
+		_, err = p.expect(tokenOp, ")")
+
+		return kind, err
+	case t.kind == tokenOp && t.val == "{":
+		if err := p.selector(); err != nil {
+			return logExpr, err
+		}
+
+		if err := p.pipeline(); err != nil {
+			return logExpr, err
+		}
+
+		if p.is(tokenOp, "[") {
+			return logExpr, fmt.Errorf("parse error at position %d: ranges are only allowed in range aggregations", p.peek().pos)
+		}
+
+		return logExpr, nil
+	case t.kind == tokenIdent && rangeAggregations[t.val]:
+		return metricExpr, p.rangeAggregation()
+	case t.kind == tokenIdent && vectorAggregations[t.val]:
+		return metricExpr, p.vectorAggregation()
+	case t.kind == tokenIdent && t.val == "label_replace":
+		return metricExpr, p.labelReplace()
+	case t.kind == tokenIdent && t.val == "vector":
+		p.next()
+
+		if _, err := p.expect(tokenOp, "("); err != nil {
+			return metricExpr, err
+		}
+
+		if _, err := p.expect(tokenNumber, ""); err != nil {
+			return metricExpr, err
+		}
+
+		_, err := p.expect(tokenOp, ")")
+
+		return metricExpr, err
+	}
+
+	return logExpr, p.unexpected(t)
+}
+
+// selector parses a stream selector like {app="up", env=~"prod|stage"}.
+func (p *logQLParser) selector() error {
+	if _, err := p.expect(tokenOp, "{"); err != nil {
+		return err
+	}
+
+	for {
+		if _, err := p.expect(tokenIdent, ""); err != nil {
+			return err
+		}
+
+		op := p.next()
+		if op.kind != tokenOp || (op.val != "=" && op.val != "!=" && op.val != "=~" && op.val != "!~") {
+			return p.unexpected(op)
+		}
+
+		v, err := p.expect(tokenString, "")
+		if err != nil {
+			return err
+		}
+
+		if op.val == "=~" || op.val == "!~" {
+			if _, err := regexp.Compile(v.val); err != nil {
+				return fmt.Errorf("invalid regular expression at position %d: %w", v.pos, err)
+			}
+		}
+
+		if !p.is(tokenOp, ",") {
+			break
+		}
+
+		p.next()
+	}
+
+	_, err := p.expect(tokenOp, "}")
+
+	return err
+}
+
+// pipeline parses the line filters and stages following a stream selector.
+func (p *logQLParser) pipeline() error {
+	for {
+		t := p.peek()
+
+		switch {
+		case t.kind == tokenOp && lineFilterOps[t.val]:
+			if err := p.lineFilter(); err != nil {
+				return err
+			}
+		case t.kind == tokenOp && t.val == "|":
+			p.next()
+
+			if err := p.stage(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *logQLParser) lineFilter() error {
+	op := p.next()
+
+	for {
+		if p.is(tokenIdent, "ip") {
+			if err := p.ipFilter(); err != nil {
+				return err
+			}
+		} else {
+			v, err := p.expect(tokenString, "")
+			if err != nil {
+				return err
+			}
+
+			if op.val == "|~" || op.val == "!~" {
+				if _, err := regexp.Compile(v.val); err != nil {
+					return fmt.Errorf("invalid regular expression at position %d: %w", v.pos, err)
+				}
+			}
+		}
+
+		if !p.is(tokenIdent, "or") {
+			return nil
+		}
+
+		p.next()
+	}
+}
+
+func (p *logQLParser) ipFilter() error {
+	p.next()
+
+	if _, err := p.expect(tokenOp, "("); err != nil {
+		return err
+	}
+
+	if _, err := p.expect(tokenString, ""); err != nil {
+		return err
+	}
+
+	_, err := p.expect(tokenOp, ")")
+
+	return err
+}
+
+func (p *logQLParser) stage() error {
+	t := p.peek()
+
+	if t.kind == tokenOp && t.val == "(" {
+		return p.labelFilters()
+	}
+
+	if t.kind != tokenIdent {
+		return p.unexpected(t)
+	}
+
+	// Label filters start with a label name followed by a comparison, stages with their name.
+	if next := p.peekAt(1); next.kind == tokenOp && labelFilterOps[next.val] {
+		return p.labelFilters()
+	}
+
+	p.next()
+
+	switch t.val {
+	case "json":
+		return p.params(true)
+	case "logfmt":
+		for p.is(tokenOp, "--strict") || p.is(tokenOp, "--keep-empty") {
+			p.next()
+		}
+
+		return p.params(true)
+	case "regexp":
+		v, err := p.expect(tokenString, "")
+		if err != nil {
+			return err
+		}
+
+		if _, err := regexp.Compile(v.val); err != nil {
+			return fmt.Errorf("invalid regular expression at position %d: %w", v.pos, err)
+		}
+
+		return nil
+	case "pattern", "line_format":
+		_, err := p.expect(tokenString, "")
+		return err
+	case "unpack", "decolorize":
+		return nil
+	case "label_format":
+		return p.labelFormat()
+	case "drop", "keep":
+		return p.params(false)
+	case "unwrap":
+		return p.unwrap()
+	}
+
+	return fmt.Errorf("parse error at position %d: unknown pipeline stage %q", t.pos, t.val)
+}
+
+// params parses a comma-separated list of labels, optionally assigned a string, like a, b="c".
+// Parsers like json extract all labels if the list is empty.
+func (p *logQLParser) params(optional bool) error {
+	if p.peek().kind != tokenIdent {
+		if optional {
+			return nil
+		}
+
+		return p.unexpected(p.peek())
+	}
+
+	for {
+		if _, err := p.expect(tokenIdent, ""); err != nil {
+			return err
+		}
+
+		if p.is(tokenOp, "=") {
+			p.next()
+
+			if _, err := p.expect(tokenString, ""); err != nil {
+				return err
+			}
+		}
+
+		if !p.is(tokenOp, ",") {
+			return nil
+		}
+
+		p.next()
+	}
+}
+
+func (p *logQLParser) labelFormat() error {
+	for {
+		if _, err := p.expect(tokenIdent, ""); err != nil {
+			return err
+		}
+
+		if _, err := p.expect(tokenOp, "="); err != nil {
+			return err
+		}
+
+		// Labels are renamed with an identifier and formatted with a template string.
+		v := p.next()
+		if v.kind != tokenString && v.kind != tokenIdent {
+			return p.unexpected(v)
+		}
+
+		if !p.is(tokenOp, ",") {
+			return nil
+		}
+
+		p.next()
+	}
+}
+
+func (p *logQLParser) unwrap() error {
+	label, err := p.expect(tokenIdent, "")
+	if err != nil {
+		return err
+	}
+
+	switch label.val {
+	case "duration", "duration_seconds", "bytes":
+		if !p.is(tokenOp, "(") {
+			// A label with the name of a conversion function.
+			return nil
+		}
+
+		p.next()
+
+		if _, err := p.expect(tokenIdent, ""); err != nil {
+			return err
+		}
+
+		_, err := p.expect(tokenOp, ")")
+
+		return err
+	}
+
+	return nil
+}
+
+// labelFilters parses label filters combined with and, or, commas or spaces, like a="b" or (c>1, d=~"e").
+func (p *logQLParser) labelFilters() error {
+	if err := p.labelFilter(); err != nil {
+		return err
+	}
+
+	for {
+		switch {
+		case p.is(tokenIdent, "and") || p.is(tokenIdent, "or") || p.is(tokenOp, ","):
+			p.next()
+		case p.peek().kind == tokenIdent && p.peekAt(1).kind == tokenOp && labelFilterOps[p.peekAt(1).val]:
+		case p.is(tokenOp, "("):
+		default:
+			return nil
+		}
+
+		if err := p.labelFilter(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *logQLParser) labelFilter() error {
+	if p.is(tokenOp, "(") {
+		p.next()
+
+		if err := p.labelFilters(); err != nil {
+			return err
+		}
+
+		_, err := p.expect(tokenOp, ")")
+
+		return err
+	}
+
+	if _, err := p.expect(tokenIdent, ""); err != nil {
+		return err
+	}
+
+	op := p.next()
+	if op.kind != tokenOp || !labelFilterOps[op.val] {
+		return p.unexpected(op)
+	}
+
+	if p.is(tokenIdent, "ip") {
+		return p.ipFilter()
+	}
+
+	v := p.next()
+
+	switch v.kind {
+	case tokenString:
+		if op.val == "=~" || op.val == "!~" {
+			if _, err := regexp.Compile(v.val); err != nil {
+				return fmt.Errorf("invalid regular expression at position %d: %w", v.pos, err)
+			}
+		}
+	case tokenNumber, tokenDuration:
+	default:
+		return p.unexpected(v)
+	}
+
+	return nil
+}
+
+// logRange parses a stream selector with pipeline and range, the argument of range aggregations.
+func (p *logQLParser) logRange() error {
+	if p.is(tokenOp, "(") {
+		p.next()
+
+		if err := p.selector(); err != nil {
+			return err
+		}
+
+		if err := p.pipeline(); err != nil {
+			return err
+		}
+
+		if _, err := p.expect(tokenOp, ")"); err != nil {
+			return err
+		}
+	} else {
+		if err := p.selector(); err != nil {
+			return err
+		}
+
+		if err := p.pipeline(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := p.expect(tokenOp, "["); err != nil {
+		return err
+	}
+
+	if _, err := p.expect(tokenDuration, ""); err != nil {
+		return err
+	}
+
+	if _, err := p.expect(tokenOp, "]"); err != nil {
+		return err
+	}
+
+	if p.is(tokenIdent, "offset") {
+		p.next()
+
+		if _, err := p.expect(tokenDuration, ""); err != nil {
+			return err
+		}
+	}
+
+	// The pipeline can also follow the range.
+	return p.pipeline()
+}
+
+func (p *logQLParser) rangeAggregation() error {
+	name := p.next()
+
+	if _, err := p.expect(tokenOp, "("); err != nil {
+		return err
+	}
+
+	if name.val == "quantile_over_time" {
+		if _, err := p.expect(tokenNumber, ""); err != nil {
+			return err
+		}
+
+		if _, err := p.expect(tokenOp, ","); err != nil {
+			return err
+		}
+	}
+
+	if err := p.logRange(); err != nil {
+		return err
+	}
+
+	if _, err := p.expect(tokenOp, ")"); err != nil {
+		return err
+	}
+
+	return p.grouping()
+}
+
+func (p *logQLParser) vectorAggregation() error {
+	name := p.next()
+
+	if err := p.grouping(); err != nil {
+		return err
+	}
+
+	if _, err := p.expect(tokenOp, "("); err != nil {
+		return err
+	}
+
+	if name.val == "topk" || name.val == "bottomk" {
+		if _, err := p.expect(tokenNumber, ""); err != nil {
+			return err
+		}
+
+		if _, err := p.expect(tokenOp, ","); err != nil {
+			return err
+		}
+	}
+
+	start := p.peek()
+
+	kind, err := p.expr(0)
+	if err != nil {
+		return err
+	}
+
+	if kind != metricExpr {
+		return fmt.Errorf("parse error at position %d: aggregation %q requires a metric query", start.pos, name.val)
+	}
+
+	if _, err := p.expect(tokenOp, ")"); err != nil {
+		return err
+	}
+
+	return p.grouping()
+}
+
+func (p *logQLParser) labelReplace() error {
+	p.next()
+
+	if _, err := p.expect(tokenOp, "("); err != nil {
+		return err
+	}
+
+	start := p.peek()
+
+	kind, err := p.expr(0)
+	if err != nil {
+		return err
+	}
+
+	if kind != metricExpr {
+		return fmt.Errorf("parse error at position %d: label_replace requires a metric query", start.pos)
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := p.expect(tokenOp, ","); err != nil {
+			return err
+		}
+
+		if _, err := p.expect(tokenString, ""); err != nil {
+			return err
+		}
+	}
+
+	_, err = p.expect(tokenOp, ")")
+
+	return err
+}
+
+// grouping parses an optional by or without clause.
+func (p *logQLParser) grouping() error {
+	if !p.is(tokenIdent, "by") && !p.is(tokenIdent, "without") {
+		return nil
+	}
+
+	p.next()
+
+	return p.labelList()
+}
+
+// labelList parses a parenthesized, comma-separated and possibly empty list of label names.
+func (p *logQLParser) labelList() error {
+	if _, err := p.expect(tokenOp, "("); err != nil {
+		return err
+	}
+
+	if p.is(tokenOp, ")") {
+		p.next()
+		return nil
+	}
+
+	for {
+		if _, err := p.expect(tokenIdent, ""); err != nil {
+			return err
+		}
+
+		if !p.is(tokenOp, ",") {
+			break
+		}
+
+		p.next()
+	}
+
+	_, err := p.expect(tokenOp, ")")
+
+	return err
+}
@@ -0,0 +1,78 @@
+package logs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+)
+
+func TestValidateQuery(t *testing.T) {
+	for i, tc := range []struct {
+		query string
+		valid bool
+	}{
+		{query: `{job="up"}`, valid: true},
+		{query: `{job="up", env=~"prod|stage"}`, valid: true},
+		{query: `{job="up"} |= "error" != "timeout" |~ "(?i)fail" !~ "ok"`, valid: true},
+		{query: `{job="up"} |= "a" or "b"`, valid: true},
+		{query: `{job="up"} |> "<_> foo <_>" !> "<_> bar"`, valid: true},
+		{query: `{job="up"} |> "<_> a" or "<_> b"`, valid: true},
+		{query: `count_over_time({job="up"} !> "<_> ok <_>" [5m])`, valid: true},
+		{query: "{job=\"up\"} |= `raw \\string`", valid: true},
+		{query: `{job="up"} | json | level="error" and duration > 10s`, valid: true},
+		{query: `{job="up"} | json first="servers[0]", ua | logfmt --strict`, valid: true},
+		{query: `{job="up"} | logfmt --keep-empty a`, valid: true},
+		{query: `{job="up"} | logfmt --strict --keep-empty a="b", c`, valid: true},
+		{query: `{job="up"} # comment`, valid: true},
+		{query: `{job="up"} # |= "unterminated`, valid: true},
+		{query: "{job=\"up\"} # comment\n|= \"error # not a comment\" # comment", valid: true},
+		{query: `{job="up"} | regexp "(?P<method>\\w+)" | line_format "{{.method}}" | label_format m=method`, valid: true},
+		{query: `{job="up"} | pattern "<ip> - <_>" | (status>=400 or status=404), size > 1KB | drop a, b="c"`, valid: true},
+		{query: `{job="up"} |= ip("192.168.0.0/16") | addr = ip("10.0.0.1")`, valid: true},
+		{query: `rate({job="up"}[5m])`, valid: true},
+		{query: `count_over_time({job="up"} |= "error" [1h]) > 10`, valid: true},
+		{query: `sum by (level) (count_over_time({job="up"} | json [5m] offset 1h))`, valid: true},
+		{query: `sum(rate({job="up"}[1m])) without (pod) / 2`, valid: true},
+		{query: `topk(5, sum_over_time({job="up"} | logfmt | unwrap bytes(size) | __error__="" [5m]))`, valid: true},
+		{query: `quantile_over_time(0.99, {job="up"} | json | unwrap latency [5m]) by (path)`, valid: true},
+		{query: `sum(rate({a="b"}[1m])) / on (x) group_left sum(rate({c="d"}[1m]))`, valid: true},
+		{query: `label_replace(rate({job="up"}[1m]), "foo", "$1", "job", "(.*)")`, valid: true},
+		{query: `-vector(1) ^ 2 ^ 3`, valid: true},
+		{query: `up`},
+		{query: `{}`},
+		{query: `{job="up"`},
+		{query: `{job=up}`},
+		{query: `{job=~"("}`},
+		{query: `{job="up"} |~ "("`},
+		{query: `{job="up"} | unknown`},
+		{query: `{job="up"}[5m]`},
+		{query: `rate({job="up"})`},
+		{query: `rate({job="up"}[5])`},
+		{query: `sum({job="up"})`},
+		{query: `{a="b"} + {c="d"}`},
+		{query: `sum(rate({job="up"}[1m])) by`},
+		{query: `rate({job="up"}[1m]) )`},
+		{query: `{job="up"} |= "unterminated`},
+		{query: `{job="up"} |> foo`},
+		{query: `{job="up"} | logfmt --unknown`},
+		{query: `{job="up"} | json --strict`},
+		{query: `# {job="up"}`},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			err := ValidateQuery(tc.query)
+			if tc.valid {
+				testutil.Ok(t, err)
+				return
+			}
+
+			testutil.NotOk(t, err)
+		})
+	}
+}
+
+func TestValidateSelector(t *testing.T) {
+	testutil.Ok(t, ValidateSelector(`{job="up", env!="dev"}`))
+	testutil.NotOk(t, ValidateSelector(`{job="up"} |= "error"`))
+	testutil.NotOk(t, ValidateSelector(`job`))
+}
@@ -0,0 +1,102 @@
+package logs
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// marshalProto encodes the push request as Loki's logproto.PushRequest. The message is encoded by hand
+// to avoid depending on Loki, its layout is:
+//
+//	PushRequest      { repeated StreamAdapter streams = 1; }
+//	StreamAdapter    { string labels = 1; repeated EntryAdapter entries = 2; }
+//	EntryAdapter     { google.protobuf.Timestamp timestamp = 1; string line = 2; repeated LabelPairAdapter structuredMetadata = 3; }
+//	LabelPairAdapter { string name = 1; string value = 2; }
+func (r *PushRequest) marshalProto() ([]byte, error) {
+	var buf []byte
+
+	for _, s := range r.Streams {
+		var sb []byte
+
+		sb = protowire.AppendTag(sb, 1, protowire.BytesType)
+		sb = protowire.AppendString(sb, labelsString(s.Stream))
+
+		for _, e := range s.Values {
+			eb, err := marshalEntry(e)
+			if err != nil {
+				return nil, err
+			}
+
+			sb = protowire.AppendTag(sb, 2, protowire.BytesType)
+			sb = protowire.AppendBytes(sb, eb)
+		}
+
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, sb)
+	}
+
+	return buf, nil
+}
+
+// marshalEntry encodes the entry as logproto.EntryAdapter.
+func marshalEntry(e entry) ([]byte, error) {
+	ns, err := strconv.ParseInt(e.Timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("log entry timestamp %q is invalid: %w", e.Timestamp, err)
+	}
+
+	var ts []byte
+
+	ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+	ts = protowire.AppendVarint(ts, uint64(ns/1e9))
+	ts = protowire.AppendTag(ts, 2, protowire.VarintType)
+	ts = protowire.AppendVarint(ts, uint64(ns%1e9))
+
+	var eb []byte
+
+	eb = protowire.AppendTag(eb, 1, protowire.BytesType)
+	eb = protowire.AppendBytes(eb, ts)
+	eb = protowire.AppendTag(eb, 2, protowire.BytesType)
+	eb = protowire.AppendString(eb, e.Line)
+
+	for _, n := range sortedKeys(e.StructuredMetadata) {
+		var lb []byte
+
+		lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+		lb = protowire.AppendString(lb, n)
+		lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+		lb = protowire.AppendString(lb, e.StructuredMetadata[n])
+
+		eb = protowire.AppendTag(eb, 3, protowire.BytesType)
+		eb = protowire.AppendBytes(eb, lb)
+	}
+
+	return eb, nil
+}
+
+// labelsString formats the labels of a stream in Prometheus' text format, as expected by logproto.
+func labelsString(labels map[string]string) string {
+	names := sortedKeys(labels)
+
+	pairs := make([]string, len(names))
+	for i, n := range names {
+		pairs[i] = n + "=" + strconv.Quote(labels[n])
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
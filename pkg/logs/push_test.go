@@ -0,0 +1,82 @@
+package logs
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/prometheus/prometheus/prompb"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// consumeMessage returns the fields of a protobuf message by number, keeping the raw bytes or varints.
+func consumeMessage(t *testing.T, b []byte) map[protowire.Number][]interface{} {
+	t.Helper()
+
+	fields := map[protowire.Number][]interface{}{}
+
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		testutil.Assert(t, n > 0, "invalid tag")
+		b = b[n:]
+
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			testutil.Assert(t, n > 0, "invalid bytes")
+			fields[num] = append(fields[num], v)
+			b = b[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			testutil.Assert(t, n > 0, "invalid varint")
+			fields[num] = append(fields[num], v)
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+	}
+
+	return fields
+}
+
+func TestPushRequest_MarshalProto(t *testing.T) {
+	r := Generate(
+		[]prompb.Label{{Name: "job", Value: "up"}, {Name: "app", Value: `a"b`}},
+		[][]string{{"1700000000123456789", "first"}, {"1700000001000000000", "second"}},
+		map[string]string{"trace_id": "abc"},
+	)
+
+	b, err := r.marshalProto()
+	testutil.Ok(t, err)
+
+	streams := consumeMessage(t, b)[1]
+	testutil.Equals(t, 1, len(streams))
+
+	s := consumeMessage(t, streams[0].([]byte))
+	testutil.Equals(t, `{app="a\"b", job="up"}`, string(s[1][0].([]byte)))
+	testutil.Equals(t, 2, len(s[2]))
+
+	e := consumeMessage(t, s[2][0].([]byte))
+	testutil.Equals(t, "first", string(e[2][0].([]byte)))
+
+	ts := consumeMessage(t, e[1][0].([]byte))
+	testutil.Equals(t, uint64(1700000000), ts[1][0])
+	testutil.Equals(t, uint64(123456789), ts[2][0])
+
+	md := consumeMessage(t, e[3][0].([]byte))
+	testutil.Equals(t, "trace_id", string(md[1][0].([]byte)))
+	testutil.Equals(t, "abc", string(md[2][0].([]byte)))
+
+	_, err = Generate(nil, [][]string{{"now", "line"}}, nil).marshalProto()
+	testutil.NotOk(t, err)
+}
+
+func TestPushRequest_MarshalJSON(t *testing.T) {
+	b, err := json.Marshal(Generate(nil, [][]string{{"1", "plain"}}, nil))
+	testutil.Ok(t, err)
+	testutil.Equals(t, `{"streams":[{"stream":{},"values":[["1","plain"]]}]}`, string(b))
+
+	b, err = json.Marshal(Generate(nil, [][]string{{"1", "with metadata"}}, map[string]string{"trace_id": "abc"}))
+	testutil.Ok(t, err)
+	testutil.Equals(t, `{"streams":[{"stream":{},"values":[["1","with metadata",{"trace_id":"abc"}]]}]}`, string(b))
+}
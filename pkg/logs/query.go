@@ -2,10 +2,10 @@ package logs
 
 import (
 	"context"
-	"encoding/json"
-	"io/ioutil"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/observatorium/up/pkg/auth"
@@ -15,98 +15,90 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
-
+	promapi "github.com/prometheus/client_golang/api"
 	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 )
 
+// apiPrefix is the prefix of the Loki HTTP API, which the logs read endpoint is expected to be part of.
+const apiPrefix = "/loki/api/v1/"
+
+// Query executes a query specification, a set of queries, against Loki.
 func Query(
 	ctx context.Context,
 	l log.Logger,
 	endpoint *url.URL,
 	t auth.TokenProvider,
-	q options.Query,
+	query options.Query,
 	tls options.TLS,
 	defaultStep time.Duration,
+	tenantHeader string,
+	tenant string,
 ) (int, promapiv1.Warnings, error) {
-	// TODO: avoid type casting when we need to support all query endpoints for logs.
-	query, ok := q.(*options.QuerySpec)
-	if !ok {
-		return 0, nil, errors.New("Incorrect query type for logs queries")
+	level.Debug(l).Log("msg", "running specified query", "name", query.GetName(), "query", query.GetQuery())
+
+	c, err := newClient(l, endpoint, t, tls, tenantHeader, tenant, query.GetCommon().Headers)
+	if err != nil {
+		return 0, nil, err
 	}
 
-	level.Debug(l).Log("msg", "running specified query", "name", query.Name, "query", query.Query)
+	return query.Run(ctx, c, l, defaultStep)
+}
 
-	var (
-		rt   http.RoundTripper
-		warn promapiv1.Warnings
-		err  error
-	)
+// NewClient returns a client of the Loki HTTP API the given endpoint is part of.
+func NewClient(endpoint *url.URL, tp auth.TokenProvider, l log.Logger, tls options.TLS,
+	tenantHeader, tenant string) (promapi.Client, error) {
+	c, err := newClient(l, endpoint, tp, tls, tenantHeader, tenant, nil)
+
+	return c, err
+}
+
+// newClient returns a client of the Loki HTTP API the endpoint is part of.
+func newClient(
+	l log.Logger,
+	endpoint *url.URL,
+	t auth.TokenProvider,
+	tls options.TLS,
+	tenantHeader string,
+	tenant string,
+	headers map[string]string,
+) (promapi.Client, error) {
+	var rt http.RoundTripper
 
 	if endpoint.Scheme == transport.HTTPS {
-		rt, err = transport.NewTLSTransport(l, tls)
+		tp, err := transport.NewTLSTransport(l, tls)
 		if err != nil {
-			return 0, warn, errors.Wrap(err, "create round tripper")
+			return nil, errors.Wrap(err, "create round tripper")
 		}
 
-		rt = auth.NewBearerTokenRoundTripper(l, t, rt)
+		rt = auth.NewBearerTokenRoundTripper(l, t, tp)
 	} else {
 		rt = auth.NewBearerTokenRoundTripper(l, t, nil)
 	}
 
-	client := &http.Client{Transport: rt}
-
-	params := url.Values{}
-	params.Add("query", query.Query)
-
-	if query.Duration > 0 {
-		step := defaultStep
-		if query.Step > 0 {
-			step = query.Step
-		}
-
-		params.Add("start", time.Now().Add(-time.Duration(query.Duration)).String())
-		params.Add("end", time.Now().String())
-		params.Add("step", step.String())
-	}
-
-	endpoint.RawQuery = params.Encode()
-
-	req, err := http.NewRequest(http.MethodGet, endpoint.String(), nil)
-	if err != nil {
-		return 0, warn, errors.Wrap(err, "creating request")
-	}
-
-	res, err := client.Do(req.WithContext(ctx))
-	if err != nil {
-		if res == nil {
-			return 0, warn, errors.Wrap(err, "making request")
-		}
-
-		return res.StatusCode, warn, errors.Wrap(err, "making request")
-	}
-
-	if res.StatusCode != http.StatusOK {
-		err = errors.Errorf(res.Status)
-		return res.StatusCode, warn, errors.Wrap(err, "non-200 status")
-	}
-
-	defer res.Body.Close()
-
-	body, err := ioutil.ReadAll(res.Body)
+	c, err := promapi.NewClient(promapi.Config{
+		Address: APIBase(endpoint).String(),
+		RoundTripper: transport.InstrumentedRoundTripper(
+			auth.NewTenantRoundTripper(tenantHeader, tenant, auth.NewHeadersRoundTripper(headers, rt)),
+		),
+	})
 	if err != nil {
-		return res.StatusCode, warn, errors.Wrap(err, "reading response body")
+		return nil, fmt.Errorf("create new API client: %w", err)
 	}
 
-	rr := &queryResponse{}
+	return c, nil
+}
 
-	err = json.Unmarshal(body, rr)
-	if err != nil {
-		return res.StatusCode, warn, errors.Wrap(err, "unmarshalling response")
-	}
+// APIBase returns the address the Loki HTTP API is served under, given any of its endpoints,
+// e.g. http://localhost:3100 for http://localhost:3100/loki/api/v1/query.
+func APIBase(endpoint *url.URL) *url.URL {
+	u := new(url.URL)
+	*u = *endpoint
+	u.RawQuery = ""
 
-	if len(rr.Data.Result) == 0 {
-		return res.StatusCode, warn, errors.Errorf("expected at min one log entry, got none")
+	if i := strings.Index(u.Path, apiPrefix); i >= 0 {
+		u.Path = u.Path[:i]
+		u.RawPath = ""
 	}
 
-	return res.StatusCode, warn, nil
+	return u
 }
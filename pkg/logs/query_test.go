@@ -0,0 +1,39 @@
+package logs
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+)
+
+func TestAPIBase(t *testing.T) {
+	for i, tc := range []struct {
+		endpoint string
+		expected string
+	}{
+		{
+			endpoint: "http://localhost:3100/loki/api/v1/query",
+			expected: "http://localhost:3100",
+		},
+		{
+			endpoint: "http://localhost:3100/loki/api/v1/query_range?query=%7Bjob%3D%22up%22%7D",
+			expected: "http://localhost:3100",
+		},
+		{
+			endpoint: "https://observatorium.io/api/logs/v1/test/loki/api/v1/query",
+			expected: "https://observatorium.io/api/logs/v1/test",
+		},
+		{
+			endpoint: "http://localhost:3100",
+			expected: "http://localhost:3100",
+		},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			u, err := url.Parse(tc.endpoint)
+			testutil.Ok(t, err)
+			testutil.Equals(t, tc.expected, APIBase(u).String())
+		})
+	}
+}
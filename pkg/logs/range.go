@@ -0,0 +1,213 @@
+package logs
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/observatorium/up/pkg/api"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/options"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// RangeReader reads the written logs back with a range query and verifies that every stream contains
+// the entries of all pushes within the window, detecting dropped and partially ingested pushes.
+// If the lines carry sequence numbers, the entries are also verified to be ordered and not duplicated.
+// It remembers the newest entry seen per stream, so an issue is only counted once by overlapping reads.
+type RangeReader struct {
+	// Window is the range to read back.
+	Window time.Duration
+	// Period is the time between pushes.
+	Period time.Duration
+	// Streams is the number of streams written per push.
+	Streams int
+	// EntriesPerPush is the number of entries written to every stream per push.
+	EntriesPerPush int
+	// Started is the time the writer started, before which no entries are expected.
+	Started time.Time
+	// Template generated the lines, which allows finding their sequence numbers.
+	Template *LineTemplate
+
+	mtx     sync.Mutex
+	streams map[string]*streamOrder
+}
+
+// streamOrder is the state of the verification of the order of a stream.
+type streamOrder struct {
+	newest time.Time
+	maxSeq uint64
+	// seen are the timestamps of the sequence numbers within the window.
+	seen map[uint64]time.Time
+}
+
+// Read executes a range query against Loki with the same labels to retrieve the written logs back.
+// If query is not empty, it is used instead of the stream selector for the labels.
+func (r *RangeReader) Read(
+	ctx context.Context,
+	endpoint *url.URL,
+	tp auth.TokenProvider,
+	labels []prompb.Label,
+	query string,
+	ago time.Duration,
+	m instr.Metrics,
+	l log.Logger,
+	tls options.TLS,
+	tenantHeader string,
+	tenant string,
+	metadata map[string]string,
+) (int, error) {
+	client, err := newClient(l, endpoint, tp, tls, tenantHeader, tenant, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if query == "" {
+		query = Selector(labels)
+	}
+
+	end := time.Now().Add(ago)
+
+	start := end.Add(-r.Window)
+	if start.Before(r.Started) {
+		start = r.Started
+	}
+
+	expected := r.expectedEntries(end.Sub(start))
+
+	ctx, stats := api.WithStats(ctx)
+
+	// Leave room for the pushes at both ends of the window, so the limit can never hide missing entries.
+	res, httpCode, warn, err := api.LokiQueryRange(ctx, client, query, promapiv1.Range{
+		Start: start,
+		End:   end,
+		Step:  r.Period,
+	}, false, api.LokiParams{
+		Limit:     (expected + 2*r.EntriesPerPush) * r.Streams,
+		Direction: options.DirectionForward,
+	})
+	if err != nil {
+		return httpCode, errors.Wrap(err, "query range request failed")
+	}
+
+	m.ObserveResultSize(instr.ReadQueryType, "", *stats)
+	m.ObserveWarnings(instr.ReadQueryType, "", warn)
+	m.ObserveLogsRead(*stats)
+	m.LogsReadEntries.Observe(float64(stats.Samples))
+
+	if len(res.Streams) != r.Streams {
+		return httpCode, errors.Errorf("expected %d log streams, got %d", r.Streams, len(res.Streams))
+	}
+
+	var outOfOrder, duplicates int
+
+	for _, s := range res.Streams {
+		o, d := r.checkOrder(s, start)
+		outOfOrder += o
+		duplicates += d
+	}
+
+	m.LogsOutOfOrder.Add(float64(outOfOrder))
+	m.LogsDuplicates.Add(float64(duplicates))
+
+	if outOfOrder > 0 || duplicates > 0 {
+		return httpCode, errors.Errorf("found %d log entries out of order and %d duplicated log entries", outOfOrder, duplicates)
+	}
+
+	for _, s := range res.Streams {
+		if len(s.Entries) < expected {
+			return httpCode, errors.Errorf("expected at least %d log entries in stream %s since %s, got %d",
+				expected, s.Labels, start.Format(time.RFC3339), len(s.Entries))
+		}
+
+		if err := checkMetadata(s, metadata); err != nil {
+			return httpCode, err
+		}
+	}
+
+	return httpCode, nil
+}
+
+// checkOrder counts the entries of the stream newer than the previous reads which are out of order,
+// either by timestamp or by sequence number, and the entries with duplicated sequence numbers.
+func (r *RangeReader) checkOrder(s api.LokiStream, start time.Time) (outOfOrder, duplicates int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.streams == nil {
+		r.streams = map[string]*streamOrder{}
+	}
+
+	st, ok := r.streams[s.Labels.String()]
+	if !ok {
+		st = &streamOrder{seen: map[uint64]time.Time{}}
+		r.streams[s.Labels.String()] = st
+	}
+
+	var prev time.Time
+
+	for _, e := range s.Entries {
+		newer := e.Timestamp.After(st.newest)
+
+		if newer && e.Timestamp.Before(prev) {
+			outOfOrder++
+		}
+
+		prev = e.Timestamp
+
+		if !newer {
+			continue
+		}
+
+		seq, ok := r.Template.Sequence(e.Line)
+		if !ok {
+			continue
+		}
+
+		if _, ok := st.seen[seq]; ok {
+			duplicates++
+			continue
+		}
+
+		st.seen[seq] = e.Timestamp
+
+		if seq < st.maxSeq {
+			outOfOrder++
+		} else {
+			st.maxSeq = seq
+		}
+	}
+
+	for i := len(s.Entries) - 1; i >= 0; i-- {
+		if ts := s.Entries[i].Timestamp; ts.After(st.newest) {
+			st.newest = ts
+		}
+	}
+
+	// Forget the sequence numbers which cannot be read again.
+	for seq, ts := range st.seen {
+		if ts.Before(start) {
+			delete(st.seen, seq)
+		}
+	}
+
+	return outOfOrder, duplicates
+}
+
+// expectedEntries returns the number of entries every stream must contain within the window.
+// One push less than fits into the window is expected, as the newest push can still be in flight
+// or be missed due to jitter between pushes.
+func (r *RangeReader) expectedEntries(window time.Duration) int {
+	pushes := int(window/r.Period) - 1
+	if pushes < 0 {
+		return 0
+	}
+
+	return pushes * r.EntriesPerPush
+}
@@ -0,0 +1,131 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+	"github.com/observatorium/up/pkg/api"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+)
+
+// streamsResponse returns a query range response with the given number of entries per stream.
+func streamsResponse(entries ...int) string {
+	streams := make([]string, len(entries))
+
+	for i, n := range entries {
+		values := make([]string, n)
+		for j := range values {
+			values[j] = fmt.Sprintf(`["%d", "line %d"]`, j+1, j)
+		}
+
+		streams[i] = fmt.Sprintf(`{"stream": {"job": "up", "stream": "%d"}, "values": [%s]}`, i, strings.Join(values, ","))
+	}
+
+	return fmt.Sprintf(`{"status": "success", "data": {"resultType": "streams", "result": [%s]}}`, strings.Join(streams, ","))
+}
+
+func TestRangeReader_Read(t *testing.T) {
+	for i, tc := range []struct {
+		response string
+		started  time.Duration
+		limit    string
+		valid    bool
+	}{
+		{
+			// A minute of 10s pushes with two entries each, the newest push may still be missing.
+			response: streamsResponse(10, 10),
+			started:  time.Hour,
+			limit:    "28",
+			valid:    true,
+		},
+		{
+			// Partially ingested pushes are detected in every stream.
+			response: streamsResponse(12, 8),
+			started:  time.Hour,
+			limit:    "28",
+		},
+		{
+			response: streamsResponse(12),
+			started:  time.Hour,
+			limit:    "28",
+		},
+		{
+			// No entries are expected before the writer started.
+			response: streamsResponse(4, 4),
+			started:  30 * time.Second,
+			limit:    "16",
+			valid:    true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				testutil.Equals(t, "/loki/api/v1/query_range", r.URL.Path)
+				testutil.Equals(t, tc.limit, r.FormValue("limit"))
+				fmt.Fprint(w, tc.response)
+			}))
+			defer s.Close()
+
+			u, err := url.Parse(s.URL + "/loki/api/v1/query")
+			testutil.Ok(t, err)
+
+			r := &RangeReader{
+				Window:         time.Minute,
+				Period:         10 * time.Second,
+				Streams:        2,
+				EntriesPerPush: 2,
+				Started:        time.Now().Add(-tc.started),
+			}
+			m := instr.RegisterMetrics(prometheus.NewRegistry(), options.Buckets{})
+
+			_, err = r.Read(context.Background(), u, auth.NewStaticToken(""), nil, `{job="up"}`, 0, m, log.NewNopLogger(),
+				options.TLS{}, "", "", nil)
+			if tc.valid {
+				testutil.Ok(t, err)
+				testutil.Equals(t, 2, promtestutil.CollectAndCount(m.LogsReadDuration))
+				return
+			}
+
+			testutil.NotOk(t, err)
+		})
+	}
+}
+
+func TestRangeReader_CheckOrder(t *testing.T) {
+	lt, err := NewLineTemplate("seq={{.Sequence}}", 0)
+	testutil.Ok(t, err)
+
+	stream := func(entries ...api.LokiEntry) api.LokiStream {
+		return api.LokiStream{Labels: model.LabelSet{"job": "up"}, Entries: entries}
+	}
+	entry := func(ts int64, seq int) api.LokiEntry {
+		return api.LokiEntry{Timestamp: time.Unix(ts, 0), Line: fmt.Sprintf("seq=%d", seq)}
+	}
+
+	r := &RangeReader{Template: lt}
+
+	outOfOrder, duplicates := r.checkOrder(stream(entry(1, 1), entry(2, 2), entry(3, 3)), time.Unix(0, 0))
+	testutil.Equals(t, 0, outOfOrder)
+	testutil.Equals(t, 0, duplicates)
+
+	outOfOrder, duplicates = r.checkOrder(stream(entry(2, 2), entry(3, 3), entry(5, 5), entry(4, 4), entry(6, 3)), time.Unix(0, 0))
+	testutil.Equals(t, 2, outOfOrder)
+	testutil.Equals(t, 1, duplicates)
+
+	// Entries already verified by a previous read are not counted again.
+	outOfOrder, duplicates = r.checkOrder(stream(entry(5, 5), entry(4, 4), entry(6, 3), entry(7, 7)), time.Unix(0, 0))
+	testutil.Equals(t, 0, outOfOrder)
+	testutil.Equals(t, 0, duplicates)
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/observatorium/up/pkg/api"
 	"github.com/observatorium/up/pkg/auth"
 	"github.com/observatorium/up/pkg/instr"
 	"github.com/observatorium/up/pkg/options"
@@ -17,19 +18,28 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/prompb"
 )
 
 // Read executes query against Loki with the same labels to retrieve the written logs back.
+// If query is not empty, it is used instead of the stream selector for the labels.
+// Every written stream, given by its labels, and the structured metadata of its entries must be returned,
+// the latter either as stream labels or categorized per entry.
 func Read(
 	ctx context.Context,
 	endpoint *url.URL,
 	tp auth.TokenProvider,
 	labels []prompb.Label, // change to Loki ProtoBufs
+	query string,
 	ago, latency time.Duration,
 	m instr.Metrics,
 	l log.Logger,
 	tls options.TLS,
+	tenantHeader string,
+	tenant string,
+	streams [][]prompb.Label,
+	metadata map[string]string,
 ) (int, error) {
 	var (
 		rt  http.RoundTripper
@@ -47,15 +57,12 @@ func Read(
 		rt = auth.NewBearerTokenRoundTripper(l, tp, nil)
 	}
 
-	client := &http.Client{Transport: rt}
+	client := &http.Client{Transport: transport.InstrumentedRoundTripper(auth.NewTenantRoundTripper(tenantHeader, tenant, rt))}
 
-	labelSelectors := make([]string, len(labels))
-	for i, label := range labels {
-		labelSelectors[i] = fmt.Sprintf(`%s="%s"`, label.Name, label.Value)
+	if query == "" {
+		query = Selector(labels)
 	}
 
-	query := fmt.Sprintf("{%s}", strings.Join(labelSelectors, ","))
-
 	params := url.Values{}
 	params.Add("query", query)
 	endpoint.RawQuery = params.Encode()
@@ -65,6 +72,8 @@ func Read(
 		return 0, errors.Wrap(err, "creating request")
 	}
 
+	start := time.Now()
+
 	res, err := client.Do(req.WithContext(ctx))
 	if err != nil {
 		if res == nil {
@@ -87,6 +96,7 @@ func Read(
 		return res.StatusCode, errors.Wrap(err, "reading response body")
 	}
 
+	requested := time.Now()
 	rr := &queryResponse{}
 
 	err = json.Unmarshal(body, rr)
@@ -94,10 +104,93 @@ func Read(
 		return res.StatusCode, errors.Wrap(err, "unmarshalling response")
 	}
 
+	stats := api.Stats{
+		Series:          len(rr.Data.Result),
+		Bytes:           len(body),
+		RequestDuration: requested.Sub(start),
+		DecodeDuration:  time.Since(requested),
+	}
+	for _, s := range rr.Data.Result {
+		stats.Samples += len(s.Entries)
+	}
+
+	m.ObserveResultSize(instr.ReadQueryType, "", stats)
+	m.ObserveLogsRead(stats)
+	m.LogsReadEntries.Observe(float64(stats.Samples))
+
 	rl := len(rr.Data.Result)
-	if rl != 1 {
-		return res.StatusCode, errors.Errorf("expected one log entry, got %d", rl)
+	if rl != len(streams) {
+		return res.StatusCode, errors.Errorf("expected %d log streams, got %d", len(streams), rl)
+	}
+
+	for _, s := range streams {
+		if !containsStream(rr.Data.Result, s) {
+			return res.StatusCode, errors.Errorf("log stream %s is missing from the read logs", Selector(s))
+		}
+	}
+
+	for _, s := range rr.Data.Result {
+		if err := checkMetadata(s, metadata); err != nil {
+			return res.StatusCode, err
+		}
 	}
 
 	return res.StatusCode, nil
 }
+
+// containsStream returns whether any of the read streams has the labels. The read streams can have more labels,
+// e.g. the structured metadata.
+func containsStream(read []api.LokiStream, labels []prompb.Label) bool {
+	for _, s := range read {
+		found := true
+
+		for _, l := range labels {
+			if s.Labels[model.LabelName(l.Name)] != model.LabelValue(l.Value) {
+				found = false
+				break
+			}
+		}
+
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkMetadata returns an error if an entry of the stream is missing the structured metadata.
+// Loki returns the metadata as stream labels unless it is asked to categorize labels.
+func checkMetadata(s api.LokiStream, metadata map[string]string) error {
+	for _, e := range s.Entries {
+		for k, v := range metadata {
+			got, ok := e.StructuredMetadata[k]
+			if !ok {
+				var l model.LabelValue
+
+				l, ok = s.Labels[model.LabelName(k)]
+				got = string(l)
+			}
+
+			if !ok {
+				return errors.Errorf("structured metadata %q is missing from the read logs", k)
+			}
+
+			if got != v {
+				return errors.Errorf("structured metadata %q is %q in the read logs, expected %q", k, got, v)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Selector returns the stream selector matching exactly the given labels.
+func Selector(labels []prompb.Label) string {
+	labelSelectors := make([]string, len(labels))
+	for i, label := range labels {
+		labelSelectors[i] = fmt.Sprintf(`%s="%s"`, label.Name, label.Value)
+	}
+
+	return fmt.Sprintf("{%s}", strings.Join(labelSelectors, ","))
+}
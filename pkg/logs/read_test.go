@@ -0,0 +1,115 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+	"github.com/observatorium/up/pkg/api"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestCheckMetadata(t *testing.T) {
+	for i, tc := range []struct {
+		stream   string
+		metadata map[string]string
+		valid    bool
+	}{
+		{
+			stream: `{"stream": {"job": "up"}, "values": [["1", "line"]]}`,
+			valid:  true,
+		},
+		{
+			stream:   `{"stream": {"job": "up", "trace_id": "abc"}, "values": [["1", "line"]]}`,
+			metadata: map[string]string{"trace_id": "abc"},
+			valid:    true,
+		},
+		{
+			stream:   `{"stream": {"job": "up"}, "values": [["1", "line", {"structuredMetadata": {"trace_id": "abc"}}]]}`,
+			metadata: map[string]string{"trace_id": "abc"},
+			valid:    true,
+		},
+		{
+			stream:   `{"stream": {"job": "up"}, "values": [["1", "line"]]}`,
+			metadata: map[string]string{"trace_id": "abc"},
+		},
+		{
+			stream:   `{"stream": {"job": "up", "trace_id": "def"}, "values": [["1", "line"]]}`,
+			metadata: map[string]string{"trace_id": "abc"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			var s api.LokiStream
+			testutil.Ok(t, json.Unmarshal([]byte(tc.stream), &s))
+
+			err := checkMetadata(s, tc.metadata)
+			if tc.valid {
+				testutil.Ok(t, err)
+				return
+			}
+
+			testutil.NotOk(t, err)
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	streams := [][]prompb.Label{
+		{{Name: "app", Value: "api"}, {Name: "job", Value: "up"}},
+		{{Name: "app", Value: "db"}, {Name: "job", Value: "up"}},
+	}
+
+	for i, tc := range []struct {
+		response string
+		valid    bool
+	}{
+		{
+			response: `[{"stream": {"app": "api", "job": "up"}, "values": [["1", "line"]]},
+				{"stream": {"app": "db", "job": "up", "trace_id": "abc"}, "values": [["1", "line"]]}]`,
+			valid: true,
+		},
+		{
+			response: `[{"stream": {"app": "api", "job": "up"}, "values": [["1", "line"]]}]`,
+		},
+		{
+			// Every written stream must be read back.
+			response: `[{"stream": {"app": "api", "job": "up"}, "values": [["1", "line"]]},
+				{"stream": {"app": "api", "job": "up", "trace_id": "abc"}, "values": [["1", "line"]]}]`,
+		},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				testutil.Equals(t, `{job="up"}`, r.URL.Query().Get("query"))
+				fmt.Fprintf(w, `{"status": "success", "data": {"resultType": "streams", "result": %s}}`, tc.response)
+			}))
+			defer s.Close()
+
+			u, err := url.Parse(s.URL + "/loki/api/v1/query")
+			testutil.Ok(t, err)
+
+			m := instr.RegisterMetrics(prometheus.NewRegistry(), options.Buckets{})
+
+			_, err = Read(context.Background(), u, auth.NewStaticToken(""), []prompb.Label{{Name: "job", Value: "up"}}, "", 0, 0, m,
+				log.NewNopLogger(), options.TLS{}, "", "", streams, nil)
+			if tc.valid {
+				testutil.Ok(t, err)
+				// The request and decode phases are observed.
+				testutil.Equals(t, 2, promtestutil.CollectAndCount(m.LogsReadDuration))
+				return
+			}
+
+			testutil.NotOk(t, err)
+		})
+	}
+}
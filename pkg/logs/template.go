@@ -0,0 +1,161 @@
+package logs
+
+import (
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const paddingChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// DefaultLineTemplate is used to generate lines if only the volume of logs is configured.
+const DefaultLineTemplate = "ts={{.Timestamp.UnixNano}} seq={{.Sequence}} {{.Padding}}"
+
+// LineData is the data available to log line templates.
+type LineData struct {
+	// Timestamp is the time the line is generated at, which is also the timestamp of the entry.
+	Timestamp time.Time
+	// Sequence is incremented for every generated line, starting at 1, to detect lost lines.
+	Sequence uint64
+	// Padding is a random string filling the line up to the configured size.
+	Padding string
+}
+
+// LineTemplate generates log lines from a Go template instead of a fixed set of lines.
+type LineTemplate struct {
+	tmpl *template.Template
+	size int
+	seq  atomic.Uint64
+	// seqRE extracts the sequence number from generated lines, if the template allows finding it.
+	seqRE *regexp.Regexp
+}
+
+// NewLineTemplate parses the template for log lines. If size is positive, lines are padded
+// up to size bytes, either where the template uses {{.Padding}} or at the end of the line.
+func NewLineTemplate(text string, size int) (*LineTemplate, error) {
+	tmpl, err := template.New("line").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing template")
+	}
+
+	if !strings.Contains(text, ".Padding") {
+		tmpl, err = template.New("line").Option("missingkey=error").Parse(text + "{{.Padding}}")
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing template")
+		}
+	}
+
+	t := &LineTemplate{tmpl: tmpl, size: size, seqRE: sequenceRegexp(tmpl)}
+
+	// Fail on templates which cannot be executed right away instead of on the first push.
+	if _, err := t.execute(LineData{Timestamp: time.Now()}); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Generate returns the given number of log entries generated at the given time in the format of the logs flags.
+// The entries are a nanosecond apart to keep their order.
+func (t *LineTemplate) Generate(now time.Time, lines int) ([][]string, error) {
+	values := make([][]string, 0, lines)
+
+	for i := 0; i < lines; i++ {
+		d := LineData{Timestamp: now.Add(time.Duration(i)), Sequence: t.seq.Add(1)}
+
+		line, err := t.execute(d)
+		if err != nil {
+			return nil, err
+		}
+
+		if n := t.size - len(line); n > 0 {
+			d.Padding = padding(n)
+
+			line, err = t.execute(d)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		values = append(values, []string{strconv.FormatInt(d.Timestamp.UnixNano(), 10), line})
+	}
+
+	return values, nil
+}
+
+// Sequence returns the sequence number of a line generated by the template. It is only found if the template
+// puts the sequence at the beginning of the line or after some text, e.g. 'seq={{.Sequence}}'.
+func (t *LineTemplate) Sequence(line string) (uint64, bool) {
+	if t == nil || t.seqRE == nil {
+		return 0, false
+	}
+
+	match := t.seqRE.FindStringSubmatch(line)
+	if match == nil {
+		return 0, false
+	}
+
+	seq, err := strconv.ParseUint(match[1], 10, 64)
+
+	return seq, err == nil
+}
+
+// sequenceRegexp returns a regular expression matching the sequence number by the text preceding it in the template.
+func sequenceRegexp(tmpl *template.Template) *regexp.Regexp {
+	var prev parse.Node
+
+	for _, n := range tmpl.Tree.Root.Nodes {
+		if isSequence(n) {
+			switch p := prev.(type) {
+			case nil:
+				return regexp.MustCompile(`^(\d+)`)
+			case *parse.TextNode:
+				return regexp.MustCompile(regexp.QuoteMeta(string(p.Text)) + `(\d+)`)
+			}
+
+			// The sequence cannot be told apart from the output of a preceding action.
+			return nil
+		}
+
+		prev = n
+	}
+
+	return nil
+}
+
+func isSequence(n parse.Node) bool {
+	a, ok := n.(*parse.ActionNode)
+	if !ok || len(a.Pipe.Decl) > 0 || len(a.Pipe.Cmds) != 1 || len(a.Pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+
+	f, ok := a.Pipe.Cmds[0].Args[0].(*parse.FieldNode)
+
+	return ok && len(f.Ident) == 1 && f.Ident[0] == "Sequence"
+}
+
+func (t *LineTemplate) execute(d LineData) (string, error) {
+	var b strings.Builder
+
+	if err := t.tmpl.Execute(&b, d); err != nil {
+		return "", errors.Wrap(err, "executing template")
+	}
+
+	return b.String(), nil
+}
+
+func padding(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = paddingChars[rand.Intn(len(paddingChars))] //nolint:gosec
+	}
+
+	return string(b)
+}
@@ -0,0 +1,99 @@
+package logs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+)
+
+func TestLineTemplate_Generate(t *testing.T) {
+	now := time.Unix(1700000000, 42)
+
+	for i, tc := range []struct {
+		template string
+		size     int
+		prefix   string
+		length   int
+	}{
+		{
+			template: "ts={{.Timestamp.UnixNano}} seq={{.Sequence}}",
+			prefix:   "ts=1700000000000000042 seq=1",
+			length:   len("ts=1700000000000000042 seq=1"),
+		},
+		{
+			template: "seq={{.Sequence}} ",
+			size:     64,
+			prefix:   "seq=1 ",
+			length:   64,
+		},
+		{
+			template: "{{.Padding}} seq={{.Sequence}}",
+			size:     3,
+			prefix:   " seq=1",
+			length:   len(" seq=1"),
+		},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			lt, err := NewLineTemplate(tc.template, tc.size)
+			testutil.Ok(t, err)
+
+			values, err := lt.Generate(now, 1)
+			testutil.Ok(t, err)
+			testutil.Equals(t, 1, len(values))
+			testutil.Equals(t, "1700000000000000042", values[0][0])
+			testutil.Assert(t, strings.HasPrefix(values[0][1], tc.prefix), "unexpected line %q", values[0][1])
+			testutil.Equals(t, tc.length, len(values[0][1]))
+		})
+	}
+}
+
+func TestLineTemplate_Sequence(t *testing.T) {
+	lt, err := NewLineTemplate("{{.Sequence}}", 0)
+	testutil.Ok(t, err)
+
+	for i := 1; i <= 3; i++ {
+		values, err := lt.Generate(time.Now(), 1)
+		testutil.Ok(t, err)
+		testutil.Equals(t, fmt.Sprint(i), values[0][1])
+	}
+
+	values, err := lt.Generate(time.Unix(0, 10), 2)
+	testutil.Ok(t, err)
+	testutil.Equals(t, [][]string{{"10", "4"}, {"11", "5"}}, values)
+}
+
+func TestNewLineTemplate_Invalid(t *testing.T) {
+	_, err := NewLineTemplate("{{.Sequence", 0)
+	testutil.NotOk(t, err)
+
+	_, err = NewLineTemplate("{{.Unknown}}", 0)
+	testutil.NotOk(t, err)
+}
+
+func TestLineTemplate_SequenceOf(t *testing.T) {
+	for i, tc := range []struct {
+		template string
+		line     string
+		seq      uint64
+		found    bool
+	}{
+		{template: DefaultLineTemplate, line: "ts=1700000000000000042 seq=17 abc", seq: 17, found: true},
+		{template: "{{.Sequence}} {{.Padding}}", line: "42 xyz", seq: 42, found: true},
+		{template: "seq={{.Sequence}}", line: "seq=x", found: false},
+		// The sequence directly follows another action.
+		{template: "{{.Timestamp.Unix}}{{.Sequence}}", line: "17000000001"},
+		{template: "ts={{.Timestamp.Unix}}", line: "ts=1700000000"},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			lt, err := NewLineTemplate(tc.template, 0)
+			testutil.Ok(t, err)
+
+			seq, found := lt.Sequence(tc.line)
+			testutil.Equals(t, tc.found, found)
+			testutil.Equals(t, tc.seq, seq)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package logs
+
+import (
+	"sync"
+)
+
+// Tenants rotates the tenant of every push through a list of tenants, exercising the fairness between tenants
+// of multi-tenant Loki deployments. A nil Tenants leaves the tenant unchanged.
+type Tenants struct {
+	tenants []string
+
+	mtx    sync.Mutex
+	next   int
+	pushed string
+}
+
+// NewTenants returns a Tenants rotating through the given tenants, starting with the first one.
+func NewTenants(tenants []string) *Tenants {
+	return &Tenants{tenants: tenants}
+}
+
+// Next returns the tenant of the next push.
+func (t *Tenants) Next(tenant string) string {
+	if t == nil {
+		return tenant
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	tenant = t.tenants[t.next]
+	t.next = (t.next + 1) % len(t.tenants)
+
+	return tenant
+}
+
+// Pushed records the tenant of a successful push.
+func (t *Tenants) Pushed(tenant string) {
+	if t == nil {
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.pushed = tenant
+}
+
+// ReadTenant returns the tenant of the newest successful push, which the reader expects to find the logs of.
+// The tenant is returned unchanged until the first push succeeded.
+func (t *Tenants) ReadTenant(tenant string) string {
+	if t == nil {
+		return tenant
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.pushed == "" {
+		return tenant
+	}
+
+	return t.pushed
+}
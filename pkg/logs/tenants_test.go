@@ -0,0 +1,27 @@
+package logs
+
+import (
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+)
+
+func TestTenants(t *testing.T) {
+	var nilTenants *Tenants
+	testutil.Equals(t, "tenant", nilTenants.Next("tenant"))
+	nilTenants.Pushed("other")
+	testutil.Equals(t, "tenant", nilTenants.ReadTenant("tenant"))
+
+	ts := NewTenants([]string{"team-a", "team-b"})
+	testutil.Equals(t, "", ts.ReadTenant(""))
+
+	testutil.Equals(t, "team-a", ts.Next(""))
+	ts.Pushed("team-a")
+	testutil.Equals(t, "team-a", ts.ReadTenant(""))
+
+	// A failed push of the next tenant does not change the tenant read back.
+	testutil.Equals(t, "team-b", ts.Next(""))
+	testutil.Equals(t, "team-a", ts.ReadTenant(""))
+
+	testutil.Equals(t, "team-a", ts.Next(""))
+}
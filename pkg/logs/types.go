@@ -1,10 +1,16 @@
 package logs
 
+import (
+	"encoding/json"
+
+	"github.com/observatorium/up/pkg/api"
+)
+
 type queryResponse struct {
 	Status string `json:"status"`
 	Data   struct {
-		ResultType string   `json:"resultType"`
-		Result     []stream `json:"result"`
+		ResultType string           `json:"resultType"`
+		Result     []api.LokiStream `json:"result"`
 	} `json:"data"`
 }
 
@@ -15,5 +21,23 @@ type PushRequest struct {
 
 type stream struct {
 	Stream map[string]string `json:"stream"`
-	Values [][]string        `json:"values"`
+	Values []entry           `json:"values"`
+}
+
+// entry is a log line with its timestamp in nanoseconds and optional structured metadata.
+type entry struct {
+	Timestamp          string
+	Line               string
+	StructuredMetadata map[string]string
+}
+
+// MarshalJSON encodes the entry as Loki's [timestamp, line] or, with structured metadata,
+// [timestamp, line, metadata] array.
+func (e entry) MarshalJSON() ([]byte, error) {
+	v := []interface{}{e.Timestamp, e.Line}
+	if len(e.StructuredMetadata) > 0 {
+		v = append(v, e.StructuredMetadata)
+	}
+
+	return json.Marshal(v)
 }
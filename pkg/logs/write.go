@@ -2,22 +2,32 @@ package logs
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/instr"
 	"github.com/observatorium/up/pkg/options"
 	"github.com/observatorium/up/pkg/transport"
 
 	"github.com/go-kit/log"
+	"github.com/golang/snappy"
 	"github.com/pkg/errors"
 	"github.com/prometheus/prometheus/prompb"
 )
 
 // Write executes a push against Loki sending a set of labels and log entries to store.
-func Write(ctx context.Context, endpoint *url.URL, t auth.TokenProvider, wreq *PushRequest, l log.Logger, tls options.TLS) (int, error) {
+// The entries are pushed as JSON or as snappy compressed protobuf, depending on the encoding,
+// and the request body is optionally compressed with gzip on top. Endpoints with a gRPC scheme are pushed
+// to over the gRPC API of the distributor instead, always encoded as protobuf.
+func Write(ctx context.Context, endpoint *url.URL, t auth.TokenProvider, wreq *PushRequest, m instr.Metrics, l log.Logger,
+	tls options.TLS, encoding options.PushEncoding, compression options.PushCompression, tenantHeader, tenant string) (int, error) {
 	var (
 		buf []byte
 		err error
@@ -26,6 +36,10 @@ func Write(ctx context.Context, endpoint *url.URL, t auth.TokenProvider, wreq *P
 		rt  http.RoundTripper
 	)
 
+	if IsGRPC(endpoint) {
+		return writeGRPC(ctx, endpoint, t, wreq, m, l, tls, tenantHeader, tenant)
+	}
+
 	if endpoint.Scheme == transport.HTTPS {
 		rt, err = transport.NewTLSTransport(l, tls)
 		if err != nil {
@@ -37,11 +51,40 @@ func Write(ctx context.Context, endpoint *url.URL, t auth.TokenProvider, wreq *P
 		rt = auth.NewBearerTokenRoundTripper(l, t, nil)
 	}
 
-	client := &http.Client{Transport: rt}
+	client := &http.Client{Transport: transport.InstrumentedRoundTripper(auth.NewTenantRoundTripper(tenantHeader, tenant, rt))}
 
-	buf, err = json.Marshal(wreq)
-	if err != nil {
-		return 0, errors.Wrap(err, "marshalling payload")
+	contentType := "application/json"
+
+	if encoding == options.ProtobufPushEncoding {
+		buf, err = wreq.marshalProto()
+		if err != nil {
+			return 0, errors.Wrap(err, "marshalling proto")
+		}
+
+		contentType = "application/x-protobuf"
+	} else {
+		buf, err = json.Marshal(wreq)
+		if err != nil {
+			return 0, errors.Wrap(err, "marshalling payload")
+		}
+	}
+
+	m.LogsPushSize.Observe(float64(len(buf)))
+	m.LogsPushEntries.Observe(float64(wreq.entries()))
+
+	if encoding == options.ProtobufPushEncoding {
+		buf = snappy.Encode(nil, buf)
+	}
+
+	if compression == options.GzipPushCompression {
+		buf, err = gzipCompress(buf)
+		if err != nil {
+			return 0, errors.Wrap(err, "compressing payload")
+		}
+	}
+
+	if encoding == options.ProtobufPushEncoding || compression == options.GzipPushCompression {
+		m.LogsPushCompressedSize.Observe(float64(len(buf)))
 	}
 
 	req, err = http.NewRequest(http.MethodPost, endpoint.String(), bytes.NewBuffer(buf))
@@ -49,7 +92,11 @@ func Write(ctx context.Context, endpoint *url.URL, t auth.TokenProvider, wreq *P
 		return 0, errors.Wrap(err, "creating request")
 	}
 
-	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Content-Type", contentType)
+
+	if compression == options.GzipPushCompression {
+		req.Header.Add("Content-Encoding", "gzip")
+	}
 
 	res, err = client.Do(req.WithContext(ctx)) //nolint:bodyclose
 	if err != nil {
@@ -63,22 +110,143 @@ func Write(ctx context.Context, endpoint *url.URL, t auth.TokenProvider, wreq *P
 		return res.StatusCode, errors.Wrap(err, "non-204 status")
 	}
 
+	m.LogsBytesWritten.Add(float64(wreq.LineBytes()))
+
 	return res.StatusCode, nil
 }
 
+func gzipCompress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
 // Generate takes a set of labels and log lines and returns the payload to push logs to Loki.
-func Generate(labels []prompb.Label, values [][]string) *PushRequest {
+// The structured metadata, if any, is attached to every log line.
+func Generate(labels []prompb.Label, values [][]string, metadata map[string]string) *PushRequest {
 	s := make(map[string]string)
 	for _, label := range labels {
 		s[label.Name] = label.Value
 	}
 
+	entries := make([]entry, 0, len(values))
+
+	for _, v := range values {
+		e := entry{StructuredMetadata: metadata}
+		if len(v) > 0 {
+			e.Timestamp = v[0]
+		}
+
+		if len(v) > 1 {
+			e.Line = v[1]
+		}
+
+		entries = append(entries, e)
+	}
+
 	return &PushRequest{
 		Streams: []stream{
 			{
 				Stream: s,
-				Values: values,
+				Values: entries,
 			},
 		},
 	}
 }
+
+// GenerateStreams returns the payload to push the given streams at once. The labels of every stream
+// are added to the given labels.
+func GenerateStreams(labels []prompb.Label, streams []options.LogsStream, metadata map[string]string) *PushRequest {
+	r := &PushRequest{}
+
+	for _, s := range streams {
+		r.Streams = append(r.Streams, Generate(StreamLabels(labels, s.Labels), s.Logs, metadata).Streams[0])
+	}
+
+	return r
+}
+
+// StreamLabels returns the labels with the additional labels of a stream, which take precedence, sorted by name.
+func StreamLabels(labels []prompb.Label, extra map[string]string) []prompb.Label {
+	res := make([]prompb.Label, 0, len(labels)+len(extra))
+
+	for _, l := range labels {
+		if _, ok := extra[l.Name]; !ok {
+			res = append(res, l)
+		}
+	}
+
+	for k, v := range extra {
+		res = append(res, prompb.Label{Name: k, Value: v})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res
+}
+
+// VolumeStreams returns the labels of the given number of streams pushed by GenerateVolume.
+func VolumeStreams(labels []prompb.Label, streams int) [][]prompb.Label {
+	if streams <= 1 {
+		return [][]prompb.Label{labels}
+	}
+
+	res := make([][]prompb.Label, streams)
+	for i := range res {
+		res[i] = StreamLabels(labels, map[string]string{StreamLabel: strconv.Itoa(i)})
+	}
+
+	return res
+}
+
+// StreamLabel distinguishes the streams of a push if more than one stream is generated.
+const StreamLabel = "stream"
+
+// GenerateVolume returns the payload to push the given number of streams, each with the given number
+// of lines generated from the template.
+func GenerateVolume(labels []prompb.Label, lt *LineTemplate, now time.Time, streams, lines int,
+	metadata map[string]string) (*PushRequest, error) {
+	r := &PushRequest{}
+
+	for _, sl := range VolumeStreams(labels, streams) {
+		values, err := lt.Generate(now, lines)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Streams = append(r.Streams, Generate(sl, values, metadata).Streams[0])
+	}
+
+	return r, nil
+}
+
+func (r *PushRequest) entries() int {
+	n := 0
+	for _, s := range r.Streams {
+		n += len(s.Values)
+	}
+
+	return n
+}
+
+// LineBytes returns the number of bytes of the pushed log lines.
+func (r *PushRequest) LineBytes() int {
+	n := 0
+
+	for _, s := range r.Streams {
+		for _, e := range s.Values {
+			n += len(e.Line)
+		}
+	}
+
+	return n
+}
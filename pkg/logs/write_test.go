@@ -0,0 +1,100 @@
+package logs
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestGenerateVolume(t *testing.T) {
+	lt, err := NewLineTemplate("{{.Sequence}} ", 10)
+	testutil.Ok(t, err)
+
+	labels := []prompb.Label{{Name: "job", Value: "up"}}
+
+	r, err := GenerateVolume(labels, lt, time.Unix(0, 0), 3, 2, nil)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 3, len(r.Streams))
+	testutil.Equals(t, 60, r.LineBytes())
+
+	for i, s := range r.Streams {
+		testutil.Equals(t, map[string]string{"job": "up", StreamLabel: string(rune('0' + i))}, s.Stream)
+		testutil.Equals(t, 2, len(s.Values))
+	}
+
+	// A single stream is pushed with the labels only, matching the selector of the reader exactly.
+	r, err = GenerateVolume(labels, lt, time.Unix(0, 0), 1, 1, nil)
+	testutil.Ok(t, err)
+	testutil.Equals(t, map[string]string{"job": "up"}, r.Streams[0].Stream)
+}
+
+func TestGenerateStreams(t *testing.T) {
+	labels := []prompb.Label{{Name: "instance", Value: "a"}, {Name: "job", Value: "up"}}
+
+	r := GenerateStreams(labels, []options.LogsStream{
+		{Labels: map[string]string{"app": "api"}, Logs: [][]string{{"1", "first"}, {"2", "second"}}},
+		// The labels of the stream take precedence.
+		{Labels: map[string]string{"job": "canary"}, Logs: [][]string{{"3", "third"}}},
+	}, nil)
+
+	testutil.Equals(t, 2, len(r.Streams))
+	testutil.Equals(t, map[string]string{"app": "api", "instance": "a", "job": "up"}, r.Streams[0].Stream)
+	testutil.Equals(t, 2, len(r.Streams[0].Values))
+	testutil.Equals(t, map[string]string{"instance": "a", "job": "canary"}, r.Streams[1].Stream)
+	testutil.Equals(t, "third", r.Streams[1].Values[0].Line)
+}
+
+func TestWrite(t *testing.T) {
+	var tenant, contentType, body string
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, contentType = r.Header.Get("X-Scope-OrgID"), r.Header.Get("Content-Type")
+
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gr, err := gzip.NewReader(r.Body)
+			testutil.Ok(t, err)
+
+			b, err := ioutil.ReadAll(gr)
+			testutil.Ok(t, err)
+
+			body = string(b)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL + "/loki/api/v1/push")
+	testutil.Ok(t, err)
+
+	wreq := Generate(nil, [][]string{{"1", "line"}}, nil)
+
+	m := instr.RegisterMetrics(prometheus.NewRegistry(), options.Buckets{})
+
+	_, err = Write(context.Background(), u, auth.NewStaticToken(""), wreq, m, log.NewNopLogger(), options.TLS{},
+		options.ProtobufPushEncoding, options.NoPushCompression, "X-Scope-OrgID", "team-a")
+	testutil.Ok(t, err)
+	testutil.Equals(t, "team-a", tenant)
+	testutil.Equals(t, "application/x-protobuf", contentType)
+	testutil.Equals(t, float64(len("line")), promtestutil.ToFloat64(m.LogsBytesWritten))
+
+	_, err = Write(context.Background(), u, auth.NewStaticToken(""), wreq, m, log.NewNopLogger(), options.TLS{},
+		options.JSONPushEncoding, options.GzipPushCompression, "X-Scope-OrgID", "team-a")
+	testutil.Ok(t, err)
+	testutil.Equals(t, "application/json", contentType)
+	testutil.Equals(t, `{"streams":[{"stream":{},"values":[["1","line"]]}]}`, body)
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/observatorium/up/pkg/api"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/options"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+// ReadBuildInfo queries the build information of the server behind the endpoint, failing without version.
+func ReadBuildInfo(
+	ctx context.Context,
+	endpoint *url.URL,
+	tp auth.TokenProvider,
+	l log.Logger,
+	tls options.TLS,
+	tenantHeader string,
+	tenant string,
+) (promapiv1.BuildinfoResult, int, error) {
+	client, err := NewClient(endpoint, tp, l, tls, tenantHeader, tenant, nil)
+	if err != nil {
+		return promapiv1.BuildinfoResult{}, 0, err
+	}
+
+	res, httpCode, _, err := api.BuildInfo(ctx, client, false)
+	if err != nil {
+		return res, httpCode, errors.Wrap(err, "build info request failed")
+	}
+
+	if res.Version == "" {
+		return res, httpCode, errors.New("expected the version in the build info, got none")
+	}
+
+	return res, httpCode, nil
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/observatorium/up/pkg/api"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/options"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// ReadExemplars queries the exemplars of the written metrics back and checks the newest one is within the latency.
+func ReadExemplars(
+	ctx context.Context,
+	endpoint *url.URL,
+	tp auth.TokenProvider,
+	labels []prompb.Label,
+	latency time.Duration,
+	l log.Logger,
+	tls options.TLS,
+	tenantHeader string,
+	tenant string,
+) (int, error) {
+	client, err := NewClient(endpoint, tp, l, tls, tenantHeader, tenant, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+
+	res, httpCode, _, err := api.QueryExemplars(ctx, client, Selector(labels), now.Add(-latency), now, false)
+	if err != nil {
+		return httpCode, errors.Wrap(err, "query exemplars request failed")
+	}
+
+	if len(res) != 1 {
+		return httpCode, errors.Errorf("expected exemplars of one series, got %d", len(res))
+	}
+
+	if len(res[0].Exemplars) == 0 {
+		return httpCode, errors.Errorf("expected at least one exemplar within %s, got none", latency)
+	}
+
+	var newest time.Time
+
+	for _, e := range res[0].Exemplars {
+		if e.Labels[exemplarLabel] == "" {
+			return httpCode, errors.Errorf("exemplar is missing the %s label", exemplarLabel)
+		}
+
+		if t := e.Timestamp.Time(); t.After(newest) {
+			newest = t
+		}
+	}
+
+	if diff := time.Since(newest); diff > latency {
+		return httpCode, errors.Errorf("exemplar is too old: %2.fs", diff.Seconds())
+	}
+
+	return httpCode, nil
+}
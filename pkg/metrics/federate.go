@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/observatorium/up/pkg/api"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/options"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// ReadFederated scrapes the written series from the federation endpoint and checks its sample is within the latency.
+// The federated series can carry more labels than the written ones, e.g. the external labels of the server.
+func ReadFederated(
+	ctx context.Context,
+	endpoint *url.URL,
+	tp auth.TokenProvider,
+	labels []prompb.Label,
+	latency time.Duration,
+	l log.Logger,
+	tls options.TLS,
+	tenantHeader string,
+	tenant string,
+) (int, error) {
+	client, err := NewClient(endpoint, tp, l, tls, tenantHeader, tenant, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	families, httpCode, err := api.Federate(ctx, client, []string{Selector(labels)})
+	if err != nil {
+		return httpCode, errors.Wrap(err, "federate request failed")
+	}
+
+	var (
+		name   string
+		newest time.Time
+		found  bool
+	)
+
+	for _, lb := range labels {
+		if lb.Name == model.MetricNameLabel {
+			name = lb.Value
+		}
+	}
+
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			got := make(map[string]string, len(m.GetLabel()))
+			for _, lp := range m.GetLabel() {
+				got[lp.GetName()] = lp.GetValue()
+			}
+
+			if !hasLabels(got, labels) {
+				continue
+			}
+
+			if m.TimestampMs == nil {
+				return httpCode, errors.Errorf("federated sample of %s has no timestamp", Selector(labels))
+			}
+
+			found = true
+
+			if t := time.UnixMilli(m.GetTimestampMs()); t.After(newest) {
+				newest = t
+			}
+		}
+	}
+
+	if !found {
+		return httpCode, errors.Errorf("expected the series %s to be federated, got none", Selector(labels))
+	}
+
+	if diff := time.Since(newest); diff > latency {
+		return httpCode, errors.Errorf("federated sample is too old: %2.fs", diff.Seconds())
+	}
+
+	return httpCode, nil
+}
+
+// hasLabels returns whether the labels of a federated series include the written labels, except the name.
+func hasLabels(got map[string]string, labels []prompb.Label) bool {
+	for _, l := range labels {
+		if l.Name == model.MetricNameLabel {
+			continue
+		}
+
+		if v, ok := got[l.Name]; !ok || v != l.Value {
+			return false
+		}
+	}
+
+	return true
+}
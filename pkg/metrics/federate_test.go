@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestReadFederated(t *testing.T) {
+	labels := []prompb.Label{{Name: "__name__", Value: "up_custom"}, {Name: "job", Value: "canary"}}
+
+	for i, tc := range []struct {
+		body string
+		age  time.Duration
+		ok   bool
+	}{
+		// The external labels of the server are added to the federated series.
+		{body: "up_custom{job=\"canary\",replica=\"a\"} 1 %d\n", ok: true},
+		// The sample is older than the latency.
+		{body: "up_custom{job=\"canary\"} 1 %d\n", age: time.Minute},
+		// The series is not federated.
+		{body: "up_custom{job=\"other\"} 1 %d\n"},
+		{body: "# %d\n"},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			ts := time.Now().Add(-tc.age)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				testutil.Equals(t, "/federate", r.URL.Path)
+				testutil.Equals(t, []string{`{__name__="up_custom",job="canary"}`}, r.URL.Query()["match[]"])
+
+				w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+				fmt.Fprintf(w, tc.body, ts.UnixMilli())
+			}))
+			defer srv.Close()
+
+			u, err := url.Parse(srv.URL)
+			testutil.Ok(t, err)
+
+			code, err := ReadFederated(context.Background(), u, auth.NewNoOpTokenProvider(), labels, 15*time.Second,
+				log.NewNopLogger(), options.TLS{}, "", "")
+			testutil.Equals(t, http.StatusOK, code)
+
+			if tc.ok {
+				testutil.Ok(t, err)
+				return
+			}
+
+			testutil.NotOk(t, err)
+		})
+	}
+}
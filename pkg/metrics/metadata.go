@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/observatorium/up/pkg/api"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/options"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+// ReadMetadata queries the metadata of the written metric back and checks it matches the written metadata.
+func ReadMetadata(
+	ctx context.Context,
+	endpoint *url.URL,
+	tp auth.TokenProvider,
+	name string,
+	l log.Logger,
+	tls options.TLS,
+	tenantHeader string,
+	tenant string,
+) (int, error) {
+	client, err := NewClient(endpoint, tp, l, tls, tenantHeader, tenant, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	res, httpCode, _, err := api.Metadata(ctx, client, name, false)
+	if err != nil {
+		return httpCode, errors.Wrap(err, "metadata request failed")
+	}
+
+	expected := GenerateMetadata(name)[0]
+
+	for _, md := range res[name] {
+		if md.Type == promapiv1.MetricTypeGauge && md.Help == expected.Help {
+			return httpCode, nil
+		}
+	}
+
+	return httpCode, errors.Errorf("expected metadata of metric %s, got %v", name, res[name])
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// GenerateFromGatherer returns the payload to remote-write the metrics of the gatherer with the prefix, as scraped at
+// the timestamp in milliseconds. Histograms and summaries are written as their classic series, e.g. '_bucket', '_sum'
+// and '_count'. The labels are added to every series, e.g. to tell the runs of up apart.
+func GenerateFromGatherer(g prometheus.Gatherer, prefix string, labels []prompb.Label, timestamp int64) (*prompb.WriteRequest, error) {
+	mfs, err := g.Gather()
+	if err != nil {
+		return nil, errors.Wrap(err, "gathering metrics")
+	}
+
+	wreq := &prompb.WriteRequest{}
+
+	for _, mf := range mfs {
+		if !strings.HasPrefix(mf.GetName(), prefix) {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			add := func(name string, value float64, extra ...prompb.Label) {
+				wreq.Timeseries = append(wreq.Timeseries, prompb.TimeSeries{
+					Labels:  seriesLabels(name, m.GetLabel(), labels, extra),
+					Samples: []prompb.Sample{{Value: value, Timestamp: timestamp}},
+				})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(mf.GetName(), m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(mf.GetName(), m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(mf.GetName(), m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					le := prompb.Label{Name: model.BucketLabel, Value: formatFloat(b.GetUpperBound())}
+					add(mf.GetName()+"_bucket", float64(b.GetCumulativeCount()), le)
+				}
+
+				add(mf.GetName()+"_bucket", float64(h.GetSampleCount()), prompb.Label{Name: model.BucketLabel, Value: "+Inf"})
+				add(mf.GetName()+"_sum", h.GetSampleSum())
+				add(mf.GetName()+"_count", float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(mf.GetName(), q.GetValue(), prompb.Label{Name: model.QuantileLabel, Value: formatFloat(q.GetQuantile())})
+				}
+
+				add(mf.GetName()+"_sum", s.GetSampleSum())
+				add(mf.GetName()+"_count", float64(s.GetSampleCount()))
+			}
+		}
+	}
+
+	return wreq, nil
+}
+
+// seriesLabels returns the sorted labels of a series of the name, as remote-write receivers expect them.
+func seriesLabels(name string, pairs []*dto.LabelPair, labels, extra []prompb.Label) []prompb.Label {
+	ls := make([]prompb.Label, 0, 1+len(pairs)+len(labels)+len(extra))
+	ls = append(ls, prompb.Label{Name: model.MetricNameLabel, Value: name})
+
+	for _, p := range pairs {
+		ls = append(ls, prompb.Label{Name: p.GetName(), Value: p.GetValue()})
+	}
+
+	ls = append(ls, labels...)
+	ls = append(ls, extra...)
+
+	sort.SliceStable(ls, func(i, j int) bool { return ls[i].Name < ls[j].Name })
+
+	return ls
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
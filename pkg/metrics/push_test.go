@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestGenerateFromGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "up_writes_total"}, []string{"result"})
+	c.WithLabelValues("success").Add(3)
+
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "up_duration_seconds", Buckets: []float64{0.5, 1}})
+	h.Observe(0.2)
+	h.Observe(2)
+
+	// Metrics without the prefix are not written.
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_goroutines"})
+
+	reg.MustRegister(c, h, g)
+
+	wreq, err := GenerateFromGatherer(reg, "up_", []prompb.Label{{Name: "job", Value: "up"}}, 1000)
+	testutil.Ok(t, err)
+
+	series := map[string]float64{}
+
+	for _, ts := range wreq.Timeseries {
+		testutil.Equals(t, []prompb.Sample{{Value: ts.Samples[0].Value, Timestamp: 1000}}, ts.Samples)
+
+		for i := 1; i < len(ts.Labels); i++ {
+			testutil.Assert(t, ts.Labels[i-1].Name < ts.Labels[i].Name, "labels must be sorted: %v", ts.Labels)
+		}
+
+		series[Selector(ts.Labels)] = ts.Samples[0].Value
+	}
+
+	testutil.Equals(t, map[string]float64{
+		`{__name__="up_writes_total",job="up",result="success"}`:     3,
+		`{__name__="up_duration_seconds_bucket",job="up",le="0.5"}`:  1,
+		`{__name__="up_duration_seconds_bucket",job="up",le="1"}`:    1,
+		`{__name__="up_duration_seconds_bucket",job="up",le="+Inf"}`: 2,
+		`{__name__="up_duration_seconds_sum",job="up"}`:              2.2,
+		`{__name__="up_duration_seconds_count",job="up"}`:            2,
+	}, series)
+}
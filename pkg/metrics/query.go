@@ -26,6 +26,8 @@ func Query(
 	query options.Query,
 	tls options.TLS,
 	defaultStep time.Duration,
+	tenantHeader string,
+	tenant string,
 ) (int, promapiv1.Warnings, error) {
 	var (
 		warn promapiv1.Warnings
@@ -50,14 +52,34 @@ func Query(
 		rt = auth.NewBearerTokenRoundTripper(l, t, nil)
 	}
 
+	crt := transport.InstrumentedRoundTripper(
+		auth.NewTenantRoundTripper(tenantHeader, tenant, auth.NewHeadersRoundTripper(query.GetCommon().Headers, rt)),
+	)
+
+	if mq, ok := query.(options.MultiEndpointQuery); ok {
+		clients := make([]promapi.Client, 0, len(mq.GetEndpoints()))
+
+		for _, e := range mq.GetEndpoints() {
+			c, err := promapi.NewClient(promapi.Config{Address: e, RoundTripper: crt})
+			if err != nil {
+				err = fmt.Errorf("create new API client: %w", err)
+				return 0, warn, err
+			}
+
+			clients = append(clients, c)
+		}
+
+		return mq.RunAll(ctx, clients, l, defaultStep)
+	}
+
 	c, err := promapi.NewClient(promapi.Config{
 		Address:      u.String(),
-		RoundTripper: rt,
+		RoundTripper: crt,
 	})
 	if err != nil {
 		err = fmt.Errorf("create new API client: %w", err)
 		return 0, warn, err
 	}
 
-	return query.Run(ctx, c, l, rt.TraceID, defaultStep)
+	return query.Run(ctx, c, l, defaultStep)
 }
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/observatorium/up/pkg/api"
@@ -17,72 +19,295 @@ import (
 	"github.com/go-kit/log"
 	"github.com/pkg/errors"
 	promapi "github.com/prometheus/client_golang/api"
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/prompb"
 )
 
 // Read executes query against Prometheus with the same labels to retrieve the written metrics back.
+// If query is not empty, it is used instead of the selector for the labels.
+// If failOnWarnings is true, warnings returned by the query count as failure.
+// If skew is not nil, the clock skew to the server is estimated. The freshness of the metrics is only corrected by it
+// with remoteWriter, if the metrics were written by another instance, e.g. in read-only mode. The metrics written by
+// this instance carry its own clock, which the freshness is measured with, so the skew to the server does not apply.
 func Read(
 	ctx context.Context,
 	endpoint *url.URL,
 	tp auth.TokenProvider,
 	labels []prompb.Label,
+	query string,
 	ago, latency time.Duration,
 	m instr.Metrics,
 	l log.Logger,
 	tls options.TLS,
+	tenantHeader string,
+	tenant string,
+	failOnWarnings bool,
+	skew *transport.ClockSkew,
+	remoteWriter bool,
 ) (int, error) {
-	var (
-		rt  http.RoundTripper
-		err error
-	)
-
-	if endpoint.Scheme == transport.HTTPS {
-		rt, err = transport.NewTLSTransport(l, tls)
-		if err != nil {
-			return 0, errors.Wrap(err, "create round tripper")
-		}
-
-		rt = auth.NewBearerTokenRoundTripper(l, tp, rt)
-	} else {
-		rt = auth.NewBearerTokenRoundTripper(l, tp, nil)
-	}
-
-	client, err := promapi.NewClient(promapi.Config{
-		Address:      endpoint.String(),
-		RoundTripper: rt,
-	})
+	client, err := NewClient(endpoint, tp, l, tls, tenantHeader, tenant, skew)
 	if err != nil {
 		return 0, err
 	}
 
-	labelSelectors := make([]string, len(labels))
-	for i, label := range labels {
-		labelSelectors[i] = fmt.Sprintf(`%s="%s"`, label.Name, label.Value)
+	if query == "" {
+		query = Selector(labels)
 	}
 
-	query := fmt.Sprintf("{%s}", strings.Join(labelSelectors, ","))
 	ts := time.Now().Add(ago)
 
-	value, httpCode, _, err := api.Query(ctx, client, query, ts, false)
+	ctx, stats := api.WithStats(ctx)
+
+	value, httpCode, warn, err := api.Query(ctx, client, query, ts, false, api.QueryParams{})
 	if err != nil {
 		return httpCode, errors.Wrap(err, "query request failed")
 	}
 
+	m.ObserveResultSize(instr.ReadQueryType, "", *stats)
+	m.ObserveWarnings(instr.ReadQueryType, "", warn)
+
+	if failOnWarnings {
+		if err := api.WarningsError(warn); err != nil {
+			return httpCode, err
+		}
+	}
+
 	vec := value.(model.Vector)
 	if len(vec) != 1 {
 		return httpCode, errors.Errorf("expected one metric, got %d", len(vec))
 	}
 
-	t := time.Unix(int64(vec[0].Value/1000), 0)
+	return httpCode, checkFreshness(int64(vec[0].Value), latency, m, correction(skew, remoteWriter))
+}
+
+const (
+	duplicateSeries = "series"
+	duplicateSample = "sample"
+)
+
+// RangeReader reads the written metrics back with a range query and detects gaps between the written samples
+// as well as series and samples duplicated e.g. by broken deduplication of replicas.
+// It remembers the newest sample seen, so a gap or duplicate sample is only counted once by overlapping reads.
+type RangeReader struct {
+	// Window is the range to read back.
+	Window time.Duration
+	// Period is the time between writes.
+	Period time.Duration
+	// FailOnWarnings makes warnings returned by the query count as failure.
+	FailOnWarnings bool
+	// ClockSkew estimates the clock skew to the server, if not nil.
+	ClockSkew *transport.ClockSkew
+	// RemoteWriter corrects the freshness of the metrics by the clock skew, as they are written by another instance.
+	RemoteWriter bool
+
+	mtx    sync.Mutex
+	newest int64
+}
+
+// Read executes a range query against Prometheus with the same labels to retrieve the written metrics back.
+// If query is not empty, it is used instead of the selector for the labels.
+func (r *RangeReader) Read(
+	ctx context.Context,
+	endpoint *url.URL,
+	tp auth.TokenProvider,
+	labels []prompb.Label,
+	query string,
+	ago, latency time.Duration,
+	m instr.Metrics,
+	l log.Logger,
+	tls options.TLS,
+	tenantHeader string,
+	tenant string,
+) (int, error) {
+	client, err := NewClient(endpoint, tp, l, tls, tenantHeader, tenant, r.ClockSkew)
+	if err != nil {
+		return 0, err
+	}
+
+	if query == "" {
+		query = Selector(labels)
+	}
+
+	end := time.Now().Add(ago)
+
+	ctx, stats := api.WithStats(ctx)
+
+	// Use half the period as step, so jitter between writes cannot hide a sample between two steps.
+	value, httpCode, warn, err := api.QueryRange(ctx, client, query, promapiv1.Range{
+		Start: end.Add(-r.Window),
+		End:   end,
+		Step:  r.Period / 2,
+	}, false, api.QueryParams{})
+	if err != nil {
+		return httpCode, errors.Wrap(err, "query range request failed")
+	}
+
+	m.ObserveResultSize(instr.ReadQueryType, "", *stats)
+	m.ObserveWarnings(instr.ReadQueryType, "", warn)
+
+	if r.FailOnWarnings {
+		if err := api.WarningsError(warn); err != nil {
+			return httpCode, err
+		}
+	}
+
+	mat := value.(model.Matrix)
+	if len(mat) == 0 {
+		return httpCode, errors.New("expected one series, got none")
+	}
+
+	// The value of every sample is the timestamp of its write, which reveals the written samples
+	// even though the query evaluation repeats them for every step.
+	written := make([][]int64, len(mat))
+	for i, s := range mat {
+		written[i] = writtenTimestamps(s.Values)
+	}
+
+	merged := r.analyze(written, m)
+	if len(merged) == 0 {
+		return httpCode, errors.New("expected at least one sample, got none")
+	}
+
+	if len(mat) > 1 {
+		m.ReadbackDuplicates.WithLabelValues(duplicateSeries).Add(float64(len(mat) - 1))
+		return httpCode, errors.Errorf("expected one series, got %d duplicated series", len(mat))
+	}
+
+	return httpCode, checkFreshness(merged[len(merged)-1], latency, m, correction(r.ClockSkew, r.RemoteWriter))
+}
 
-	diffSeconds := time.Since(t).Seconds()
+// analyze counts the gaps between the written samples and the samples duplicated across series.
+// It returns the distinct written samples of all series.
+func (r *RangeReader) analyze(written [][]int64, m instr.Metrics) []int64 {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var (
+		occurrences = map[int64]int{}
+		merged      []int64
+	)
+
+	for _, series := range written {
+		for _, ts := range series {
+			if occurrences[ts] == 0 {
+				merged = append(merged, ts)
+			}
+
+			occurrences[ts]++
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool { return merged[i] < merged[j] })
+
+	// Allow some jitter between writes before considering a sample missing.
+	maxInterval := r.Period.Milliseconds() * 3 / 2
+
+	for i, ts := range merged {
+		if ts <= r.newest {
+			continue
+		}
+
+		if n := occurrences[ts]; n > 1 {
+			m.ReadbackDuplicates.WithLabelValues(duplicateSample).Add(float64(n - 1))
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		if interval := ts - merged[i-1]; interval > maxInterval {
+			m.ReadbackGaps.Inc()
+			m.ReadbackGapDuration.Observe(time.Duration(interval*int64(time.Millisecond) - int64(r.Period)).Seconds())
+		}
+	}
+
+	if len(merged) > 0 && merged[len(merged)-1] > r.newest {
+		r.newest = merged[len(merged)-1]
+	}
+
+	return merged
+}
+
+func writtenTimestamps(samples []model.SamplePair) []int64 {
+	res := make([]int64, 0, len(samples))
+	for _, s := range samples {
+		res = append(res, int64(s.Value))
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+
+	// Remove the repetitions of the same sample.
+	n := 0
+
+	for i, ts := range res {
+		if i > 0 && res[n-1] == ts {
+			continue
+		}
+
+		res[n] = ts
+		n++
+	}
+
+	return res[:n]
+}
+
+// correction returns the correction of the freshness of metrics, the clock skew to the server if they were written by
+// another instance, assumed to be in sync with the server.
+func correction(skew *transport.ClockSkew, remoteWriter bool) time.Duration {
+	if !remoteWriter {
+		return 0
+	}
+
+	return skew.Get()
+}
+
+func checkFreshness(writtenMillis int64, latency time.Duration, m instr.Metrics, correction time.Duration) error {
+	t := time.Unix(writtenMillis/1000, 0)
+
+	diffSeconds := (time.Since(t) + correction).Seconds()
 
 	m.MetricValueDifference.Observe(diffSeconds)
 
 	if diffSeconds > latency.Seconds() {
-		return httpCode, errors.Errorf("metric value is too old: %2.fs", diffSeconds)
+		return errors.Errorf("metric value is too old: %2.fs", diffSeconds)
+	}
+
+	return nil
+}
+
+// NewClient returns an API client for the endpoint, authenticating with the token and setting the tenant.
+// The clock skew to the endpoint is estimated if skew is not nil.
+func NewClient(endpoint *url.URL, tp auth.TokenProvider, l log.Logger, tls options.TLS,
+	tenantHeader, tenant string, skew *transport.ClockSkew) (promapi.Client, error) {
+	var (
+		rt  http.RoundTripper
+		err error
+	)
+
+	if endpoint.Scheme == transport.HTTPS {
+		rt, err = transport.NewTLSTransport(l, tls)
+		if err != nil {
+			return nil, errors.Wrap(err, "create round tripper")
+		}
+
+		rt = auth.NewBearerTokenRoundTripper(l, tp, skew.RoundTripper(rt))
+	} else {
+		rt = auth.NewBearerTokenRoundTripper(l, tp, skew.RoundTripper(nil))
+	}
+
+	return promapi.NewClient(promapi.Config{
+		Address:      endpoint.String(),
+		RoundTripper: transport.InstrumentedRoundTripper(auth.NewTenantRoundTripper(tenantHeader, tenant, rt)),
+	})
+}
+
+// Selector returns the series selector matching exactly the given labels.
+func Selector(labels []prompb.Label) string {
+	labelSelectors := make([]string, len(labels))
+	for i, label := range labels {
+		labelSelectors[i] = fmt.Sprintf(`%s="%s"`, label.Name, label.Value)
 	}
 
-	return httpCode, nil
+	return fmt.Sprintf("{%s}", strings.Join(labelSelectors, ","))
 }
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/observatorium/up/pkg/transport"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+)
+
+func TestRangeReader_analyze(t *testing.T) {
+	testCases := []struct {
+		reads              [][][]model.SampleValue
+		expectedGaps       float64
+		expectedDuplicates float64
+	}{
+		{
+			// Samples repeated by the query steps are not gaps.
+			[][][]model.SampleValue{{{1000, 1000, 6000, 6000, 11000}}},
+			0,
+			0,
+		},
+		{
+			// A missing sample is a gap.
+			[][][]model.SampleValue{{{1000, 6000, 16000, 21000}}},
+			1,
+			0,
+		},
+		{
+			// Overlapping reads count the same gap only once.
+			[][][]model.SampleValue{{{1000, 6000, 16000}}, {{6000, 16000, 21000}}, {{16000, 21000, 31000}}},
+			2,
+			0,
+		},
+		{
+			// Samples in several series are duplicates, filling each other's gaps.
+			[][][]model.SampleValue{{{1000, 6000, 11000}, {1000, 11000, 16000}}},
+			0,
+			2,
+		},
+		{
+			// Overlapping reads count the same duplicate only once.
+			[][][]model.SampleValue{{{1000, 6000}, {1000, 6000}}, {{6000, 11000}, {6000, 11000}}},
+			0,
+			3,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			m := instr.RegisterMetrics(prometheus.NewRegistry(), options.Buckets{})
+			r := &RangeReader{Window: time.Minute, Period: 5 * time.Second}
+
+			for _, read := range tc.reads {
+				written := make([][]int64, len(read))
+
+				for j, values := range read {
+					samples := make([]model.SamplePair, len(values))
+					for k, v := range values {
+						samples[k] = model.SamplePair{Value: v}
+					}
+
+					written[j] = writtenTimestamps(samples)
+				}
+
+				r.analyze(written, m)
+			}
+
+			testutil.Equals(t, tc.expectedGaps, promtestutil.ToFloat64(m.ReadbackGaps))
+			testutil.Equals(t, tc.expectedDuplicates, promtestutil.ToFloat64(m.ReadbackDuplicates.WithLabelValues(duplicateSample)))
+		})
+	}
+}
+
+func TestRead_ClockSkew(t *testing.T) {
+	// The clock of the server is ahead of the local one.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(30*time.Second).UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[%d,"%d"]}]}}`,
+			time.Now().Unix(), time.Now().UnixMilli())
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	testutil.Ok(t, err)
+
+	for _, tc := range []struct {
+		remoteWriter bool
+		fresh        bool
+	}{
+		// The sample is written with the local clock, so the skew to the server does not make it older.
+		{remoteWriter: false, fresh: true},
+		// The sample of another instance is as old as the server clock tells.
+		{remoteWriter: true, fresh: false},
+	} {
+		t.Run(fmt.Sprintf("remote writer %t", tc.remoteWriter), func(t *testing.T) {
+			m := instr.RegisterMetrics(prometheus.NewRegistry(), options.Buckets{})
+			skew := transport.NewClockSkew(m.ClockSkew)
+
+			_, err := Read(context.Background(), u, auth.NewNoOpTokenProvider(), nil, "up", 0, 15*time.Second, m,
+				log.NewNopLogger(), options.TLS{}, "", "", false, skew, tc.remoteWriter)
+
+			testutil.Assert(t, skew.Get() > 25*time.Second, "expected the skew to be estimated, got %v", skew.Get())
+
+			if tc.fresh {
+				testutil.Ok(t, err)
+				return
+			}
+
+			testutil.NotOk(t, err)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/observatorium/up/pkg/auth"
@@ -18,6 +19,11 @@ import (
 	"github.com/prometheus/prometheus/prompb"
 )
 
+const (
+	exemplarLabel = "trace_id"
+	metadataHelp  = "Canary metric written by up. The value is the timestamp of the write in milliseconds."
+)
+
 // Write executes a remote-write against Prometheus sending a set of labels and metrics to store.
 func Write(ctx context.Context, endpoint *url.URL, t auth.TokenProvider, wreq proto.Message, l log.Logger, tls options.TLS,
 	tenantHeader string, tenant string) (int, error) {
@@ -38,7 +44,7 @@ func Write(ctx context.Context, endpoint *url.URL, t auth.TokenProvider, wreq pr
 		rt = http.DefaultTransport
 	}
 
-	client := &http.Client{Transport: rt}
+	client := &http.Client{Transport: transport.InstrumentedRoundTripper(rt)}
 
 	buf, err = proto.Marshal(wreq)
 	if err != nil {
@@ -50,6 +56,10 @@ func Write(ctx context.Context, endpoint *url.URL, t auth.TokenProvider, wreq pr
 		return 0, errors.Wrap(err, "creating request")
 	}
 
+	// The Content-Encoding is the one of the remote-write protocol, it can be replaced by the encoding of the endpoint.
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
 	token, err := t.Get()
 	if err != nil {
 		return 0, errors.Wrap(err, "retrieving token")
@@ -79,20 +89,42 @@ func Write(ctx context.Context, endpoint *url.URL, t auth.TokenProvider, wreq pr
 }
 
 // Generate takes a set of labels and metrics key-value pairs and returns the payload to write metrics to Prometheus.
-func Generate(labels []prompb.Label) *prompb.WriteRequest {
+// If exemplar is true, an exemplar with the same value and timestamp is attached to the sample.
+func Generate(labels []prompb.Label, exemplar bool) *prompb.WriteRequest {
 	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
 
-	return &prompb.WriteRequest{
-		Timeseries: []prompb.TimeSeries{
+	ts := prompb.TimeSeries{
+		Labels: labels,
+		Samples: []prompb.Sample{
+			{
+				Value:     float64(timestamp),
+				Timestamp: timestamp,
+			},
+		},
+	}
+
+	if exemplar {
+		ts.Exemplars = []prompb.Exemplar{
 			{
-				Labels: labels,
-				Samples: []prompb.Sample{
-					{
-						Value:     float64(timestamp),
-						Timestamp: timestamp,
-					},
-				},
+				Labels:    []prompb.Label{{Name: exemplarLabel, Value: strconv.FormatInt(timestamp, 16)}},
+				Value:     float64(timestamp),
+				Timestamp: timestamp,
 			},
+		}
+	}
+
+	return &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{ts},
+	}
+}
+
+// GenerateMetadata returns the metadata to write along with the metric of the given name.
+func GenerateMetadata(name string) []prompb.MetricMetadata {
+	return []prompb.MetricMetadata{
+		{
+			Type:             prompb.MetricMetadata_GAUGE,
+			MetricFamilyName: name,
+			Help:             metadataHelp,
 		},
 	}
 }
@@ -0,0 +1,232 @@
+package options
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/observatorium/up/pkg/api"
+	promapi "github.com/prometheus/client_golang/api"
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// Labels for the query types of the targets, rules and alerts APIs.
+	labelTargets = "targets"
+	labelRules   = "rules"
+	labelAlerts  = "alerts"
+
+	TargetStateActive  = "active"
+	TargetStateDropped = "dropped"
+	TargetStateAny     = "any"
+
+	RuleTypeAlert  = "alert"
+	RuleTypeRecord = "record"
+)
+
+// TargetsSpec represents a request to the targets API, asserting on the scraped targets.
+type TargetsSpec struct {
+	CommonSpec `yaml:",inline"`
+
+	Name  string `yaml:"name"`
+	State string `yaml:"state,omitempty"`
+	Cache bool   `yaml:"cache,omitempty"`
+	// Job restricts the assertions to targets with the job label.
+	Job string `yaml:"job,omitempty"`
+	// MinHealthy is the number of active targets which must be up.
+	MinHealthy *int `yaml:"min_healthy,omitempty"`
+}
+
+func (q TargetsSpec) GetName() string { return q.Name }
+
+func (q TargetsSpec) GetType() string { return labelTargets }
+
+func (q TargetsSpec) GetQuery() string { return q.Job }
+
+func (q TargetsSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
+	_ time.Duration) (int, promapiv1.Warnings, error) {
+	res, httpCode, warn, err := api.Targets(ctx, c, q.State, q.Cache)
+	if err != nil {
+		err = fmt.Errorf("querying: %w", err)
+		return httpCode, warn, err
+	}
+
+	if q.MinHealthy != nil {
+		healthy := 0
+
+		for _, t := range res.Active {
+			if q.Job != "" && string(t.Labels[model.JobLabel]) != q.Job {
+				continue
+			}
+
+			if t.Health == promapiv1.HealthGood {
+				healthy++
+			}
+		}
+
+		if healthy < *q.MinHealthy {
+			return httpCode, warn, assertionErrorf("expected at least %d healthy targets, got %d", *q.MinHealthy, healthy)
+		}
+	}
+
+	// Don't log responses because there are a lot.
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", api.TraceID(ctx))
+
+	return httpCode, warn, err
+}
+
+// RulesSpec represents a request to the rules API, asserting on the loaded rule groups.
+type RulesSpec struct {
+	CommonSpec `yaml:",inline"`
+
+	Name  string `yaml:"name"`
+	Type  string `yaml:"type,omitempty"`
+	Cache bool   `yaml:"cache,omitempty"`
+	// Groups are the names of the rule groups which must be present.
+	Groups []string `yaml:"groups,omitempty"`
+	// MinRules is the number of rules which must be present in total.
+	MinRules *int `yaml:"min_rules,omitempty"`
+	// Healthy requires all rules to be evaluated without errors.
+	Healthy bool `yaml:"healthy,omitempty"`
+}
+
+func (q RulesSpec) GetName() string { return q.Name }
+
+func (q RulesSpec) GetType() string { return labelRules }
+
+func (q RulesSpec) GetQuery() string { return strings.Join(q.Groups, ", ") }
+
+func (q RulesSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
+	_ time.Duration) (int, promapiv1.Warnings, error) {
+	res, httpCode, warn, err := api.Rules(ctx, c, q.Type, q.Cache)
+	if err != nil {
+		err = fmt.Errorf("querying: %w", err)
+		return httpCode, warn, err
+	}
+
+	if err := q.check(res); err != nil {
+		return httpCode, warn, err
+	}
+
+	// Don't log responses because there are a lot.
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", api.TraceID(ctx))
+
+	return httpCode, warn, err
+}
+
+func (q RulesSpec) check(res promapiv1.RulesResult) error {
+	var (
+		groups  = map[string]struct{}{}
+		rules   int
+		failing []string
+	)
+
+	for _, g := range res.Groups {
+		groups[g.Name] = struct{}{}
+
+		for _, r := range g.Rules {
+			rules++
+
+			switch r := r.(type) {
+			case promapiv1.AlertingRule:
+				if r.Health != promapiv1.RuleHealthGood {
+					failing = append(failing, r.Name)
+				}
+			case promapiv1.RecordingRule:
+				if r.Health != promapiv1.RuleHealthGood {
+					failing = append(failing, r.Name)
+				}
+			}
+		}
+	}
+
+	for _, g := range q.Groups {
+		if _, ok := groups[g]; !ok {
+			return assertionErrorf("expected rule group %q to be present", g)
+		}
+	}
+
+	if q.MinRules != nil && rules < *q.MinRules {
+		return assertionErrorf("expected at least %d rules, got %d", *q.MinRules, rules)
+	}
+
+	if q.Healthy && len(failing) > 0 {
+		return assertionErrorf("expected all rules to be healthy, unhealthy: %s", strings.Join(failing, ", "))
+	}
+
+	return nil
+}
+
+// AlertsSpec represents a request to the alerts API, asserting on the firing alerts.
+type AlertsSpec struct {
+	CommonSpec `yaml:",inline"`
+
+	Name  string `yaml:"name"`
+	Cache bool   `yaml:"cache,omitempty"`
+	// Firing are the names of alerts which must be firing, e.g. a watchdog alert.
+	Firing []string `yaml:"firing,omitempty"`
+	// NotFiring are the names of alerts which must not be firing.
+	NotFiring []string `yaml:"not_firing,omitempty"`
+	// MaxFiring is the number of alerts which may be firing at most.
+	MaxFiring *int `yaml:"max_firing,omitempty"`
+}
+
+func (q AlertsSpec) GetName() string { return q.Name }
+
+func (q AlertsSpec) GetType() string { return labelAlerts }
+
+func (q AlertsSpec) GetQuery() string { return strings.Join(q.Firing, ", ") }
+
+func (q AlertsSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
+	_ time.Duration) (int, promapiv1.Warnings, error) {
+	res, httpCode, warn, err := api.Alerts(ctx, c, q.Cache)
+	if err != nil {
+		err = fmt.Errorf("querying: %w", err)
+		return httpCode, warn, err
+	}
+
+	if err := q.check(res); err != nil {
+		return httpCode, warn, err
+	}
+
+	// Don't log responses because there are a lot.
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", api.TraceID(ctx))
+
+	return httpCode, warn, err
+}
+
+func (q AlertsSpec) check(res promapiv1.AlertsResult) error {
+	var (
+		firing    = map[string]struct{}{}
+		numFiring int
+	)
+
+	for _, a := range res.Alerts {
+		if a.State == promapiv1.AlertStateFiring {
+			firing[string(a.Labels[model.AlertNameLabel])] = struct{}{}
+			numFiring++
+		}
+	}
+
+	for _, a := range q.Firing {
+		if _, ok := firing[a]; !ok {
+			return assertionErrorf("expected alert %q to be firing", a)
+		}
+	}
+
+	for _, a := range q.NotFiring {
+		if _, ok := firing[a]; ok {
+			return assertionErrorf("expected alert %q not to be firing", a)
+		}
+	}
+
+	if q.MaxFiring != nil && numFiring > *q.MaxFiring {
+		return assertionErrorf("expected at most %d firing alerts, got %d", *q.MaxFiring, numFiring)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package options
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+func TestRulesSpec_check(t *testing.T) {
+	res := promapiv1.RulesResult{Groups: []promapiv1.RuleGroup{
+		{Name: "up", Rules: promapiv1.Rules{
+			promapiv1.AlertingRule{Name: "Watchdog", Health: promapiv1.RuleHealthGood},
+			promapiv1.RecordingRule{Name: "job:up:sum", Health: promapiv1.RuleHealthBad},
+		}},
+	}}
+	two, three := 2, 3
+
+	for i, tc := range []struct {
+		spec RulesSpec
+		ok   bool
+	}{
+		{spec: RulesSpec{Groups: []string{"up"}, MinRules: &two}, ok: true},
+		{spec: RulesSpec{Groups: []string{"missing"}}},
+		{spec: RulesSpec{MinRules: &three}},
+		{spec: RulesSpec{Healthy: true}},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			err := tc.spec.check(res)
+			if tc.ok {
+				testutil.Ok(t, err)
+			} else {
+				testutil.NotOk(t, err)
+			}
+		})
+	}
+}
+
+func TestAlertsSpec_check(t *testing.T) {
+	res := promapiv1.AlertsResult{Alerts: []promapiv1.Alert{
+		{Labels: model.LabelSet{model.AlertNameLabel: "Watchdog"}, State: promapiv1.AlertStateFiring},
+		{Labels: model.LabelSet{model.AlertNameLabel: "TargetDown"}, State: promapiv1.AlertStatePending},
+	}}
+	zero, one := 0, 1
+
+	for i, tc := range []struct {
+		spec AlertsSpec
+		ok   bool
+	}{
+		{spec: AlertsSpec{Firing: []string{"Watchdog"}, NotFiring: []string{"TargetDown"}, MaxFiring: &one}, ok: true},
+		{spec: AlertsSpec{Firing: []string{"TargetDown"}}},
+		{spec: AlertsSpec{NotFiring: []string{"Watchdog"}}},
+		{spec: AlertsSpec{MaxFiring: &zero}},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			err := tc.spec.check(res)
+			if tc.ok {
+				testutil.Ok(t, err)
+			} else {
+				testutil.NotOk(t, err)
+			}
+		})
+	}
+}
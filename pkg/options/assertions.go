@@ -0,0 +1,107 @@
+package options
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/observatorium/up/pkg/api"
+	"github.com/prometheus/common/model"
+)
+
+// Assertions are the expectations on the result of a query.
+type Assertions struct {
+	NonEmpty  bool     `yaml:"non_empty,omitempty"`
+	MinSeries *int     `yaml:"min_series,omitempty"`
+	MaxSeries *int     `yaml:"max_series,omitempty"`
+	MinValue  *float64 `yaml:"min_value,omitempty"`
+	MaxValue  *float64 `yaml:"max_value,omitempty"`
+}
+
+// AssertionError is returned by queries with a result not matching their assertions.
+type AssertionError struct {
+	msg string
+}
+
+func (e *AssertionError) Error() string { return "assertion failed: " + e.msg }
+
+func assertionErrorf(format string, args ...interface{}) error {
+	return &AssertionError{msg: fmt.Sprintf(format, args...)}
+}
+
+// Check returns an AssertionError if the result does not match the assertions. Nil assertions always match.
+func (a *Assertions) Check(v model.Value) error {
+	if a == nil {
+		return nil
+	}
+
+	var (
+		numSeries int
+		values    []model.SampleValue
+	)
+
+	switch r := v.(type) {
+	case model.Vector:
+		numSeries = len(r)
+		for _, s := range r {
+			values = append(values, s.Value)
+		}
+	case model.Matrix:
+		numSeries = len(r)
+		for _, s := range r {
+			for _, p := range s.Values {
+				values = append(values, p.Value)
+			}
+		}
+	case *model.Scalar:
+		numSeries = 1
+		values = append(values, r.Value)
+	}
+
+	return a.check(numSeries, values)
+}
+
+// CheckLogs returns an AssertionError if the result of a LogQL query does not match the assertions.
+// The streams of log queries count as series without values.
+func (a *Assertions) CheckLogs(r *api.LokiResult) error {
+	if a == nil {
+		return nil
+	}
+
+	if r.Value != nil {
+		return a.Check(r.Value)
+	}
+
+	return a.check(len(r.Streams), nil)
+}
+
+func (a *Assertions) check(numSeries int, values []model.SampleValue) error {
+	if a.NonEmpty && numSeries == 0 {
+		return assertionErrorf("expected non-empty result")
+	}
+
+	if a.MinSeries != nil && numSeries < *a.MinSeries {
+		return assertionErrorf("expected at least %d series, got %d", *a.MinSeries, numSeries)
+	}
+
+	if a.MaxSeries != nil && numSeries > *a.MaxSeries {
+		return assertionErrorf("expected at most %d series, got %d", *a.MaxSeries, numSeries)
+	}
+
+	for _, v := range values {
+		f := float64(v)
+
+		if (a.MinValue != nil || a.MaxValue != nil) && math.IsNaN(f) {
+			return assertionErrorf("expected values within range, got NaN")
+		}
+
+		if a.MinValue != nil && f < *a.MinValue {
+			return assertionErrorf("expected values of at least %g, got %g", *a.MinValue, f)
+		}
+
+		if a.MaxValue != nil && f > *a.MaxValue {
+			return assertionErrorf("expected values of at most %g, got %g", *a.MaxValue, f)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/observatorium/up/pkg/api"
+	"github.com/prometheus/common/model"
+)
+
+func TestAssertions_Check(t *testing.T) {
+	one, two := 1, 2
+	zero, ten := 0.0, 10.0
+
+	vector := model.Vector{{Value: 1}, {Value: 5}}
+
+	testCases := []struct {
+		assertions *Assertions
+		value      model.Value
+		ok         bool
+	}{
+		{assertions: nil, value: model.Vector{}, ok: true},
+		{assertions: &Assertions{NonEmpty: true}, value: model.Vector{}, ok: false},
+		{assertions: &Assertions{NonEmpty: true}, value: vector, ok: true},
+		{assertions: &Assertions{MinSeries: &two}, value: vector, ok: true},
+		{assertions: &Assertions{MaxSeries: &one}, value: vector, ok: false},
+		{assertions: &Assertions{MinValue: &zero, MaxValue: &ten}, value: vector, ok: true},
+		{assertions: &Assertions{MaxValue: &zero}, value: &model.Scalar{Value: 1}, ok: false},
+		{
+			assertions: &Assertions{MinValue: &zero},
+			value:      model.Matrix{{Values: []model.SamplePair{{Value: 1}, {Value: -1}}}},
+			ok:         false,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			err := tc.assertions.Check(tc.value)
+			if tc.ok {
+				testutil.Ok(t, err)
+				return
+			}
+
+			var aErr *AssertionError
+			testutil.Assert(t, errors.As(err, &aErr), "expected assertion error, got %v", err)
+		})
+	}
+}
+
+func TestAssertions_CheckLogs(t *testing.T) {
+	zero := 0
+	streams := &api.LokiResult{Streams: []api.LokiStream{{}, {}}}
+
+	testutil.Ok(t, (&Assertions{NonEmpty: true}).CheckLogs(streams))
+	testutil.NotOk(t, (&Assertions{MaxSeries: &zero}).CheckLogs(streams))
+	testutil.Ok(t, (&Assertions{MaxSeries: &zero}).CheckLogs(&api.LokiResult{}))
+	testutil.NotOk(t, (&Assertions{NonEmpty: true}).CheckLogs(&api.LokiResult{Value: model.Vector{}}))
+}
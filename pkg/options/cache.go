@@ -0,0 +1,108 @@
+package options
+
+// Cacheable is implemented by queries which can be allowed to be answered from caches.
+type Cacheable interface {
+	Query
+	// Cached returns whether the query may be answered from caches.
+	Cached() bool
+	// Uncached returns a copy of the query which bypasses caches.
+	Uncached() Query
+}
+
+func (q QuerySpec) Cached() bool { return q.Cache }
+
+func (q QuerySpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
+
+func (q LabelSpec) Cached() bool { return q.Cache }
+
+func (q LabelSpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
+
+func (q SeriesSpec) Cached() bool { return q.Cache }
+
+func (q SeriesSpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
+
+func (q AbsentSpec) Cached() bool { return q.Cache }
+
+func (q AbsentSpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
+
+func (q LogQLSpec) Cached() bool { return q.Cache }
+
+func (q LogQLSpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
+
+func (q LogsLabelSpec) Cached() bool { return q.Cache }
+
+func (q LogsLabelSpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
+
+func (q LogsSeriesSpec) Cached() bool { return q.Cache }
+
+func (q LogsSeriesSpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
+
+func (q LogsIndexStatsSpec) Cached() bool { return q.Cache }
+
+func (q LogsIndexStatsSpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
+
+func (q LogsVolumeSpec) Cached() bool { return q.Cache }
+
+func (q LogsVolumeSpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
+
+func (q LogsDetectedFieldsSpec) Cached() bool { return q.Cache }
+
+func (q LogsDetectedFieldsSpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
+
+func (q LogsPatternsSpec) Cached() bool { return q.Cache }
+
+func (q LogsPatternsSpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
+
+func (q TargetsSpec) Cached() bool { return q.Cache }
+
+func (q TargetsSpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
+
+func (q RulesSpec) Cached() bool { return q.Cache }
+
+func (q RulesSpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
+
+func (q AlertsSpec) Cached() bool { return q.Cache }
+
+func (q AlertsSpec) Uncached() Query {
+	q.Cache = false
+	return q
+}
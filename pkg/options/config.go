@@ -0,0 +1,184 @@
+package options
+
+import (
+	"net/url"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Redacted replaces secrets in the effective configuration.
+const Redacted = "<redacted>"
+
+// Config is the effective configuration of the options after merging flags, files and environment variables,
+// serializable as YAML with secrets redacted. The keys are the names of the flags setting them.
+type Config struct {
+	EndpointType      EndpointType             `yaml:"endpoint-type"`
+	WriteEndpoint     string                   `yaml:"endpoint-write,omitempty"`
+	ReadEndpoint      string                   `yaml:"endpoint-read,omitempty"`
+	StoreEndpoint     string                   `yaml:"endpoint-store,omitempty"`
+	StoreTLS          bool                     `yaml:"endpoint-store-tls"`
+	Labels            map[string]string        `yaml:"labels"`
+	ReadQuery         string                   `yaml:"read-query,omitempty"`
+	ReadMode          ReadMode                 `yaml:"read-mode"`
+	ReadWindow        string                   `yaml:"read-window"`
+	Exemplars         bool                     `yaml:"exemplars"`
+	Metadata          bool                     `yaml:"metadata"`
+	BuildInfo         bool                     `yaml:"build-info"`
+	Federate          bool                     `yaml:"federate"`
+	LogsFile          string                   `yaml:"logs-file,omitempty"`
+	LogsEncoding      PushEncoding             `yaml:"logs-encoding,omitempty"`
+	LogsCompression   PushCompression          `yaml:"logs-compression,omitempty"`
+	LogsTemplate      string                   `yaml:"logs-template,omitempty"`
+	LogsTenants       []string                 `yaml:"logs-tenants,omitempty"`
+	Listen            string                   `yaml:"listen"`
+	Token             string                   `yaml:"token,omitempty"`
+	QueriesFile       string                   `yaml:"queries-file,omitempty"`
+	ReloadInterval    string                   `yaml:"reload-interval"`
+	Period            string                   `yaml:"period"`
+	Duration          string                   `yaml:"duration"`
+	Latency           string                   `yaml:"latency"`
+	InitialQueryDelay string                   `yaml:"initial-query-delay"`
+	WriteThreshold    float64                  `yaml:"threshold-write"`
+	ReadThreshold     float64                  `yaml:"threshold-read"`
+	EvaluationWindow  string                   `yaml:"evaluation-window"`
+	QueriesThreshold  float64                  `yaml:"queries-threshold"`
+	FailOnWarnings    bool                     `yaml:"fail-on-warnings"`
+	FailFast          bool                     `yaml:"fail-fast"`
+	TLSCert           string                   `yaml:"tls-client-cert-file,omitempty"`
+	TLSKey            string                   `yaml:"tls-client-private-key-file,omitempty"`
+	TLSCACert         string                   `yaml:"tls-ca-file,omitempty"`
+	TLSInsecure       bool                     `yaml:"tls-insecure-skip-verify,omitempty"`
+	DefaultStep       string                   `yaml:"step"`
+	Tenant            string                   `yaml:"tenant,omitempty"`
+	TenantHeader      string                   `yaml:"tenant-header"`
+	Tenants           []TenantSpec             `yaml:"tenants,omitempty"`
+	Endpoints         *EndpointsSpec           `yaml:"endpoints,omitempty"`
+	SummaryFile       string                   `yaml:"summary-file,omitempty"`
+	ResultsFile       string                   `yaml:"results-file,omitempty"`
+	Queries           []map[string]interface{} `yaml:"queries,omitempty"`
+	Scenarios         []ScenarioSpec           `yaml:"scenarios,omitempty"`
+}
+
+// Config returns the effective configuration of the options.
+func (o Options) Config() (Config, error) {
+	c := Config{
+		EndpointType:      o.EndpointType,
+		WriteEndpoint:     redactURL(o.WriteEndpoint),
+		ReadEndpoint:      redactURL(o.ReadEndpoint),
+		StoreEndpoint:     o.StoreEndpoint,
+		StoreTLS:          o.StoreTLS,
+		Labels:            make(map[string]string, len(o.Labels)),
+		ReadQuery:         o.ReadQuery,
+		ReadMode:          o.ReadMode,
+		ReadWindow:        o.ReadWindow.String(),
+		Exemplars:         o.Exemplars,
+		Metadata:          o.Metadata,
+		BuildInfo:         o.BuildInfo,
+		Federate:          o.Federate,
+		LogsFile:          o.LogsFile,
+		LogsTemplate:      o.LogsTemplate,
+		LogsTenants:       o.LogsTenants,
+		Listen:            o.Listen,
+		QueriesFile:       o.QueriesFile,
+		ReloadInterval:    o.ReloadInterval.String(),
+		Period:            o.Period.String(),
+		Duration:          o.Duration.String(),
+		Latency:           o.Latency.String(),
+		InitialQueryDelay: o.InitialQueryDelay.String(),
+		WriteThreshold:    o.WriteThreshold,
+		ReadThreshold:     o.ReadThreshold,
+		EvaluationWindow:  o.EvaluationWindow.String(),
+		QueriesThreshold:  o.QueriesThreshold,
+		FailOnWarnings:    o.FailOnWarnings,
+		FailFast:          o.FailFast,
+		TLSCert:           o.TLS.Cert,
+		TLSKey:            o.TLS.Key,
+		TLSCACert:         o.TLS.CACert,
+		TLSInsecure:       o.TLS.InsecureSkipVerify,
+		DefaultStep:       o.DefaultStep.String(),
+		Tenant:            o.Tenant,
+		TenantHeader:      o.TenantHeader,
+		SummaryFile:       o.SummaryFile,
+		ResultsFile:       o.ResultsFile,
+		Scenarios:         o.Scenarios,
+	}
+
+	if o.EndpointType == LogsEndpointType {
+		c.LogsEncoding, c.LogsCompression = o.LogsEncoding, o.LogsCompression
+	}
+
+	for _, l := range o.Labels {
+		c.Labels[l.Name] = l.Value
+	}
+
+	if o.Token != nil {
+		if t, err := o.Token.Get(); err != nil || t != "" {
+			c.Token = Redacted
+		}
+	}
+
+	for _, t := range o.Tenants {
+		if t.Token != "" {
+			t.Token = Redacted
+		}
+
+		c.Tenants = append(c.Tenants, t)
+	}
+
+	if o.Endpoints != (EndpointsSpec{}) {
+		c.Endpoints = &EndpointsSpec{
+			Write: o.Endpoints.Write.redacted(),
+			Read:  o.Endpoints.Read.redacted(),
+			Store: o.Endpoints.Store.redacted(),
+		}
+	}
+
+	for _, q := range o.Queries {
+		rq, err := redactQuery(q)
+		if err != nil {
+			return c, errors.Wrapf(err, "query %s", q.GetName())
+		}
+
+		c.Queries = append(c.Queries, rq)
+	}
+
+	return c, nil
+}
+
+// redactURL returns the URL with its password redacted, or an empty string without URL.
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	return u.Redacted()
+}
+
+// redactQuery returns the fields of the query with its type, redacting its token and the values of its headers,
+// which can carry credentials as well.
+func redactQuery(q Query) (map[string]interface{}, error) {
+	b, err := yaml.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	fields["type"] = q.GetType()
+
+	if _, ok := fields["token"]; ok {
+		fields["token"] = Redacted
+	}
+
+	if headers, ok := fields["headers"].(map[interface{}]interface{}); ok {
+		for k := range headers {
+			headers[k] = Redacted
+		}
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,196 @@
+package options
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/observatorium/up/pkg/api"
+	promapi "github.com/prometheus/client_golang/api"
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// Label for the query type of diff queries.
+const labelDiff = "diff"
+
+// MultiEndpointQuery is implemented by queries running against their own read endpoints instead of the configured one.
+type MultiEndpointQuery interface {
+	Query
+	// GetEndpoints gets the read endpoints of the query.
+	GetEndpoints() []string
+	// RunAll executes the query with a client for each of the endpoints.
+	RunAll(ctx context.Context, clients []promapi.Client, logger log.Logger,
+		defaultStep time.Duration) (int, promapiv1.Warnings, error)
+}
+
+// DiffSpec represents a query run against two read endpoints, which must return the same result.
+type DiffSpec struct {
+	CommonSpec `yaml:",inline"`
+
+	Name     string         `yaml:"name"`
+	Query    string         `yaml:"query"`
+	Duration model.Duration `yaml:"duration,omitempty"`
+	Step     time.Duration  `yaml:"step,omitempty"`
+	Cache    bool           `yaml:"cache,omitempty"`
+	// Endpoints are the two read endpoints to compare, e.g. the current and the next version of a querier.
+	Endpoints []string `yaml:"endpoints"`
+	// Tolerance is the allowed difference between values relative to the larger one.
+	Tolerance float64 `yaml:"tolerance,omitempty"`
+}
+
+func (q DiffSpec) GetName() string { return q.Name }
+
+func (q DiffSpec) GetType() string { return labelDiff }
+
+func (q DiffSpec) GetQuery() string { return q.Query }
+
+func (q DiffSpec) GetEndpoints() []string { return q.Endpoints }
+
+// Run fails, as diff queries do not run against the configured read endpoint but their own ones using RunAll.
+func (q DiffSpec) Run(_ context.Context, _ promapi.Client, _ log.Logger, _ time.Duration) (int, promapiv1.Warnings, error) {
+	return 0, nil, fmt.Errorf("diff query %q must be run against its endpoints", q.Name)
+}
+
+func (q DiffSpec) RunAll(ctx context.Context, clients []promapi.Client, logger log.Logger,
+	defaultStep time.Duration) (int, promapiv1.Warnings, error) {
+	if len(clients) != 2 {
+		return 0, nil, fmt.Errorf("expected two endpoints, got %d", len(clients))
+	}
+
+	var (
+		values   = make([]model.Value, len(clients))
+		warn     promapiv1.Warnings
+		httpCode int
+		now      = time.Now()
+	)
+
+	// Both endpoints evaluate the query at the same time, so the results are comparable.
+	for i, c := range clients {
+		var (
+			w   promapiv1.Warnings
+			err error
+		)
+
+		if q.Duration > 0 {
+			step := defaultStep
+			if q.Step > 0 {
+				step = q.Step
+			}
+
+			values[i], httpCode, w, err = api.QueryRange(ctx, c, q.Query, promapiv1.Range{
+				Start: now.Add(-time.Duration(q.Duration)),
+				End:   now,
+				Step:  step,
+			}, q.Cache, api.QueryParams{})
+		} else {
+			values[i], httpCode, w, err = api.Query(ctx, c, q.Query, now, q.Cache, api.QueryParams{})
+		}
+
+		warn = append(warn, w...)
+
+		if err != nil {
+			return httpCode, warn, fmt.Errorf("querying %s: %w", q.Endpoints[i], err)
+		}
+	}
+
+	if err := diffValues(values[0], values[1], q.Tolerance); err != nil {
+		return httpCode, warn, err
+	}
+
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "response code ", httpCode)
+
+	return httpCode, warn, nil
+}
+
+// DiffError is returned by diff queries with results differing between the endpoints.
+type DiffError struct {
+	// Series is the number of series returned by only one of the endpoints.
+	Series int
+	// Values is the number of series with differing samples.
+	Values int
+}
+
+func (e *DiffError) Error() string {
+	return fmt.Sprintf("results differ: %d series only returned by one endpoint, %d series with different values", e.Series, e.Values)
+}
+
+// diffValues compares two results by series, returning a DiffError if they differ.
+func diffValues(a, b model.Value, tolerance float64) error {
+	as, bs := samplesBySeries(a), samplesBySeries(b)
+
+	e := &DiffError{}
+
+	for s, ap := range as {
+		bp, ok := bs[s]
+		if !ok {
+			e.Series++
+			continue
+		}
+
+		if !equalSamples(ap, bp, tolerance) {
+			e.Values++
+		}
+	}
+
+	for s := range bs {
+		if _, ok := as[s]; !ok {
+			e.Series++
+		}
+	}
+
+	if e.Series == 0 && e.Values == 0 {
+		return nil
+	}
+
+	return e
+}
+
+func samplesBySeries(v model.Value) map[string][]model.SamplePair {
+	res := map[string][]model.SamplePair{}
+
+	switch r := v.(type) {
+	case model.Vector:
+		for _, s := range r {
+			// The evaluation timestamp is the same for both results.
+			res[s.Metric.String()] = []model.SamplePair{{Value: s.Value}}
+		}
+	case model.Matrix:
+		for _, s := range r {
+			res[s.Metric.String()] = s.Values
+		}
+	case *model.Scalar:
+		res[""] = []model.SamplePair{{Value: r.Value}}
+	}
+
+	return res
+}
+
+func equalSamples(a, b []model.SamplePair, tolerance float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].Timestamp != b[i].Timestamp || !equalValues(float64(a[i].Value), float64(b[i].Value), tolerance) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalValues(a, b, tolerance float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+
+	if a == b {
+		return true
+	}
+
+	return math.Abs(a-b) <= tolerance*math.Max(math.Abs(a), math.Abs(b))
+}
@@ -0,0 +1,55 @@
+package options
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/prometheus/common/model"
+)
+
+func TestDiffValues(t *testing.T) {
+	vector := func(values ...float64) model.Vector {
+		v := model.Vector{}
+		for i, val := range values {
+			v = append(v, &model.Sample{
+				Metric: model.Metric{"instance": model.LabelValue(fmt.Sprint(i))},
+				Value:  model.SampleValue(val),
+			})
+		}
+
+		return v
+	}
+
+	for i, tc := range []struct {
+		a, b      model.Value
+		tolerance float64
+		expected  *DiffError
+	}{
+		{a: vector(1, 2), b: vector(1, 2)},
+		{a: vector(math.NaN()), b: vector(math.NaN())},
+		{a: vector(100), b: vector(101), tolerance: 0.01},
+		{a: vector(100), b: vector(102), tolerance: 0.01, expected: &DiffError{Values: 1}},
+		{a: vector(1, 2), b: vector(1), expected: &DiffError{Series: 1}},
+		{a: vector(1), b: vector(1, 2, 3), expected: &DiffError{Series: 2}},
+		{
+			a: model.Matrix{{Metric: model.Metric{}, Values: []model.SamplePair{
+				{Timestamp: 1, Value: 1},
+				{Timestamp: 2, Value: 1},
+			}}},
+			b:        model.Matrix{{Metric: model.Metric{}, Values: []model.SamplePair{{Timestamp: 1, Value: 1}}}},
+			expected: &DiffError{Values: 1},
+		},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			err := diffValues(tc.a, tc.b, tc.tolerance)
+			if tc.expected == nil {
+				testutil.Ok(t, err)
+				return
+			}
+
+			testutil.Equals(t, tc.expected, err)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package options
+
+import (
+	"net/url"
+
+	"github.com/observatorium/up/pkg/auth"
+)
+
+// EndpointsSpec configures the endpoints of the --endpoints-file, each overriding the TLS, auth and proxy of the flags.
+type EndpointsSpec struct {
+	Write *EndpointSpec `yaml:"write"`
+	Read  *EndpointSpec `yaml:"read"`
+	Store *EndpointSpec `yaml:"store"`
+}
+
+// EndpointSpec configures the TLS, auth and proxy of the requests to an endpoint.
+type EndpointSpec struct {
+	// TLS overrides the TLS client flags as a whole.
+	TLS *TLSSpec `yaml:"tls"`
+	// Token and TokenFile override the token of the flags, the former taking precedence. The tokens of the tenants
+	// of the --tenants-file take precedence over both.
+	Token     string `yaml:"token"`
+	TokenFile string `yaml:"token_file"`
+	// Headers are set on every HTTP request to the endpoint, replacing existing values.
+	Headers map[string]string `yaml:"headers"`
+	// ProxyURL is the proxy of the HTTP requests to the endpoint, overriding the proxy flags and the environment.
+	ProxyURL string `yaml:"proxy_url"`
+}
+
+// TLSSpec is the TLS client configuration of an endpoint.
+type TLSSpec struct {
+	Cert               string `yaml:"cert_file"`
+	Key                string `yaml:"key_file"`
+	CACert             string `yaml:"ca_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// Apply returns the options of the requests to the endpoint. Without spec, the options are returned as is.
+func (e *EndpointSpec) Apply(opts Options) Options {
+	if e == nil {
+		return opts
+	}
+
+	if e.TLS != nil {
+		opts.TLS = TLS(*e.TLS)
+	}
+
+	if e.TokenFile != "" {
+		opts.Token = auth.NewFileToken(e.TokenFile)
+	}
+
+	if e.Token != "" {
+		opts.Token = auth.NewStaticToken(e.Token)
+	}
+
+	return opts
+}
+
+// redacted returns a copy of the spec with its token and the values of its headers redacted.
+func (e *EndpointSpec) redacted() *EndpointSpec {
+	if e == nil {
+		return nil
+	}
+
+	r := *e
+	if r.Token != "" {
+		r.Token = Redacted
+	}
+
+	if len(e.Headers) > 0 {
+		r.Headers = make(map[string]string, len(e.Headers))
+		for k := range e.Headers {
+			r.Headers[k] = Redacted
+		}
+	}
+
+	// The credentials of proxies are part of their URL.
+	if u, err := url.Parse(e.ProxyURL); err == nil && e.ProxyURL != "" {
+		r.ProxyURL = u.Redacted()
+	}
+
+	return &r
+}
@@ -0,0 +1,37 @@
+package options
+
+import (
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/observatorium/up/pkg/auth"
+)
+
+func TestEndpointSpec_Apply(t *testing.T) {
+	opts := Options{
+		Token: auth.NewStaticToken("default"),
+		TLS:   TLS{Cert: "/cert", Key: "/key", CACert: "/ca"},
+	}
+
+	eo := (&EndpointSpec{
+		TLS:       &TLSSpec{CACert: "/write-ca", InsecureSkipVerify: true},
+		Token:     "secret",
+		TokenFile: "/does/not/exist",
+	}).Apply(opts)
+
+	// The TLS of the flags is overridden as a whole.
+	testutil.Equals(t, TLS{CACert: "/write-ca", InsecureSkipVerify: true}, eo.TLS)
+
+	// The token takes precedence over the token file.
+	token, err := eo.Token.Get()
+	testutil.Ok(t, err)
+	testutil.Equals(t, "secret", token)
+
+	// The options of the flags are kept if not overridden.
+	eo = (&EndpointSpec{Headers: map[string]string{"X-Canary": "true"}}).Apply(opts)
+	testutil.Equals(t, opts.TLS, eo.TLS)
+	testutil.Equals(t, opts.Token, eo.Token)
+
+	var e *EndpointSpec
+	testutil.Equals(t, opts.TLS, e.Apply(opts).TLS)
+}
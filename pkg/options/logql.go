@@ -0,0 +1,399 @@
+package options
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/observatorium/up/pkg/api"
+	promapi "github.com/prometheus/client_golang/api"
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// Labels for LogQL query types.
+	labelLogQuery      = "logs_query"
+	labelLogQueryRange = "logs_query_range"
+	labelLogSeries     = "logs_series"
+	labelLogNames      = "logs_label_names"
+	labelLogValues     = "logs_label_values"
+	labelLogIndexStats = "logs_index_stats"
+	labelLogVolume     = "logs_volume"
+	labelLogFields     = "logs_detected_fields"
+	labelLogPatterns   = "logs_patterns"
+
+	DirectionForward  = "forward"
+	DirectionBackward = "backward"
+
+	AggregateBySeries = "series"
+	AggregateByLabels = "labels"
+)
+
+// LogQLSpec represents a LogQL query against Loki.
+type LogQLSpec struct {
+	CommonSpec `yaml:",inline"`
+
+	Name     string         `yaml:"name"`
+	Query    string         `yaml:"query"`
+	Duration model.Duration `yaml:"duration,omitempty"`
+	Step     time.Duration  `yaml:"step,omitempty"`
+	Cache    bool           `yaml:"cache,omitempty"`
+	// Limit is the maximum number of entries returned, Loki defaults to 100.
+	Limit int `yaml:"limit,omitempty"`
+	// Direction is the sort order of entries, either forward or backward.
+	Direction string `yaml:"direction,omitempty"`
+}
+
+func (q LogQLSpec) GetName() string { return q.Name }
+
+func (q LogQLSpec) GetType() string {
+	if q.Duration > 0 {
+		return labelLogQueryRange
+	}
+
+	return labelLogQuery
+}
+
+func (q LogQLSpec) GetQuery() string { return q.Query }
+
+func (q LogQLSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
+	defaultStep time.Duration) (int, promapiv1.Warnings, error) {
+	var (
+		res      *api.LokiResult
+		httpCode int
+		warn     promapiv1.Warnings
+		err      error
+		params   = api.LokiParams{Limit: q.Limit, Direction: q.Direction}
+	)
+
+	if q.Duration > 0 {
+		step := defaultStep
+		if q.Step > 0 {
+			step = q.Step
+		}
+
+		res, httpCode, warn, err = api.LokiQueryRange(ctx, c, q.Query, promapiv1.Range{
+			Start: time.Now().Add(-time.Duration(q.Duration)),
+			End:   time.Now(),
+			Step:  step,
+		}, q.Cache, params)
+	} else {
+		res, httpCode, warn, err = api.LokiQuery(ctx, c, q.Query, time.Now(), q.Cache, params)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("querying: %w", err)
+		return httpCode, warn, err
+	}
+
+	if res.Empty() {
+		return httpCode, warn, fmt.Errorf("expected at min one log entry, got none")
+	}
+
+	// Don't log responses because there are a lot.
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", api.TraceID(ctx))
+
+	return httpCode, warn, err
+}
+
+// LogsLabelSpec represents a label names or, if a label is given, label values query against Loki.
+type LogsLabelSpec struct {
+	CommonSpec `yaml:",inline"`
+
+	Name     string         `yaml:"name"`
+	Label    string         `yaml:"label"`
+	Duration model.Duration `yaml:"duration"`
+	Cache    bool           `yaml:"cache"`
+}
+
+func (q LogsLabelSpec) GetName() string { return q.Name }
+
+func (q LogsLabelSpec) GetType() string {
+	if len(q.Label) > 0 {
+		return labelLogValues
+	}
+
+	return labelLogNames
+}
+
+func (q LogsLabelSpec) GetQuery() string { return q.Label }
+
+func (q LogsLabelSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
+	_ time.Duration) (int, promapiv1.Warnings, error) {
+	var (
+		warn     promapiv1.Warnings
+		err      error
+		httpCode int
+	)
+
+	if len(q.Label) > 0 {
+		_, httpCode, warn, err = api.LokiLabelValues(ctx, c, q.Label, time.Now().Add(-time.Duration(q.Duration)), time.Now(), q.Cache)
+	} else {
+		_, httpCode, warn, err = api.LokiLabelNames(ctx, c, time.Now().Add(-time.Duration(q.Duration)), time.Now(), q.Cache)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("querying: %w", err)
+		return httpCode, warn, err
+	}
+
+	// Don't log responses because there are a lot.
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", api.TraceID(ctx))
+
+	return httpCode, warn, err
+}
+
+// LogsSeriesSpec represents a series query against Loki.
+type LogsSeriesSpec struct {
+	CommonSpec `yaml:",inline"`
+
+	Name     string         `yaml:"name"`
+	Matchers []string       `yaml:"matchers"`
+	Duration model.Duration `yaml:"duration"`
+	Cache    bool           `yaml:"cache"`
+}
+
+func (q LogsSeriesSpec) GetName() string { return q.Name }
+
+func (q LogsSeriesSpec) GetType() string { return labelLogSeries }
+
+func (q LogsSeriesSpec) GetQuery() string { return strings.Join(q.Matchers, ", ") }
+
+func (q LogsSeriesSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
+	_ time.Duration) (int, promapiv1.Warnings, error) {
+	_, httpCode, warn, err := api.LokiSeries(ctx, c, q.Matchers, time.Now().Add(-time.Duration(q.Duration)), time.Now(), q.Cache)
+	if err != nil {
+		err = fmt.Errorf("querying: %w", err)
+		return httpCode, warn, err
+	}
+
+	// Don't log responses because there are a lot.
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", api.TraceID(ctx))
+
+	return httpCode, warn, err
+}
+
+// LogsIndexStatsSpec represents an index statistics query against Loki, validating the index path in addition
+// to the retrieval of chunks. The streams matching the query must have been indexed with at least the minimal
+// numbers of streams, chunks, entries and bytes, which default to one.
+type LogsIndexStatsSpec struct {
+	CommonSpec `yaml:",inline"`
+
+	Name       string         `yaml:"name"`
+	Query      string         `yaml:"query"`
+	Duration   model.Duration `yaml:"duration"`
+	Cache      bool           `yaml:"cache"`
+	MinStreams *int           `yaml:"min_streams,omitempty"`
+	MinChunks  *int           `yaml:"min_chunks,omitempty"`
+	MinEntries *int           `yaml:"min_entries,omitempty"`
+	MinBytes   *int           `yaml:"min_bytes,omitempty"`
+}
+
+func (q LogsIndexStatsSpec) GetName() string { return q.Name }
+
+func (q LogsIndexStatsSpec) GetType() string { return labelLogIndexStats }
+
+func (q LogsIndexStatsSpec) GetQuery() string { return q.Query }
+
+func (q LogsIndexStatsSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
+	_ time.Duration) (int, promapiv1.Warnings, error) {
+	stats, httpCode, err := api.LokiIndexStats(ctx, c, q.Query, time.Now().Add(-time.Duration(q.Duration)), time.Now(), q.Cache)
+	if err != nil {
+		err = fmt.Errorf("querying: %w", err)
+		return httpCode, nil, err
+	}
+
+	if err := q.check(*stats); err != nil {
+		return httpCode, nil, err
+	}
+
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "streams", stats.Streams, "chunks", stats.Chunks,
+		"entries", stats.Entries, "bytes", stats.Bytes, "trace-id", api.TraceID(ctx))
+
+	return httpCode, nil, nil
+}
+
+func (q LogsIndexStatsSpec) check(stats api.LokiIndexStatsResult) error {
+	for _, s := range []struct {
+		name string
+		min  *int
+		got  int
+	}{
+		{name: "streams", min: q.MinStreams, got: stats.Streams},
+		{name: "chunks", min: q.MinChunks, got: stats.Chunks},
+		{name: "entries", min: q.MinEntries, got: stats.Entries},
+		{name: "bytes", min: q.MinBytes, got: stats.Bytes},
+	} {
+		least := 1
+		if s.min != nil {
+			least = *s.min
+		}
+
+		if s.got < least {
+			return assertionErrorf("expected at least %d %s in the index, got %d", least, s.name, s.got)
+		}
+	}
+
+	return nil
+}
+
+// LogsVolumeSpec represents a volume query against Loki, returning the bytes of the matching streams as vector.
+// The result must not be empty, in addition to the assertions.
+type LogsVolumeSpec struct {
+	CommonSpec `yaml:",inline"`
+
+	Name     string         `yaml:"name"`
+	Query    string         `yaml:"query"`
+	Duration model.Duration `yaml:"duration"`
+	Cache    bool           `yaml:"cache"`
+	Limit    int            `yaml:"limit,omitempty"`
+	// TargetLabels aggregate the volume by the given labels instead of the labels of the selector.
+	TargetLabels []string `yaml:"target_labels,omitempty"`
+	// AggregateBy is either series, the default, or labels.
+	AggregateBy string      `yaml:"aggregate_by,omitempty"`
+	Assertions  *Assertions `yaml:"assertions,omitempty"`
+}
+
+func (q LogsVolumeSpec) GetName() string { return q.Name }
+
+func (q LogsVolumeSpec) GetType() string { return labelLogVolume }
+
+func (q LogsVolumeSpec) GetQuery() string { return q.Query }
+
+func (q LogsVolumeSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
+	_ time.Duration) (int, promapiv1.Warnings, error) {
+	res, httpCode, warn, err := api.LokiVolume(ctx, c, q.Query, time.Now().Add(-time.Duration(q.Duration)), time.Now(), q.Cache,
+		api.LokiVolumeParams{Limit: q.Limit, TargetLabels: q.TargetLabels, AggregateBy: q.AggregateBy})
+	if err != nil {
+		err = fmt.Errorf("querying: %w", err)
+		return httpCode, warn, err
+	}
+
+	if res.Empty() {
+		return httpCode, warn, assertionErrorf("expected volume of at least one stream, got none")
+	}
+
+	if err := q.Assertions.CheckLogs(res); err != nil {
+		return httpCode, warn, err
+	}
+
+	// Don't log responses because there are a lot.
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", api.TraceID(ctx))
+
+	return httpCode, warn, nil
+}
+
+// LogsDetectedFieldsSpec represents a query of the fields Loki detects in the lines of the matching logs.
+// At least one field must be detected, including all the expected fields.
+type LogsDetectedFieldsSpec struct {
+	CommonSpec `yaml:",inline"`
+
+	Name     string         `yaml:"name"`
+	Query    string         `yaml:"query"`
+	Duration model.Duration `yaml:"duration"`
+	Cache    bool           `yaml:"cache"`
+	// Limit is the maximum number of fields returned.
+	Limit int `yaml:"limit,omitempty"`
+	// Fields must be detected, e.g. the keys of logfmt lines.
+	Fields []string `yaml:"fields,omitempty"`
+}
+
+func (q LogsDetectedFieldsSpec) GetName() string { return q.Name }
+
+func (q LogsDetectedFieldsSpec) GetType() string { return labelLogFields }
+
+func (q LogsDetectedFieldsSpec) GetQuery() string { return q.Query }
+
+func (q LogsDetectedFieldsSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
+	_ time.Duration) (int, promapiv1.Warnings, error) {
+	fields, httpCode, err := api.LokiDetectedFields(ctx, c, q.Query, time.Now().Add(-time.Duration(q.Duration)), time.Now(),
+		q.Cache, q.Limit)
+	if err != nil {
+		err = fmt.Errorf("querying: %w", err)
+		return httpCode, nil, err
+	}
+
+	if err := q.check(fields); err != nil {
+		return httpCode, nil, err
+	}
+
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "fields", len(fields), "trace-id", api.TraceID(ctx))
+
+	return httpCode, nil, nil
+}
+
+func (q LogsDetectedFieldsSpec) check(fields []api.LokiDetectedField) error {
+	if len(fields) == 0 {
+		return assertionErrorf("expected at least one detected field, got none")
+	}
+
+	detected := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		detected[f.Label] = struct{}{}
+	}
+
+	for _, f := range q.Fields {
+		if _, ok := detected[f]; !ok {
+			return assertionErrorf("expected field %q to be detected", f)
+		}
+	}
+
+	return nil
+}
+
+// LogsPatternsSpec represents a query of the patterns Loki detects in the lines of the matching logs,
+// which requires the pattern ingester. At least the minimal number of patterns, one by default, must be detected.
+type LogsPatternsSpec struct {
+	CommonSpec `yaml:",inline"`
+
+	Name        string         `yaml:"name"`
+	Query       string         `yaml:"query"`
+	Duration    model.Duration `yaml:"duration"`
+	Cache       bool           `yaml:"cache"`
+	MinPatterns *int           `yaml:"min_patterns,omitempty"`
+}
+
+func (q LogsPatternsSpec) GetName() string { return q.Name }
+
+func (q LogsPatternsSpec) GetType() string { return labelLogPatterns }
+
+func (q LogsPatternsSpec) GetQuery() string { return q.Query }
+
+func (q LogsPatternsSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
+	_ time.Duration) (int, promapiv1.Warnings, error) {
+	patterns, httpCode, warn, err := api.LokiPatterns(ctx, c, q.Query, time.Now().Add(-time.Duration(q.Duration)), time.Now(),
+		q.Cache)
+	if err != nil {
+		err = fmt.Errorf("querying: %w", err)
+		return httpCode, warn, err
+	}
+
+	least := 1
+	if q.MinPatterns != nil {
+		least = *q.MinPatterns
+	}
+
+	if len(patterns) < least {
+		return httpCode, warn, assertionErrorf("expected at least %d patterns, got %d", least, len(patterns))
+	}
+
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "patterns", len(patterns), "trace-id", api.TraceID(ctx))
+
+	return httpCode, warn, nil
+}
+
+// LogQLSpecFromQuerySpec returns a LogQL query with the settings of a query spec.
+func LogQLSpecFromQuerySpec(q QuerySpec) LogQLSpec {
+	return LogQLSpec{
+		CommonSpec: q.CommonSpec,
+		Name:       q.Name,
+		Query:      q.Query,
+		Duration:   q.Duration,
+		Step:       q.Step,
+		Cache:      q.Cache,
+	}
+}
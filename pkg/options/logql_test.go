@@ -0,0 +1,44 @@
+package options
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/observatorium/up/pkg/api"
+)
+
+func TestLogsIndexStatsSpec_check(t *testing.T) {
+	stats := api.LokiIndexStatsResult{Streams: 1, Chunks: 2, Entries: 30, Bytes: 1200}
+	zero, three, hundred := 0, 3, 100
+
+	for i, tc := range []struct {
+		spec  LogsIndexStatsSpec
+		stats api.LokiIndexStatsResult
+		ok    bool
+	}{
+		{stats: stats, ok: true},
+		{spec: LogsIndexStatsSpec{MinChunks: &three}, stats: stats},
+		{spec: LogsIndexStatsSpec{MinEntries: &hundred}, stats: stats},
+		{stats: api.LokiIndexStatsResult{Streams: 1, Entries: 30, Bytes: 1200}},
+		// Minimums of zero allow the canary stream to be missing.
+		{spec: LogsIndexStatsSpec{MinStreams: &zero, MinChunks: &zero, MinEntries: &zero, MinBytes: &zero}, ok: true},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			err := tc.spec.check(tc.stats)
+			if tc.ok {
+				testutil.Ok(t, err)
+			} else {
+				testutil.NotOk(t, err)
+			}
+		})
+	}
+}
+
+func TestLogsDetectedFieldsSpec_check(t *testing.T) {
+	fields := []api.LokiDetectedField{{Label: "level"}, {Label: "seq"}}
+
+	testutil.Ok(t, LogsDetectedFieldsSpec{Fields: []string{"seq"}}.check(fields))
+	testutil.NotOk(t, LogsDetectedFieldsSpec{Fields: []string{"ts"}}.check(fields))
+	testutil.NotOk(t, LogsDetectedFieldsSpec{}.check(nil))
+}
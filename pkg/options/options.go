@@ -1,7 +1,10 @@
 package options
 
 import (
+	"net"
 	"net/url"
+	"os"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -9,6 +12,7 @@ import (
 
 	"github.com/go-kit/log/level"
 	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/report"
 	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/prompb"
@@ -18,28 +22,235 @@ type TLS struct {
 	Cert   string
 	Key    string
 	CACert string
+	// InsecureSkipVerify disables the verification of the certificates of the servers, e.g. self-signed ones.
+	InsecureSkipVerify bool
+}
+
+// Proxy configures the proxies of the requests of up, the ones of the environment are used for those not set.
+type Proxy struct {
+	// HTTP and HTTPS are the proxies of the requests to http:// and https:// endpoints.
+	HTTP  string
+	HTTPS string
+	// NoProxy are the hosts requested without proxy, in the format of the NO_PROXY environment variable.
+	NoProxy string
+}
+
+// Resolver configures how the hosts of the endpoints are resolved when dialing them.
+type Resolver struct {
+	// Static are the addresses dialed for hosts and ports instead of the resolved ones.
+	Static resolveArg
+	// DNSServer is the address of the DNS server resolving the hosts, the resolver of the system if empty.
+	DNSServer string
+	// IPProtocol is the address family dialed.
+	IPProtocol IPProtocol
+}
+
+// Retry configures the retries of the failed requests of up.
+type Retry struct {
+	// Max is the number of retries of a request, 0 disables retries.
+	Max int
+	// StatusCodes are the status codes of responses that are retried besides the errors of requests.
+	StatusCodes statusCodes
+	// Methods are the methods of the requests retried, the idempotent ones.
+	Methods methods
+	// MinBackoff and MaxBackoff bound the exponential backoff between the retries.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Budget is the time of a request including its retries after which it is not retried, unbounded if 0.
+	Budget time.Duration
+}
+
+// RateLimit configures the token buckets limiting the rate of the requests of up.
+type RateLimit struct {
+	// Global and PerEndpoint are the requests per second of all requests and of the requests to every host, 0 does
+	// not limit them.
+	Global      float64
+	PerEndpoint float64
+	// Burst is the number of requests made at once before they are limited, the rate rounded up if 0.
+	Burst int
+}
+
+// Timeouts bound the connections and requests of up, 0 disables a timeout.
+type Timeouts struct {
+	Dial           time.Duration
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration
+	// Request bounds every request including its retries and reading its response.
+	Request time.Duration
+}
+
+// InternalServer configures the TLS and the authentication of the internal HTTP server of up.
+type InternalServer struct {
+	TLS TLS
+	// Token is the bearer token requests must set, if not nil.
+	Token auth.TokenProvider
+	// BasicAuthUsername and BasicAuthPassword are the basic auth credentials requests must set, if not empty.
+	BasicAuthUsername string
+	BasicAuthPassword string
+}
+
+// Push configures pushing the metrics of up on exit, as runs shorter than the scrape interval are never scraped.
+type Push struct {
+	// GatewayURL is the Pushgateway to push the metrics to, if not nil.
+	GatewayURL *url.URL
+	// RemoteWriteURL is the remote-write endpoint to write the metrics to, if not nil.
+	RemoteWriteURL *url.URL
+	// Job is the job label of the pushed metrics.
+	Job string
+}
+
+// Alertmanager configures the alerts of failing checks fired to an Alertmanager.
+type Alertmanager struct {
+	// URL is the Alertmanager to send the alerts to, if not nil.
+	URL *url.URL
+	// Labels and Annotations are added to every alert.
+	Labels      labelArg
+	Annotations labelArg
+}
+
+// DebugCapture configures capturing the failed requests of up to files.
+type DebugCapture struct {
+	// Dir is the directory to write the captured requests to, if not empty.
+	Dir          string
+	MaxBodyBytes int
+	MaxFiles     int
+}
+
+// Profiling configures the pprof endpoints of the internal HTTP server.
+type Profiling struct {
+	// Enabled serves the CPU profile, the execution trace and the other handlers of pprof besides the index.
+	Enabled bool
+	// MutexFraction and BlockRate are the rates of the mutex and block profiles, 0 disables them.
+	MutexFraction int
+	BlockRate     int
+}
+
+// Instance configures the label identifying the replica of up on the written series and the metrics of up.
+type Instance struct {
+	// Label is the name of the label, the replica is not labeled if empty.
+	Label string
+	// ID identifies the replica, the hostname by default, which is the name of the pod on Kubernetes.
+	ID string
+}
+
+// MetricsFilter configures the metrics served on /metrics of the internal HTTP server.
+type MetricsFilter struct {
+	// Allow and Deny match the names of the served metrics, all metrics are allowed if Allow is not set.
+	Allow nameMatcher
+	Deny  nameMatcher
+	// DropLabels are removed from the served metrics, aggregating the series that only differ by them.
+	DropLabels droppedLabels
+}
+
+// Serves returns whether the metric of the name is served.
+func (f MetricsFilter) Serves(name string) bool {
+	return (!f.Allow.IsSet() || f.Allow.Matches(name)) && !f.Deny.Matches(name)
+}
+
+// IsEmpty returns whether all metrics are served as they are.
+func (f MetricsFilter) IsEmpty() bool {
+	return !f.Allow.IsSet() && !f.Deny.IsSet() && len(f.DropLabels) == 0
 }
 
 type Options struct {
-	LogLevel          level.Option
-	EndpointType      EndpointType
-	WriteEndpoint     *url.URL
-	ReadEndpoint      *url.URL
-	Labels            labelArg
-	Logs              logs
-	Listen            string
-	Name              string
-	Token             auth.TokenProvider
-	Queries           []Query
-	Period            time.Duration
-	Duration          time.Duration
-	Latency           time.Duration
-	InitialQueryDelay time.Duration
-	SuccessThreshold  float64
-	TLS               TLS
-	DefaultStep       time.Duration
-	Tenant            string
-	TenantHeader      string
+	LogLevel      level.Option
+	EndpointType  EndpointType
+	WriteEndpoint *url.URL
+	ReadEndpoint  *url.URL
+	StoreEndpoint string
+	StoreTLS      bool
+	Labels        labelArg
+	LabelsFromEnv envLabelArg
+	ReadQuery     string
+	ReadMode      ReadMode
+	ReadWindow    time.Duration
+	Exemplars     bool
+	Metadata      bool
+	BuildInfo     bool
+	Federate      bool
+	Logs          logs
+	LogsFile      string
+	LogsStreams   []LogsStream
+	LogsEncoding  PushEncoding
+	LogsTemplate  string
+	LogsLineSize  int
+	// LogsLinesPerPush and LogsStreamsPerPush configure the volume of generated logs.
+	LogsLinesPerPush   int
+	LogsStreamsPerPush int
+	LogsChurnInterval  time.Duration
+	LogsCompression    PushCompression
+	// StructuredMetadata of the --logs-file, attached to the pushed entries.
+	StructuredMetadata map[string]string
+	Listen             string
+	Name               string
+	Token              auth.TokenProvider
+	Queries            []Query
+	QueriesFile        string
+	Scenarios          []ScenarioSpec
+	ReloadInterval     time.Duration
+	ExpandEnv          bool
+	FailOnWarnings     bool
+	FailFast           bool
+	DryRun             bool
+	QueryName          string
+	WriteOnce          bool
+	CompareCache       bool
+	Period             time.Duration
+	Duration           time.Duration
+	Latency            time.Duration
+	InitialQueryDelay  time.Duration
+	ClockSkew          bool
+	SuccessThreshold   float64
+	WriteThreshold     float64
+	ReadThreshold      float64
+	EvaluationWindow   time.Duration
+	QueriesThreshold   float64
+	TLS                TLS
+	Proxy              Proxy
+	Resolver           Resolver
+	Retry              Retry
+	RateLimit          RateLimit
+	Timeouts           Timeouts
+	MaxResponseSize    int64
+	DisableHTTP2       bool
+	Encodings          Encodings
+	HostHeader         string
+	DefaultStep        time.Duration
+	Tenant             string
+	TenantHeader       string
+	LogsTenants        tenants
+	Tenants            []TenantSpec
+	Endpoints          EndpointsSpec
+	SummaryFile        string
+	ReportFile         string
+	JUnitFile          string
+	ResultsFile        string
+	// ResultsFileResultBytes is the number of bytes of query results included in the results file.
+	ResultsFileResultBytes int
+	Baseline               *report.Summary
+	BaselineTolerance      report.Tolerances
+	Buckets                Buckets
+	TracingEndpoint        *url.URL
+	TracingSamplingRatio   float64
+	Internal               InternalServer
+	Push                   Push
+	Alertmanager           Alertmanager
+	DebugCapture           DebugCapture
+	Profiling              Profiling
+	MetricsFilter          MetricsFilter
+	Instance               Instance
+}
+
+// Buckets configures the buckets of the histograms. The defaults are used for empty buckets.
+type Buckets struct {
+	WriteDuration         buckets
+	QueryDuration         buckets
+	MetricValueDifference buckets
+	CustomQueryDuration   buckets
+	LogsReadDuration      buckets
+	RequestPhaseDuration  buckets
+	// Native emits the duration and freshness histograms also as native histograms.
+	Native bool
 }
 
 type EndpointType string
@@ -49,8 +260,121 @@ const (
 	MetricsEndpointType EndpointType = "metrics"
 )
 
+type ReadMode string
+
+const (
+	InstantReadMode ReadMode = "instant"
+	RangeReadMode   ReadMode = "range"
+)
+
+// PushEncoding is the encoding of logs pushed to Loki.
+type PushEncoding string
+
+const (
+	JSONPushEncoding     PushEncoding = "json"
+	ProtobufPushEncoding PushEncoding = "protobuf"
+)
+
+func (e *PushEncoding) String() string { return string(*e) }
+
+func (e *PushEncoding) Set(v string) error {
+	switch PushEncoding(v) {
+	case JSONPushEncoding, ProtobufPushEncoding:
+		*e = PushEncoding(v)
+	default:
+		return errors.Errorf("unsupported encoding %q", v)
+	}
+
+	return nil
+}
+
+// PushCompression is the compression of logs pushed to Loki on top of their encoding.
+type PushCompression string
+
+const (
+	NoPushCompression   PushCompression = "none"
+	GzipPushCompression PushCompression = "gzip"
+)
+
+func (c *PushCompression) String() string { return string(*c) }
+
+func (c *PushCompression) Set(v string) error {
+	switch PushCompression(v) {
+	case NoPushCompression, GzipPushCompression:
+		*c = PushCompression(v)
+	default:
+		return errors.Errorf("unsupported compression %q", v)
+	}
+
+	return nil
+}
+
+// IPProtocol is the address family of the connections of up.
+type IPProtocol string
+
+const (
+	IP4Protocol IPProtocol = "ip4"
+	IP6Protocol IPProtocol = "ip6"
+	AnyProtocol IPProtocol = "any"
+)
+
+func (p *IPProtocol) String() string { return string(*p) }
+
+func (p *IPProtocol) Set(v string) error {
+	switch IPProtocol(v) {
+	case IP4Protocol, IP6Protocol, AnyProtocol:
+		*p = IPProtocol(v)
+	default:
+		return errors.Errorf("unsupported IP protocol %q", v)
+	}
+
+	return nil
+}
+
+// ContentEncoding is an HTTP content encoding of requests and responses.
+type ContentEncoding string
+
+const (
+	IdentityContentEncoding ContentEncoding = "identity"
+	GzipContentEncoding     ContentEncoding = "gzip"
+	SnappyContentEncoding   ContentEncoding = "snappy"
+	ZstdContentEncoding     ContentEncoding = "zstd"
+)
+
+func (c *ContentEncoding) String() string { return string(*c) }
+
+func (c *ContentEncoding) Set(v string) error {
+	switch ContentEncoding(v) {
+	case IdentityContentEncoding, GzipContentEncoding, SnappyContentEncoding, ZstdContentEncoding:
+		*c = ContentEncoding(v)
+	default:
+		return errors.Errorf("unsupported content encoding %q", v)
+	}
+
+	return nil
+}
+
+// Encodings configures the content encodings of the requests to the endpoints, the ones of the protocols are used
+// for those not set.
+type Encodings struct {
+	// Write is the Content-Encoding of the requests to the write endpoint, replacing the one of the protocol.
+	Write ContentEncoding
+	// Read is the Accept-Encoding of the requests to the read endpoint, the responses are decoded transparently.
+	Read ContentEncoding
+}
+
 type LogsSpec struct {
 	Logs logs `yaml:"logs"`
+	// Streams are pushed together instead of Logs, each as a stream of its own.
+	Streams []LogsStream `yaml:"streams,omitempty"`
+	// StructuredMetadata is attached to every pushed entry and must be returned when reading the logs back.
+	StructuredMetadata map[string]string `yaml:"structured_metadata,omitempty"`
+}
+
+// LogsStream is a stream of every push, identified by its labels in addition to the --labels.
+type LogsStream struct {
+	Labels map[string]string `yaml:"labels"`
+	Logs   logs              `yaml:"logs"`
 }
 
 type labelArg []prompb.Label
@@ -91,6 +415,35 @@ func (la *labelArg) Set(v string) error {
 	return nil
 }
 
+// Merge adds the labels of a map, e.g. of the --labels-file, keeping labels of the same name already set.
+// The labels are sorted afterwards.
+func (la *labelArg) Merge(labels map[string]string) error {
+	set := make(map[string]struct{}, len(*la))
+	for _, l := range *la {
+		set[l.Name] = struct{}{}
+	}
+
+	for name, value := range labels {
+		if !model.LabelName.IsValid(model.LabelName(name)) {
+			return errors.Errorf("unsupported format for label %s", name)
+		}
+
+		if name == model.MetricNameLabel {
+			return errors.Errorf("label %s is set by --name", name)
+		}
+
+		if _, ok := set[name]; ok {
+			continue
+		}
+
+		*la = append(*la, prompb.Label{Name: name, Value: value})
+	}
+
+	la.Sort()
+
+	return nil
+}
+
 // Sort ensures all labels are ordered, in line with how upstream Prometheus code guarantees
 // ordering. See https://github.com/prometheus/prometheus/pull/5372.
 func (la *labelArg) Sort() {
@@ -133,3 +486,265 @@ func (va *logs) Set(v string) error {
 
 	return nil
 }
+
+// resolveArg maps a host and port to the IP address dialed for them, set like curl as 'host:port:addr'.
+// Repeated flags add to the mapping.
+type resolveArg map[string]string
+
+func (r *resolveArg) String() string {
+	rs := make([]string, 0, len(*r))
+	for hostPort, addr := range *r {
+		host, port, _ := net.SplitHostPort(hostPort)
+		rs = append(rs, host+":"+port+":"+addr)
+	}
+
+	sort.Strings(rs)
+
+	return strings.Join(rs, ",")
+}
+
+func (r *resolveArg) Set(v string) error {
+	parts := strings.SplitN(v, ":", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return errors.Errorf("%q must be 'host:port:addr'", v)
+	}
+
+	if _, err := strconv.ParseUint(parts[1], 10, 16); err != nil {
+		return errors.Errorf("invalid port %q in %q", parts[1], v)
+	}
+
+	addr := strings.TrimSuffix(strings.TrimPrefix(parts[2], "["), "]")
+	if net.ParseIP(addr) == nil {
+		return errors.Errorf("invalid IP address %q in %q", parts[2], v)
+	}
+
+	if *r == nil {
+		*r = resolveArg{}
+	}
+
+	(*r)[net.JoinHostPort(parts[0], parts[1])] = addr
+
+	return nil
+}
+
+// envLabelArg are labels with values of environment variables, e.g. of the Kubernetes downward API,
+// set as 'pod=POD_NAME,node=NODE_NAME'. Repeated flags add to the labels.
+type envLabelArg map[string]string
+
+func (e *envLabelArg) String() string {
+	ls := make([]string, 0, len(*e))
+	for name, value := range *e {
+		ls = append(ls, name+"="+value)
+	}
+
+	sort.Strings(ls)
+
+	return strings.Join(ls, ", ")
+}
+
+func (e *envLabelArg) Set(v string) error {
+	if *e == nil {
+		*e = envLabelArg{}
+	}
+
+	for _, l := range strings.Split(v, ",") {
+		parts := strings.SplitN(strings.TrimSpace(l), "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return errors.Errorf("unrecognized label %q, must be <label>=<environment variable>", l)
+		}
+
+		if !model.LabelName.IsValid(model.LabelName(parts[0])) {
+			return errors.Errorf("unsupported format for label %s", parts[0])
+		}
+
+		value, ok := os.LookupEnv(parts[1])
+		if !ok {
+			return errors.Errorf("environment variable %s of label %s is not set", parts[1], parts[0])
+		}
+
+		(*e)[parts[0]] = value
+	}
+
+	return nil
+}
+
+type tenants []string
+
+func (t *tenants) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *tenants) Set(v string) error {
+	vs := strings.Split(v, ",")
+	seen := make(map[string]struct{}, len(vs))
+
+	for i, s := range vs {
+		vs[i] = strings.TrimSpace(s)
+		if vs[i] == "" {
+			return errors.Errorf("empty tenant in %q", v)
+		}
+
+		if _, ok := seen[vs[i]]; ok {
+			return errors.Errorf("tenant %q is listed more than once", vs[i])
+		}
+
+		seen[vs[i]] = struct{}{}
+	}
+
+	*t = vs
+
+	return nil
+}
+
+// nameMatcher is a regular expression matching whole names, as the ones of relabeling in Prometheus.
+type nameMatcher struct {
+	raw string
+	re  *regexp.Regexp
+}
+
+func (n *nameMatcher) String() string {
+	return n.raw
+}
+
+func (n *nameMatcher) Set(v string) error {
+	re, err := regexp.Compile("^(?:" + v + ")$")
+	if err != nil {
+		return errors.Wrapf(err, "parse regular expression %q", v)
+	}
+
+	n.raw, n.re = v, re
+
+	return nil
+}
+
+// IsSet returns whether the matcher was set.
+func (n nameMatcher) IsSet() bool { return n.re != nil }
+
+// Matches returns whether the matcher is set and matches the whole name.
+func (n nameMatcher) Matches(name string) bool { return n.re != nil && n.re.MatchString(name) }
+
+type droppedLabels []string
+
+func (l *droppedLabels) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *droppedLabels) Set(v string) error {
+	vs := strings.Split(v, ",")
+
+	for i, s := range vs {
+		vs[i] = strings.TrimSpace(s)
+		if !model.LabelName(vs[i]).IsValid() {
+			return errors.Errorf("invalid label name %q", vs[i])
+		}
+	}
+
+	*l = vs
+
+	return nil
+}
+
+type statusCodes []int
+
+func (s *statusCodes) String() string {
+	codes := make([]string, len(*s))
+	for i, c := range *s {
+		codes[i] = strconv.Itoa(c)
+	}
+
+	return strings.Join(codes, ",")
+}
+
+func (s *statusCodes) Set(v string) error {
+	vs := strings.Split(v, ",")
+	codes := make(statusCodes, len(vs))
+
+	for i, c := range vs {
+		code, err := strconv.Atoi(strings.TrimSpace(c))
+		if err != nil || code < 100 || code > 599 {
+			return errors.Errorf("invalid status code %q", c)
+		}
+
+		codes[i] = code
+	}
+
+	*s = codes
+
+	return nil
+}
+
+// Contains returns whether the status code is one of the codes.
+func (s statusCodes) Contains(code int) bool {
+	for _, c := range s {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+type methods []string
+
+// Contains returns whether the method is one of the methods.
+func (m methods) Contains(method string) bool {
+	for _, v := range m {
+		if v == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *methods) String() string {
+	return strings.Join(*m, ",")
+}
+
+func (m *methods) Set(v string) error {
+	vs := strings.Split(v, ",")
+
+	for i, method := range vs {
+		vs[i] = strings.ToUpper(strings.TrimSpace(method))
+		if vs[i] == "" {
+			return errors.Errorf("empty method in %q", v)
+		}
+	}
+
+	*m = vs
+
+	return nil
+}
+
+type buckets []float64
+
+func (b *buckets) String() string {
+	s := make([]string, len(*b))
+	for i, v := range *b {
+		s[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return strings.Join(s, ",")
+}
+
+func (b *buckets) Set(v string) error {
+	vs := strings.Split(v, ",")
+	bset := make(buckets, len(vs))
+
+	for i, s := range vs {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return errors.Wrapf(err, "parse bucket %q", s)
+		}
+
+		if i > 0 && f <= bset[i-1] {
+			return errors.Errorf("buckets must be in increasing order")
+		}
+
+		bset[i] = f
+	}
+
+	*b = bset
+
+	return nil
+}
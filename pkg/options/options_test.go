@@ -48,3 +48,200 @@ func TestLabelArg_Sort(t *testing.T) {
 		})
 	}
 }
+
+func TestLabelArg_Merge(t *testing.T) {
+	for i, tc := range []struct {
+		original labelArg
+		labels   map[string]string
+		expected labelArg
+		err      bool
+	}{
+		{
+			labels:   map[string]string{"z": "1", "a": "2"},
+			expected: labelArg{{Name: "a", Value: "2"}, {Name: "z", Value: "1"}},
+		},
+		{
+			// Labels already set take precedence.
+			original: labelArg{{Name: "z", Value: "flag"}},
+			labels:   map[string]string{"z": "file", "a": "file"},
+			expected: labelArg{{Name: "a", Value: "file"}, {Name: "z", Value: "flag"}},
+		},
+		{labels: map[string]string{"not-valid": "1"}, err: true},
+		{labels: map[string]string{"__name__": "up"}, err: true},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			err := tc.original.Merge(tc.labels)
+			if tc.err {
+				testutil.NotOk(t, err)
+				return
+			}
+
+			testutil.Ok(t, err)
+			testutil.Equals(t, tc.expected, tc.original)
+		})
+	}
+}
+
+func TestEnvLabelArg_Set(t *testing.T) {
+	t.Setenv("UP_TEST_POD_NAME", "up-0")
+	t.Setenv("UP_TEST_NODE_NAME", "node-a")
+
+	for i, tc := range []struct {
+		values   []string
+		expected envLabelArg
+		err      bool
+	}{
+		{values: []string{"pod=UP_TEST_POD_NAME"}, expected: envLabelArg{"pod": "up-0"}},
+		{
+			values:   []string{"pod=UP_TEST_POD_NAME, node=UP_TEST_NODE_NAME"},
+			expected: envLabelArg{"pod": "up-0", "node": "node-a"},
+		},
+		{
+			// Repeated flags add to the labels.
+			values:   []string{"pod=UP_TEST_POD_NAME", "node=UP_TEST_NODE_NAME"},
+			expected: envLabelArg{"pod": "up-0", "node": "node-a"},
+		},
+		{values: []string{"pod=UP_TEST_NOT_SET"}, err: true},
+		{values: []string{"pod"}, err: true},
+		{values: []string{"not-valid=UP_TEST_POD_NAME"}, err: true},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			var (
+				e   envLabelArg
+				err error
+			)
+
+			for _, v := range tc.values {
+				if err = e.Set(v); err != nil {
+					break
+				}
+			}
+
+			if tc.err {
+				testutil.NotOk(t, err)
+				return
+			}
+
+			testutil.Ok(t, err)
+			testutil.Equals(t, tc.expected, e)
+		})
+	}
+}
+
+func TestBuckets_Set(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected buckets
+		err      bool
+	}{
+		{value: "0.1,1, 10", expected: buckets{0.1, 1, 10}},
+		{value: "1", expected: buckets{1}},
+		{value: "1,0.5", err: true},
+		{value: "1,a", err: true},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			var b buckets
+
+			err := b.Set(tc.value)
+			if tc.err {
+				testutil.NotOk(t, err)
+				return
+			}
+
+			testutil.Ok(t, err)
+			testutil.Equals(t, tc.expected, b)
+		})
+	}
+}
+
+func TestTenants_Set(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected tenants
+		err      bool
+	}{
+		{value: "team-a, team-b", expected: tenants{"team-a", "team-b"}},
+		{value: "team-a", expected: tenants{"team-a"}},
+		{value: "team-a,,team-b", err: true},
+		{value: "team-a,team-a", err: true},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			var ts tenants
+
+			err := ts.Set(tc.value)
+			if tc.err {
+				testutil.NotOk(t, err)
+				return
+			}
+
+			testutil.Ok(t, err)
+			testutil.Equals(t, tc.expected, ts)
+		})
+	}
+}
+
+func TestMetricsFilter_Serves(t *testing.T) {
+	testCases := []struct {
+		allow, deny string
+		name        string
+		expected    bool
+	}{
+		{name: "up_remote_writes_total", expected: true},
+		{allow: "up_.*", name: "up_remote_writes_total", expected: true},
+		{allow: "up_.*", name: "go_goroutines", expected: false},
+		// Matchers match whole names.
+		{allow: "up", name: "up_remote_writes_total", expected: false},
+		{deny: "up_custom_query_.*", name: "up_custom_query_executed_total", expected: false},
+		{allow: "up_.*", deny: "up_custom_query_.*", name: "up_remote_writes_total", expected: true},
+		{allow: "up_.*", deny: "up_custom_query_.*", name: "up_custom_query_errors_total", expected: false},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			var f MetricsFilter
+
+			if tc.allow != "" {
+				testutil.Ok(t, f.Allow.Set(tc.allow))
+			}
+
+			if tc.deny != "" {
+				testutil.Ok(t, f.Deny.Set(tc.deny))
+			}
+
+			testutil.Equals(t, tc.expected, f.Serves(tc.name))
+		})
+	}
+}
+
+func TestResolveArg_Set(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected resolveArg
+		err      bool
+	}{
+		{value: "receive.example:443:10.0.0.1", expected: resolveArg{"receive.example:443": "10.0.0.1"}},
+		{value: "receive.example:443:[::1]", expected: resolveArg{"receive.example:443": "::1"}},
+		{value: "receive.example:10.0.0.1", err: true},
+		{value: "receive.example:https:10.0.0.1", err: true},
+		{value: "receive.example:443:receive-0.example", err: true},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			var r resolveArg
+
+			err := r.Set(tc.value)
+			if tc.err {
+				testutil.NotOk(t, err)
+				return
+			}
+
+			testutil.Ok(t, err)
+			testutil.Equals(t, tc.expected, r)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package options
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/prometheus/common/model"
+)
+
+// ScenarioSpec represents a scenario, steps which are executed in order and share variables.
+type ScenarioSpec struct {
+	Name string `yaml:"name"`
+	// Variables are available to all steps as {{.name}}, in addition to run_id and captured values.
+	Variables map[string]string `yaml:"variables,omitempty"`
+	Steps     []ScenarioStep    `yaml:"steps"`
+	// Threshold overrides the global percentage of successful runs needed by the scenario to succeed overall.
+	Threshold *float64 `yaml:"threshold,omitempty"`
+}
+
+// ScenarioStep is a single action of a scenario. Exactly one of its actions must be set.
+type ScenarioStep struct {
+	Name   string          `yaml:"name,omitempty"`
+	Write  *ScenarioWrite  `yaml:"write,omitempty"`
+	Wait   model.Duration  `yaml:"wait,omitempty"`
+	Query  *ScenarioQuery  `yaml:"query,omitempty"`
+	Delete *ScenarioDelete `yaml:"delete,omitempty"`
+}
+
+// ScenarioWrite writes a single sample of a series to the write endpoint, or a single entry of a log stream
+// for logs endpoints.
+type ScenarioWrite struct {
+	Labels map[string]string `yaml:"labels"`
+	// Value is the value of the sample, the current timestamp in milliseconds by default.
+	Value *float64 `yaml:"value,omitempty"`
+	// Line is the line of the log entry, the run ID by default.
+	Line string `yaml:"line,omitempty"`
+}
+
+// ScenarioQuery runs an instant query against the read endpoint, which is a LogQL query for logs endpoints.
+type ScenarioQuery struct {
+	Query      string      `yaml:"query"`
+	Assertions *Assertions `yaml:"assertions,omitempty"`
+	// Capture stores the value of the first sample of the result in the variable of the given name.
+	Capture string `yaml:"capture,omitempty"`
+	// Eventually retries the query until its assertions hold for up to the given duration,
+	// e.g. to wait for deletions processed in the background.
+	Eventually model.Duration `yaml:"eventually,omitempty"`
+}
+
+// ScenarioDelete deletes the series matching any of the matchers using the TSDB admin API of the read endpoint.
+// For logs endpoints, every matcher is a LogQL stream selector whose entries are deleted using the delete API
+// of Loki, which only removes them once the compactor processed the deletion.
+type ScenarioDelete struct {
+	Matchers []string `yaml:"matchers"`
+}
+
+// StepName returns the name of the i-th step, defaulting to its position and action.
+func (s ScenarioSpec) StepName(i int) string {
+	if s.Steps[i].Name != "" {
+		return s.Steps[i].Name
+	}
+
+	return fmt.Sprintf("%d-%s", i, s.Steps[i].action())
+}
+
+func (s ScenarioStep) action() string {
+	switch {
+	case s.Write != nil:
+		return "write"
+	case s.Query != nil:
+		return "query"
+	case s.Delete != nil:
+		return "delete"
+	case s.Wait > 0:
+		return "wait"
+	}
+
+	return ""
+}
+
+// Validate returns an error if the scenario cannot be run against the endpoint type.
+func (s ScenarioSpec) Validate(endpointType EndpointType) error {
+	if s.Name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("steps cannot be empty")
+	}
+
+	for i, st := range s.Steps {
+		n := 0
+
+		for _, set := range []bool{st.Write != nil, st.Query != nil, st.Delete != nil, st.Wait > 0} {
+			if set {
+				n++
+			}
+		}
+
+		if n != 1 {
+			return fmt.Errorf("step %d must have exactly one of write, wait, query and delete", i)
+		}
+
+		if err := st.validate(endpointType); err != nil {
+			return fmt.Errorf("step %q is invalid: %w", s.StepName(i), err)
+		}
+	}
+
+	return nil
+}
+
+func (s ScenarioStep) validate(endpointType EndpointType) error {
+	var templates []string
+
+	switch {
+	case s.Write != nil:
+		if endpointType == LogsEndpointType {
+			if len(s.Write.Labels) == 0 {
+				return fmt.Errorf("write labels cannot be empty")
+			}
+
+			if s.Write.Value != nil {
+				return fmt.Errorf("write value cannot be set for logs")
+			}
+		} else {
+			if _, ok := s.Write.Labels[model.MetricNameLabel]; !ok {
+				return fmt.Errorf("write labels must contain %s", model.MetricNameLabel)
+			}
+
+			if s.Write.Line != "" {
+				return fmt.Errorf("write line can only be set for logs")
+			}
+		}
+
+		for k, v := range s.Write.Labels {
+			templates = append(templates, k, v)
+		}
+
+		templates = append(templates, s.Write.Line)
+	case s.Query != nil:
+		if s.Query.Query == "" {
+			return fmt.Errorf("query cannot be empty")
+		}
+
+		templates = append(templates, s.Query.Query)
+	case s.Delete != nil:
+		if len(s.Delete.Matchers) == 0 {
+			return fmt.Errorf("delete matchers cannot be empty")
+		}
+
+		templates = append(templates, s.Delete.Matchers...)
+	}
+
+	for _, t := range templates {
+		if _, err := template.New("").Parse(t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package options
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/prometheus/common/model"
+)
+
+func TestScenarioSpec_Validate(t *testing.T) {
+	for i, tc := range []struct {
+		spec         ScenarioSpec
+		endpointType EndpointType
+		valid        bool
+	}{
+		{
+			spec: ScenarioSpec{Name: "valid", Steps: []ScenarioStep{
+				{Write: &ScenarioWrite{Labels: map[string]string{"__name__": "{{.run_id}}"}}},
+				{Wait: model.Duration(1)},
+				{Query: &ScenarioQuery{Query: "up"}},
+				{Delete: &ScenarioDelete{Matchers: []string{"up"}}},
+			}},
+			valid: true,
+		},
+		{
+			spec: ScenarioSpec{Steps: []ScenarioStep{{Wait: model.Duration(1)}}},
+		},
+		{
+			spec: ScenarioSpec{Name: "no-steps"},
+		},
+		{
+			spec: ScenarioSpec{Name: "two-actions", Steps: []ScenarioStep{
+				{Wait: model.Duration(1), Query: &ScenarioQuery{Query: "up"}},
+			}},
+		},
+		{
+			spec: ScenarioSpec{Name: "no-action", Steps: []ScenarioStep{{Name: "empty"}}},
+		},
+		{
+			spec: ScenarioSpec{Name: "no-metric-name", Steps: []ScenarioStep{
+				{Write: &ScenarioWrite{Labels: map[string]string{"job": "up"}}},
+			}},
+		},
+		{
+			spec: ScenarioSpec{Name: "logs", Steps: []ScenarioStep{
+				{Write: &ScenarioWrite{Labels: map[string]string{"job": "{{.run_id}}"}, Line: "canary {{.run_id}}"}},
+				{Delete: &ScenarioDelete{Matchers: []string{`{job="{{.run_id}}"}`}}},
+				{Query: &ScenarioQuery{Query: `{job="{{.run_id}}"}`, Eventually: model.Duration(time.Hour)}},
+			}},
+			endpointType: LogsEndpointType,
+			valid:        true,
+		},
+		{
+			spec: ScenarioSpec{Name: "logs-value", Steps: []ScenarioStep{
+				{Write: &ScenarioWrite{Labels: map[string]string{"job": "up"}, Value: new(float64)}},
+			}},
+			endpointType: LogsEndpointType,
+		},
+		{
+			spec: ScenarioSpec{Name: "metrics-line", Steps: []ScenarioStep{
+				{Write: &ScenarioWrite{Labels: map[string]string{"__name__": "up"}, Line: "up"}},
+			}},
+		},
+		{
+			spec: ScenarioSpec{Name: "invalid-template", Steps: []ScenarioStep{
+				{Query: &ScenarioQuery{Query: "{{.run_id"}},
+			}},
+		},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			endpointType := tc.endpointType
+			if endpointType == "" {
+				endpointType = MetricsEndpointType
+			}
+
+			err := tc.spec.Validate(endpointType)
+			if tc.valid {
+				testutil.Ok(t, err)
+			} else {
+				testutil.NotOk(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package options
+
+import (
+	"sort"
+
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// TenantSpec configures a tenant of the --tenants-file, which runs its own write and read pipelines.
+type TenantSpec struct {
+	// Name is the ID of the tenant, sent in the tenant header.
+	Name string `yaml:"name"`
+	// Token and TokenFile override the token of the flags, the former taking precedence.
+	Token     string `yaml:"token"`
+	TokenFile string `yaml:"token_file"`
+	// Labels are added to the --labels written and read back by the tenant, overriding labels of the same name.
+	Labels map[string]string `yaml:"labels"`
+	// Threshold overrides the --threshold, --threshold-write and --threshold-read of successful requests of the tenant.
+	Threshold *float64 `yaml:"threshold"`
+}
+
+// Apply returns the options of the pipelines of the tenant.
+func (t TenantSpec) Apply(opts Options) Options {
+	opts.Tenant = t.Name
+
+	if t.TokenFile != "" {
+		opts.Token = auth.NewFileToken(t.TokenFile)
+	}
+
+	if t.Token != "" {
+		opts.Token = auth.NewStaticToken(t.Token)
+	}
+
+	if t.Threshold != nil {
+		opts.SuccessThreshold = *t.Threshold
+		opts.WriteThreshold = *t.Threshold
+		opts.ReadThreshold = *t.Threshold
+	}
+
+	labels := make(labelArg, 0, len(opts.Labels)+len(t.Labels))
+
+	for _, l := range opts.Labels {
+		if _, ok := t.Labels[l.Name]; !ok {
+			labels = append(labels, l)
+		}
+	}
+
+	for k, v := range t.Labels {
+		labels = append(labels, prompb.Label{Name: k, Value: v})
+	}
+
+	sort.Sort(&labels)
+	opts.Labels = labels
+
+	return opts
+}
@@ -0,0 +1,45 @@
+package options
+
+import (
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestTenantSpec_Apply(t *testing.T) {
+	threshold := 0.5
+	opts := Options{
+		Tenant:           "default",
+		Token:            auth.NewStaticToken("default"),
+		SuccessThreshold: 0.9,
+		Labels:           labelArg{{Name: "__name__", Value: "up"}, {Name: "team", Value: "default"}},
+	}
+
+	ts := TenantSpec{
+		Name:      "team-a",
+		Token:     "secret",
+		TokenFile: "/does/not/exist",
+		Labels:    map[string]string{"team": "a", "cluster": "eu"},
+		Threshold: &threshold,
+	}.Apply(opts)
+
+	testutil.Equals(t, "team-a", ts.Tenant)
+	testutil.Equals(t, 0.5, ts.SuccessThreshold)
+	testutil.Equals(t, 0.5, ts.WriteThreshold)
+	testutil.Equals(t, 0.5, ts.ReadThreshold)
+	testutil.Equals(t, labelArg{{Name: "__name__", Value: "up"}, {Name: "cluster", Value: "eu"}, {Name: "team", Value: "a"}}, ts.Labels)
+
+	// The token takes precedence over the token file.
+	token, err := ts.Token.Get()
+	testutil.Ok(t, err)
+	testutil.Equals(t, "secret", token)
+
+	// The options of the flags are kept if not overridden.
+	ts = TenantSpec{Name: "team-b"}.Apply(opts)
+	testutil.Equals(t, 0.9, ts.SuccessThreshold)
+	testutil.Equals(t, opts.Token, ts.Token)
+	testutil.Equals(t, opts.Labels, ts.Labels)
+	testutil.Equals(t, []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "team", Value: "default"}}, []prompb.Label(opts.Labels))
+}
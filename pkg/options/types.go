@@ -9,6 +9,7 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/observatorium/up/pkg/api"
+	"github.com/observatorium/up/pkg/auth"
 	promapi "github.com/prometheus/client_golang/api"
 	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
@@ -21,6 +22,7 @@ const (
 	labelSeries     = "series"
 	labelNames      = "label_names"
 	labelValues     = "label_values"
+	labelAbsent     = "absent"
 )
 
 // Query represents different types of queries.
@@ -31,17 +33,115 @@ type Query interface {
 	GetType() string
 	// GetQuery gets the query statement (promql) or label/matchers of the query.
 	GetQuery() string
+	// GetCommon gets the settings shared by all query types.
+	GetCommon() CommonSpec
 	// Run executes the query.
-	Run(ctx context.Context, c promapi.Client, logger log.Logger, traceID string,
+	Run(ctx context.Context, c promapi.Client, logger log.Logger,
 		defaultStep time.Duration) (int, promapiv1.Warnings, error)
 }
 
+// CommonSpec holds the settings shared by all query types.
+type CommonSpec struct {
+	// FailOnWarnings overrides the global setting whether warnings returned by the query count as failure.
+	FailOnWarnings *bool `yaml:"fail_on_warnings,omitempty"`
+	// Tenant overrides the global tenant for the query.
+	Tenant string `yaml:"tenant,omitempty"`
+	// Token and TokenFile override the global bearer token for the query. Token takes precedence if both are set.
+	Token     string `yaml:"token,omitempty"`
+	TokenFile string `yaml:"token_file,omitempty"`
+	// Threshold overrides the global percentage of successful executions needed by the query to succeed overall.
+	Threshold *float64 `yaml:"threshold,omitempty"`
+	// Headers are set on the requests of the query, overriding the tenant header but not the bearer token.
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// ExpectStatus is the HTTP status code the query must fail with, e.g. 403 for data of a forbidden tenant.
+	ExpectStatus int `yaml:"expect_status,omitempty"`
+}
+
+func (c CommonSpec) GetCommon() CommonSpec { return c }
+
+// FailsOnWarnings returns whether warnings count as failure, given the global setting.
+func (c CommonSpec) FailsOnWarnings(global bool) bool {
+	if c.FailOnWarnings != nil {
+		return *c.FailOnWarnings
+	}
+
+	return global
+}
+
+// EffectiveThreshold returns the success threshold of the query, given the global threshold.
+func (c CommonSpec) EffectiveThreshold(global float64) float64 {
+	if c.Threshold != nil {
+		return *c.Threshold
+	}
+
+	return global
+}
+
+// CheckStatus returns the outcome of the query given its status code and error, succeeding
+// only with the expected status if one is set.
+func (c CommonSpec) CheckStatus(httpCode int, err error) error {
+	if c.ExpectStatus == 0 {
+		return err
+	}
+
+	if httpCode == c.ExpectStatus {
+		// Successful responses can still fail on their content, e.g. assertions.
+		if httpCode/100 == 2 {
+			return err
+		}
+
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("expected status %d, got %d: %w", c.ExpectStatus, httpCode, err)
+	}
+
+	return fmt.Errorf("expected status %d, got %d", c.ExpectStatus, httpCode)
+}
+
+// EffectiveTenant returns the tenant of the query, given the global tenant.
+func (c CommonSpec) EffectiveTenant(global string) string {
+	if c.Tenant != "" {
+		return c.Tenant
+	}
+
+	return global
+}
+
+// EffectiveToken returns the token provider of the query, given the global token provider.
+func (c CommonSpec) EffectiveToken(global auth.TokenProvider) auth.TokenProvider {
+	switch {
+	case c.Token != "":
+		return auth.NewStaticToken(c.Token)
+	case c.TokenFile != "":
+		return auth.NewFileToken(c.TokenFile)
+	}
+
+	return global
+}
+
 type QuerySpec struct {
+	CommonSpec `yaml:",inline"`
+
 	Name     string         `yaml:"name"`
 	Query    string         `yaml:"query"`
 	Duration model.Duration `yaml:"duration,omitempty"`
 	Step     time.Duration  `yaml:"step,omitempty"`
 	Cache    bool           `yaml:"cache,omitempty"`
+	// Thanos specific query parameters.
+	Dedup               *bool  `yaml:"dedup,omitempty"`
+	PartialResponse     *bool  `yaml:"partial_response,omitempty"`
+	MaxSourceResolution string `yaml:"max_source_resolution,omitempty"`
+	// Stats requests the statistics of the query engine, which are exported as metrics.
+	Stats bool `yaml:"stats,omitempty"`
+	// Timeout is sent as the timeout of the evaluation on the server, which aborts queries running longer.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// LookbackDelta overrides the lookback delta of the server, e.g. shorter than its default of 5m to notice stale
+	// series sooner.
+	LookbackDelta time.Duration `yaml:"lookback_delta,omitempty"`
+	// Assertions on the result in addition to the query succeeding.
+	Assertions *Assertions `yaml:"assertions,omitempty"`
 }
 
 func (q QuerySpec) GetName() string {
@@ -58,7 +158,18 @@ func (q QuerySpec) GetType() string {
 
 func (q QuerySpec) GetQuery() string { return q.Query }
 
-func (q QuerySpec) Run(ctx context.Context, c promapi.Client, logger log.Logger, traceID string,
+func (q QuerySpec) params() api.QueryParams {
+	return api.QueryParams{
+		Dedup:               q.Dedup,
+		PartialResponse:     q.PartialResponse,
+		MaxSourceResolution: q.MaxSourceResolution,
+		Stats:               q.Stats,
+		Timeout:             q.Timeout,
+		LookbackDelta:       q.LookbackDelta,
+	}
+}
+
+func (q QuerySpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
 	defaultStep time.Duration) (int, promapiv1.Warnings, error) {
 	var (
 		warn promapiv1.Warnings
@@ -71,34 +182,44 @@ func (q QuerySpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
 			step = q.Step
 		}
 
-		_, httpCode, warn, err := api.QueryRange(ctx, c, q.Query, promapiv1.Range{
+		value, httpCode, warn, err := api.QueryRange(ctx, c, q.Query, promapiv1.Range{
 			Start: time.Now().Add(-time.Duration(q.Duration)),
 			End:   time.Now(),
 			Step:  step,
-		}, q.Cache)
+		}, q.Cache, q.params())
 		if err != nil {
 			err = fmt.Errorf("querying: %w", err)
 			return httpCode, warn, err
 		}
 
+		if err := q.Assertions.Check(value); err != nil {
+			return httpCode, warn, err
+		}
+
 		// Don't log response in range query case because there are a lot.
-		level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", traceID)
+		level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", api.TraceID(ctx))
 
 		return httpCode, warn, err
 	}
 
-	_, httpCode, warn, err := api.Query(ctx, c, q.Query, time.Now(), q.Cache)
+	value, httpCode, warn, err := api.Query(ctx, c, q.Query, time.Now(), q.Cache, q.params())
 	if err != nil {
 		err = fmt.Errorf("querying: %w", err)
 		return httpCode, warn, err
 	}
 
-	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "response code ", httpCode, "trace-id", traceID)
+	if err := q.Assertions.Check(value); err != nil {
+		return httpCode, warn, err
+	}
+
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "response code ", httpCode, "trace-id", api.TraceID(ctx))
 
 	return httpCode, warn, err
 }
 
 type LabelSpec struct {
+	CommonSpec `yaml:",inline"`
+
 	Name     string         `yaml:"name"`
 	Label    string         `yaml:"label"`
 	Duration model.Duration `yaml:"duration"`
@@ -117,7 +238,7 @@ func (q LabelSpec) GetType() string {
 
 func (q LabelSpec) GetQuery() string { return q.Label }
 
-func (q LabelSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger, traceID string,
+func (q LabelSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
 	_ time.Duration) (int, promapiv1.Warnings, error) {
 	var (
 		warn     promapiv1.Warnings
@@ -137,12 +258,14 @@ func (q LabelSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
 	}
 
 	// Don't log responses because there are a lot.
-	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", traceID)
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", api.TraceID(ctx))
 
 	return httpCode, warn, err
 }
 
 type SeriesSpec struct {
+	CommonSpec `yaml:",inline"`
+
 	Name     string         `yaml:"name"`
 	Matchers []string       `yaml:"matchers"`
 	Duration model.Duration `yaml:"duration"`
@@ -155,7 +278,7 @@ func (q SeriesSpec) GetType() string { return labelSeries }
 
 func (q SeriesSpec) GetQuery() string { return strings.Join(q.Matchers, ", ") }
 
-func (q SeriesSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger, traceID string,
+func (q SeriesSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
 	_ time.Duration) (int, promapiv1.Warnings, error) {
 	_, httpCode, warn, err := api.Series(ctx, c, q.Matchers, time.Now().Add(-time.Duration(q.Duration)), time.Now(), q.Cache)
 	if err != nil {
@@ -164,7 +287,62 @@ func (q SeriesSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger
 	}
 
 	// Don't log responses because there are a lot.
-	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", traceID)
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", api.TraceID(ctx))
+
+	return httpCode, warn, err
+}
+
+// AbsentSpec represents a query which must not return any data, e.g. for the data of a forbidden tenant.
+type AbsentSpec struct {
+	CommonSpec `yaml:",inline"`
+
+	Name     string         `yaml:"name"`
+	Query    string         `yaml:"query"`
+	Duration model.Duration `yaml:"duration,omitempty"`
+	Step     time.Duration  `yaml:"step,omitempty"`
+	Cache    bool           `yaml:"cache,omitempty"`
+}
+
+func (q AbsentSpec) GetName() string { return q.Name }
+
+func (q AbsentSpec) GetType() string { return labelAbsent }
+
+func (q AbsentSpec) GetQuery() string { return q.Query }
+
+func (q AbsentSpec) Run(ctx context.Context, c promapi.Client, logger log.Logger,
+	defaultStep time.Duration) (int, promapiv1.Warnings, error) {
+	var (
+		value    model.Value
+		warn     promapiv1.Warnings
+		err      error
+		httpCode int
+	)
+
+	if q.Duration > 0 {
+		step := defaultStep
+		if q.Step > 0 {
+			step = q.Step
+		}
+
+		value, httpCode, warn, err = api.QueryRange(ctx, c, q.Query, promapiv1.Range{
+			Start: time.Now().Add(-time.Duration(q.Duration)),
+			End:   time.Now(),
+			Step:  step,
+		}, q.Cache, api.QueryParams{})
+	} else {
+		value, httpCode, warn, err = api.Query(ctx, c, q.Query, time.Now(), q.Cache, api.QueryParams{})
+	}
+
+	if err != nil {
+		err = fmt.Errorf("querying: %w", err)
+		return httpCode, warn, err
+	}
+
+	if !api.Empty(value) {
+		return httpCode, warn, fmt.Errorf("expected no data, got %s", value.Type())
+	}
+
+	level.Debug(logger).Log("msg", "request finished", "name", q.Name, "trace-id", api.TraceID(ctx))
 
 	return httpCode, warn, err
 }
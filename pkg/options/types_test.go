@@ -0,0 +1,77 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+
+	"github.com/observatorium/up/pkg/auth"
+)
+
+func TestCommonSpec_CheckStatus(t *testing.T) {
+	errForbidden := errors.New("forbidden")
+	errAssertion := assertionErrorf("expected non-empty result")
+
+	for i, tc := range []struct {
+		expectStatus int
+		httpCode     int
+		err          error
+		ok           bool
+	}{
+		{httpCode: 200, ok: true},
+		{httpCode: 403, err: errForbidden},
+		{expectStatus: 403, httpCode: 403, err: errForbidden, ok: true},
+		{expectStatus: 403, httpCode: 200},
+		{expectStatus: 403, httpCode: 500, err: errForbidden},
+		{expectStatus: 200, httpCode: 200, ok: true},
+		{expectStatus: 200, httpCode: 200, err: errAssertion},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			err := CommonSpec{ExpectStatus: tc.expectStatus}.CheckStatus(tc.httpCode, tc.err)
+			if tc.ok {
+				testutil.Ok(t, err)
+			} else {
+				testutil.NotOk(t, err)
+			}
+		})
+	}
+}
+
+func TestCommonSpec_EffectiveTenant(t *testing.T) {
+	testutil.Equals(t, "global", CommonSpec{}.EffectiveTenant("global"))
+	testutil.Equals(t, "query", CommonSpec{Tenant: "query"}.EffectiveTenant("global"))
+	testutil.Equals(t, "query", CommonSpec{Tenant: "query"}.EffectiveTenant(""))
+}
+
+func TestCommonSpec_EffectiveToken(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	testutil.Ok(t, os.WriteFile(tokenFile, []byte("file"), 0o600))
+
+	for i, tc := range []struct {
+		spec     CommonSpec
+		expected string
+		ok       bool
+	}{
+		{spec: CommonSpec{}, expected: "global", ok: true},
+		{spec: CommonSpec{Token: "static"}, expected: "static", ok: true},
+		{spec: CommonSpec{TokenFile: tokenFile}, expected: "file", ok: true},
+		{spec: CommonSpec{Token: "static", TokenFile: tokenFile}, expected: "static", ok: true},
+		{spec: CommonSpec{TokenFile: filepath.Join(dir, "missing")}},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			token, err := tc.spec.EffectiveToken(auth.NewStaticToken("global")).Get()
+			if !tc.ok {
+				testutil.NotOk(t, err)
+				return
+			}
+
+			testutil.Ok(t, err)
+			testutil.Equals(t, tc.expected, token)
+		})
+	}
+}
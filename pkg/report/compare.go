@@ -0,0 +1,83 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Tolerances configures how much worse a run may get compared to its baseline before it counts as a regression.
+type Tolerances struct {
+	// Latency is the allowed relative increase of the mean latency, e.g. 0.2 allows 20% slower requests.
+	Latency float64
+	// Ratio is the allowed absolute decrease of the success ratio, e.g. 0.05 allows 5 percentage points less.
+	Ratio float64
+}
+
+// Regression describes a single check that got significantly worse compared to the baseline.
+type Regression struct {
+	Component string
+	Reason    string
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s: %s", r.Component, r.Reason)
+}
+
+// Compare returns the regressions of the current summary against the baseline.
+// Checks that are not part of the baseline are not compared.
+func Compare(baseline, current Summary, t Tolerances) []Regression {
+	var res []Regression
+
+	res = append(res, compareComponent("write", baseline.Write, current.Write, t)...)
+	res = append(res, compareComponent("read", baseline.Read, current.Read, t)...)
+
+	names := make([]string, 0, len(current.Queries))
+	for name := range current.Queries {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		b, ok := baseline.Queries[name]
+		if !ok {
+			continue
+		}
+
+		res = append(res, compareComponent("query "+name, b, current.Queries[name], t)...)
+	}
+
+	return res
+}
+
+func compareComponent(name string, baseline, current Component, t Tolerances) []Regression {
+	var res []Regression
+
+	if current.Success+current.Errors == 0 || baseline.Success+baseline.Errors == 0 {
+		return res
+	}
+
+	if baseline.Errors == 0 && current.Errors > 0 {
+		res = append(res, Regression{
+			Component: name,
+			Reason:    fmt.Sprintf("new failures: %.f errors, baseline had none", current.Errors),
+		})
+	}
+
+	if baseline.Ratio-current.Ratio > t.Ratio {
+		res = append(res, Regression{
+			Component: name,
+			Reason:    fmt.Sprintf("success ratio dropped from %.4f to %.4f", baseline.Ratio, current.Ratio),
+		})
+	}
+
+	if baseline.MeanLatency > 0 && current.MeanLatency > baseline.MeanLatency*(1+t.Latency) {
+		res = append(res, Regression{
+			Component: name,
+			Reason: fmt.Sprintf("mean latency increased from %.3fs to %.3fs (%+.f%%)",
+				baseline.MeanLatency, current.MeanLatency, (current.MeanLatency/baseline.MeanLatency-1)*100),
+		})
+	}
+
+	return res
+}
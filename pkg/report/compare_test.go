@@ -0,0 +1,61 @@
+package report
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+)
+
+func TestCompare(t *testing.T) {
+	tolerances := Tolerances{Latency: 0.2, Ratio: 0.05}
+	healthy := Component{Success: 100, Ratio: 1, MeanLatency: 1}
+
+	testCases := []struct {
+		baseline Summary
+		current  Summary
+		expected []string
+	}{
+		{
+			// Same results do not regress.
+			Summary{Write: healthy, Read: healthy},
+			Summary{Write: healthy, Read: healthy},
+			nil,
+		},
+		{
+			// Latency within tolerance and a few failures within tolerance but new.
+			Summary{Write: healthy},
+			Summary{Write: Component{Success: 98, Errors: 2, Ratio: 0.98, MeanLatency: 1.1}},
+			[]string{"write: new failures: 2 errors, baseline had none"},
+		},
+		{
+			// Latency and ratio beyond tolerance.
+			Summary{Read: Component{Success: 99, Errors: 1, Ratio: 0.99, MeanLatency: 1}},
+			Summary{Read: Component{Success: 90, Errors: 10, Ratio: 0.9, MeanLatency: 1.5}},
+			[]string{
+				"read: success ratio dropped from 0.9900 to 0.9000",
+				"read: mean latency increased from 1.000s to 1.500s (+50%)",
+			},
+		},
+		{
+			// Queries missing from the baseline are ignored.
+			Summary{Queries: map[string]Component{"a": healthy}},
+			Summary{Queries: map[string]Component{
+				"a": {Success: 100, Ratio: 1, MeanLatency: 2},
+				"b": {Errors: 100},
+			}},
+			[]string{"query a: mean latency increased from 1.000s to 2.000s (+100%)"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			var got []string
+			for _, r := range Compare(tc.baseline, tc.current, tolerances) {
+				got = append(got, r.String())
+			}
+
+			testutil.Equals(t, tc.expected, got)
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package report provides the summary of a run, its comparison against a baseline from a previous run and the
+// report of a run written on exit.
+package report
@@ -0,0 +1,121 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// runCase is the test case of the run as a whole, failing with the errors of the run.
+const runCase = "run"
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit returns the report as JUnit XML. The write and read checks and every custom query are test cases, taking
+// the time of all their requests and failing if their success ratio is below the threshold. The run is a test case
+// of its own, failing with the errors of the run.
+func JUnit(r Report) ([]byte, error) {
+	verdicts := make(map[string]Verdict, len(r.Verdicts))
+	for _, v := range r.Verdicts {
+		verdicts[v.Check] = v
+	}
+
+	suite := junitTestSuite{Name: "up", Time: seconds(r.Finished.Sub(r.Started).Seconds())}
+
+	addCase := func(name string, c Component) {
+		if c.Success+c.Errors == 0 {
+			return
+		}
+
+		tc := junitTestCase{Name: name, ClassName: "up", Time: seconds(c.MeanLatency * (c.Success + c.Errors))}
+
+		if v, ok := verdicts[name]; ok && !v.Passed {
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("success ratio %.4f is below the threshold %.4f", v.Ratio, v.Threshold),
+				Text:    fmt.Sprintf("%.f successful and %.f failed requests", c.Success, c.Errors),
+			}
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	addCase("write", r.Summary.Write)
+	addCase("read", r.Summary.Read)
+
+	names := make([]string, 0, len(r.Summary.Queries))
+	for name := range r.Summary.Queries {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		addCase("query "+name, r.Summary.Queries[name])
+	}
+
+	run := junitTestCase{Name: runCase, ClassName: "up", Time: suite.Time}
+	if !r.Success {
+		run.Failure = &junitFailure{
+			Message: fmt.Sprintf("up failed (%s)", r.ExitReason),
+			Text:    strings.Join(append(append([]string{}, r.Errors...), r.Regressions...), "\n"),
+		}
+	}
+
+	suite.Cases = append(suite.Cases, run)
+
+	for _, tc := range suite.Cases {
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+	}
+
+	suite.Tests = len(suite.Cases)
+
+	b, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "marshalling JUnit report")
+	}
+
+	return append([]byte(xml.Header), b...), nil
+}
+
+// WriteJUnitFile writes the report as JUnit XML.
+func WriteJUnitFile(file string, r Report) error {
+	b, err := JUnit(r)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(ioutil.WriteFile(file, b, 0o644), "writing JUnit file") //nolint:gosec
+}
+
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
@@ -0,0 +1,57 @@
+package report
+
+import (
+	"encoding/xml"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+)
+
+func TestJUnit(t *testing.T) {
+	s := Summary{
+		Write:   Component{Success: 10, Ratio: 1, MeanLatency: 0.5},
+		Read:    Component{Success: 5, Errors: 5, Ratio: 0.5, MeanLatency: 1},
+		Queries: map[string]Component{"q1": {Success: 2, Ratio: 1, MeanLatency: 2}},
+	}
+	started := time.Now().Add(-time.Minute)
+
+	r := NewReport(started, ExitDurationElapsed, s, Thresholds{Write: 0.9, Read: 0.9}, nil,
+		[]error{errors.New("failed with less than 90% success ratio - actual 50%")})
+
+	b, err := JUnit(r)
+	testutil.Ok(t, err)
+
+	var suites junitTestSuites
+	testutil.Ok(t, xml.Unmarshal(b, &suites))
+	testutil.Equals(t, 1, len(suites.Suites))
+
+	suite := suites.Suites[0]
+	testutil.Equals(t, 4, suite.Tests)
+	testutil.Equals(t, 2, suite.Failures)
+
+	for i, tc := range []struct {
+		name    string
+		time    string
+		failure string
+	}{
+		{name: "write", time: "5.000"},
+		{name: "read", time: "10.000", failure: "success ratio 0.5000 is below the threshold 0.9000"},
+		{name: "query q1", time: "4.000"},
+		{name: runCase, time: suite.Time, failure: "up failed (duration elapsed)"},
+	} {
+		c := suite.Cases[i]
+		testutil.Equals(t, tc.name, c.Name, "case #%d", i)
+		testutil.Equals(t, tc.time, c.Time, "case #%d", i)
+
+		if tc.failure == "" {
+			testutil.Assert(t, c.Failure == nil, "case #%d must not fail", i)
+			continue
+		}
+
+		testutil.Equals(t, tc.failure, c.Failure.Message, "case #%d", i)
+	}
+
+	testutil.Equals(t, "failed with less than 90% success ratio - actual 50%", suite.Cases[3].Failure.Text)
+}
@@ -0,0 +1,105 @@
+package report
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The reasons for a run to end.
+const (
+	ExitDurationElapsed = "duration elapsed"
+	ExitInterrupted     = "interrupted"
+	ExitFailedFast      = "failed fast"
+	ExitStopped         = "stopped"
+)
+
+// Report is the machine-readable outcome of a run, written on exit.
+type Report struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// Success is whether the run succeeded, i.e. up exits with 0.
+	Success    bool      `json:"success"`
+	ExitReason string    `json:"exit_reason"`
+	Summary    Summary   `json:"summary"`
+	Verdicts   []Verdict `json:"verdicts"`
+	// Regressions against the baseline, if any.
+	Regressions []string `json:"regressions,omitempty"`
+	// Errors are why the run failed, if so.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Verdict is the evaluation of the success ratio of a check against its threshold.
+type Verdict struct {
+	Check     string  `json:"check"`
+	Ratio     float64 `json:"ratio"`
+	Threshold float64 `json:"threshold"`
+	Passed    bool    `json:"passed"`
+}
+
+// Thresholds are the success thresholds of the checks. Queries without threshold are not evaluated.
+type Thresholds struct {
+	Write   float64
+	Read    float64
+	Queries map[string]float64
+}
+
+// NewReport returns the report of a run started at started and finished now, which failed if there are errors.
+func NewReport(started time.Time, exitReason string, s Summary, t Thresholds, regressions []Regression, errs []error) Report {
+	r := Report{
+		Started:    started,
+		Finished:   time.Now(),
+		Success:    len(errs) == 0,
+		ExitReason: exitReason,
+		Summary:    s,
+		Verdicts:   []Verdict{},
+	}
+
+	r.addVerdict("write", s.Write, t.Write)
+	r.addVerdict("read", s.Read, t.Read)
+
+	names := make([]string, 0, len(s.Queries))
+	for name := range s.Queries {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if threshold, ok := t.Queries[name]; ok && threshold > 0 {
+			r.addVerdict("query "+name, s.Queries[name], threshold)
+		}
+	}
+
+	for _, reg := range regressions {
+		r.Regressions = append(r.Regressions, reg.String())
+	}
+
+	for _, err := range errs {
+		r.Errors = append(r.Errors, err.Error())
+	}
+
+	return r
+}
+
+// addVerdict adds the verdict of the component, if it ran.
+func (r *Report) addVerdict(check string, c Component, threshold float64) {
+	if c.Success+c.Errors == 0 {
+		return
+	}
+
+	r.Verdicts = append(r.Verdicts, Verdict{Check: check, Ratio: c.Ratio, Threshold: threshold, Passed: c.Ratio >= threshold})
+}
+
+// WriteReportFile writes the report as JSON.
+func WriteReportFile(file string, r Report) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshalling report")
+	}
+
+	return errors.Wrap(ioutil.WriteFile(file, b, 0o644), "writing report file") //nolint:gosec
+}
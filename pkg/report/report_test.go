@@ -0,0 +1,37 @@
+package report
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+)
+
+func TestNewReport(t *testing.T) {
+	s := Summary{
+		Write: Component{Success: 9, Errors: 1, Ratio: 0.9},
+		Queries: map[string]Component{
+			"q1": {Success: 1, Errors: 1, Ratio: 0.5},
+			"q2": {Success: 2, Ratio: 1},
+		},
+	}
+	thresholds := Thresholds{Write: 0.95, Read: 0.9, Queries: map[string]float64{"q1": 0.5, "q2": 0}}
+
+	r := NewReport(time.Now(), ExitDurationElapsed, s, thresholds, []Regression{{Component: "write", Reason: "new failures"}},
+		[]error{errors.New("failed with less than 95% success ratio")})
+
+	testutil.Assert(t, !r.Success, "a run with errors must fail")
+	testutil.Equals(t, ExitDurationElapsed, r.ExitReason)
+	// The reader did not run and the threshold of q2 is disabled.
+	testutil.Equals(t, []Verdict{
+		{Check: "write", Ratio: 0.9, Threshold: 0.95, Passed: false},
+		{Check: "query q1", Ratio: 0.5, Threshold: 0.5, Passed: true},
+	}, r.Verdicts)
+	testutil.Equals(t, []string{"write: new failures"}, r.Regressions)
+	testutil.Equals(t, []string{"failed with less than 95% success ratio"}, r.Errors)
+
+	r = NewReport(time.Now(), ExitInterrupted, Summary{}, thresholds, nil, nil)
+	testutil.Assert(t, r.Success, "a run without errors must succeed")
+	testutil.Equals(t, []Verdict{}, r.Verdicts)
+}
@@ -0,0 +1,179 @@
+package report
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	labelResult = "result"
+	labelQuery  = "query"
+
+	resultError = "error"
+
+	metricWrites              = "up_remote_writes_total"
+	metricWriteDuration       = "up_remote_writes_duration_seconds"
+	metricReads               = "up_queries_total"
+	metricReadDuration        = "up_queries_duration_seconds"
+	metricLogsWrites          = "up_logs_writes_total"
+	metricLogsWriteDuration   = "up_logs_writes_duration_seconds"
+	metricLogsReads           = "up_logs_queries_total"
+	metricLogsReadDuration    = "up_logs_queries_duration_seconds"
+	metricCustomQueries       = "up_custom_query_executed_total"
+	metricCustomQueryErrors   = "up_custom_query_errors_total"
+	metricCustomQueryDuration = "up_custom_query_duration_seconds"
+)
+
+// Summary represents the final results of a run.
+type Summary struct {
+	Write   Component            `json:"write"`
+	Read    Component            `json:"read"`
+	Queries map[string]Component `json:"queries,omitempty"`
+}
+
+// Component represents the final results of a single check.
+type Component struct {
+	Success     float64 `json:"success"`
+	Errors      float64 `json:"errors"`
+	Ratio       float64 `json:"ratio"`
+	MeanLatency float64 `json:"mean_latency_seconds"`
+}
+
+func (c *Component) finish() {
+	if total := c.Success + c.Errors; total > 0 {
+		c.Ratio = c.Success / total
+	}
+}
+
+// Collect builds the summary of a run from the metrics gathered by up.
+func Collect(g prometheus.Gatherer) (Summary, error) {
+	mfs, err := g.Gather()
+	if err != nil {
+		return Summary{}, errors.Wrap(err, "gathering metrics")
+	}
+
+	var (
+		s         = Summary{Queries: map[string]Component{}}
+		writes    histogramTotals
+		reads     histogramTotals
+		durations = map[string]histogramTotals{}
+		executed  = map[string]float64{}
+		failed    = map[string]float64{}
+	)
+
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			switch mf.GetName() {
+			// Only the metrics of the written signal are populated, the others stay empty.
+			case metricWrites, metricLogsWrites:
+				addResult(&s.Write, m)
+			case metricReads, metricLogsReads:
+				addResult(&s.Read, m)
+			case metricWriteDuration, metricLogsWriteDuration:
+				writes = writes.add(histogramTotalsOf(m))
+			case metricReadDuration, metricLogsReadDuration:
+				reads = reads.add(histogramTotalsOf(m))
+			case metricCustomQueries:
+				executed[labelValue(m, labelQuery)] += m.GetCounter().GetValue()
+			case metricCustomQueryErrors:
+				failed[labelValue(m, labelQuery)] += m.GetCounter().GetValue()
+			case metricCustomQueryDuration:
+				name := labelValue(m, labelQuery)
+				durations[name] = durations[name].add(histogramTotalsOf(m))
+			}
+		}
+	}
+
+	s.Write.MeanLatency = mean(writes)
+	s.Read.MeanLatency = mean(reads)
+
+	s.Write.finish()
+	s.Read.finish()
+
+	for name, total := range executed {
+		c := Component{
+			// Failed queries are only counted as executed when they got an HTTP response.
+			Success:     total - failed[name],
+			Errors:      failed[name],
+			MeanLatency: mean(durations[name]),
+		}
+		if c.Success < 0 {
+			c.Success = 0
+		}
+
+		c.finish()
+		s.Queries[name] = c
+	}
+
+	return s, nil
+}
+
+// ReadFile reads a summary previously written with WriteFile.
+func ReadFile(file string) (Summary, error) {
+	s := Summary{}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return s, errors.Wrap(err, "reading summary file")
+	}
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return s, errors.Wrap(err, "unmarshalling summary")
+	}
+
+	return s, nil
+}
+
+// WriteFile writes the summary as JSON so it can be used as a baseline for later runs.
+func WriteFile(file string, s Summary) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshalling summary")
+	}
+
+	return errors.Wrap(ioutil.WriteFile(file, b, 0o644), "writing summary file") //nolint:gosec
+}
+
+func addResult(c *Component, m *dto.Metric) {
+	if labelValue(m, labelResult) == resultError {
+		c.Errors += m.GetCounter().GetValue()
+		return
+	}
+
+	c.Success += m.GetCounter().GetValue()
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+
+	return ""
+}
+
+type histogramTotals struct {
+	sum   float64
+	count uint64
+}
+
+func (h histogramTotals) add(o histogramTotals) histogramTotals {
+	return histogramTotals{sum: h.sum + o.sum, count: h.count + o.count}
+}
+
+func histogramTotalsOf(m *dto.Metric) histogramTotals {
+	return histogramTotals{sum: m.GetHistogram().GetSampleSum(), count: m.GetHistogram().GetSampleCount()}
+}
+
+func mean(h histogramTotals) float64 {
+	if h.count == 0 {
+		return 0
+	}
+
+	return h.sum / float64(h.count)
+}
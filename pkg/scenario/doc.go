@@ -0,0 +1,2 @@
+// Package scenario runs scenarios, ordered steps like writing, querying and deleting series which share variables.
+package scenario
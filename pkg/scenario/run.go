@@ -0,0 +1,293 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/observatorium/up/pkg/api"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/logs"
+	"github.com/observatorium/up/pkg/metrics"
+	"github.com/observatorium/up/pkg/options"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	promapi "github.com/prometheus/client_golang/api"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// Env are the endpoints and credentials scenarios are run with.
+type Env struct {
+	EndpointType  options.EndpointType
+	WriteEndpoint *url.URL
+	ReadEndpoint  *url.URL
+	// WriteToken and WriteTLS authenticate the writes, Token and TLS the queries. Without them, the writes are
+	// authenticated like the queries.
+	WriteToken   auth.TokenProvider
+	WriteTLS     *options.TLS
+	Token        auth.TokenProvider
+	TLS          options.TLS
+	TenantHeader string
+	Tenant       string
+	// LogsEncoding and LogsCompression are the encoding of log pushes, which are recorded by the metrics.
+	LogsEncoding    options.PushEncoding
+	LogsCompression options.PushCompression
+	Metrics         instr.Metrics
+}
+
+// writeAuth returns the token and TLS of the writes.
+func (e Env) writeAuth() (auth.TokenProvider, options.TLS) {
+	token, tls := e.Token, e.TLS
+
+	if e.WriteToken != nil {
+		token = e.WriteToken
+	}
+
+	if e.WriteTLS != nil {
+		tls = *e.WriteTLS
+	}
+
+	return token, tls
+}
+
+// StepError is returned by Run for the step a scenario failed at.
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string { return fmt.Sprintf("step %q: %v", e.Step, e.Err) }
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// maxRetryInterval is the longest time between the attempts of queries retried until their assertions hold.
+const maxRetryInterval = 10 * time.Second
+
+// RunIDVariable is the variable holding an ID unique to every run of a scenario.
+const RunIDVariable = "run_id"
+
+// Run executes the steps of the scenario in order, stopping at the first failing step.
+func Run(ctx context.Context, l log.Logger, s options.ScenarioSpec, env Env) error {
+	vars := map[string]string{RunIDVariable: strconv.FormatInt(time.Now().UnixNano(), 36)}
+	for k, v := range s.Variables {
+		vars[k] = v
+	}
+
+	for i, st := range s.Steps {
+		name := s.StepName(i)
+
+		level.Debug(l).Log("msg", "running scenario step", "scenario", s.Name, "step", name)
+
+		if err := runStep(ctx, l, st, env, vars); err != nil {
+			return &StepError{Step: name, Err: err}
+		}
+	}
+
+	return nil
+}
+
+func runStep(ctx context.Context, l log.Logger, st options.ScenarioStep, env Env, vars map[string]string) error {
+	switch {
+	case st.Write != nil:
+		return write(ctx, l, st.Write, env, vars)
+	case st.Query != nil:
+		return query(ctx, l, st.Query, env, vars)
+	case st.Delete != nil:
+		return deleteSeries(ctx, l, st.Delete, env, vars)
+	case st.Wait > 0:
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(st.Wait)):
+		}
+	}
+
+	return nil
+}
+
+func write(ctx context.Context, l log.Logger, w *options.ScenarioWrite, env Env, vars map[string]string) error {
+	if env.WriteEndpoint == nil {
+		return errors.New("no write endpoint configured")
+	}
+
+	var (
+		labels = make([]prompb.Label, 0, len(w.Labels))
+		err    error
+	)
+
+	for k, v := range w.Labels {
+		name, err := expand(k, vars)
+		if err != nil {
+			return err
+		}
+
+		value, err := expand(v, vars)
+		if err != nil {
+			return err
+		}
+
+		labels = append(labels, prompb.Label{Name: name, Value: value})
+	}
+
+	// Remote write requires sorted labels.
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+
+	if env.EndpointType == options.LogsEndpointType {
+		line := vars[RunIDVariable]
+		if w.Line != "" {
+			line, err = expand(w.Line, vars)
+			if err != nil {
+				return err
+			}
+		}
+
+		preq := logs.Generate(labels, [][]string{{strconv.FormatInt(time.Now().UnixNano(), 10), line}}, nil)
+		token, tls := env.writeAuth()
+		_, err = logs.Write(ctx, env.WriteEndpoint, token, preq, env.Metrics, l, tls, env.LogsEncoding, env.LogsCompression,
+			env.TenantHeader, env.Tenant)
+
+		return err
+	}
+
+	wreq := metrics.Generate(labels, false)
+	if w.Value != nil {
+		wreq.Timeseries[0].Samples[0].Value = *w.Value
+	}
+
+	token, tls := env.writeAuth()
+	_, err = metrics.Write(ctx, env.WriteEndpoint, token, wreq, l, tls, env.TenantHeader, env.Tenant)
+
+	return err
+}
+
+func query(ctx context.Context, l log.Logger, q *options.ScenarioQuery, env Env, vars map[string]string) error {
+	c, err := newClient(l, env)
+	if err != nil {
+		return err
+	}
+
+	expr, err := expand(q.Query, vars)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(time.Duration(q.Eventually))
+
+	value, err := queryOnce(ctx, c, q, env, expr)
+
+	// Only retry for the assertions to eventually hold, failing requests fail the step right away.
+	var aErr *options.AssertionError
+	for errors.As(err, &aErr) && time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryInterval(q.Eventually)):
+		}
+
+		value, err = queryOnce(ctx, c, q, env, expr)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if q.Capture != "" {
+		v, ok := firstValue(value)
+		if !ok {
+			return errors.Errorf("cannot capture %q from an empty result", q.Capture)
+		}
+
+		vars[q.Capture] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return nil
+}
+
+// queryOnce runs the query and checks its assertions, returning the value of metric queries.
+func queryOnce(ctx context.Context, c promapi.Client, q *options.ScenarioQuery, env Env, expr string) (model.Value, error) {
+	if env.EndpointType == options.LogsEndpointType {
+		res, _, _, err := api.LokiQuery(ctx, c, expr, time.Now(), false, api.LokiParams{})
+		if err != nil {
+			return nil, errors.Wrap(err, "query request failed")
+		}
+
+		return res.Value, q.Assertions.CheckLogs(res)
+	}
+
+	value, _, _, err := api.Query(ctx, c, expr, time.Now(), false, api.QueryParams{})
+	if err != nil {
+		return nil, errors.Wrap(err, "query request failed")
+	}
+
+	return value, q.Assertions.Check(value)
+}
+
+// retryInterval returns the time between the attempts of a query retried for up to the given duration.
+func retryInterval(eventually model.Duration) time.Duration {
+	interval := time.Duration(eventually) / 10
+	if interval > maxRetryInterval {
+		return maxRetryInterval
+	}
+
+	return interval
+}
+
+func firstValue(v model.Value) (float64, bool) {
+	switch r := v.(type) {
+	case model.Vector:
+		if len(r) > 0 {
+			return float64(r[0].Value), true
+		}
+	case *model.Scalar:
+		return float64(r.Value), true
+	}
+
+	return 0, false
+}
+
+func deleteSeries(ctx context.Context, l log.Logger, d *options.ScenarioDelete, env Env, vars map[string]string) error {
+	c, err := newClient(l, env)
+	if err != nil {
+		return err
+	}
+
+	matchers := make([]string, len(d.Matchers))
+	for i, m := range d.Matchers {
+		if matchers[i], err = expand(m, vars); err != nil {
+			return err
+		}
+	}
+
+	// Delete everything ever written, as samples can be written with timestamps from the past.
+	if env.EndpointType == options.LogsEndpointType {
+		for _, m := range matchers {
+			if _, err := api.LokiDelete(ctx, c, m, time.Unix(0, 0), time.Now()); err != nil {
+				return errors.Wrap(err, "delete logs request failed")
+			}
+		}
+
+		return nil
+	}
+
+	if _, err := api.DeleteSeries(ctx, c, matchers, time.Unix(0, 0), time.Now()); err != nil {
+		return errors.Wrap(err, "delete series request failed")
+	}
+
+	return nil
+}
+
+// newClient returns a client of the read endpoint, which is part of the Loki HTTP API for logs.
+func newClient(l log.Logger, env Env) (promapi.Client, error) {
+	if env.EndpointType == options.LogsEndpointType {
+		return logs.NewClient(env.ReadEndpoint, env.Token, l, env.TLS, env.TenantHeader, env.Tenant)
+	}
+
+	return metrics.NewClient(env.ReadEndpoint, env.Token, l, env.TLS, env.TenantHeader, env.Tenant, nil)
+}
@@ -0,0 +1,170 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestRun(t *testing.T) {
+	var (
+		written []prompb.Label
+		queried string
+		deleted []string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/write":
+			b, err := ioutil.ReadAll(r.Body)
+			testutil.Ok(t, err)
+			b, err = snappy.Decode(nil, b)
+			testutil.Ok(t, err)
+
+			var wreq prompb.WriteRequest
+			testutil.Ok(t, proto.Unmarshal(b, &wreq))
+			written = wreq.Timeseries[0].Labels
+		case "/api/v1/query":
+			testutil.Ok(t, r.ParseForm())
+			queried = r.Form.Get("query")
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"42"]}]}}`)
+		case "/api/v1/admin/tsdb/delete_series":
+			testutil.Ok(t, r.ParseForm())
+			deleted = r.Form["match[]"]
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	writeURL, err := url.Parse(srv.URL + "/write")
+	testutil.Ok(t, err)
+	readURL, err := url.Parse(srv.URL)
+	testutil.Ok(t, err)
+
+	env := Env{WriteEndpoint: writeURL, ReadEndpoint: readURL, Token: auth.NewNoOpTokenProvider()}
+
+	s := options.ScenarioSpec{
+		Name:      "test",
+		Variables: map[string]string{"metric": "up_scenario"},
+		Steps: []options.ScenarioStep{
+			{Write: &options.ScenarioWrite{Labels: map[string]string{"__name__": "{{.metric}}", "run": "{{.run_id}}"}}},
+			{Query: &options.ScenarioQuery{
+				Query:      `{{.metric}}{run="{{.run_id}}"}`,
+				Assertions: &options.Assertions{NonEmpty: true},
+				Capture:    "value",
+			}},
+			{Delete: &options.ScenarioDelete{Matchers: []string{`{{.metric}}{value="{{.value}}"}`}}},
+		},
+	}
+
+	testutil.Ok(t, Run(context.Background(), log.NewNopLogger(), s, env))
+
+	testutil.Equals(t, 2, len(written))
+	testutil.Equals(t, "up_scenario", written[0].Value)
+	testutil.Equals(t, fmt.Sprintf(`up_scenario{run="%s"}`, written[1].Value), queried)
+	testutil.Equals(t, []string{`up_scenario{value="42"}`}, deleted)
+
+	// Undefined variables fail their step.
+	s.Steps = []options.ScenarioStep{{Name: "undefined", Query: &options.ScenarioQuery{Query: "{{.undefined}}"}}}
+	err = Run(context.Background(), log.NewNopLogger(), s, env)
+
+	var sErr *StepError
+	testutil.Assert(t, errors.As(err, &sErr), "expected step error, got %v", err)
+	testutil.Equals(t, "undefined", sErr.Step)
+}
+
+func TestRun_Logs(t *testing.T) {
+	var (
+		pushed  string
+		deleted url.Values
+		queries int
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loki/api/v1/push":
+			b, err := ioutil.ReadAll(r.Body)
+			testutil.Ok(t, err)
+			pushed = string(b)
+			w.WriteHeader(http.StatusNoContent)
+		case "/loki/api/v1/delete":
+			testutil.Equals(t, http.MethodPost, r.Method)
+			deleted = r.URL.Query()
+			w.WriteHeader(http.StatusNoContent)
+		case "/loki/api/v1/query":
+			queries++
+			// The entries disappear some time after the deletion.
+			if deleted == nil || queries < 3 {
+				fmt.Fprint(w, `{"status":"success","data":{"resultType":"streams","result":[{"stream":{},"values":[["1","x"]]}]}}`)
+				return
+			}
+
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"streams","result":[]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	writeURL, err := url.Parse(srv.URL + "/loki/api/v1/push")
+	testutil.Ok(t, err)
+	readURL, err := url.Parse(srv.URL + "/loki/api/v1/query")
+	testutil.Ok(t, err)
+
+	env := Env{
+		EndpointType:  options.LogsEndpointType,
+		WriteEndpoint: writeURL,
+		ReadEndpoint:  readURL,
+		Token:         auth.NewNoOpTokenProvider(),
+		Metrics:       instr.RegisterMetrics(prometheus.NewRegistry(), options.Buckets{}),
+	}
+
+	zero := 0
+	s := options.ScenarioSpec{
+		Name: "delete",
+		Steps: []options.ScenarioStep{
+			{Write: &options.ScenarioWrite{Labels: map[string]string{"canary": "{{.run_id}}"}, Line: "canary {{.run_id}}"}},
+			{Query: &options.ScenarioQuery{Query: `{canary="{{.run_id}}"}`, Assertions: &options.Assertions{NonEmpty: true}}},
+			{Delete: &options.ScenarioDelete{Matchers: []string{`{canary="{{.run_id}}"}`}}},
+			{Query: &options.ScenarioQuery{
+				Query:      `{canary="{{.run_id}}"}`,
+				Assertions: &options.Assertions{MaxSeries: &zero},
+				Eventually: model.Duration(time.Second),
+			}},
+		},
+	}
+
+	testutil.Ok(t, Run(context.Background(), log.NewNopLogger(), s, env))
+	testutil.Assert(t, strings.Contains(pushed, `"canary `), "unexpected push %s", pushed)
+	testutil.Equals(t, 3, queries)
+	testutil.Assert(t, strings.HasPrefix(deleted.Get("query"), `{canary="`), "unexpected deletion %v", deleted)
+	testutil.Equals(t, "0", deleted.Get("start"))
+
+	// Assertions which never hold fail the step once the duration passed.
+	s.Steps = []options.ScenarioStep{{Query: &options.ScenarioQuery{
+		Query:      `{canary="up"}`,
+		Assertions: &options.Assertions{NonEmpty: true},
+		Eventually: model.Duration(100 * time.Millisecond),
+	}}}
+	testutil.NotOk(t, Run(context.Background(), log.NewNopLogger(), s, env))
+}
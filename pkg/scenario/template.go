@@ -0,0 +1,21 @@
+package scenario
+
+import (
+	"bytes"
+	"text/template"
+)
+
+// expand executes the text as template with the variables, failing for undefined variables.
+func expand(text string, vars map[string]string) (string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,2 @@
+// Package store represents the reader to query Thanos StoreAPI over gRPC for a set of labels.
+package store
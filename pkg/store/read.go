@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/observatorium/up/pkg/transport"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// Read executes a Series call against a Thanos StoreAPI with the same labels to retrieve the written metrics back.
+// It returns the gRPC status code of the call.
+func Read(
+	ctx context.Context,
+	address string,
+	useTLS bool,
+	tp auth.TokenProvider,
+	labels []prompb.Label,
+	latency time.Duration,
+	m instr.Metrics,
+	l log.Logger,
+	tls options.TLS,
+) (string, error) {
+	dialOpts := []grpc.DialOption{
+		grpc.WithContextDialer(transport.DialContext),
+		grpc.WithPerRPCCredentials(auth.NewTokenCredentials(tp)),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(codec{})),
+	}
+
+	if useTLS {
+		tlsConfig, err := transport.NewTLSConfig(l, tls)
+		if err != nil {
+			return "", errors.Wrap(err, "tls config")
+		}
+
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	conn, err := grpc.DialContext(ctx, address, dialOpts...)
+	if err != nil {
+		return "", errors.Wrap(err, "dialing store")
+	}
+
+	defer func() {
+		if err := conn.Close(); err != nil {
+			level.Warn(l).Log("msg", "detected close error", "err", errors.Wrap(err, "store connection close"))
+		}
+	}()
+
+	now := time.Now()
+
+	req := &seriesRequest{
+		MinTime:                 now.Add(-latency).UnixMilli(),
+		MaxTime:                 now.UnixMilli(),
+		Aggregations:            []int32{aggrRaw},
+		PartialResponseDisabled: true,
+	}
+
+	for _, label := range labels {
+		req.Matchers = append(req.Matchers, &labelMatcher{Type: matcherEQ, Name: label.Name, Value: label.Value})
+	}
+
+	newest, numSeries, err := seriesCall(ctx, conn, req, l)
+	if err != nil {
+		return status.Code(err).String(), errors.Wrap(err, "series request failed")
+	}
+
+	code := codes.OK.String()
+
+	if numSeries == 0 {
+		return code, errors.New("expected at least one series, got none")
+	}
+
+	// The value of the samples is the timestamp of their write.
+	diffSeconds := time.Since(time.UnixMilli(int64(newest))).Seconds()
+
+	m.StoreMetricValueDifference.Observe(diffSeconds)
+
+	if diffSeconds > latency.Seconds() {
+		return code, errors.Errorf("metric value is too old: %2.fs", diffSeconds)
+	}
+
+	return code, nil
+}
+
+// seriesCall streams the series matching the request and returns the newest sample value and the number of series.
+func seriesCall(ctx context.Context, conn *grpc.ClientConn, req *seriesRequest, l log.Logger) (float64, int, error) {
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, seriesMethod)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := stream.SendMsg(req); err != nil {
+		return 0, 0, err
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return 0, 0, err
+	}
+
+	var (
+		newestTimestamp int64
+		newest          float64
+		numSeries       int
+	)
+
+	for {
+		res := &seriesResponse{}
+
+		if err := stream.RecvMsg(res); err != nil {
+			if err == io.EOF {
+				return newest, numSeries, nil
+			}
+
+			return 0, 0, err
+		}
+
+		if res.Warning != "" {
+			level.Warn(l).Log("msg", "store returned warning", "warning", res.Warning)
+		}
+
+		if res.Series == nil {
+			continue
+		}
+
+		numSeries++
+
+		for _, c := range res.Series.Chunks {
+			if c.Raw == nil || c.Raw.Type != chunkXOR {
+				continue
+			}
+
+			chk, err := chunkenc.FromData(chunkenc.EncXOR, c.Raw.Data)
+			if err != nil {
+				return 0, 0, errors.Wrap(err, "decoding chunk")
+			}
+
+			it := chk.Iterator(nil)
+			for it.Next() == chunkenc.ValFloat {
+				if t, v := it.At(); t > newestTimestamp {
+					newestTimestamp, newest = t, v
+				}
+			}
+
+			if err := it.Err(); err != nil {
+				return 0, 0, errors.Wrap(err, "iterating chunk")
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"google.golang.org/grpc"
+)
+
+func TestRead(t *testing.T) {
+	written := time.Now().Add(-time.Second).UnixMilli()
+
+	chk := chunkenc.NewXORChunk()
+	app, err := chk.Appender()
+	testutil.Ok(t, err)
+	app.Append(written-5000, float64(written-5000))
+	app.Append(written, float64(written))
+
+	var got *seriesRequest
+
+	srv := grpc.NewServer(grpc.ForceServerCodec(codec{}), grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		got = &seriesRequest{}
+		if err := stream.RecvMsg(got); err != nil {
+			return err
+		}
+
+		return stream.SendMsg(&seriesResponse{Series: &series{
+			Labels: []*label{{Name: "__name__", Value: "up"}},
+			Chunks: []*aggrChunk{{Raw: &chunk{Type: chunkXOR, Data: chk.Bytes()}}},
+		}})
+	}))
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	testutil.Ok(t, err)
+
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	m := instr.RegisterMetrics(prometheus.NewRegistry(), options.Buckets{})
+	labels := []prompb.Label{{Name: "__name__", Value: "up"}}
+
+	code, err := Read(context.Background(), lis.Addr().String(), false, auth.NewNoOpTokenProvider(), labels, 10*time.Second, m,
+		log.NewNopLogger(), options.TLS{})
+	testutil.Ok(t, err)
+	testutil.Equals(t, "OK", code)
+	testutil.Equals(t, []*labelMatcher{{Type: matcherEQ, Name: "__name__", Value: "up"}}, got.Matchers)
+
+	_, err = Read(context.Background(), lis.Addr().String(), false, auth.NewNoOpTokenProvider(), labels, 500*time.Millisecond, m,
+		log.NewNopLogger(), options.TLS{})
+	testutil.NotOk(t, err)
+}
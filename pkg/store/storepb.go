@@ -0,0 +1,100 @@
+package store
+
+import (
+	"github.com/gogo/protobuf/proto"
+)
+
+// The types below are a minimal copy of the Thanos StoreAPI messages, see
+// https://github.com/thanos-io/thanos/blob/main/pkg/store/storepb/rpc.proto.
+// Only the fields needed to read series are declared, unknown fields are skipped when decoding.
+
+const (
+	seriesMethod = "/thanos.Store/Series"
+
+	matcherEQ = 0
+	aggrRaw   = 0
+	chunkXOR  = 0
+)
+
+type label struct {
+	Name  string `protobuf:"bytes,1,opt,name=name,proto3"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *label) Reset()         { *m = label{} }
+func (m *label) String() string { return proto.CompactTextString(m) }
+func (*label) ProtoMessage()    {}
+
+type labelMatcher struct {
+	Type  int32  `protobuf:"varint,1,opt,name=type,proto3"`
+	Name  string `protobuf:"bytes,2,opt,name=name,proto3"`
+	Value string `protobuf:"bytes,3,opt,name=value,proto3"`
+}
+
+func (m *labelMatcher) Reset()         { *m = labelMatcher{} }
+func (m *labelMatcher) String() string { return proto.CompactTextString(m) }
+func (*labelMatcher) ProtoMessage()    {}
+
+type seriesRequest struct {
+	MinTime                 int64           `protobuf:"varint,1,opt,name=min_time,proto3"`
+	MaxTime                 int64           `protobuf:"varint,2,opt,name=max_time,proto3"`
+	Matchers                []*labelMatcher `protobuf:"bytes,3,rep,name=matchers,proto3"`
+	MaxResolutionWindow     int64           `protobuf:"varint,4,opt,name=max_resolution_window,proto3"`
+	Aggregations            []int32         `protobuf:"varint,5,rep,packed,name=aggregations,proto3"`
+	PartialResponseDisabled bool            `protobuf:"varint,6,opt,name=partial_response_disabled,proto3"`
+}
+
+func (m *seriesRequest) Reset()         { *m = seriesRequest{} }
+func (m *seriesRequest) String() string { return proto.CompactTextString(m) }
+func (*seriesRequest) ProtoMessage()    {}
+
+// seriesResponse declares the members of the oneof result as plain fields, which is equivalent on the wire.
+type seriesResponse struct {
+	Series  *series `protobuf:"bytes,1,opt,name=series,proto3"`
+	Warning string  `protobuf:"bytes,2,opt,name=warning,proto3"`
+}
+
+func (m *seriesResponse) Reset()         { *m = seriesResponse{} }
+func (m *seriesResponse) String() string { return proto.CompactTextString(m) }
+func (*seriesResponse) ProtoMessage()    {}
+
+type series struct {
+	Labels []*label     `protobuf:"bytes,1,rep,name=labels,proto3"`
+	Chunks []*aggrChunk `protobuf:"bytes,2,rep,name=chunks,proto3"`
+}
+
+func (m *series) Reset()         { *m = series{} }
+func (m *series) String() string { return proto.CompactTextString(m) }
+func (*series) ProtoMessage()    {}
+
+type aggrChunk struct {
+	MinTime int64  `protobuf:"varint,1,opt,name=min_time,proto3"`
+	MaxTime int64  `protobuf:"varint,2,opt,name=max_time,proto3"`
+	Raw     *chunk `protobuf:"bytes,3,opt,name=raw,proto3"`
+}
+
+func (m *aggrChunk) Reset()         { *m = aggrChunk{} }
+func (m *aggrChunk) String() string { return proto.CompactTextString(m) }
+func (*aggrChunk) ProtoMessage()    {}
+
+type chunk struct {
+	Type int32  `protobuf:"varint,1,opt,name=type,proto3"`
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3"`
+}
+
+func (m *chunk) Reset()         { *m = chunk{} }
+func (m *chunk) String() string { return proto.CompactTextString(m) }
+func (*chunk) ProtoMessage()    {}
+
+// codec encodes the messages above with gogo/protobuf, as they do not implement the newer protobuf APIs.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	return proto.Marshal(v.(proto.Message))
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+func (codec) Name() string { return "proto" }
@@ -0,0 +1,8 @@
+// Package tracing traces the requests of up in OpenTelemetry compatible spans, exported to an OTLP/HTTP endpoint
+// and propagated to the servers with the W3C trace context.
+//
+// The package does not use the OpenTelemetry SDK on purpose. up only needs the spans of its own requests, a trace ID
+// ratio sampler, the traceparent header and an exporter, while the SDK and its OTLP exporters would add their gRPC
+// and protobuf dependencies to the prober. As only the wire formats are shared with OpenTelemetry, the export is
+// tested against the OTLP/JSON encoding of the specification.
+package tracing
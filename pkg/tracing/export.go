@@ -0,0 +1,254 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const (
+	// exportInterval is the time between exports of the spans ended in the meantime.
+	exportInterval = 5 * time.Second
+	// exportTimeout is the timeout of a single export, also bounding the final export on shutdown.
+	exportTimeout = 10 * time.Second
+	// maxQueueSize is the number of spans kept until the next export, further spans are dropped.
+	maxQueueSize = 2048
+
+	serviceName = "up"
+	scopeName   = "github.com/observatorium/up"
+)
+
+// Tracer exports the spans of sampled traces to an OTLP/HTTP endpoint, e.g. of an OpenTelemetry Collector.
+type Tracer struct {
+	l        log.Logger
+	endpoint string
+	ratio    float64
+	client   *http.Client
+
+	mtx     sync.Mutex
+	queue   []*Span
+	dropped int
+}
+
+// NewTracer returns a tracer exporting to the traces path of the OTLP/HTTP endpoint, sampling the ratio of traces.
+func NewTracer(l log.Logger, endpoint *url.URL, ratio float64) *Tracer {
+	return &Tracer{
+		l:        log.With(l, "component", "tracer"),
+		endpoint: strings.TrimSuffix(endpoint.String(), "/") + "/v1/traces",
+		ratio:    ratio,
+		client:   &http.Client{Timeout: exportTimeout},
+	}
+}
+
+func (t *Tracer) sample(traceID [16]byte) bool {
+	return sampleRatio(traceID, t.ratio)
+}
+
+func (t *Tracer) enqueue(s *Span) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if len(t.queue) >= maxQueueSize {
+		t.dropped++
+		return
+	}
+
+	t.queue = append(t.queue, s)
+}
+
+// Run exports the ended spans periodically until the context is canceled, exporting the remaining spans then.
+func (t *Tracer) Run(ctx context.Context) error {
+	if t == nil {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.Shutdown()
+			return nil
+		case <-ticker.C:
+			if err := t.export(context.Background()); err != nil {
+				level.Warn(t.l).Log("msg", "failed to export spans", "err", err)
+			}
+		}
+	}
+}
+
+// Shutdown exports the remaining spans.
+func (t *Tracer) Shutdown() {
+	if t == nil {
+		return
+	}
+
+	if err := t.export(context.Background()); err != nil {
+		level.Warn(t.l).Log("msg", "failed to export spans", "err", err)
+	}
+}
+
+func (t *Tracer) export(ctx context.Context) error {
+	t.mtx.Lock()
+	spans, dropped := t.queue, t.dropped
+	t.queue, t.dropped = nil, 0
+	t.mtx.Unlock()
+
+	if dropped > 0 {
+		level.Warn(t.l).Log("msg", "dropped spans exceeding the queue", "dropped", dropped)
+	}
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(encode(spans))
+	if err != nil {
+		return errors.Wrap(err, "encoding spans")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "making request")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return errors.Errorf("exporting %d spans: non-2xx status: %s", len(spans), res.Status)
+	}
+
+	return nil
+}
+
+// The OTLP/JSON encoding of spans, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding.
+type (
+	exportRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	resource struct {
+		Attributes []keyValue `json:"attributes"`
+	}
+	scopeSpans struct {
+		Scope scope      `json:"scope"`
+		Spans []spanJSON `json:"spans"`
+	}
+	scope struct {
+		Name string `json:"name"`
+	}
+	spanJSON struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              int        `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Status            status     `json:"status"`
+	}
+	keyValue struct {
+		Key   string `json:"key"`
+		Value value  `json:"value"`
+	}
+	value struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+	status struct {
+		// Code is 1 for successful and 2 for failed spans.
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+func encode(spans []*Span) exportRequest {
+	ss := make([]spanJSON, 0, len(spans))
+
+	for _, s := range spans {
+		s.mtx.Lock()
+
+		sj := spanJSON{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Status:            status{Code: 1},
+		}
+
+		if s.parentID != [8]byte{} {
+			sj.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+
+		for _, a := range s.attrs {
+			sj.Attributes = append(sj.Attributes, keyValue{Key: a.key, Value: encodeValue(a.value)})
+		}
+
+		if s.err != nil {
+			sj.Status = status{Code: 2, Message: s.err.Error()}
+		}
+
+		s.mtx.Unlock()
+
+		ss = append(ss, sj)
+	}
+
+	name := serviceName
+
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource:   resource{Attributes: []keyValue{{Key: "service.name", Value: value{StringValue: &name}}}},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: scopeName}, Spans: ss}},
+	}}}
+}
+
+func encodeValue(v interface{}) value {
+	switch v := v.(type) {
+	case string:
+		return value{StringValue: &v}
+	case bool:
+		return value{BoolValue: &v}
+	case int:
+		i := strconv.Itoa(v)
+		return value{IntValue: &i}
+	case int64:
+		i := strconv.FormatInt(v, 10)
+		return value{IntValue: &i}
+	case float64:
+		return value{DoubleValue: &v}
+	}
+
+	s := fmt.Sprint(v)
+
+	return value{StringValue: &s}
+}
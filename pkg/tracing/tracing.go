@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The kinds of spans of OTLP.
+const (
+	internalKind = 1
+	clientKind   = 3
+)
+
+// TraceParentHeader is the header of the W3C trace context propagating traces to servers.
+const TraceParentHeader = "traceparent"
+
+var defaultTracer atomic.Pointer[Tracer]
+
+type spanKey struct{}
+
+// SetTracer sets the tracer of the spans started afterwards. Without tracer, no spans are started.
+func SetTracer(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// Span is a span of a trace. All methods are safe to call on nil spans, which are returned without tracer.
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	sampled  bool
+	name     string
+	kind     int
+	start    time.Time
+
+	mtx   sync.Mutex
+	attrs []attribute
+	end   time.Time
+	err   error
+}
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// Start starts a span of the operation as child of the span of the context, if any.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return start(ctx, name, internalKind)
+}
+
+// StartClient starts a span of a request to a server as child of the span of the context, if any.
+func StartClient(ctx context.Context, name string) (context.Context, *Span) {
+	return start(ctx, name, clientKind)
+}
+
+func start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	t := defaultTracer.Load()
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.traceID, s.parentID, s.sampled = parent.traceID, parent.spanID, parent.sampled
+	} else {
+		rand.Read(s.traceID[:]) //nolint:errcheck
+		s.sampled = t.sample(s.traceID)
+	}
+
+	rand.Read(s.spanID[:]) //nolint:errcheck
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SpanFromContext returns the span of the context, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+
+	return s
+}
+
+// SetAttributes sets attributes of alternating keys and values, which are strings, bools, ints or floats.
+func (s *Span) SetAttributes(kv ...interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for i := 0; i+1 < len(kv); i += 2 {
+		s.attrs = append(s.attrs, attribute{key: fmt.Sprint(kv[i]), value: kv[i+1]})
+	}
+}
+
+// End ends the span, which failed if err is not nil. Sampled spans are exported afterwards.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	s.end, s.err = time.Now(), err
+	s.mtx.Unlock()
+
+	if s.sampled {
+		s.tracer.enqueue(s)
+	}
+}
+
+// Sampled returns whether the trace of the span is sampled, so its spans are exported.
+func (s *Span) Sampled() bool {
+	return s != nil && s.sampled
+}
+
+// TraceID returns the ID of the trace of the span, or an empty string for nil spans.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// TraceParent returns the value of the TraceParentHeader propagating the span to a server.
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+
+	flags := 0
+	if s.sampled {
+		flags = 1
+	}
+
+	return fmt.Sprintf("00-%x-%x-%02x", s.traceID, s.spanID, flags)
+}
+
+// sampleRatio returns whether a trace is sampled with the ratio, deciding on the trace ID alone
+// as the trace ID ratio based sampler of OpenTelemetry does.
+func sampleRatio(traceID [16]byte, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+
+	if ratio <= 0 {
+		return false
+	}
+
+	return binary.BigEndian.Uint64(traceID[8:16])>>1 < uint64(ratio*(1<<63))
+}
@@ -0,0 +1,200 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+)
+
+func TestSampleRatio(t *testing.T) {
+	for i, tc := range []struct {
+		traceID [16]byte
+		ratio   float64
+		sampled bool
+	}{
+		{ratio: 1, sampled: true},
+		{ratio: 0, sampled: false},
+		{traceID: [16]byte{8: 0x10}, ratio: 0.5, sampled: true},
+		{traceID: [16]byte{8: 0xf0}, ratio: 0.5, sampled: false},
+		// Only the lower half of the trace ID decides.
+		{traceID: [16]byte{0: 0xff, 8: 0x10}, ratio: 0.5, sampled: true},
+	} {
+		testutil.Equals(t, tc.sampled, sampleRatio(tc.traceID, tc.ratio), "case #%d", i)
+	}
+}
+
+func TestStart(t *testing.T) {
+	SetTracer(nil)
+
+	ctx, s := Start(context.Background(), "up")
+	testutil.Assert(t, s == nil, "no spans must be started without tracer")
+	testutil.Equals(t, "", s.TraceParent())
+	s.SetAttributes("key", "value")
+	s.End(nil)
+
+	tr := NewTracer(log.NewNopLogger(), &url.URL{Scheme: "http", Host: "localhost:4318"}, 1)
+	SetTracer(tr)
+	defer SetTracer(nil)
+
+	ctx, parent := Start(ctx, "parent")
+	_, child := StartClient(ctx, "child")
+
+	testutil.Equals(t, parent.traceID, child.traceID)
+	testutil.Equals(t, parent.spanID, child.parentID)
+	testutil.Equals(t, clientKind, child.kind)
+	testutil.Equals(t, "00-"+child.TraceID()+"-", child.TraceParent()[:36])
+	testutil.Assert(t, strings.HasSuffix(child.TraceParent(), "-01"), "sampled spans must propagate the sampled flag")
+
+	child.End(nil)
+	parent.End(nil)
+	testutil.Equals(t, 2, len(tr.queue))
+
+	tr.ratio = 0
+	_, s = Start(context.Background(), "unsampled")
+	testutil.Assert(t, strings.HasSuffix(s.TraceParent(), "-00"), "unsampled spans must not propagate the sampled flag")
+	s.End(nil)
+	testutil.Equals(t, 2, len(tr.queue))
+}
+
+func TestTracer_Export(t *testing.T) {
+	var got exportRequest
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testutil.Equals(t, "/v1/traces", r.URL.Path)
+		testutil.Equals(t, "application/json", r.Header.Get("Content-Type"))
+		testutil.Ok(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	testutil.Ok(t, err)
+
+	tr := NewTracer(log.NewNopLogger(), u, 1)
+	SetTracer(tr)
+	defer SetTracer(nil)
+
+	ctx, parent := Start(context.Background(), "write")
+	_, child := StartClient(ctx, "HTTP POST")
+	child.SetAttributes("http.status_code", 500, "http.method", "POST")
+	child.End(errors.New("status 500"))
+	parent.End(nil)
+
+	tr.Shutdown()
+	testutil.Equals(t, 0, len(tr.queue))
+
+	testutil.Equals(t, 1, len(got.ResourceSpans))
+	testutil.Equals(t, "up", *got.ResourceSpans[0].Resource.Attributes[0].Value.StringValue)
+
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	testutil.Equals(t, 2, len(spans))
+	testutil.Equals(t, "HTTP POST", spans[0].Name)
+	testutil.Equals(t, spans[1].SpanID, spans[0].ParentSpanID)
+	testutil.Equals(t, "", spans[1].ParentSpanID)
+	testutil.Equals(t, status{Code: 2, Message: "status 500"}, spans[0].Status)
+	testutil.Equals(t, status{Code: 1}, spans[1].Status)
+	testutil.Equals(t, "500", *spans[0].Attributes[0].Value.IntValue)
+	testutil.Equals(t, "POST", *spans[0].Attributes[1].Value.StringValue)
+}
+
+// otlpFixture is the export of the spans in TestEncode in the OTLP/JSON encoding, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding. Trace and span IDs are hex encoded rather than base64,
+// 64-bit integers are strings, and enums are integers.
+const otlpFixture = `{
+  "resourceSpans": [
+    {
+      "resource": {
+        "attributes": [
+          {"key": "service.name", "value": {"stringValue": "up"}}
+        ]
+      },
+      "scopeSpans": [
+        {
+          "scope": {"name": "github.com/observatorium/up"},
+          "spans": [
+            {
+              "traceId": "5b8efff798038103d269b633813fc60c",
+              "spanId": "eee19b7ec3c1b174",
+              "parentSpanId": "eee19b7ec3c1b173",
+              "name": "HTTP GET",
+              "kind": 3,
+              "startTimeUnixNano": "1544712660000000000",
+              "endTimeUnixNano": "1544712661000000000",
+              "attributes": [
+                {"key": "http.method", "value": {"stringValue": "GET"}},
+                {"key": "http.status_code", "value": {"intValue": "503"}},
+                {"key": "retried", "value": {"boolValue": true}},
+                {"key": "ratio", "value": {"doubleValue": 0.5}}
+              ],
+              "status": {"code": 2, "message": "status 503"}
+            },
+            {
+              "traceId": "5b8efff798038103d269b633813fc60c",
+              "spanId": "eee19b7ec3c1b173",
+              "name": "query",
+              "kind": 1,
+              "startTimeUnixNano": "1544712660000000000",
+              "endTimeUnixNano": "1544712662000000000",
+              "status": {"code": 1}
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}`
+
+func TestEncode(t *testing.T) {
+	traceID := [16]byte{0x5b, 0x8e, 0xff, 0xf7, 0x98, 0x03, 0x81, 0x03, 0xd2, 0x69, 0xb6, 0x33, 0x81, 0x3f, 0xc6, 0x0c}
+	start := time.Unix(1544712660, 0)
+
+	parent := &Span{
+		traceID: traceID,
+		spanID:  [8]byte{0xee, 0xe1, 0x9b, 0x7e, 0xc3, 0xc1, 0xb1, 0x73},
+		name:    "query",
+		kind:    internalKind,
+		start:   start,
+		end:     start.Add(2 * time.Second),
+	}
+	child := &Span{
+		traceID:  traceID,
+		spanID:   [8]byte{0xee, 0xe1, 0x9b, 0x7e, 0xc3, 0xc1, 0xb1, 0x74},
+		parentID: parent.spanID,
+		name:     "HTTP GET",
+		kind:     clientKind,
+		start:    start,
+		end:      start.Add(time.Second),
+		err:      errors.New("status 503"),
+	}
+	child.SetAttributes("http.method", "GET", "http.status_code", 503, "retried", true, "ratio", 0.5)
+
+	b, err := json.Marshal(encode([]*Span{child, parent}))
+	testutil.Ok(t, err)
+
+	var got, expected interface{}
+	testutil.Ok(t, json.Unmarshal(b, &got))
+	testutil.Ok(t, json.Unmarshal([]byte(otlpFixture), &expected))
+	testutil.Equals(t, expected, got)
+}
+
+func TestSpan_TraceParent(t *testing.T) {
+	// The example of https://www.w3.org/TR/trace-context/#examples-of-http-traceparent-headers.
+	s := &Span{
+		traceID: [16]byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		spanID:  [8]byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		sampled: true,
+	}
+	testutil.Equals(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", s.TraceParent())
+	testutil.Equals(t, "4bf92f3577b34da6a3ce929d0e0e4736", s.TraceID())
+
+	s.sampled = false
+	testutil.Equals(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", s.TraceParent())
+}
@@ -0,0 +1,279 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const redacted = "REDACTED"
+
+// secretNames are parts of the names of headers and query parameters whose values are redacted in captures.
+var secretNames = []string{"auth", "token", "secret", "password", "cookie", "key", "signature", "credential"}
+
+var defaultCapture atomic.Pointer[Capture]
+
+// SetCapture sets the capture of the failed requests of the round trippers returned by InstrumentedRoundTripper.
+// Without capture, no requests are captured.
+func SetCapture(c *Capture) {
+	defaultCapture.Store(c)
+}
+
+// Capture writes the failed requests and their responses to files of a directory, to reproduce the failures of
+// the servers without capturing the traffic. The values of secret headers and query parameters are redacted.
+type Capture struct {
+	l            log.Logger
+	dir          string
+	maxBodyBytes int
+	maxFiles     int
+
+	mtx   sync.Mutex
+	files int
+}
+
+// NewCapture returns a capture writing up to maxFiles files to the directory, with bodies truncated to maxBodyBytes.
+func NewCapture(l log.Logger, dir string, maxBodyBytes, maxFiles int) (*Capture, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, errors.Wrap(err, "creating capture directory")
+	}
+
+	return &Capture{l: log.With(l, "component", "capture"), dir: dir, maxBodyBytes: maxBodyBytes, maxFiles: maxFiles}, nil
+}
+
+// capturedRequest is the file written for every failed request.
+type capturedRequest struct {
+	Time    time.Time         `json:"time"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    *capturedBody     `json:"body,omitempty"`
+	// Error is the error of the round trip, if the request failed without response.
+	Error    string            `json:"error,omitempty"`
+	Response *capturedResponse `json:"response,omitempty"`
+}
+
+type capturedResponse struct {
+	Status  string            `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    *capturedBody     `json:"body,omitempty"`
+}
+
+// capturedBody is a body truncated to the limit, base64 encoded if it is binary, e.g. snappy compressed protobuf.
+type capturedBody struct {
+	Text      string `json:"text,omitempty"`
+	Binary    []byte `json:"binary,omitempty"`
+	Size      int    `json:"size"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// InstrumentedRoundTripper returns a round tripper tracing every request, setting the headers of its endpoint,
+// encoding it in the encoding of its endpoint and counting the encodings, limiting the size of its response, bounding
+// it by the request timeout, retrying it by the retry policy, limiting the rate of the attempts, observing the duration
+// of their phases and capturing the failed ones with capture.
+func InstrumentedRoundTripper(r http.RoundTripper) http.RoundTripper {
+	if r == nil {
+		r = http.DefaultTransport
+	}
+
+	var rt http.RoundTripper = &timingRoundTripper{r: r}
+	rt = &captureRoundTripper{r: rt}
+	rt = &rateLimitRoundTripper{r: rt}
+	rt = &retryRoundTripper{r: rt}
+	rt = &timeoutRoundTripper{r: rt}
+	rt = &sizeLimitRoundTripper{r: rt}
+	rt = &encodingRoundTripper{r: rt}
+	rt = &headersRoundTripper{r: rt}
+
+	return TracingRoundTripper(rt)
+}
+
+type captureRoundTripper struct {
+	r http.RoundTripper
+}
+
+func (c *captureRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	capture := defaultCapture.Load()
+	if capture == nil {
+		return c.r.RoundTrip(req)
+	}
+
+	// Keep the body to capture if the request fails, unless it can be gotten again.
+	var body []byte
+
+	if req.Body != nil && req.GetBody == nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+
+		if err != nil {
+			return nil, errors.Wrap(err, "reading request body")
+		}
+
+		body = b
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	resp, err := c.r.RoundTrip(req)
+	if err == nil && resp.StatusCode < http.StatusBadRequest {
+		return resp, nil
+	}
+
+	if req.GetBody != nil {
+		if rc, gErr := req.GetBody(); gErr == nil {
+			body, _ = io.ReadAll(rc)
+			rc.Close()
+		}
+	}
+
+	capture.write(req, body, resp, err)
+
+	return resp, err
+}
+
+// write captures the request with the body and the response, or the error if there is no response.
+func (c *Capture) write(req *http.Request, body []byte, resp *http.Response, rtErr error) {
+	c.mtx.Lock()
+	if c.files >= c.maxFiles {
+		c.mtx.Unlock()
+		return
+	}
+
+	c.files++
+	n := c.files
+	c.mtx.Unlock()
+
+	cr := capturedRequest{
+		Time:    time.Now(),
+		Method:  req.Method,
+		URL:     redactURL(req.URL),
+		Headers: redactHeaders(req.Header),
+		Body:    c.body(body, len(body)),
+	}
+
+	status := "error"
+
+	if rtErr != nil {
+		cr.Error = rtErr.Error()
+	} else {
+		// The request of the response has the headers set by the round trippers wrapped, e.g. the tenant.
+		if resp.Request != nil {
+			cr.Headers = redactHeaders(resp.Request.Header)
+		}
+
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, int64(c.maxBodyBytes)+1))
+		resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(b), resp.Body), Closer: resp.Body}
+
+		size := len(b)
+		if resp.ContentLength > int64(size) {
+			size = int(resp.ContentLength)
+		}
+
+		cr.Response = &capturedResponse{Status: resp.Status, Headers: redactHeaders(resp.Header), Body: c.body(b, size)}
+		status = fmt.Sprint(resp.StatusCode)
+	}
+
+	b, err := json.MarshalIndent(cr, "", "  ")
+	if err != nil {
+		level.Warn(c.l).Log("msg", "failed to encode capture", "err", err)
+		return
+	}
+
+	name := filepath.Join(c.dir, fmt.Sprintf("%s-%04d-%s-%s.json", cr.Time.UTC().Format("20060102T150405"), n, req.Method, status))
+	if err := os.WriteFile(name, b, 0o600); err != nil {
+		level.Warn(c.l).Log("msg", "failed to write capture", "file", name, "err", err)
+		return
+	}
+
+	level.Info(c.l).Log("msg", "captured failed request", "file", name)
+
+	if n == c.maxFiles {
+		level.Warn(c.l).Log("msg", "maximum of captured requests reached, further failed requests are not captured", "files", n)
+	}
+}
+
+// body returns the captured body of the size, truncated to the limit.
+func (c *Capture) body(b []byte, size int) *capturedBody {
+	if size == 0 {
+		return nil
+	}
+
+	cb := &capturedBody{Size: size}
+
+	if len(b) > c.maxBodyBytes {
+		b, cb.Truncated = b[:c.maxBodyBytes], true
+	}
+
+	if len(b) < size {
+		cb.Truncated = true
+	}
+
+	if utf8.Valid(b) {
+		cb.Text = string(b)
+	} else {
+		cb.Binary = b
+	}
+
+	return cb
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func isSecret(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, s := range secretNames {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func redactHeaders(h http.Header) map[string]string {
+	res := make(map[string]string, len(h))
+
+	for name, values := range h {
+		if isSecret(name) {
+			res[name] = redacted
+			continue
+		}
+
+		res[name] = strings.Join(values, ", ")
+	}
+
+	return res
+}
+
+func redactURL(u *url.URL) string {
+	r := *u
+
+	q := r.Query()
+	for name := range q {
+		if isSecret(name) {
+			q.Set(name, redacted)
+		}
+	}
+
+	if len(q) > 0 {
+		r.RawQuery = q.Encode()
+	}
+
+	return r.Redacted()
+}
@@ -0,0 +1,79 @@
+package transport
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+)
+
+func TestCapture(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request: " + string(body))) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+
+	c, err := NewCapture(log.NewNopLogger(), dir, 16, 1)
+	testutil.Ok(t, err)
+
+	SetCapture(c)
+	defer SetCapture(nil)
+
+	client := &http.Client{Transport: InstrumentedRoundTripper(nil)}
+
+	for _, path := range []string{"/ok", "/fail?token=secret&query=up", "/fail"} {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader("up{}"))
+		testutil.Ok(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("X-Tenant", "tenant-a")
+
+		resp, err := client.Do(req)
+		testutil.Ok(t, err)
+
+		// The response body is returned as is, regardless of capturing.
+		body, err := io.ReadAll(resp.Body)
+		testutil.Ok(t, err)
+		testutil.Ok(t, resp.Body.Close())
+
+		if path != "/ok" {
+			testutil.Equals(t, "bad request: up{}", string(body))
+		}
+	}
+
+	// Only the first failed request is captured.
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1, len(files))
+	testutil.Assert(t, strings.HasSuffix(files[0], "-0001-POST-400.json"), "unexpected file %s", files[0])
+
+	b, err := os.ReadFile(files[0])
+	testutil.Ok(t, err)
+	testutil.Assert(t, !strings.Contains(string(b), "secret"), "secrets must be redacted: %s", b)
+
+	var cr capturedRequest
+	testutil.Ok(t, json.Unmarshal(b, &cr))
+	testutil.Equals(t, srv.URL+"/fail?query=up&token=REDACTED", cr.URL)
+	testutil.Equals(t, redacted, cr.Headers["Authorization"])
+	testutil.Equals(t, "tenant-a", cr.Headers["X-Tenant"])
+	testutil.Equals(t, &capturedBody{Text: "up{}", Size: 4}, cr.Body)
+	testutil.Equals(t, "400 Bad Request", cr.Response.Status)
+	testutil.Equals(t, redacted, cr.Response.Headers["Set-Cookie"])
+	testutil.Equals(t, &capturedBody{Text: "bad request: up{", Size: 17, Truncated: true}, cr.Response.Body)
+}
@@ -0,0 +1,165 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	defaultCertReloads atomic.Pointer[prometheus.CounterVec]
+	defaultCertExpiry  atomic.Pointer[prometheus.GaugeVec]
+
+	certSourcesMtx sync.Mutex
+	certSources    = map[options.TLS]*certSource{}
+)
+
+// SetCertMetrics sets the counter the reloads of the client certificates are counted in, by result, and the gauge
+// the expiry of the client certificates is set in, by file. Without them, no reloads and expiries are observed.
+func SetCertMetrics(reloads *prometheus.CounterVec, expiry *prometheus.GaugeVec) {
+	defaultCertReloads.Store(reloads)
+	defaultCertExpiry.Store(expiry)
+}
+
+// certSource keeps the client certificate and the CA of the files of a TLS configuration, reloading them when the
+// files change, e.g. when cert-manager rotates them, rather than every time a transport is created.
+type certSource struct {
+	l   log.Logger
+	cfg options.TLS
+
+	mtx      sync.Mutex
+	loaded   bool
+	modTimes [3]time.Time
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+}
+
+// getCertSource returns the source of the files of the configuration, shared by all its transports.
+func getCertSource(l log.Logger, cfg options.TLS) *certSource {
+	certSourcesMtx.Lock()
+	defer certSourcesMtx.Unlock()
+
+	s, ok := certSources[cfg]
+	if !ok {
+		s = &certSource{l: log.With(l, "component", "tls"), cfg: cfg}
+		certSources[cfg] = s
+	}
+
+	return s
+}
+
+// load returns the certificate, if any, and the CA pool, reading the files again if they changed since they were
+// read last. The previous ones are kept if the changed files cannot be read, e.g. in the middle of a rotation, until
+// the files change again.
+func (s *certSource) load() (*tls.Certificate, *x509.CertPool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	modTimes := s.stat()
+	if s.loaded && modTimes == s.modTimes {
+		return s.cert, s.pool, nil
+	}
+
+	reload := s.loaded
+
+	cert, pool, err := s.read()
+	if err != nil {
+		if !reload {
+			return nil, nil, err
+		}
+
+		s.modTimes = modTimes
+		s.observeReload("failure")
+		level.Warn(s.l).Log("msg", "failed to reload client TLS certificates, keeping the previous ones", "err", err)
+
+		return s.cert, s.pool, nil
+	}
+
+	s.loaded, s.modTimes, s.cert, s.pool = true, modTimes, cert, pool
+
+	if reload {
+		s.observeReload("success")
+		level.Info(s.l).Log("msg", "reloaded client TLS certificates", "cert", s.cfg.Cert, "ca", s.cfg.CACert)
+	}
+
+	if expiry := defaultCertExpiry.Load(); expiry != nil && cert != nil {
+		expiry.WithLabelValues(s.cfg.Cert).Set(float64(cert.Leaf.NotAfter.Unix()))
+	}
+
+	return cert, pool, nil
+}
+
+func (s *certSource) observeReload(result string) {
+	if reloads := defaultCertReloads.Load(); reloads != nil {
+		reloads.WithLabelValues(result).Inc()
+	}
+}
+
+// stat returns the modification times of the certificate, the key and the CA, zero for files not set or missing.
+func (s *certSource) stat() [3]time.Time {
+	var res [3]time.Time
+
+	for i, name := range []string{s.cfg.Cert, s.cfg.Key, s.cfg.CACert} {
+		if name == "" {
+			continue
+		}
+
+		if fi, err := os.Stat(name); err == nil {
+			res[i] = fi.ModTime()
+		}
+	}
+
+	return res
+}
+
+func (s *certSource) read() (*tls.Certificate, *x509.CertPool, error) {
+	var certPool *x509.CertPool
+
+	if s.cfg.CACert != "" {
+		caPEM, err := os.ReadFile(s.cfg.CACert)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "reading client CA")
+		}
+
+		certPool = x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caPEM) {
+			return nil, nil, errors.New("building client CA")
+		}
+
+		level.Info(s.l).Log("msg", "TLS client using provided certificate pool")
+	} else {
+		var err error
+		certPool, err = x509.SystemCertPool()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "reading system certificate pool")
+		}
+
+		level.Info(s.l).Log("msg", "TLS client using system certificate pool")
+	}
+
+	if s.cfg.Cert == "" {
+		return nil, certPool, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.cfg.Cert, s.cfg.Key)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "client credentials")
+	}
+
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, nil, errors.Wrap(err, "parsing client certificate")
+	}
+
+	level.Info(s.l).Log("msg", "TLS client authentication enabled", "expiry", cert.Leaf.NotAfter)
+
+	return &cert, certPool, nil
+}
@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// writeCert writes a self-signed certificate expiring at the time and its key, modified at the time as well.
+func writeCert(t *testing.T, certFile, keyFile string, notAfter time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testutil.Ok(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "up"},
+		NotBefore:    notAfter.Add(-time.Hour),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	testutil.Ok(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	testutil.Ok(t, err)
+
+	testutil.Ok(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	testutil.Ok(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	for _, name := range []string{certFile, keyFile} {
+		testutil.Ok(t, os.Chtimes(name, notAfter, notAfter))
+	}
+}
+
+func TestNewTLSConfig_Reload(t *testing.T) {
+	reloads := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "reloads"}, []string{"result"})
+	expiry := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "expiry"}, []string{"file"})
+
+	SetCertMetrics(reloads, expiry)
+	defer SetCertMetrics(nil, nil)
+
+	dir := t.TempDir()
+	cfg := options.TLS{Cert: filepath.Join(dir, "tls.crt"), Key: filepath.Join(dir, "tls.key")}
+
+	first := time.Now().Add(time.Hour).Truncate(time.Second)
+	writeCert(t, cfg.Cert, cfg.Key, first)
+
+	tlsCfg, err := NewTLSConfig(log.NewNopLogger(), cfg)
+	testutil.Ok(t, err)
+
+	cert, err := tlsCfg.GetClientCertificate(nil)
+	testutil.Ok(t, err)
+	testutil.Equals(t, first.Unix(), cert.Leaf.NotAfter.Unix())
+	testutil.Equals(t, float64(first.Unix()), promtestutil.ToFloat64(expiry.WithLabelValues(cfg.Cert)))
+
+	// The rotated certificate is used by the existing configuration.
+	second := first.Add(time.Hour)
+	writeCert(t, cfg.Cert, cfg.Key, second)
+
+	cert, err = tlsCfg.GetClientCertificate(nil)
+	testutil.Ok(t, err)
+	testutil.Equals(t, second.Unix(), cert.Leaf.NotAfter.Unix())
+	testutil.Equals(t, float64(second.Unix()), promtestutil.ToFloat64(expiry.WithLabelValues(cfg.Cert)))
+	testutil.Equals(t, 1.0, promtestutil.ToFloat64(reloads.WithLabelValues("success")))
+
+	// The previous certificate is kept while the files cannot be loaded.
+	testutil.Ok(t, os.WriteFile(cfg.Key, []byte("rotating"), 0o600))
+	testutil.Ok(t, os.Chtimes(cfg.Key, second.Add(time.Hour), second.Add(time.Hour)))
+
+	cert, err = tlsCfg.GetClientCertificate(nil)
+	testutil.Ok(t, err)
+	testutil.Equals(t, second.Unix(), cert.Leaf.NotAfter.Unix())
+	testutil.Equals(t, 1.0, promtestutil.ToFloat64(reloads.WithLabelValues("failure")))
+
+	// Unchanged files are not loaded again.
+	_, err = NewTLSConfig(log.NewNopLogger(), cfg)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1.0, promtestutil.ToFloat64(reloads.WithLabelValues("failure")))
+}
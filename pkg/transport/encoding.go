@@ -0,0 +1,239 @@
+package transport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The directions of the encodings counted by the round trippers returned by InstrumentedRoundTripper.
+const (
+	RequestDirection  = "request"
+	ResponseDirection = "response"
+)
+
+var defaultRequestEncodings atomic.Pointer[prometheus.CounterVec]
+
+// SetRequestEncodings sets the counter the round trippers returned by InstrumentedRoundTripper count the content
+// encodings of the bodies of requests and responses in, by the host of the endpoint, the direction and the encoding.
+// Without counter, no encodings are counted.
+func SetRequestEncodings(c *prometheus.CounterVec) {
+	defaultRequestEncodings.Store(c)
+}
+
+// SetEncodings sets the encodings of the requests to the endpoint, and all URLs below it, made by the round trippers
+// returned by InstrumentedRoundTripper. The bodies of requests are encoded again in the Write encoding, the Read
+// one is accepted for responses, which are decoded transparently.
+func SetEncodings(endpoint *url.URL, encs options.Encodings) {
+	updateEndpoint(endpoint, func(e *endpointConfig) {
+		if encs.Write != "" {
+			e.encodings.Write = encs.Write
+		}
+
+		if encs.Read != "" {
+			e.encodings.Read = encs.Read
+		}
+	})
+}
+
+func encodingsOf(u *url.URL) options.Encodings {
+	e, _ := endpointOf(u)
+
+	return e.encodings
+}
+
+type encodingRoundTripper struct {
+	r http.RoundTripper
+}
+
+func (e *encodingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	encs := encodingsOf(req.URL)
+	counter := defaultRequestEncodings.Load()
+
+	if req.Body != nil && req.Body != http.NoBody && encs.Write != "" {
+		r, err := encodeRequest(req, encs.Write)
+		if err != nil {
+			return nil, err
+		}
+
+		req = r
+	}
+
+	if encs.Read != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", string(encs.Read))
+	}
+
+	if counter != nil && req.Body != nil && req.Body != http.NoBody {
+		counter.WithLabelValues(req.URL.Host, RequestDirection, encodingName(req.Header.Get("Content-Encoding"))).Inc()
+	}
+
+	resp, err := e.r.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	enc := encodingName(resp.Header.Get("Content-Encoding"))
+	// The transport decodes gzip transparently if it requested it itself.
+	if resp.Uncompressed {
+		enc = string(options.GzipContentEncoding)
+	}
+
+	if counter != nil {
+		counter.WithLabelValues(req.URL.Host, ResponseDirection, enc).Inc()
+	}
+
+	if encs.Read != "" && enc != string(options.IdentityContentEncoding) && !resp.Uncompressed {
+		if err := decodeResponse(req.URL.Host, resp, options.ContentEncoding(enc)); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+// encodeRequest returns the request with the body decoded from its Content-Encoding and encoded in the encoding.
+func encodeRequest(req *http.Request, enc options.ContentEncoding) (*http.Request, error) {
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "reading request body")
+	}
+
+	if b, err = decode(options.ContentEncoding(encodingName(req.Header.Get("Content-Encoding"))), b, 0); err != nil {
+		return nil, errors.Wrap(err, "decoding request body")
+	}
+
+	if b, err = encode(enc, b); err != nil {
+		return nil, errors.Wrapf(err, "encoding request body as %s", enc)
+	}
+
+	r := req.Clone(req.Context())
+	r.Body, r.ContentLength = io.NopCloser(bytes.NewReader(b)), int64(len(b))
+	r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(b)), nil }
+
+	if enc == options.IdentityContentEncoding {
+		r.Header.Del("Content-Encoding")
+	} else {
+		r.Header.Set("Content-Encoding", string(enc))
+	}
+
+	return r, nil
+}
+
+// decodeResponse replaces the body of the response with the one decoded from the encoding, decoding at most the maximum
+// size of SetMaxResponseSize.
+func decodeResponse(endpoint string, resp *http.Response, enc options.ContentEncoding) error {
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if err != nil {
+		return errors.Wrap(err, "reading response body")
+	}
+
+	limit := defaultResponseLimit.Load()
+
+	if b, err = decode(enc, b, limit.maxSize()); err != nil {
+		if errors.Is(err, ErrResponseTooLarge) {
+			limit.count(endpoint)
+		}
+
+		return errors.Wrapf(err, "decoding response body of %s", enc)
+	}
+
+	resp.Body, resp.ContentLength, resp.Uncompressed = io.NopCloser(bytes.NewReader(b)), int64(len(b)), true
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(b)))
+
+	return nil
+}
+
+// encodingName returns the encoding of a Content-Encoding header, identity if it is empty.
+func encodingName(h string) string {
+	if h == "" {
+		return string(options.IdentityContentEncoding)
+	}
+
+	return strings.ToLower(h)
+}
+
+func encode(enc options.ContentEncoding, b []byte) ([]byte, error) {
+	switch enc {
+	case options.IdentityContentEncoding:
+		return b, nil
+	case options.GzipContentEncoding:
+		var buf bytes.Buffer
+
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	case options.SnappyContentEncoding:
+		return snappy.Encode(nil, b), nil
+	case options.ZstdContentEncoding:
+		w, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer w.Close()
+
+		return w.EncodeAll(b, nil), nil
+	}
+
+	return nil, errors.Errorf("unsupported content encoding %q", enc)
+}
+
+// decode decodes the bytes from the encoding, failing with ErrResponseTooLarge decoding more than max bytes, if positive.
+func decode(enc options.ContentEncoding, b []byte, max int64) ([]byte, error) {
+	switch enc {
+	case options.IdentityContentEncoding:
+		return b, nil
+	case options.GzipContentEncoding:
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+
+		return readAtMost(r, max)
+	case options.SnappyContentEncoding:
+		// The decoded length is in the header of the block, so larger blocks are not decoded at all.
+		n, err := snappy.DecodedLen(b)
+		if err != nil {
+			return nil, err
+		}
+
+		if max > 0 && int64(n) > max {
+			return nil, tooLarge(max)
+		}
+
+		return snappy.Decode(nil, b)
+	case options.ZstdContentEncoding:
+		r, err := zstd.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		return readAtMost(r, max)
+	}
+
+	return nil, errors.Errorf("unsupported content encoding %q", enc)
+}
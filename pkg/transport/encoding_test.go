@@ -0,0 +1,83 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/golang/snappy"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestEncodingRoundTripper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/receive" {
+			testutil.Equals(t, "zstd", r.Header.Get("Content-Encoding"))
+
+			b, err := io.ReadAll(r.Body)
+			testutil.Ok(t, err)
+
+			b, err = decode(options.ZstdContentEncoding, b, 0)
+			testutil.Ok(t, err)
+			testutil.Equals(t, "write request", string(b))
+
+			return
+		}
+
+		testutil.Equals(t, "zstd", r.Header.Get("Accept-Encoding"))
+
+		b, err := encode(options.ZstdContentEncoding, []byte(`{"status":"success"}`))
+		testutil.Ok(t, err)
+
+		w.Header().Set("Content-Encoding", "zstd")
+		w.Write(b) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	write, err := url.Parse(srv.URL + "/api/v1/receive")
+	testutil.Ok(t, err)
+
+	read, err := url.Parse(srv.URL + "/api/v1/query")
+	testutil.Ok(t, err)
+
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"}, []string{"endpoint", "direction", "encoding"})
+
+	SetRequestEncodings(c)
+	SetEncodings(write, options.Encodings{Write: options.ZstdContentEncoding})
+	SetEncodings(read, options.Encodings{Read: options.ZstdContentEncoding})
+
+	defer func() {
+		SetRequestEncodings(nil)
+		endpoints.Store(nil)
+	}()
+
+	client := &http.Client{Transport: InstrumentedRoundTripper(nil)}
+
+	// The snappy encoding of remote-write is replaced.
+	req, err := http.NewRequest(http.MethodPost, write.String(), bytes.NewReader(snappy.Encode(nil, []byte("write request"))))
+	testutil.Ok(t, err)
+	req.Header.Set("Content-Encoding", "snappy")
+
+	resp, err := client.Do(req)
+	testutil.Ok(t, err)
+	testutil.Ok(t, resp.Body.Close())
+
+	resp, err = client.Get(read.String() + "?query=up")
+	testutil.Ok(t, err)
+
+	b, err := io.ReadAll(resp.Body)
+	testutil.Ok(t, err)
+	testutil.Ok(t, resp.Body.Close())
+	testutil.Equals(t, `{"status":"success"}`, string(b))
+
+	host := write.Host
+	testutil.Equals(t, 1.0, promtestutil.ToFloat64(c.WithLabelValues(host, RequestDirection, "zstd")))
+	testutil.Equals(t, 1.0, promtestutil.ToFloat64(c.WithLabelValues(host, ResponseDirection, "identity")))
+	testutil.Equals(t, 1.0, promtestutil.ToFloat64(c.WithLabelValues(host, ResponseDirection, "zstd")))
+}
@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/observatorium/up/pkg/options"
+)
+
+var (
+	endpointsMtx sync.Mutex
+	endpoints    atomic.Pointer[[]endpointConfig]
+)
+
+// endpointConfig is the configuration of the requests to an endpoint and the URLs below it.
+type endpointConfig struct {
+	url       *url.URL
+	host      string
+	headers   map[string]string
+	encodings options.Encodings
+	proxy     *url.URL
+}
+
+// matches returns the length of the path of the endpoint if the URL is the one of the endpoint or below it, -1 if not.
+// Paths only match at the boundaries of their segments, e.g. /api/v1 does not match /api/v1beta.
+func (e endpointConfig) matches(u *url.URL) int {
+	if !strings.EqualFold(e.url.Scheme, u.Scheme) || !strings.EqualFold(e.url.Host, u.Host) {
+		return -1
+	}
+
+	p := strings.TrimSuffix(e.url.Path, "/")
+	if u.Path != p && !strings.HasPrefix(u.Path, p+"/") {
+		return -1
+	}
+
+	return len(p)
+}
+
+// updateEndpoint updates the configuration of the endpoint, adding it if it was not configured yet.
+func updateEndpoint(u *url.URL, update func(e *endpointConfig)) {
+	endpointsMtx.Lock()
+	defer endpointsMtx.Unlock()
+
+	var es []endpointConfig
+	if p := endpoints.Load(); p != nil {
+		es = append(es, *p...)
+	}
+
+	for i, e := range es {
+		// The URL is below the endpoint with a path as long as its own only if it is the endpoint.
+		if e.matches(u) == len(strings.TrimSuffix(u.Path, "/")) {
+			update(&es[i])
+			endpoints.Store(&es)
+
+			return
+		}
+	}
+
+	e := endpointConfig{url: u}
+	update(&e)
+
+	es = append(es, e)
+	endpoints.Store(&es)
+}
+
+// endpointOf returns the configuration of the endpoint of the URL. The write and read endpoints can share a prefix,
+// e.g. the write endpoint of the Observatorium API is below the read one, so only the endpoint with the longest path
+// applies.
+func endpointOf(u *url.URL) (endpointConfig, bool) {
+	p := endpoints.Load()
+	if p == nil {
+		return endpointConfig{}, false
+	}
+
+	var (
+		res     endpointConfig
+		longest = -1
+	)
+
+	for _, e := range *p {
+		if n := e.matches(u); n > longest {
+			res, longest = e, n
+		}
+	}
+
+	return res, longest >= 0
+}
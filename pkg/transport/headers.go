@@ -0,0 +1,42 @@
+package transport
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// SetHostHeader sets the Host header of the requests to the endpoint, and all URLs below it, made by the round
+// trippers returned by InstrumentedRoundTripper, e.g. to probe a virtual host of an ingress controller at its address.
+// The certificates of servers are still verified against the host of the endpoint.
+func SetHostHeader(endpoint *url.URL, host string) {
+	updateEndpoint(endpoint, func(e *endpointConfig) { e.host = host })
+}
+
+// SetHeaders sets the headers of the requests to the endpoint, and all URLs below it, made by the round trippers
+// returned by InstrumentedRoundTripper, replacing existing values.
+func SetHeaders(endpoint *url.URL, headers map[string]string) {
+	updateEndpoint(endpoint, func(e *endpointConfig) { e.headers = headers })
+}
+
+type headersRoundTripper struct {
+	r http.RoundTripper
+}
+
+func (h *headersRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	e, ok := endpointOf(req.URL)
+	if !ok || (e.host == "" && len(e.headers) == 0) {
+		return h.r.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+
+	if e.host != "" {
+		req.Host = e.host
+	}
+
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	return h.r.RoundTrip(req)
+}
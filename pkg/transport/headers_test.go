@@ -0,0 +1,82 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+)
+
+func TestHeadersRoundTripper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + " " + r.Header.Get("X-Canary"))) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL + "/api/v1")
+	testutil.Ok(t, err)
+
+	SetHostHeader(u, "receive.example")
+	SetHeaders(u, map[string]string{"X-Canary": "true"})
+	defer endpoints.Store(nil)
+
+	client := &http.Client{Transport: InstrumentedRoundTripper(nil)}
+
+	for _, tc := range []struct {
+		url      string
+		expected string
+	}{
+		{url: srv.URL + "/api/v1/receive", expected: "receive.example true"},
+		// Other URLs of the server are requested with their own host and headers.
+		{url: srv.URL + "/metrics", expected: u.Host + " "},
+	} {
+		resp, err := client.Get(tc.url)
+		testutil.Ok(t, err)
+
+		b, err := io.ReadAll(resp.Body)
+		testutil.Ok(t, err)
+		testutil.Ok(t, resp.Body.Close())
+		testutil.Equals(t, tc.expected, string(b))
+	}
+}
+
+func TestHeadersRoundTripper_NestedEndpoints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Api-Key"))) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	// The write endpoint of the Observatorium API is below the read one.
+	read, err := url.Parse(srv.URL + "/api/metrics/v1/tenant")
+	testutil.Ok(t, err)
+
+	write, err := url.Parse(srv.URL + "/api/metrics/v1/tenant/api/v1/receive")
+	testutil.Ok(t, err)
+
+	SetHeaders(write, map[string]string{"X-Api-Key": "w"})
+	SetHeaders(read, map[string]string{"X-Api-Key": "r"})
+	defer endpoints.Store(nil)
+
+	client := &http.Client{Transport: InstrumentedRoundTripper(nil)}
+
+	for _, tc := range []struct {
+		url      string
+		expected string
+	}{
+		{url: write.String(), expected: "w"},
+		{url: read.String() + "/api/v1/query", expected: "r"},
+		// Paths only match at the boundaries of their segments.
+		{url: srv.URL + "/api/metrics/v1/tenant2/api/v1/query", expected: ""},
+	} {
+		resp, err := client.Get(tc.url)
+		testutil.Ok(t, err)
+
+		b, err := io.ReadAll(resp.Body)
+		testutil.Ok(t, err)
+		testutil.Ok(t, resp.Body.Close())
+		testutil.Equals(t, tc.expected, string(b))
+	}
+}
@@ -0,0 +1,120 @@
+package transport
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrResponseTooLarge is returned reading a response body beyond the maximum size of SetMaxResponseSize.
+var ErrResponseTooLarge = errors.New("response body too large")
+
+type responseLimit struct {
+	max      int64
+	exceeded *prometheus.CounterVec
+}
+
+var defaultResponseLimit atomic.Pointer[responseLimit]
+
+// SetMaxResponseSize sets the maximum size in bytes of the response bodies read through the round trippers returned by
+// InstrumentedRoundTripper, both before and after they are decoded, and the counter the responses exceeding it are
+// counted in, by the host of the endpoint. Reading or decoding beyond the maximum fails with ErrResponseTooLarge.
+// Without maximum, the size is not limited.
+func SetMaxResponseSize(max int64, exceeded *prometheus.CounterVec) {
+	if max <= 0 {
+		defaultResponseLimit.Store(nil)
+		return
+	}
+
+	defaultResponseLimit.Store(&responseLimit{max: max, exceeded: exceeded})
+}
+
+// maxSize returns the maximum size, 0 without limit.
+func (l *responseLimit) maxSize() int64 {
+	if l == nil {
+		return 0
+	}
+
+	return l.max
+}
+
+func (l *responseLimit) count(endpoint string) {
+	if l != nil && l.exceeded != nil {
+		l.exceeded.WithLabelValues(endpoint).Inc()
+	}
+}
+
+func tooLarge(max int64) error {
+	return fmt.Errorf("reading more than %d bytes: %w", max, ErrResponseTooLarge)
+}
+
+// readAtMost reads the reader to its end, failing with ErrResponseTooLarge reading more than max bytes, if positive.
+func readAtMost(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(b)) > max {
+		return nil, tooLarge(max)
+	}
+
+	return b, nil
+}
+
+type sizeLimitRoundTripper struct {
+	r http.RoundTripper
+}
+
+func (rt *sizeLimitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.r.RoundTrip(req)
+
+	limit := defaultResponseLimit.Load()
+	if err != nil || limit == nil {
+		return resp, err
+	}
+
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: limit.max, limit: limit, endpoint: req.URL.Host}
+
+	return resp, nil
+}
+
+// limitedBody fails reading more than remaining bytes, counting the response once.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limit     *responseLimit
+	endpoint  string
+	err       error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	// Read one byte more than remaining to tell bodies of exactly the maximum size from larger ones.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return n, err
+	}
+
+	n, b.remaining = int(b.remaining), 0
+	b.err = tooLarge(b.limit.max)
+	b.limit.count(b.endpoint)
+
+	return n, b.err
+}
@@ -0,0 +1,103 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSizeLimitRoundTripper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", len(r.URL.Path)-1))) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	testutil.Ok(t, err)
+
+	exceeded := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"}, []string{"endpoint"})
+
+	SetMaxResponseSize(4, exceeded)
+	defer SetMaxResponseSize(0, nil)
+
+	client := &http.Client{Transport: InstrumentedRoundTripper(nil)}
+
+	for i, tc := range []struct {
+		path    string
+		tooLong bool
+	}{
+		{path: "/xxx"},
+		// A body of exactly the maximum size is read.
+		{path: "/xxxx"},
+		{path: "/xxxxx", tooLong: true},
+		{path: "/" + strings.Repeat("x", 64*1024), tooLong: true},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			resp, err := client.Get(srv.URL + tc.path)
+			testutil.Ok(t, err)
+
+			b, err := io.ReadAll(resp.Body)
+			testutil.Ok(t, resp.Body.Close())
+
+			if tc.tooLong {
+				testutil.Assert(t, errors.Is(err, ErrResponseTooLarge), "expected the response to be too large, got %v", err)
+				testutil.Equals(t, 4, len(b))
+
+				return
+			}
+
+			testutil.Ok(t, err)
+			testutil.Equals(t, len(tc.path)-1, len(b))
+		})
+	}
+
+	testutil.Equals(t, 2.0, promtestutil.ToFloat64(exceeded.WithLabelValues(u.Host)))
+}
+
+func TestSizeLimitRoundTripper_Decoded(t *testing.T) {
+	for _, enc := range []options.ContentEncoding{
+		options.GzipContentEncoding,
+		options.SnappyContentEncoding,
+		options.ZstdContentEncoding,
+	} {
+		t.Run(string(enc), func(t *testing.T) {
+			// The compressed body is below the maximum, the decoded one is not.
+			b, err := encode(enc, []byte(strings.Repeat("x", 64*1024)))
+			testutil.Ok(t, err)
+			testutil.Assert(t, len(b) < 4096, "expected the compressed body to be below the maximum, got %d bytes", len(b))
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", string(enc))
+				w.Write(b) //nolint:errcheck
+			}))
+			defer srv.Close()
+
+			u, err := url.Parse(srv.URL)
+			testutil.Ok(t, err)
+
+			exceeded := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"}, []string{"endpoint"})
+
+			SetMaxResponseSize(4096, exceeded)
+			SetEncodings(u, options.Encodings{Read: enc})
+
+			defer func() {
+				SetMaxResponseSize(0, nil)
+				endpoints.Store(nil)
+			}()
+
+			_, err = (&http.Client{Transport: InstrumentedRoundTripper(nil)}).Get(srv.URL)
+			testutil.Assert(t, errors.Is(err, ErrResponseTooLarge), "expected the response to be too large, got %v", err)
+			testutil.Equals(t, 1.0, promtestutil.ToFloat64(exceeded.WithLabelValues(u.Host)))
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"net/http"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/observatorium/up/pkg/options"
+	"golang.org/x/net/http/httpproxy"
+)
+
+var defaultProxy atomic.Pointer[func(*url.URL) (*url.URL, error)]
+
+// SetProxy sets the proxies of the transports returned by NewTLSTransport and of the default transport of http,
+// overriding the ones of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables. The proxies that are not
+// set are the ones of the environment.
+func SetProxy(p options.Proxy) {
+	cfg := httpproxy.FromEnvironment()
+
+	if p.HTTP != "" {
+		cfg.HTTPProxy = p.HTTP
+	}
+
+	if p.HTTPS != "" {
+		cfg.HTTPSProxy = p.HTTPS
+	}
+
+	if p.NoProxy != "" {
+		cfg.NoProxy = p.NoProxy
+	}
+
+	f := cfg.ProxyFunc()
+	defaultProxy.Store(&f)
+
+	// The plain HTTP endpoints are called with the default transport.
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.Proxy = proxy
+	}
+}
+
+// SetEndpointProxy sets the proxy of the requests to the endpoint, and all URLs below it, made by the transports
+// returned by NewTLSTransport and the default transport of http, overriding the ones of SetProxy and the environment,
+// e.g. to request the write and read endpoints through different proxies.
+func SetEndpointProxy(endpoint, proxyURL *url.URL) {
+	updateEndpoint(endpoint, func(e *endpointConfig) { e.proxy = proxyURL })
+
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.Proxy = proxy
+	}
+}
+
+// proxy returns the proxy of the request, the one of the environment without proxies set.
+func proxy(req *http.Request) (*url.URL, error) {
+	if e, ok := endpointOf(req.URL); ok && e.proxy != nil {
+		return e.proxy, nil
+	}
+
+	if f := defaultProxy.Load(); f != nil {
+		return (*f)(req.URL)
+	}
+
+	return http.ProxyFromEnvironment(req)
+}
@@ -0,0 +1,57 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/observatorium/up/pkg/options"
+)
+
+func TestSetProxy(t *testing.T) {
+	SetProxy(options.Proxy{HTTP: "http://http-proxy:3128", HTTPS: "http://https-proxy:3128", NoProxy: ".svc"})
+	defer defaultProxy.Store(nil)
+
+	for endpoint, p := range map[string]string{
+		"https://read.example/api/metrics/v1/tenant":                "http://read-proxy:3128",
+		"https://read.example/api/metrics/v1/tenant/api/v1/receive": "http://write-proxy:3128",
+	} {
+		e, err := url.Parse(endpoint)
+		testutil.Ok(t, err)
+
+		u, err := url.Parse(p)
+		testutil.Ok(t, err)
+
+		SetEndpointProxy(e, u)
+	}
+	defer endpoints.Store(nil)
+
+	for i, tc := range []struct {
+		url      string
+		expected string
+	}{
+		{url: "http://read.example/api/v1/query", expected: "http://http-proxy:3128"},
+		{url: "https://write.example/api/v1/receive", expected: "http://https-proxy:3128"},
+		{url: "https://thanos-receive.monitoring.svc/api/v1/receive"},
+		// The proxy of the endpoint overrides the one of its scheme.
+		{url: "https://read.example/api/metrics/v1/tenant/api/v1/query", expected: "http://read-proxy:3128"},
+		{url: "https://read.example/api/metrics/v1/tenant/api/v1/receive", expected: "http://write-proxy:3128"},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			testutil.Ok(t, err)
+
+			u, err := proxy(req)
+			testutil.Ok(t, err)
+
+			if tc.expected == "" {
+				testutil.Assert(t, u == nil, "unexpected proxy %v", u)
+				return
+			}
+
+			testutil.Equals(t, tc.expected, u.String())
+		})
+	}
+}
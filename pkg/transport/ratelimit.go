@@ -0,0 +1,150 @@
+package transport
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	defaultLimiter   atomic.Pointer[limiter]
+	defaultThrottled atomic.Pointer[prometheus.CounterVec]
+)
+
+// SetRateLimit sets the rate limits of the round trippers returned by InstrumentedRoundTripper and the counter the
+// requests that waited for the limits are counted in, by the host of the endpoint. Every attempt of retried requests
+// is limited. Without limits, requests are not limited.
+func SetRateLimit(l options.RateLimit, throttled *prometheus.CounterVec) {
+	defaultLimiter.Store(newLimiter(l))
+	defaultThrottled.Store(throttled)
+}
+
+// bucket is a token bucket, filled at the rate up to the burst.
+type bucket struct {
+	rate  float64
+	burst float64
+
+	mtx    sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, burst int) *bucket {
+	if rate <= 0 {
+		return nil
+	}
+
+	b := float64(burst)
+	if burst <= 0 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+
+	return &bucket{rate: rate, burst: b, tokens: b}
+}
+
+// reserve takes a token of the bucket, returning how long to wait until it is available.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+
+	b.last = now
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a token of a request that did not wait for it.
+func (b *bucket) cancel() {
+	if b == nil {
+		return
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+type limiter struct {
+	cfg    options.RateLimit
+	global *bucket
+
+	mtx       sync.Mutex
+	endpoints map[string]*bucket
+}
+
+func newLimiter(cfg options.RateLimit) *limiter {
+	if cfg.Global <= 0 && cfg.PerEndpoint <= 0 {
+		return nil
+	}
+
+	return &limiter{cfg: cfg, global: newBucket(cfg.Global, cfg.Burst), endpoints: map[string]*bucket{}}
+}
+
+func (l *limiter) endpoint(host string) *bucket {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	b, ok := l.endpoints[host]
+	if !ok {
+		b = newBucket(l.cfg.PerEndpoint, l.cfg.Burst)
+		l.endpoints[host] = b
+	}
+
+	return b
+}
+
+type rateLimitRoundTripper struct {
+	r http.RoundTripper
+}
+
+func (rt *rateLimitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	l := defaultLimiter.Load()
+	if l == nil {
+		return rt.r.RoundTrip(req)
+	}
+
+	now := time.Now()
+	endpoint := l.endpoint(req.URL.Host)
+
+	wait := l.global.reserve(now)
+	if w := endpoint.reserve(now); w > wait {
+		wait = w
+	}
+
+	if wait > 0 {
+		if throttled := defaultThrottled.Load(); throttled != nil {
+			throttled.WithLabelValues(req.URL.Host).Inc()
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			t.Stop()
+			l.global.cancel()
+			endpoint.cancel()
+
+			return nil, req.Context().Err()
+		case <-t.C:
+		}
+	}
+
+	return rt.r.RoundTrip(req)
+}
@@ -0,0 +1,68 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBucket(t *testing.T) {
+	b := newBucket(10, 2)
+	now := time.Now()
+
+	// The burst is available at once, then a token every 100ms.
+	testutil.Equals(t, time.Duration(0), b.reserve(now))
+	testutil.Equals(t, time.Duration(0), b.reserve(now))
+	testutil.Equals(t, 100*time.Millisecond, b.reserve(now))
+	testutil.Equals(t, 200*time.Millisecond, b.reserve(now))
+
+	b.cancel()
+	testutil.Equals(t, 150*time.Millisecond, b.reserve(now.Add(50*time.Millisecond)))
+
+	// No limit without rate.
+	testutil.Equals(t, time.Duration(0), newBucket(0, 0).reserve(now))
+}
+
+func TestRateLimitRoundTripper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	testutil.Ok(t, err)
+
+	throttled := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"}, []string{"endpoint"})
+
+	SetRateLimit(options.RateLimit{PerEndpoint: 20, Burst: 1}, throttled)
+	defer SetRateLimit(options.RateLimit{}, nil)
+
+	client := &http.Client{Transport: InstrumentedRoundTripper(nil)}
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL)
+		testutil.Ok(t, err)
+		testutil.Ok(t, resp.Body.Close())
+	}
+
+	testutil.Assert(t, time.Since(start) >= 90*time.Millisecond, "requests must wait for the limit")
+	testutil.Equals(t, 2.0, promtestutil.ToFloat64(throttled.WithLabelValues(u.Host)))
+
+	// Requests give up waiting with their context.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	testutil.Ok(t, err)
+
+	_, err = client.Do(req)
+	testutil.NotOk(t, err)
+}
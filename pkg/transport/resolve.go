@@ -0,0 +1,100 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/observatorium/up/pkg/options"
+)
+
+// resolver dials the static addresses of hosts instead of resolving them, and resolves the others with the dialer,
+// over the network of the address family, if any.
+type resolver struct {
+	static  map[string]string
+	dialer  *net.Dialer
+	network string
+}
+
+var (
+	defaultDialer = &net.Dialer{
+		Timeout:   DefaultTimeouts.Dial,
+		KeepAlive: 30 * time.Second,
+	}
+
+	defaultResolver atomic.Pointer[resolver]
+)
+
+// SetResolver sets the static addresses, the DNS server and the address family of the hosts dialed by the transports
+// returned by NewTLSTransport, the default transport of http and DialContext, e.g. to probe one replica behind a
+// shared host or one family of a dual-stack host. The certificates of servers are still verified against the host.
+func SetResolver(r options.Resolver) {
+	d := *defaultDialer
+
+	if r.DNSServer != "" {
+		d.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return defaultDialer.DialContext(ctx, network, r.DNSServer)
+			},
+		}
+	}
+
+	res := &resolver{static: r.Static, dialer: &d}
+
+	switch r.IPProtocol {
+	case options.IP4Protocol:
+		res.network = "tcp4"
+	case options.IP6Protocol:
+		res.network = "tcp6"
+	}
+
+	defaultResolver.Store(res)
+
+	// The plain HTTP endpoints are called with the default transport.
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.DialContext = dial
+	}
+}
+
+// DialContext dials the address over TCP like the transports of up, for clients that are not HTTP, e.g. gRPC.
+func DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	return dial(ctx, "tcp", addr)
+}
+
+func dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	r := defaultResolver.Load()
+	if r == nil {
+		return withTimeout(defaultDialer).DialContext(ctx, network, addr)
+	}
+
+	if ip, ok := r.static[addr]; ok {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		addr = net.JoinHostPort(ip, port)
+	}
+
+	if r.network != "" && network == "tcp" {
+		network = r.network
+	}
+
+	return withTimeout(r.dialer).DialContext(ctx, network, addr)
+}
+
+// withTimeout returns the dialer with the dial timeout of SetTimeouts, if set.
+func withTimeout(d *net.Dialer) *net.Dialer {
+	t := defaultTimeouts.Load()
+	if t == nil {
+		return d
+	}
+
+	c := *d
+	c.Timeout = t.Dial
+
+	return &c
+}
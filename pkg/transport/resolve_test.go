@@ -0,0 +1,60 @@
+package transport
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/observatorium/up/pkg/options"
+)
+
+func TestSetResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	testutil.Ok(t, err)
+
+	_, port, err := net.SplitHostPort(u.Host)
+	testutil.Ok(t, err)
+
+	SetResolver(options.Resolver{Static: map[string]string{net.JoinHostPort("receive.example", port): "127.0.0.1"}})
+	defer defaultResolver.Store(nil)
+
+	client := &http.Client{Transport: &http.Transport{DialContext: dial}}
+
+	// The host is requested at the static address.
+	resp, err := client.Get("http://" + net.JoinHostPort("receive.example", port))
+	testutil.Ok(t, err)
+	testutil.Ok(t, resp.Body.Close())
+	testutil.Equals(t, http.StatusOK, resp.StatusCode)
+
+	// Other ports of the host are resolved.
+	_, err = client.Get("http://receive.example:1")
+	testutil.NotOk(t, err)
+}
+
+func TestSetResolver_IPProtocol(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	SetResolver(options.Resolver{IPProtocol: options.IP6Protocol})
+	defer defaultResolver.Store(nil)
+
+	client := &http.Client{Transport: &http.Transport{DialContext: dial}}
+
+	// The IPv4 address of the server is not dialed over IPv6.
+	_, err := client.Get(srv.URL)
+	testutil.NotOk(t, err)
+
+	SetResolver(options.Resolver{IPProtocol: options.IP4Protocol})
+
+	resp, err := client.Get(srv.URL)
+	testutil.Ok(t, err)
+	testutil.Ok(t, resp.Body.Close())
+}
@@ -0,0 +1,119 @@
+package transport
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	defaultRetry   atomic.Pointer[options.Retry]
+	defaultRetries atomic.Pointer[prometheus.CounterVec]
+)
+
+// SetRetry sets the retry policy of the round trippers returned by InstrumentedRoundTripper and the counter the
+// retries are counted in, by the host of the endpoint and the reason, the status code or 'error'. Without policy,
+// requests are not retried.
+func SetRetry(r *options.Retry, retries *prometheus.CounterVec) {
+	defaultRetry.Store(r)
+	defaultRetries.Store(retries)
+}
+
+type retryRoundTripper struct {
+	r http.RoundTripper
+}
+
+func (rt *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	policy := defaultRetry.Load()
+
+	// Requests whose body cannot be gotten again are not retried.
+	if policy == nil || policy.Max == 0 || !policy.Methods.Contains(req.Method) ||
+		(req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return rt.r.RoundTrip(req)
+	}
+
+	start := time.Now()
+	body := req.Body
+
+	for attempt := 0; ; attempt++ {
+		// Every attempt gets its own clone of the request, so headers set down the chain are not sent twice.
+		r := req.Clone(req.Context())
+		r.Body = body
+
+		resp, err := rt.r.RoundTrip(r)
+
+		reason := retryReason(req.Context(), policy, resp, err)
+		if reason == "" || attempt == policy.Max {
+			return resp, err
+		}
+
+		wait := backoff(policy, attempt, resp)
+		if policy.Budget > 0 && time.Since(start)+wait > policy.Budget {
+			return resp, err
+		}
+
+		if resp != nil {
+			ExhaustCloseWithLogOnErr(log.NewNopLogger(), resp.Body)
+		}
+
+		if retries := defaultRetries.Load(); retries != nil {
+			retries.WithLabelValues(req.URL.Host, reason).Inc()
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			t.Stop()
+			return nil, req.Context().Err()
+		case <-t.C:
+		}
+
+		if req.GetBody != nil {
+			if body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// retryReason returns why the request is retried, empty if it is not.
+func retryReason(ctx context.Context, policy *options.Retry, resp *http.Response, err error) string {
+	switch {
+	case ctx.Err() != nil:
+		return ""
+	case err != nil:
+		return "error"
+	case policy.StatusCodes.Contains(resp.StatusCode):
+		return strconv.Itoa(resp.StatusCode)
+	}
+
+	return ""
+}
+
+// backoff returns the jittered exponential backoff of the attempt, at least the Retry-After of the response.
+func backoff(policy *options.Retry, attempt int, resp *http.Response) time.Duration {
+	d := policy.MinBackoff << attempt
+	if d > policy.MaxBackoff || d <= 0 {
+		d = policy.MaxBackoff
+	}
+
+	// Full jitter in the upper half keeps the backoff growing while spreading the retries of replicas.
+	d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1)) //nolint:gosec
+
+	if resp != nil {
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			if after := time.Duration(s) * time.Second; after > d {
+				d = after
+			}
+		}
+	}
+
+	return d
+}
@@ -0,0 +1,121 @@
+package transport
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRetryRoundTripper(t *testing.T) {
+	for i, tc := range []struct {
+		method   string
+		failures int
+		budget   time.Duration
+		attempts int
+		status   int
+	}{
+		{method: http.MethodGet, failures: 2, attempts: 3, status: http.StatusOK},
+		// The retries are exhausted.
+		{method: http.MethodGet, failures: 5, attempts: 4, status: http.StatusServiceUnavailable},
+		// The body is sent again.
+		{method: http.MethodPut, failures: 1, attempts: 2, status: http.StatusOK},
+		// The method is not idempotent.
+		{method: http.MethodPost, failures: 1, attempts: 1, status: http.StatusServiceUnavailable},
+		// The backoff exceeds the budget.
+		{method: http.MethodGet, failures: 1, budget: time.Millisecond, attempts: 1, status: http.StatusServiceUnavailable},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			attempts := 0
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+
+				if r.Method == http.MethodPut {
+					b, err := io.ReadAll(r.Body)
+					testutil.Ok(t, err)
+					testutil.Equals(t, "body", string(b))
+				}
+
+				if attempts <= tc.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			defer srv.Close()
+
+			retries := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"}, []string{"endpoint", "reason"})
+
+			SetRetry(&options.Retry{
+				Max:         3,
+				StatusCodes: []int{http.StatusServiceUnavailable},
+				Methods:     []string{http.MethodGet, http.MethodPut},
+				MinBackoff:  10 * time.Millisecond,
+				MaxBackoff:  20 * time.Millisecond,
+				Budget:      tc.budget,
+			}, retries)
+			defer SetRetry(nil, nil)
+
+			req, err := http.NewRequest(tc.method, srv.URL, strings.NewReader("body"))
+			testutil.Ok(t, err)
+
+			resp, err := (&http.Client{Transport: InstrumentedRoundTripper(nil)}).Do(req)
+			testutil.Ok(t, err)
+			testutil.Ok(t, resp.Body.Close())
+
+			u, err := url.Parse(srv.URL)
+			testutil.Ok(t, err)
+
+			testutil.Equals(t, tc.status, resp.StatusCode)
+			testutil.Equals(t, tc.attempts, attempts)
+			testutil.Equals(t, float64(tc.attempts-1), promtestutil.ToFloat64(retries.WithLabelValues(u.Host, "503")))
+		})
+	}
+}
+
+func TestRetryRoundTripper_Authorization(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		t.Run(method, func(t *testing.T) {
+			var authorization []string
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authorization = r.Header.Values("Authorization")
+
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer srv.Close()
+
+			SetRetry(&options.Retry{
+				Max:         2,
+				StatusCodes: []int{http.StatusServiceUnavailable},
+				Methods:     []string{http.MethodGet, http.MethodPut},
+				MinBackoff:  time.Millisecond,
+				MaxBackoff:  time.Millisecond,
+			}, nil)
+			defer SetRetry(nil, nil)
+
+			req, err := http.NewRequest(method, srv.URL, strings.NewReader("body"))
+			testutil.Ok(t, err)
+
+			rt := InstrumentedRoundTripper(auth.NewBearerTokenRoundTripper(log.NewNopLogger(), auth.NewStaticToken("token"), nil))
+
+			resp, err := (&http.Client{Transport: rt}).Do(req)
+			testutil.Ok(t, err)
+			testutil.Ok(t, resp.Body.Close())
+
+			// The token is set once on the last attempt, not once per attempt.
+			testutil.Equals(t, []string{"Bearer token"}, authorization)
+			testutil.Equals(t, 0, len(req.Header.Values("Authorization")))
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// skewSmoothing is the weight of a new measurement, as the Date header only has a resolution of one second.
+const skewSmoothing = 0.2
+
+// ClockSkew estimates the clock skew to a server from the Date header of its responses.
+type ClockSkew struct {
+	mtx   sync.Mutex
+	skew  time.Duration
+	known bool
+	g     prometheus.Gauge
+}
+
+// NewClockSkew returns a clock skew estimator reporting the estimated skew to the gauge.
+func NewClockSkew(g prometheus.Gauge) *ClockSkew {
+	return &ClockSkew{g: g}
+}
+
+// Get returns the estimated skew. It is positive if the server clock is ahead of the local clock.
+func (c *ClockSkew) Get() time.Duration {
+	if c == nil {
+		return 0
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.skew
+}
+
+func (c *ClockSkew) observe(requested, responded time.Time, date string) {
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+
+	// The server time was truncated to the second, assume the middle of it and of the round trip.
+	skew := serverTime.Add(500 * time.Millisecond).Sub(requested.Add(responded.Sub(requested) / 2))
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.known {
+		skew = time.Duration(skewSmoothing*float64(skew) + (1-skewSmoothing)*float64(c.skew))
+	}
+
+	c.skew = skew
+	c.known = true
+	c.g.Set(skew.Seconds())
+}
+
+// RoundTripper returns a round tripper observing the responses for the estimation. It returns r if c is nil.
+func (c *ClockSkew) RoundTripper(r http.RoundTripper) http.RoundTripper {
+	if c == nil {
+		return r
+	}
+
+	if r == nil {
+		r = http.DefaultTransport
+	}
+
+	return &skewRoundTripper{c: c, r: r}
+}
+
+type skewRoundTripper struct {
+	c *ClockSkew
+	r http.RoundTripper
+}
+
+func (s *skewRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	requested := time.Now()
+
+	resp, err := s.r.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	s.c.observe(requested, time.Now(), resp.Header.Get("Date"))
+
+	return resp, err
+}
@@ -0,0 +1,34 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestClockSkew(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	}))
+	defer srv.Close()
+
+	c := NewClockSkew(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test"}))
+	client := &http.Client{Transport: c.RoundTripper(nil)}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL)
+		testutil.Ok(t, err)
+		testutil.Ok(t, resp.Body.Close())
+	}
+
+	// The Date header only has a resolution of a second.
+	skew := c.Get()
+	testutil.Assert(t, skew > -61*time.Second && skew < -59*time.Second, "unexpected skew %s", skew)
+
+	var disabled *ClockSkew
+	testutil.Equals(t, time.Duration(0), disabled.Get())
+}
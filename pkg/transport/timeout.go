@@ -0,0 +1,44 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+type timeoutRoundTripper struct {
+	r http.RoundTripper
+}
+
+func (rt *timeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := defaultTimeouts.Load()
+	if t == nil || t.Request <= 0 {
+		return rt.r.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.Request)
+
+	resp, err := rt.r.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Like the timeout of http.Client, the timeout bounds reading the body of the response as well.
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+// cancelBody cancels the context of the request once the body of its response is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err
+}
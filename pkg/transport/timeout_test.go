@@ -0,0 +1,50 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/observatorium/up/pkg/options"
+)
+
+func TestTimeoutRoundTripper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+
+		w.Write([]byte("body")) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	SetTimeouts(options.Timeouts{Dial: DefaultTimeouts.Dial, TLSHandshake: DefaultTimeouts.TLSHandshake, Request: 50 * time.Millisecond})
+	defer defaultTimeouts.Store(nil)
+
+	client := &http.Client{Transport: InstrumentedRoundTripper(&http.Transport{DialContext: dial})}
+
+	resp, err := client.Get(srv.URL)
+	testutil.Ok(t, err)
+
+	b, err := io.ReadAll(resp.Body)
+	testutil.Ok(t, err)
+	testutil.Ok(t, resp.Body.Close())
+	testutil.Equals(t, "body", string(b))
+
+	// The timeout bounds reading the body as well.
+	resp, err = client.Get(srv.URL + "/slow")
+	testutil.Ok(t, err)
+
+	_, err = io.ReadAll(resp.Body)
+	testutil.NotOk(t, err)
+	testutil.Ok(t, resp.Body.Close())
+}
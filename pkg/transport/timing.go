@@ -0,0 +1,190 @@
+package transport
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The phases of requests observed by the round trippers returned by InstrumentedRoundTripper. Requests on reused
+// connections have no DNS, connect and TLS phases. The time to first byte is the time from writing the request to
+// the first byte of the response, the time of the server including the round trip of the network.
+const (
+	DNSPhase     = "dns"
+	ConnectPhase = "connect"
+	TLSPhase     = "tls"
+	TTFBPhase    = "ttfb"
+)
+
+// The address families of the connections of requests in the phase durations, unknown for requests failing before
+// they got a connection.
+const (
+	IPv4Family    = "ip4"
+	IPv6Family    = "ip6"
+	UnknownFamily = "unknown"
+)
+
+// The protocols of requests in the phase durations. The protocol is unknown for requests failing before the TLS
+// handshake negotiated it.
+const (
+	HTTP1Protocol   = "h1"
+	HTTP2Protocol   = "h2"
+	UnknownProtocol = "unknown"
+)
+
+var defaultPhaseDuration atomic.Pointer[prometheus.HistogramVec]
+
+// SetPhaseDuration sets the histogram the round trippers returned by InstrumentedRoundTripper observe the duration
+// of the phases of every request in, by the host of the endpoint, the phase, the protocol and the address family.
+// Without histogram, no phases are observed.
+func SetPhaseDuration(h *prometheus.HistogramVec) {
+	defaultPhaseDuration.Store(h)
+}
+
+type timingRoundTripper struct {
+	r http.RoundTripper
+}
+
+type phaseDuration struct {
+	phase    string
+	duration time.Duration
+}
+
+func (t *timingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	h := defaultPhaseDuration.Load()
+	if h == nil {
+		return t.r.RoundTrip(req)
+	}
+
+	// The phases are observed once the protocol is known with the response, phases of connections dialed for the
+	// request but finished after it, e.g. as it got an idle connection, are not observed.
+	var (
+		mtx                         sync.Mutex
+		dnsStart, tlsStart, written time.Time
+		connectStarts               = map[string]time.Time{}
+		durations                   []phaseDuration
+		negotiated                  = UnknownProtocol
+		family                      = UnknownFamily
+		done                        bool
+	)
+
+	observe := func(phase string, start time.Time) {
+		if !start.IsZero() && !done {
+			durations = append(durations, phaseDuration{phase: phase, duration: time.Since(start)})
+		}
+	}
+
+	// Connections can be dialed concurrently, e.g. to the IPv4 and IPv6 addresses of a host.
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			observe(DNSPhase, dnsStart)
+		},
+		ConnectStart: func(_, addr string) {
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			connectStarts[addr] = time.Now()
+		},
+		ConnectDone: func(_, addr string, err error) {
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			if err == nil {
+				observe(ConnectPhase, connectStarts[addr])
+			}
+		},
+		TLSHandshakeStart: func() {
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(cs tls.ConnectionState, err error) {
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			if err == nil {
+				observe(TLSPhase, tlsStart)
+				negotiated = protocol(cs.NegotiatedProtocol == "h2")
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			family = addrFamily(info.Conn.RemoteAddr())
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			written = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			observe(TTFBPhase, written)
+		},
+	}
+
+	resp, err := t.r.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	done = true
+
+	proto := negotiated
+	switch {
+	case resp != nil:
+		proto = protocol(resp.ProtoMajor == 2)
+	case req.URL.Scheme == HTTP:
+		// Plain HTTP requests do not upgrade to HTTP/2.
+		proto = HTTP1Protocol
+	}
+
+	phases := h.MustCurryWith(prometheus.Labels{"endpoint": req.URL.Host, "protocol": proto, "family": family})
+	for _, d := range durations {
+		phases.WithLabelValues(d.phase).Observe(d.duration.Seconds())
+	}
+
+	return resp, err
+}
+
+// addrFamily returns the address family of a TCP address, e.g. the remote one of a connection.
+func addrFamily(addr net.Addr) string {
+	a, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return UnknownFamily
+	}
+
+	if a.IP.To4() != nil {
+		return IPv4Family
+	}
+
+	return IPv6Family
+}
+
+func protocol(h2 bool) string {
+	if h2 {
+		return HTTP2Protocol
+	}
+
+	return HTTP1Protocol
+}
@@ -0,0 +1,84 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestTimingRoundTripper(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	testutil.Ok(t, err)
+
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test"}, []string{"endpoint", "phase", "protocol", "family"})
+
+	SetPhaseDuration(h)
+	defer SetPhaseDuration(nil)
+
+	client := &http.Client{Transport: InstrumentedRoundTripper(srv.Client().Transport)}
+
+	// The second request reuses the connection of the first.
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		testutil.Ok(t, err)
+		testutil.Ok(t, resp.Body.Close())
+	}
+
+	for i, tc := range []struct {
+		phase    string
+		protocol string
+		count    uint64
+	}{
+		// The server listens on an IP address, which is not looked up.
+		{phase: DNSPhase, protocol: HTTP1Protocol, count: 0},
+		{phase: ConnectPhase, protocol: HTTP1Protocol, count: 1},
+		{phase: TLSPhase, protocol: HTTP1Protocol, count: 1},
+		{phase: TTFBPhase, protocol: HTTP1Protocol, count: 2},
+		{phase: TTFBPhase, protocol: HTTP2Protocol, count: 0},
+	} {
+		var m dto.Metric
+		testutil.Ok(t, h.WithLabelValues(u.Host, tc.phase, tc.protocol, IPv4Family).(prometheus.Histogram).Write(&m))
+		testutil.Equals(t, tc.count, m.GetHistogram().GetSampleCount(), "case #%d", i)
+	}
+}
+
+func TestTimingRoundTripper_HTTP2(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	testutil.Ok(t, err)
+
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test"}, []string{"endpoint", "phase", "protocol", "family"})
+
+	SetPhaseDuration(h)
+	defer SetPhaseDuration(nil)
+
+	client := &http.Client{Transport: InstrumentedRoundTripper(srv.Client().Transport)}
+
+	resp, err := client.Get(srv.URL)
+	testutil.Ok(t, err)
+	testutil.Ok(t, resp.Body.Close())
+
+	for i, tc := range []struct {
+		phase string
+		count uint64
+	}{
+		{phase: TLSPhase, count: 1},
+		{phase: TTFBPhase, count: 1},
+	} {
+		var m dto.Metric
+		testutil.Ok(t, h.WithLabelValues(u.Host, tc.phase, HTTP2Protocol, IPv4Family).(prometheus.Histogram).Write(&m))
+		testutil.Equals(t, tc.count, m.GetHistogram().GetSampleCount(), "case #%d", i)
+	}
+}
@@ -2,56 +2,45 @@ package transport
 
 import (
 	"crypto/tls"
-	"crypto/x509"
-	"io/ioutil"
 
 	"github.com/go-kit/log"
-	"github.com/go-kit/log/level"
+	"github.com/observatorium/up/pkg/options"
 	"github.com/pkg/errors"
 )
 
-const HTTPS = "https"
-
-func newTLSConfig(logger log.Logger, certFile, keyFile, caCertFile string) (*tls.Config, error) {
-	var certPool *x509.CertPool
-
-	if caCertFile != "" {
-		caPEM, err := ioutil.ReadFile(caCertFile)
-		if err != nil {
-			return nil, errors.Wrap(err, "reading client CA")
-		}
-
-		certPool = x509.NewCertPool()
-		if !certPool.AppendCertsFromPEM(caPEM) {
-			return nil, errors.Wrap(err, "building client CA")
-		}
+const (
+	HTTP  = "http"
+	HTTPS = "https"
+	// GRPC and GRPCS are the schemes of endpoints called over gRPC, the latter using TLS.
+	GRPC  = "grpc"
+	GRPCS = "grpcs"
+)
 
-		level.Info(logger).Log("msg", "TLS client using provided certificate pool")
-	} else {
-		var err error
-		certPool, err = x509.SystemCertPool()
-		if err != nil {
-			return nil, errors.Wrap(err, "reading system certificate pool")
-		}
+// NewTLSConfig returns the client TLS configuration for non-HTTP clients, e.g. gRPC.
+func NewTLSConfig(l log.Logger, cfg options.TLS) (*tls.Config, error) {
+	return newTLSConfig(l, cfg)
+}
 
-		level.Info(logger).Log("msg", "TLS client using system certificate pool")
+func newTLSConfig(logger log.Logger, cfg options.TLS) (*tls.Config, error) {
+	if (cfg.Key != "") != (cfg.Cert != "") {
+		return nil, errors.Errorf("both client key and certificate must be provided")
 	}
 
-	tlsCfg := &tls.Config{RootCAs: certPool}
+	src := getCertSource(logger, cfg)
 
-	if (keyFile != "") != (certFile != "") {
-		return nil, errors.Errorf("both client key and certificate must be provided")
+	cert, certPool, err := src.load()
+	if err != nil {
+		return nil, err
 	}
 
-	if certFile != "" {
-		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
-		if err != nil {
-			return nil, errors.Wrap(err, "client credentials")
-		}
-
-		tlsCfg.Certificates = []tls.Certificate{cert}
+	tlsCfg := &tls.Config{RootCAs: certPool, InsecureSkipVerify: cfg.InsecureSkipVerify} //nolint:gosec
 
-		level.Info(logger).Log("msg", "TLS client authentication enabled")
+	if cert != nil {
+		// The certificate is loaded on every handshake, so that long-lived transports use the rotated one.
+		tlsCfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _, err := src.load()
+			return cert, err
+		}
 	}
 
 	return tlsCfg, nil
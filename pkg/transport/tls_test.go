@@ -0,0 +1,40 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+	"github.com/observatorium/up/pkg/options"
+)
+
+func TestNewTLSTransport_InsecureSkipVerify(t *testing.T) {
+	// The certificate of the server is self-signed.
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	for i, tc := range []struct {
+		insecure bool
+		err      bool
+	}{
+		{insecure: false, err: true},
+		{insecure: true},
+	} {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			rt, err := NewTLSTransport(log.NewNopLogger(), options.TLS{InsecureSkipVerify: tc.insecure})
+			testutil.Ok(t, err)
+
+			resp, err := (&http.Client{Transport: rt}).Get(srv.URL)
+			if tc.err {
+				testutil.NotOk(t, err)
+				return
+			}
+
+			testutil.Ok(t, err)
+			testutil.Ok(t, resp.Body.Close())
+		})
+	}
+}
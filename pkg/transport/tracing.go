@@ -0,0 +1,49 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/observatorium/up/pkg/tracing"
+)
+
+// TracingRoundTripper returns a round tripper tracing every request in a client span, propagating the trace to the
+// server in the traceparent header. Requests are not traced without tracer.
+func TracingRoundTripper(r http.RoundTripper) http.RoundTripper {
+	if r == nil {
+		r = http.DefaultTransport
+	}
+
+	return &tracingRoundTripper{r: r}
+}
+
+type tracingRoundTripper struct {
+	r http.RoundTripper
+}
+
+func (t *tracingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracing.StartClient(req.Context(), "HTTP "+req.Method)
+	if span == nil {
+		return t.r.RoundTrip(req)
+	}
+
+	req = req.Clone(ctx)
+	req.Header.Set(tracing.TraceParentHeader, span.TraceParent())
+	span.SetAttributes("http.method", req.Method, "http.url", req.URL.Redacted())
+
+	resp, err := t.r.RoundTrip(req)
+	if err != nil {
+		span.End(err)
+		return resp, err
+	}
+
+	span.SetAttributes("http.status_code", resp.StatusCode)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.End(fmt.Errorf("status %s", resp.Status))
+	} else {
+		span.End(nil)
+	}
+
+	return resp, err
+}
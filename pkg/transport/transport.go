@@ -1,8 +1,9 @@
 package transport
 
 import (
-	"net"
+	"crypto/tls"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/log"
@@ -10,24 +11,70 @@ import (
 	"github.com/pkg/errors"
 )
 
-func NewTLSTransport(l log.Logger, tls options.TLS) (*http.Transport, error) {
-	tlsConfig, err := newTLSConfig(l, tls.Cert, tls.Key, tls.CACert)
+var (
+	http2Disabled atomic.Bool
+
+	defaultTimeouts atomic.Pointer[options.Timeouts]
+)
+
+// DefaultTimeouts are the timeouts of the transports unless set with SetTimeouts.
+var DefaultTimeouts = options.Timeouts{Dial: 30 * time.Second, TLSHandshake: 10 * time.Second}
+
+// SetTimeouts sets the timeouts of the transports returned by NewTLSTransport, the default transport of http and
+// DialContext, and the timeout of the requests of the round trippers returned by InstrumentedRoundTripper.
+func SetTimeouts(t options.Timeouts) {
+	defaultTimeouts.Store(&t)
+
+	if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+		dt.DialContext = dial
+		dt.TLSHandshakeTimeout = t.TLSHandshake
+		dt.ResponseHeaderTimeout = t.ResponseHeader
+	}
+}
+
+func timeouts() options.Timeouts {
+	if t := defaultTimeouts.Load(); t != nil {
+		return *t
+	}
+
+	return DefaultTimeouts
+}
+
+// DisableHTTP2 makes the transports returned by NewTLSTransport and the default transport of http use HTTP/1.1 only,
+// e.g. to isolate bugs of gateways that only occur over HTTP/2.
+func DisableHTTP2() {
+	http2Disabled.Store(true)
+
+	// An empty, rather than nil map of protocols keeps the transport from upgrading to HTTP/2.
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+}
+
+func NewTLSTransport(l log.Logger, cfg options.TLS) (*http.Transport, error) {
+	tlsConfig, err := newTLSConfig(l, cfg)
 	if err != nil {
 		return nil, errors.Wrap(err, "tls config")
 	}
 
-	return &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
-		ForceAttemptHTTP2:     true,
+	timeouts := timeouts()
+
+	t := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dial,
+		ForceAttemptHTTP2:     !http2Disabled.Load(),
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
+		TLSHandshakeTimeout:   timeouts.TLSHandshake,
+		ResponseHeaderTimeout: timeouts.ResponseHeader,
 		ExpectContinueTimeout: 1 * time.Second,
 		TLSClientConfig:       tlsConfig,
-	}, nil
+	}
+
+	if http2Disabled.Load() {
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return t, nil
 }
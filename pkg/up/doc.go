@@ -0,0 +1,3 @@
+// Package up writes and reads back metrics and logs and executes custom queries, as the up command does.
+// Its Runner runs the checks programmatically, e.g. embedded in the end-to-end tests of other projects.
+package up
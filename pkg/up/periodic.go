@@ -0,0 +1,158 @@
+package up
+
+import (
+	"context"
+	"time"
+
+	"github.com/observatorium/up/pkg/options"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// RunPeriodically runs f every period until the context is done, then evaluates the success ratio of the requests
+// counted by c against the threshold. In the infinite mode of --duration=0, the success ratio is also evaluated for every
+// --evaluation-window, counting the failed windows in windowFailed.
+func RunPeriodically(ctx context.Context, opts options.Options, threshold float64, c *prometheus.CounterVec,
+	windowFailed prometheus.Counter, l log.Logger, ch chan error, f func(rCtx context.Context)) error {
+	var (
+		t        = time.NewTicker(opts.Period)
+		deadline time.Time
+		rCtx     context.Context
+		rCancel  context.CancelFunc
+		window   <-chan time.Time
+		last     Counts
+	)
+
+	if opts.Duration == 0 && opts.EvaluationWindow > 0 {
+		w := time.NewTicker(opts.EvaluationWindow)
+		defer w.Stop()
+
+		window = w.C
+	}
+
+	for {
+		select {
+		case <-t.C:
+			// NOTICE: Do not propagate parent context to prevent cancellation of in-flight request.
+			// It will be cancelled after the deadline.
+			deadline = time.Now().Add(opts.Period)
+			rCtx, rCancel = context.WithDeadline(context.Background(), deadline)
+
+			// Will only get scheduled once per period and guaranteed to get cancelled after deadline.
+			go func() {
+				defer rCancel() // Make sure context gets cancelled even if execution panics.
+
+				f(rCtx)
+			}()
+		case <-window:
+			counts := CollectCounts(l, c)
+			if !evaluateWindow(l, counts.Sub(last), threshold, opts.EvaluationWindow) {
+				windowFailed.Inc()
+			}
+
+			last = counts
+		case <-ctx.Done():
+			t.Stop()
+
+			if rCtx != nil {
+				select {
+				// If it gets immediately cancelled, zero value of deadline won't cause a lock!
+				case <-time.After(time.Until(deadline)):
+					rCancel()
+				case <-rCtx.Done():
+				}
+			}
+
+			return reportResults(l, ch, c, threshold)
+		}
+	}
+}
+
+// Counts are the numbers of successful and failed requests.
+type Counts struct {
+	Success, Failures float64
+}
+
+// Sub returns the counts since o.
+func (r Counts) Sub(o Counts) Counts {
+	return Counts{Success: r.Success - o.Success, Failures: r.Failures - o.Failures}
+}
+
+// Ratio returns the ratio of successful requests.
+func (r Counts) Ratio() float64 {
+	return r.Success / (r.Success + r.Failures)
+}
+
+// CollectCounts sums the successful and failed requests counted by c over all other labels, e.g. the HTTP codes.
+func CollectCounts(l log.Logger, c *prometheus.CounterVec) Counts {
+	// The number of series is unknown, so collect concurrently instead of into a buffered channel.
+	metrics := make(chan prometheus.Metric)
+
+	go func() {
+		c.Collect(metrics)
+		close(metrics)
+	}()
+
+	var counts Counts
+
+	for m := range metrics {
+		m1 := &dto.Metric{}
+		if err := m.Write(m1); err != nil {
+			level.Warn(l).Log("msg", "cannot read success and error count from prometheus counter", "err", err)
+		}
+
+		for _, l := range m1.Label {
+			switch *l.Value {
+			case labelError:
+				counts.Failures += m1.GetCounter().GetValue()
+			case labelSuccess:
+				counts.Success += m1.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return counts
+}
+
+// evaluateWindow returns whether the success ratio of the requests of the last window reached the threshold.
+// Windows without requests are not evaluated.
+func evaluateWindow(l log.Logger, counts Counts, threshold float64, window time.Duration) bool {
+	if counts.Success+counts.Failures == 0 {
+		return true
+	}
+
+	ratio := counts.Ratio()
+	if ratio < threshold {
+		level.Error(l).Log("msg", "ratio of the evaluation window is below threshold", "window", window,
+			"success", counts.Success, "errors", counts.Failures, "ratio", ratio, "threshold", threshold)
+
+		return false
+	}
+
+	level.Info(l).Log("msg", "ratio of the evaluation window reached the threshold", "window", window,
+		"success", counts.Success, "errors", counts.Failures, "ratio", ratio)
+
+	return true
+}
+
+func reportResults(l log.Logger, ch chan error, c *prometheus.CounterVec, threshold float64) error {
+	counts := CollectCounts(l, c)
+
+	level.Info(l).Log("msg", "number of requests", "success", counts.Success, "errors", counts.Failures)
+
+	ratio := counts.Ratio()
+	if ratio < threshold {
+		level.Error(l).Log("msg", "ratio is below threshold")
+
+		err := errors.Errorf("failed with less than %2.f%% success ratio - actual %2.f%%", threshold*100, ratio*100)
+		ch <- err
+
+		return err
+	}
+
+	return nil
+}
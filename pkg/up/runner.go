@@ -0,0 +1,298 @@
+package up
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/observatorium/up/pkg/api"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/logs"
+	"github.com/observatorium/up/pkg/metrics"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/observatorium/up/pkg/transport"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// WriterCheck and ReaderCheck are the checks of writing and reading back, QueryCheckPrefix prefixes the
+	// names of the checks of custom queries.
+	WriterCheck      = "writer"
+	ReaderCheck      = "reader"
+	QueryCheckPrefix = "query/"
+)
+
+// Result is the outcome of a check of a run.
+type Result struct {
+	// Check is the WriterCheck, the ReaderCheck or the QueryCheckPrefix followed by the name of a custom query.
+	Check string
+	Counts
+	Threshold float64
+	// Err is why the check failed, if so.
+	Err error
+}
+
+// Results are the outcomes of all checks of a run.
+type Results []Result
+
+// Err returns the errors of the failed checks, or nil if all succeeded.
+func (r Results) Err() error {
+	var errs []string
+
+	for _, res := range r {
+		if res.Err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", res.Check, res.Err))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("checks failed: %s", strings.Join(errs, "; "))
+}
+
+// Runner runs the writer, the reader and the custom queries of the options, e.g. embedded in the test suites of
+// other projects instead of running the up binary. It covers a subset of the up command only: the tenants of the
+// --tenants-file, the scenarios, the exemplar, metadata, federation, build info and store checks, reloading and the
+// readiness and status of the checks are not supported. The transports are configured by the options when running,
+// but they are shared by the process, so runners running at the same time must not differ in their transports.
+type Runner struct {
+	l    log.Logger
+	opts options.Options
+	m    instr.Metrics
+}
+
+// NewRunner returns a runner of the options, registering its metrics with reg. The options are expected to be valid,
+// as after parsing the flags of up, including the '__name__' label.
+func NewRunner(l log.Logger, opts options.Options, reg *prometheus.Registry) *Runner {
+	return &Runner{l: l, opts: opts, m: instr.RegisterMetrics(reg, opts.Buckets)}
+}
+
+// Run runs every check periodically for the --duration of the options, or until the context is canceled,
+// returning the results of the checks.
+func (r *Runner) Run(ctx context.Context) (Results, error) {
+	if err := r.unsupported(); err != nil {
+		return nil, err
+	}
+
+	if err := ConfigureTransport(r.l, r.opts); err != nil {
+		return nil, err
+	}
+
+	InstrumentTransport(r.opts, r.m)
+
+	ls, err := NewLogsState(r.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var cancel context.CancelFunc
+	if r.opts.Duration != 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.opts.Duration)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	var (
+		cfg    = NewStaticConfig(r.opts)
+		checks []func() Result
+		wg     sync.WaitGroup
+	)
+
+	if r.opts.WriteEndpoint != nil {
+		checks = append(checks, func() Result { return r.runWriter(ctx, cfg, ls) })
+	}
+
+	if r.opts.ReadEndpoint != nil && (r.opts.WriteEndpoint != nil || r.opts.ReadQuery != "") {
+		checks = append(checks, func() Result { return r.runReader(ctx, cfg, ls) })
+	}
+
+	if r.opts.ReadEndpoint != nil {
+		for _, q := range r.opts.Queries {
+			q := q
+			checks = append(checks, func() Result { return r.runQuery(ctx, q) })
+		}
+	}
+
+	results := make(Results, len(checks))
+
+	for i, check := range checks {
+		wg.Add(1)
+
+		go func(i int, check func() Result) {
+			defer wg.Done()
+
+			results[i] = check()
+		}(i, check)
+	}
+
+	wg.Wait()
+
+	return results, nil
+}
+
+// unsupported returns an error if the options configure checks the runner does not run, rather than ignoring them.
+func (r *Runner) unsupported() error {
+	var checks []string
+
+	for _, c := range []struct {
+		name       string
+		configured bool
+	}{
+		{name: "tenants", configured: len(r.opts.Tenants) > 0},
+		{name: "scenarios", configured: len(r.opts.Scenarios) > 0},
+		{name: "exemplars", configured: r.opts.Exemplars},
+		{name: "metadata", configured: r.opts.Metadata},
+		{name: "federation", configured: r.opts.Federate},
+		{name: "build info", configured: r.opts.BuildInfo},
+		{name: "store", configured: r.opts.StoreEndpoint != ""},
+	} {
+		if c.configured {
+			checks = append(checks, c.name)
+		}
+	}
+
+	if len(checks) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("the runner does not support the checks of %s", strings.Join(checks, ", "))
+}
+
+func (r *Runner) runWriter(ctx context.Context, cfg Config, ls LogsState) Result {
+	l := log.With(r.l, "component", WriterCheck)
+	opts := r.opts.Endpoints.Write.Apply(r.opts)
+	requests, requestDuration := r.m.RemoteWriteRequests, r.m.RemoteWriteRequestDuration
+
+	if r.opts.EndpointType == options.LogsEndpointType {
+		requests = r.m.LogsWrites.MustCurryWith(prometheus.Labels{"encoding": string(r.opts.LogsEncoding)})
+		requestDuration = r.m.LogsWriteDuration
+	}
+
+	return r.runCheck(ctx, l, WriterCheck, r.opts.WriteThreshold, requests, func(rCtx context.Context) {
+		t := time.Now()
+		httpCode, err := Write(rCtx, l, r.m, opts, cfg, ls)
+		requestDuration.Observe(time.Since(t).Seconds())
+
+		if err != nil {
+			requests.WithLabelValues(labelError, strconv.Itoa(httpCode)).Inc()
+			level.Error(l).Log("msg", "failed to make request", "err", err)
+
+			return
+		}
+
+		requests.WithLabelValues(labelSuccess, strconv.Itoa(httpCode)).Inc()
+		r.m.LastSuccessfulWrite.WithLabelValues(r.opts.Tenant).SetToCurrentTime()
+	})
+}
+
+func (r *Runner) runReader(ctx context.Context, cfg Config, ls LogsState) Result {
+	l := log.With(r.l, "component", ReaderCheck)
+	opts := r.opts.Endpoints.Read.Apply(r.opts)
+	responses, responseDuration := r.m.QueryResponses, r.m.QueryResponseDuration
+
+	if r.opts.EndpointType == options.LogsEndpointType {
+		responses, responseDuration = r.m.LogsQueries, r.m.LogsQueryDuration
+	}
+
+	var skew *transport.ClockSkew
+	if r.opts.ClockSkew {
+		skew = transport.NewClockSkew(r.m.ClockSkew)
+	}
+
+	rr := &metrics.RangeReader{
+		Window:         r.opts.ReadWindow,
+		Period:         r.opts.Period,
+		FailOnWarnings: r.opts.FailOnWarnings,
+		ClockSkew:      skew,
+		RemoteWriter:   r.opts.WriteEndpoint == nil,
+	}
+	lrr := &logs.RangeReader{
+		Window:         r.opts.ReadWindow,
+		Period:         r.opts.Period,
+		Streams:        r.opts.LogsStreamsPerPush,
+		EntriesPerPush: r.opts.LogsLinesPerPush,
+		Started:        time.Now(),
+		Template:       ls.Template,
+	}
+
+	// Wait for the first writes before reading them back.
+	select {
+	case <-ctx.Done():
+		return Result{Check: ReaderCheck, Threshold: r.opts.ReadThreshold}
+	case <-time.After(r.opts.InitialQueryDelay):
+	}
+
+	return r.runCheck(ctx, l, ReaderCheck, r.opts.ReadThreshold, responses, func(rCtx context.Context) {
+		rCtx, trace := api.WithTrace(rCtx)
+		t := time.Now()
+		httpCode, err := Read(rCtx, l, r.m, opts, cfg, ls, rr, lrr, skew)
+		instr.ObserveWithTrace(responseDuration, time.Since(t).Seconds(), *trace)
+
+		if err != nil {
+			if httpCode != 0 {
+				responses.WithLabelValues(labelError, strconv.Itoa(httpCode)).Inc()
+			}
+
+			level.Error(l).Log("msg", "failed to query", "err", err)
+
+			return
+		}
+
+		if httpCode != 0 {
+			responses.WithLabelValues(labelSuccess, strconv.Itoa(httpCode)).Inc()
+		}
+
+		r.m.LastSuccessfulRead.WithLabelValues(r.opts.Tenant).SetToCurrentTime()
+	})
+}
+
+func (r *Runner) runQuery(ctx context.Context, q options.Query) Result {
+	check := QueryCheckPrefix + q.GetName()
+	l := log.With(r.l, "component", check)
+	opts := r.opts.Endpoints.Read.Apply(r.opts)
+
+	// The executions of every query are counted on their own, the metrics of up do not tell failures apart.
+	executions := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "executions"}, []string{"result"})
+
+	return r.runCheck(ctx, l, check, q.GetCommon().EffectiveThreshold(r.opts.QueriesThreshold), executions, func(rCtx context.Context) {
+		rCtx, trace := api.WithTrace(rCtx)
+		httpCode, warn, err := Query(rCtx, l, q, opts)
+		r.m.ObserveWarnings(q.GetType(), q.GetName(), warn)
+		err = q.GetCommon().CheckStatus(httpCode, err)
+
+		if err == nil && q.GetCommon().FailsOnWarnings(r.opts.FailOnWarnings) {
+			err = api.WarningsError(warn)
+		}
+
+		if err != nil {
+			executions.WithLabelValues(labelError).Inc()
+			level.Error(l).Log("msg", "failed to execute specified query", "trace-id", trace.ID, "err", err)
+
+			return
+		}
+
+		executions.WithLabelValues(labelSuccess).Inc()
+		r.m.LastSuccessfulQuery.WithLabelValues(q.GetType(), q.GetName()).SetToCurrentTime()
+	})
+}
+
+// runCheck runs f periodically, returning the result of the requests it counts in c.
+func (r *Runner) runCheck(ctx context.Context, l log.Logger, check string, threshold float64, c *prometheus.CounterVec,
+	f func(rCtx context.Context)) Result {
+	// Every check reports its failure once, the channel only needs to hold that.
+	ch := make(chan error, 1)
+	windowFailed := r.m.CheckWindowFailed.WithLabelValues(check, r.opts.Tenant)
+
+	err := RunPeriodically(ctx, r.opts, threshold, c, windowFailed, l, ch, f)
+
+	return Result{Check: check, Counts: CollectCounts(l, c), Threshold: threshold, Err: err}
+}
@@ -0,0 +1,113 @@
+package up
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/testutil"
+	"github.com/go-kit/log"
+	"github.com/observatorium/up/pkg/auth"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestRunner_Run(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/receive":
+		case "/api/v1/query":
+			// The written value is the timestamp of the write, which the reader checks the freshness of.
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"status": "success", "data": {"resultType": "vector", "result": [{"metric": {}, "value": [%d, "%d"]}]}}`,
+				time.Now().Unix(), time.Now().UnixNano()/int64(time.Millisecond))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	writeEndpoint, err := url.Parse(s.URL + "/api/v1/receive")
+	testutil.Ok(t, err)
+
+	readEndpoint, err := url.Parse(s.URL)
+	testutil.Ok(t, err)
+
+	opts := options.Options{
+		EndpointType:      options.MetricsEndpointType,
+		WriteEndpoint:     writeEndpoint,
+		ReadEndpoint:      readEndpoint,
+		Labels:            []prompb.Label{{Name: "__name__", Value: "up"}},
+		Name:              "up",
+		Token:             auth.NewNoOpTokenProvider(),
+		ReadMode:          options.InstantReadMode,
+		Period:            50 * time.Millisecond,
+		Duration:          500 * time.Millisecond,
+		Latency:           15 * time.Second,
+		InitialQueryDelay: 100 * time.Millisecond,
+		WriteThreshold:    0.9,
+		ReadThreshold:     0.9,
+		TenantHeader:      "tenant_id",
+		Queries: []options.Query{
+			options.QuerySpec{Name: "up", Query: "up"},
+			// The query succeeds, but is expected to be forbidden.
+			options.QuerySpec{Name: "forbidden", Query: "up", CommonSpec: options.CommonSpec{ExpectStatus: http.StatusForbidden}},
+		},
+		QueriesThreshold: 0.9,
+	}
+
+	reg := prometheus.NewRegistry()
+
+	results, err := NewRunner(log.NewNopLogger(), opts, reg).Run(context.Background())
+	testutil.Ok(t, err)
+	testutil.Equals(t, 4, len(results))
+
+	for i, check := range []string{WriterCheck, ReaderCheck, QueryCheckPrefix + "up", QueryCheckPrefix + "forbidden"} {
+		testutil.Equals(t, check, results[i].Check)
+		testutil.Assert(t, results[i].Success+results[i].Failures > 0, "check %s must have run", check)
+	}
+
+	for _, r := range results[:3] {
+		testutil.Ok(t, r.Err)
+		testutil.Equals(t, float64(0), r.Failures)
+	}
+
+	testutil.NotOk(t, results[3].Err)
+	testutil.Equals(t, float64(0), results[3].Success)
+	testutil.NotOk(t, results.Err())
+
+	// The last successes are recorded for the checks that succeeded, not for the forbidden query.
+	for i, tc := range []struct {
+		metric string
+		series int
+	}{
+		{metric: "up_last_successful_write_timestamp_seconds", series: 1},
+		{metric: "up_last_successful_read_timestamp_seconds", series: 1},
+		{metric: "up_last_successful_custom_query_timestamp_seconds", series: 1},
+	} {
+		n, err := promtestutil.GatherAndCount(reg, tc.metric)
+		testutil.Ok(t, err, "case #%d", i)
+		testutil.Equals(t, tc.series, n, "case #%d", i)
+	}
+}
+
+func TestRunner_Run_Unsupported(t *testing.T) {
+	u, err := url.Parse("http://localhost:9090")
+	testutil.Ok(t, err)
+
+	opts := options.Options{
+		ReadEndpoint: u,
+		Tenants:      []options.TenantSpec{{Name: "a"}},
+		Exemplars:    true,
+	}
+
+	_, err = NewRunner(log.NewNopLogger(), opts, prometheus.NewRegistry()).Run(context.Background())
+	testutil.NotOk(t, err)
+	testutil.Equals(t, "the runner does not support the checks of tenants, exemplars", err.Error())
+}
@@ -0,0 +1,97 @@
+package up
+
+import (
+	"net/url"
+
+	"github.com/go-kit/log"
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/observatorium/up/pkg/transport"
+	"github.com/pkg/errors"
+)
+
+// ConfigureTransport sets the configuration of the transports of the options, e.g. the proxies, the timeouts and the
+// headers of the endpoints. The transports are shared by the process, so the configuration applies to all requests of
+// up, the one set last wins.
+func ConfigureTransport(l log.Logger, opts options.Options) error {
+	if opts.Proxy != (options.Proxy{}) {
+		transport.SetProxy(opts.Proxy)
+	}
+
+	if opts.DisableHTTP2 {
+		transport.DisableHTTP2()
+	}
+
+	if opts.Timeouts != transport.DefaultTimeouts {
+		transport.SetTimeouts(opts.Timeouts)
+	}
+
+	if len(opts.Resolver.Static) > 0 || opts.Resolver.DNSServer != "" || opts.Resolver.IPProtocol != options.AnyProtocol {
+		transport.SetResolver(opts.Resolver)
+	}
+
+	if opts.Encodings.Write != "" && opts.WriteEndpoint != nil {
+		transport.SetEncodings(opts.WriteEndpoint, options.Encodings{Write: opts.Encodings.Write})
+	}
+
+	if opts.Encodings.Read != "" && opts.ReadEndpoint != nil {
+		transport.SetEncodings(opts.ReadEndpoint, options.Encodings{Read: opts.Encodings.Read})
+	}
+
+	if opts.HostHeader != "" {
+		for _, e := range []*url.URL{opts.WriteEndpoint, opts.ReadEndpoint} {
+			if e != nil {
+				transport.SetHostHeader(e, opts.HostHeader)
+			}
+		}
+	}
+
+	if e := opts.Endpoints.Write; e != nil && len(e.Headers) > 0 && opts.WriteEndpoint != nil {
+		transport.SetHeaders(opts.WriteEndpoint, e.Headers)
+	}
+
+	if e := opts.Endpoints.Read; e != nil && len(e.Headers) > 0 && opts.ReadEndpoint != nil {
+		transport.SetHeaders(opts.ReadEndpoint, e.Headers)
+	}
+
+	for _, e := range []struct {
+		endpoint *url.URL
+		spec     *options.EndpointSpec
+	}{
+		{endpoint: opts.WriteEndpoint, spec: opts.Endpoints.Write},
+		{endpoint: opts.ReadEndpoint, spec: opts.Endpoints.Read},
+	} {
+		if e.endpoint == nil || e.spec == nil || e.spec.ProxyURL == "" {
+			continue
+		}
+
+		u, err := url.Parse(e.spec.ProxyURL)
+		if err != nil {
+			return errors.Wrap(err, "parse proxy URL")
+		}
+
+		transport.SetEndpointProxy(e.endpoint, u)
+	}
+
+	if opts.DebugCapture.Dir != "" {
+		c, err := transport.NewCapture(l, opts.DebugCapture.Dir, opts.DebugCapture.MaxBodyBytes, opts.DebugCapture.MaxFiles)
+		if err != nil {
+			return errors.Wrap(err, "set up debug capture")
+		}
+
+		transport.SetCapture(c)
+	}
+
+	return nil
+}
+
+// InstrumentTransport sets the metrics of the transports and the policies counted in them, the retries, the rate
+// limits and the maximum size of responses. Like the configuration, they are shared by the process.
+func InstrumentTransport(opts options.Options, m instr.Metrics) {
+	transport.SetPhaseDuration(m.RequestPhaseDuration)
+	transport.SetCertMetrics(m.TLSCertReloads, m.TLSCertExpiry)
+	transport.SetRequestEncodings(m.RequestEncodings)
+	transport.SetRetry(&opts.Retry, m.RequestRetries)
+	transport.SetRateLimit(opts.RateLimit, m.RequestsThrottled)
+	transport.SetMaxResponseSize(opts.MaxResponseSize, m.ResponsesTooLarge)
+}
@@ -0,0 +1,227 @@
+package up
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/observatorium/up/pkg/instr"
+	"github.com/observatorium/up/pkg/logs"
+	"github.com/observatorium/up/pkg/metrics"
+	"github.com/observatorium/up/pkg/options"
+	"github.com/observatorium/up/pkg/tracing"
+	"github.com/observatorium/up/pkg/transport"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+const (
+	labelSuccess = "success"
+	labelError   = "error"
+)
+
+// Config provides the logs and queries, which can change while running, e.g. when their files are reloaded.
+type Config interface {
+	Queries() []options.Query
+	Logs() [][]string
+	Streams() []options.LogsStream
+	StructuredMetadata() map[string]string
+}
+
+// staticConfig is the configuration of the options, which never changes.
+type staticConfig struct {
+	opts options.Options
+}
+
+// NewStaticConfig returns the configuration of the options.
+func NewStaticConfig(opts options.Options) Config {
+	return staticConfig{opts: opts}
+}
+
+func (c staticConfig) Queries() []options.Query              { return c.opts.Queries }
+func (c staticConfig) Logs() [][]string                      { return c.opts.Logs }
+func (c staticConfig) Streams() []options.LogsStream         { return c.opts.LogsStreams }
+func (c staticConfig) StructuredMetadata() map[string]string { return c.opts.StructuredMetadata }
+
+// LogsState is the state of generated logs shared by the writer and the reader.
+type LogsState struct {
+	Template *logs.LineTemplate
+	Churn    *logs.Churn
+	Tenants  *logs.Tenants
+}
+
+// NewLogsState returns the state of the logs generated for the options.
+func NewLogsState(opts options.Options) (LogsState, error) {
+	var (
+		ls  LogsState
+		err error
+	)
+
+	if opts.LogsTemplate != "" {
+		ls.Template, err = logs.NewLineTemplate(opts.LogsTemplate, opts.LogsLineSize)
+		if err != nil {
+			return ls, err
+		}
+	}
+
+	if opts.LogsChurnInterval > 0 {
+		ls.Churn = logs.NewChurn(opts.LogsChurnInterval)
+	}
+
+	if len(opts.LogsTenants) > 0 {
+		ls.Tenants = logs.NewTenants(opts.LogsTenants)
+	}
+
+	return ls, nil
+}
+
+// Write makes a single write request of generated metrics or logs.
+func Write(ctx context.Context, l log.Logger, m instr.Metrics, opts options.Options, cfg Config,
+	ls LogsState) (int, error) {
+	ctx, span := tracing.Start(ctx, "write")
+	span.SetAttributes("endpoint_type", string(opts.EndpointType))
+
+	httpCode, err := write(ctx, l, m, opts, cfg, ls)
+	span.SetAttributes("http.status_code", httpCode)
+	span.End(err)
+
+	return httpCode, err
+}
+
+func write(ctx context.Context, l log.Logger, m instr.Metrics, opts options.Options, cfg Config,
+	ls LogsState) (int, error) {
+	switch opts.EndpointType {
+	case options.MetricsEndpointType:
+		wreq := metrics.Generate(opts.Labels, opts.Exemplars)
+		if opts.Metadata {
+			wreq.Metadata = metrics.GenerateMetadata(opts.Name)
+		}
+
+		return metrics.Write(ctx, opts.WriteEndpoint, opts.Token, wreq, l, opts.TLS, opts.TenantHeader, opts.Tenant)
+	case options.LogsEndpointType:
+		now := time.Now()
+		labels := ls.Churn.Labels(opts.Labels, now)
+		wreq := logs.Generate(labels, cfg.Logs(), cfg.StructuredMetadata())
+
+		if streams := cfg.Streams(); len(streams) > 0 {
+			wreq = logs.GenerateStreams(labels, streams, cfg.StructuredMetadata())
+		}
+
+		if ls.Template != nil {
+			var err error
+
+			wreq, err = logs.GenerateVolume(labels, ls.Template, now, opts.LogsStreamsPerPush, opts.LogsLinesPerPush,
+				cfg.StructuredMetadata())
+			if err != nil {
+				return 0, errors.Wrap(err, "generating logs")
+			}
+		}
+
+		tenant := ls.Tenants.Next(opts.Tenant)
+
+		httpCode, err := logs.Write(ctx, opts.WriteEndpoint, opts.Token, wreq, m, l, opts.TLS, opts.LogsEncoding, opts.LogsCompression,
+			opts.TenantHeader, tenant)
+		if ls.Tenants != nil {
+			if err != nil {
+				m.LogsTenantWrites.WithLabelValues(tenant, labelError).Inc()
+
+				return httpCode, errors.Wrapf(err, "tenant %s", tenant)
+			}
+
+			m.LogsTenantWrites.WithLabelValues(tenant, labelSuccess).Inc()
+			ls.Tenants.Pushed(tenant)
+		}
+
+		if err != nil {
+			return httpCode, err
+		}
+
+		m.LogsActiveStreams.Set(float64(len(wreq.Streams)))
+
+		if ls.Churn.Pushed(labels) {
+			m.LogsStreamsCreated.Add(float64(len(wreq.Streams)))
+		}
+
+		return httpCode, nil
+	}
+
+	return 0, fmt.Errorf("invalid endpoint-type: %v", opts.EndpointType)
+}
+
+// Read reads back the data written by Write once, using the range readers in the range read mode.
+func Read(ctx context.Context, l log.Logger, m instr.Metrics, opts options.Options, cfg Config, ls LogsState,
+	rr *metrics.RangeReader, lrr *logs.RangeReader, skew *transport.ClockSkew) (int, error) {
+	ctx, span := tracing.Start(ctx, "read")
+	span.SetAttributes("endpoint_type", string(opts.EndpointType), "read_mode", string(opts.ReadMode))
+
+	httpCode, err := read(ctx, l, m, opts, cfg, ls, rr, lrr, skew)
+	span.SetAttributes("http.status_code", httpCode)
+	span.End(err)
+
+	return httpCode, err
+}
+
+func read(ctx context.Context, l log.Logger, m instr.Metrics, opts options.Options, cfg Config, ls LogsState,
+	rr *metrics.RangeReader, lrr *logs.RangeReader, skew *transport.ClockSkew) (int, error) {
+	switch opts.EndpointType {
+	case options.MetricsEndpointType:
+		if opts.ReadMode == options.RangeReadMode {
+			return rr.Read(ctx, opts.ReadEndpoint, opts.Token, opts.Labels, opts.ReadQuery, -1*opts.InitialQueryDelay, opts.Latency, m, l,
+				opts.TLS, opts.TenantHeader, opts.Tenant)
+		}
+
+		return metrics.Read(ctx, opts.ReadEndpoint, opts.Token, opts.Labels, opts.ReadQuery, -1*opts.InitialQueryDelay, opts.Latency, m, l,
+			opts.TLS, opts.TenantHeader, opts.Tenant, opts.FailOnWarnings, skew, opts.WriteEndpoint == nil)
+	case options.LogsEndpointType:
+		if opts.ReadMode == options.RangeReadMode {
+			return lrr.Read(ctx, opts.ReadEndpoint, opts.Token, opts.Labels, opts.ReadQuery, -1*opts.InitialQueryDelay, m, l,
+				opts.TLS, opts.TenantHeader, opts.Tenant, cfg.StructuredMetadata())
+		}
+
+		labels := ls.Churn.ReadLabels(opts.Labels)
+
+		streams := logs.VolumeStreams(labels, opts.LogsStreamsPerPush)
+		if s := cfg.Streams(); len(s) > 0 {
+			streams = make([][]prompb.Label, len(s))
+			for i := range s {
+				streams[i] = logs.StreamLabels(labels, s[i].Labels)
+			}
+		}
+
+		return logs.Read(ctx, opts.ReadEndpoint, opts.Token, labels, opts.ReadQuery, -1*opts.InitialQueryDelay, opts.Latency, m, l,
+			opts.TLS, opts.TenantHeader, ls.Tenants.ReadTenant(opts.Tenant), streams, cfg.StructuredMetadata())
+	}
+
+	return 0, fmt.Errorf("invalid endpoint-type: %v", opts.EndpointType)
+}
+
+// Query executes a single custom query against the read endpoint.
+func Query(ctx context.Context, l log.Logger, q options.Query, opts options.Options) (int, promapiv1.Warnings, error) {
+	ctx, span := tracing.Start(ctx, "query")
+	span.SetAttributes("endpoint_type", string(opts.EndpointType), "query.name", q.GetName(), "query.type", q.GetType())
+
+	httpCode, warn, err := query(ctx, l, q, opts)
+	span.SetAttributes("http.status_code", httpCode, "warnings", len(warn))
+	span.End(err)
+
+	return httpCode, warn, err
+}
+
+func query(ctx context.Context, l log.Logger, q options.Query, opts options.Options) (int, promapiv1.Warnings, error) {
+	var (
+		token  = q.GetCommon().EffectiveToken(opts.Token)
+		tenant = q.GetCommon().EffectiveTenant(opts.Tenant)
+	)
+
+	switch opts.EndpointType {
+	case options.MetricsEndpointType:
+		return metrics.Query(ctx, l, opts.ReadEndpoint, token, q, opts.TLS, opts.DefaultStep, opts.TenantHeader, tenant)
+	case options.LogsEndpointType:
+		return logs.Query(ctx, l, opts.ReadEndpoint, token, q, opts.TLS, opts.DefaultStep, opts.TenantHeader, tenant)
+	}
+
+	return 0, nil, fmt.Errorf("invalid endpoint-type: %v", opts.EndpointType)
+}